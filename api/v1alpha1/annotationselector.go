@@ -0,0 +1,12 @@
+package v1alpha1
+
+// Matches reports whether annotations include every key and value of
+// MatchAnnotations.
+func (s *AnnotationSelector) Matches(annotations map[string]string) bool {
+	for k, v := range s.MatchAnnotations {
+		if got, ok := annotations[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,12 @@
+package v1alpha1
+
+import "slices"
+
+// SendsAt reports whether an event is sent at stage, Deleted being the
+// only one selected by default.
+func (c *CloudEventConfig) SendsAt(stage CloudEventStage) bool {
+	if c == nil || len(c.Events) == 0 {
+		return stage == CloudEventStageDeleted
+	}
+	return slices.Contains(c.Events, stage)
+}
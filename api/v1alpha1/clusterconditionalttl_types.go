@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterConditionalTTLSpec represents the configuration for a
+// ClusterConditionalTTL object. It mirrors ConditionalTTLSpec, its targets
+// being resolved in every namespace selected by NamespaceSelector.
+type ClusterConditionalTTLSpec struct {
+	// Duration the controller should wait relative to the ClusterConditionalTTL's
+	// CreationTime before starting deletion. When unset, conditions are evaluated
+	// right away.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Specifies how the controller should retry the evaluation of conditions.
+	// When omitted, the controller's default retry period is used and missing
+	// targets are resolved again every 30s.
+	// +optional
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// NamespaceSelector selects the namespaces targets are resolved in. It
+	// must set matchLabels or matchExpressions. Namespaces being deleted,
+	// `default`, `kube-*` and the controller's own namespace are never
+	// selected.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+
+	// List of targets the ClusterConditionalTTL is interested in deleting or
+	// that are needed for evaluating the conditions, resolved in each selected
+	// namespace. Targets must be of namespaced kinds.
+	Targets []Target `json:"targets,omitempty"`
+
+	// Optional list of [Common Expression Language](https://github.com/google/cel-spec) conditions
+	// which should all evaluate to true before deletion takes place, unless
+	// a different ConditionPolicy is set. They may reference `time` and
+	// `namespaces`, a map from the name of each selected namespace to an
+	// object holding the `namespace` itself and its `targets` included when
+	// evaluating, keyed by target name.
+	// +optional
+	Conditions []string `json:"conditions,omitempty"`
+
+	// Optional list of conditions with a name, evaluated after and like
+	// Conditions, which are named after their index instead.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	NamedConditions []NamedCondition `json:"namedConditions,omitempty"`
+
+	// Optional: Declares how many conditions must evaluate to true before
+	// deletion takes place. Defaults to requiring all of them.
+	// +optional
+	ConditionPolicy *ConditionPolicy `json:"conditionPolicy,omitempty"`
+
+	// Optional: Declares whether target groups which can't be deleted due to
+	// a permanent error, such as an invalid label selector or missing
+	// permissions, block the deletion of the ClusterConditionalTTL or are
+	// skipped. Defaults to Continue.
+	// +kubebuilder:default=Continue
+	// +optional
+	FinalizerFailurePolicy FinalizerFailurePolicy `json:"finalizerFailurePolicy,omitempty"`
+
+	// Optional: Duration to wait after the target groups of a DeletionOrder
+	// are gone from every namespace before deleting those of the next one,
+	// e.g. to let other controllers react. Defaults to not waiting.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	DeletionDelay *metav1.Duration `json:"deletionDelay,omitempty"`
+
+	// Optional: Restricts the beginning of deletion to a recurring time
+	// range, e.g. off-hours. When conditions are met outside of it,
+	// deletion waits for the window to open. Defaults to no restriction.
+	// +optional
+	DeletionWindow *DeletionWindow `json:"deletionWindow,omitempty"`
+
+	// Optional: Allows targets to reference ConditionalTTLs, which would
+	// otherwise be rejected to prevent accidental cascades.
+	// +optional
+	AllowConditionalTTLTargets bool `json:"allowConditionalTTLTargets,omitempty"`
+
+	// Optional: Deletes the selected namespaces themselves once all their
+	// targets are gone. Defaults to false.
+	// +optional
+	DeleteNamespaces bool `json:"deleteNamespaces,omitempty"`
+}
+
+// ClusterConditionalTTLStatus defines the observed state of
+// ClusterConditionalTTL.
+type ClusterConditionalTTLStatus struct {
+	// Namespaces lists the namespaces selected on the last evaluation. Once
+	// the conditions are met, the targets of these namespaces are deleted.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExpiresAt is the time when the TTL passes, after which the
+	// conditions are evaluated.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpiredAt is the time when the controller first observed the TTL
+	// had passed and started evaluating the conditions.
+	// +optional
+	ExpiredAt *metav1.Time `json:"expiredAt,omitempty"`
+
+	// EvaluationTime is the time when the conditions for deletion were met.
+	// +optional
+	EvaluationTime *metav1.Time `json:"evaluationTime,omitempty"`
+
+	// ConditionResults holds the outcome of each condition on the last
+	// successful evaluation.
+	// +optional
+	ConditionResults []ConditionResult `json:"conditionResults,omitempty"`
+
+	// DeletionProgress tracks the deletion of ordered target groups when
+	// `spec.deletionDelay` is set.
+	// +optional
+	DeletionProgress *DeletionProgress `json:"deletionProgress,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=ccttl
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="TTL",type=string,format=date-time,JSONPath=`.spec.ttl`
+// +kubebuilder:printcolumn:name="Expires At",type=string,format=date-time,JSONPath=`.status.expiresAt`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+
+// ClusterConditionalTTL is the cluster-scoped variant of ConditionalTTL,
+// declaring a set of conditions under which a set of resources should be
+// deleted across all the namespaces matching a selector.
+type ClusterConditionalTTL struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterConditionalTTLSpec   `json:"spec,omitempty"`
+	Status ClusterConditionalTTLStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterConditionalTTLList contains a list of ClusterConditionalTTL.
+type ClusterConditionalTTLList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterConditionalTTL `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterConditionalTTL{}, &ClusterConditionalTTLList{})
+}
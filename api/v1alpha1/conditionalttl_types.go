@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // RetryConfig defines how the controller should retry evaluating the
@@ -34,11 +35,207 @@ type RetryConfig struct {
 // HelmConfig specifies a Helm release by its name and whether
 // the release should be deleted.
 type HelmConfig struct {
-	// The Helm Release name.
+	// The Helm Release name. Required unless FromTarget is set.
 	Release string `json:"release,omitempty"`
 
+	// Namespace of the Helm release. Defaults to the ConditionalTTL's
+	// namespace. Releases in other namespaces are only uninstalled when
+	// the controller is started with --allow-cross-namespace-helm and the
+	// namespace is listed in `allowedNamespaces`, if set, and its
+	// ServiceAccount must be allowed to manage the release's Secrets and
+	// resources in that namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// FromTarget names a target whose `meta.helm.sh/release-name` and
+	// `meta.helm.sh/release-namespace` annotations, as stored when deletion
+	// began, identify the release instead of Release and Namespace. For
+	// list targets, the first annotated item is used. The release is
+	// skipped with a warning event when the annotations are missing.
+	// +optional
+	FromTarget string `json:"fromTarget,omitempty"`
+
 	// Delete specifies whether the Helm release should be deleted.
 	Delete bool `json:"delete,omitempty"`
+
+	// DryRun makes the finalizer only report the resources uninstalling
+	// the release would remove, in an event and in status.helmDryRuns,
+	// without uninstalling it. Requires Delete.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Timeout is how long uninstalling the release may take before it is
+	// retried, including waiting for its hooks. Defaults to the
+	// controller's --helm-timeout.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// IncludeWhenEvaluating exposes the release to the conditions as the
+	// `helmRelease` variable, holding its name, namespace, version,
+	// status, firstDeployed and lastDeployed timestamps and chart's name,
+	// version and appVersion. Only supported on spec.helm, whose release
+	// must be set.
+	// +optional
+	IncludeWhenEvaluating bool `json:"includeWhenEvaluating,omitempty"`
+
+	// OptionalWhenEvaluating makes `helmRelease` null when the release
+	// isn't found instead of waiting for it to be installed before
+	// evaluating the conditions.
+	// +optional
+	OptionalWhenEvaluating bool `json:"optionalWhenEvaluating,omitempty"`
+
+	// CompleteWhenNotFound considers the release's resources deleted when
+	// the release isn't found while evaluating, e.g. as it was uninstalled
+	// out of band, and proceeds with deletion without evaluating the
+	// conditions. Requires IncludeWhenEvaluating and can't be combined
+	// with OptionalWhenEvaluating.
+	// +optional
+	CompleteWhenNotFound bool `json:"completeWhenNotFound,omitempty"`
+
+	// FailurePolicy is one of Block or Continue. Continue lets deletion
+	// proceed, with a warning event, when uninstalling the release fails.
+	// Releases which aren't found or were already uninstalled never block
+	// deletion, and timeouts are always retried. Defaults to Block.
+	// +kubebuilder:default=Block
+	// +optional
+	FailurePolicy HelmFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// HelmFailurePolicy declares whether failing to uninstall a Helm release
+// blocks deletion.
+// +kubebuilder:validation:Enum=Block;Continue
+type HelmFailurePolicy string
+
+const (
+	// HelmFailurePolicyBlock retries the uninstall until it succeeds.
+	HelmFailurePolicyBlock HelmFailurePolicy = "Block"
+	// HelmFailurePolicyContinue gives up on the release after a failed
+	// uninstall.
+	HelmFailurePolicyContinue HelmFailurePolicy = "Continue"
+)
+
+// NamedCondition is a [Common Expression Language](https://github.com/google/cel-spec)
+// condition with a name used to report its outcome and errors.
+type NamedCondition struct {
+	// Name of the condition. It must start with a letter, which keeps it
+	// apart from the conditions named after their index.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z][a-zA-Z0-9_-]*$`
+	Name string `json:"name"`
+
+	// Expression is the CEL expression of the condition.
+	Expression string `json:"expression"`
+}
+
+// ConditionPolicyType declares how many conditions must be met.
+// +kubebuilder:validation:Enum=All;Any;AtLeast
+type ConditionPolicyType string
+
+const (
+	// ConditionPolicyAll requires every condition to be met.
+	ConditionPolicyAll ConditionPolicyType = "All"
+	// ConditionPolicyAny requires at least one condition to be met.
+	ConditionPolicyAny ConditionPolicyType = "Any"
+	// ConditionPolicyAtLeast requires at least Count conditions to be met.
+	ConditionPolicyAtLeast ConditionPolicyType = "AtLeast"
+)
+
+// ConditionPolicy declares how many of the conditions must evaluate to true
+// before deletion takes place.
+type ConditionPolicy struct {
+	// Type is one of All, Any or AtLeast.
+	// +kubebuilder:default=All
+	Type ConditionPolicyType `json:"type"`
+
+	// Count is the minimum number of conditions which must be met.
+	// Required when Type is AtLeast, ignored otherwise.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+}
+
+// FinalizerFailurePolicy declares how target groups which can't be deleted
+// due to a permanent error are handled during deletion.
+// +kubebuilder:validation:Enum=Block;Continue
+type FinalizerFailurePolicy string
+
+const (
+	// FinalizerFailurePolicyBlock keeps retrying until the error is fixed.
+	FinalizerFailurePolicyBlock FinalizerFailurePolicy = "Block"
+	// FinalizerFailurePolicyContinue skips the target group.
+	FinalizerFailurePolicyContinue FinalizerFailurePolicy = "Continue"
+)
+
+// OrphanPolicy declares how a ConditionalTTL referencing a namespace which
+// is gone or being deleted is handled.
+// +kubebuilder:validation:Enum=Fail;Complete
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyFail keeps evaluating the conditions, failing wherever
+	// the namespace is needed.
+	OrphanPolicyFail OrphanPolicy = "Fail"
+	// OrphanPolicyComplete considers what lived in the namespace deleted
+	// and proceeds with deletion without evaluating the conditions once
+	// every referenced namespace is gone.
+	OrphanPolicyComplete OrphanPolicy = "Complete"
+)
+
+// StateInclusion declares how much of a target group's state is stored on
+// the status and sent on the deletion cloud event.
+// +kubebuilder:validation:Enum=Full;MetadataOnly;None
+type StateInclusion string
+
+const (
+	// StateInclusionFull stores the objects as read.
+	StateInclusionFull StateInclusion = "Full"
+	// StateInclusionMetadataOnly stores the objects' apiVersion, kind and
+	// metadata.
+	StateInclusionMetadataOnly StateInclusion = "MetadataOnly"
+	// StateInclusionNone stores no state.
+	StateInclusionNone StateInclusion = "None"
+)
+
+// DeletionMode declares whether the target finalizer waits for a target
+// group's objects to be gone.
+// +kubebuilder:validation:Enum=Verify;FireAndForget
+type DeletionMode string
+
+const (
+	// DeletionModeVerify waits until the objects are gone before the
+	// finalizer completes, checking again after deleting them.
+	DeletionModeVerify DeletionMode = "Verify"
+	// DeletionModeFireAndForget deletes the objects in the background and
+	// considers them gone right away, trusting the garbage collector.
+	DeletionModeFireAndForget DeletionMode = "FireAndForget"
+)
+
+// Weekday is a day of the week.
+// +kubebuilder:validation:Enum=Sunday;Monday;Tuesday;Wednesday;Thursday;Friday;Saturday
+type Weekday string
+
+// DeletionWindow declares a recurring time range during which deletion
+// may begin.
+type DeletionWindow struct {
+	// Days of the week on which the window opens. Defaults to every day.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// Start is the time of day the window opens, formatted as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// End is the time of day the window closes, formatted as HH:MM. An End
+	// before Start closes the window on the following day and an End equal
+	// to Start keeps it open for a whole day.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+
+	// TimeZone is the IANA name of the time zone of Start and End, such as
+	// America/Sao_Paulo. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 // TargetReference declares how a target group should be looked up.
@@ -47,9 +244,12 @@ type HelmConfig struct {
 // a collection of resources of the same GroupVersionKind. In contrast
 // with single targets, an empty collection is a valid value when evaluating
 // the set of conditions.
+// The apiVersion may be omitted, in which case the kind is resolved to the
+// preferred version of the only group serving it.
+// +kubebuilder:validation:XValidation:rule="has(self.kind) && size(self.kind) > 0",message="kind is required"
 type TargetReference struct {
-	// TODO: apiVersion and kind of TypeMeta are optional, can they be made
-	// required without duplicating it?
+	// TODO: kind of TypeMeta is optional, can it be made required without
+	// duplicating it?
 	metav1.TypeMeta `json:",inline"`
 
 	// Name matches a single object. If name is specified, LabelSelector
@@ -61,6 +261,21 @@ type TargetReference struct {
 	// group. If Name is not empty, LabelSelector is ignored.
 	// +optional
 	LabelSelector *metav1.LabelSelector `json:"labelSelector"`
+
+	// AnnotationSelector further restricts the objects included in the
+	// target group to those with matching annotations. Since the API server
+	// can't select objects by their annotations, every object matching
+	// LabelSelector, or every object of the kind when it is nil, is listed
+	// and then filtered. If Name is not empty, AnnotationSelector is ignored.
+	// +optional
+	AnnotationSelector *AnnotationSelector `json:"annotationSelector,omitempty"`
+}
+
+// AnnotationSelector matches objects by their annotations.
+type AnnotationSelector struct {
+	// MatchAnnotations requires each of its keys to be annotated on the
+	// object with the given value.
+	MatchAnnotations map[string]string `json:"matchAnnotations"`
 }
 
 // Target declares how to find one or more resources related to the ConditionalTTL,
@@ -69,21 +284,334 @@ type TargetReference struct {
 type Target struct {
 	// Name identifies this target group and is used to refer to its state
 	// when evaluating the set of conditions.
-	// The name `time` is invalid and is included by default during evaluation.
-	// +kubebuilder:validation:Pattern=`^[^t].*|t($|[^i]).*|ti($|[^m]).*|tim($|[^e]).*|time.+`
+	// The name `time` is reserved and is included by default during evaluation,
+	// as are `history` when historyLimit is set and `previous` when
+	// keepPreviousState is set.
 	Name string `json:"name"`
 
 	// Delete indicates whether this target group should be deleted
-	// when the ConditionalTTL is triggered.
+	// when the ConditionalTTL is triggered. When no target group nor Helm
+	// release is deleted, the ConditionalTTL only deletes itself, still
+	// sending its Cloud Event.
 	Delete bool `json:"delete"`
 
 	// IncludeWhenEvaluating indicates whether this target group should be
-	// included in the CEL evaluation context.
-	IncludeWhenEvaluating bool `json:"includeWhenEvaluating"`
+	// included in the CEL evaluation context. When unset on a
+	// ConditionalTTL, it defaults to whether its conditions reference the
+	// target group.
+	// +optional
+	IncludeWhenEvaluating *bool `json:"includeWhenEvaluating,omitempty"`
 
 	// Reference declares how to find either a single object, using its name,
 	// or a collection, using a LabelSelector.
 	Reference TargetReference `json:"reference"`
+
+	// DeletionOrder declares when this target group is deleted relative to
+	// the others, lower values first. Deletion only proceeds to the next
+	// order once every target group before it is gone. Target groups with
+	// the same order are deleted together, in declaration order. Defaults
+	// to 0.
+	// +optional
+	DeletionOrder *int32 `json:"deletionOrder,omitempty"`
+
+	// MetadataOnly indicates whether only the metadata of this target
+	// group's objects should be read, reducing the load on the API server
+	// and the controller's memory usage for large lists. The objects' state
+	// then only holds their apiVersion, kind and metadata.
+	// +optional
+	MetadataOnly bool `json:"metadataOnly,omitempty"`
+
+	// ForceRemoveFinalizers indicates whether the finalizers of this target
+	// group's objects should be removed when they are still present
+	// ForceRemoveFinalizersAfter their deletion. This is dangerous as it
+	// skips the cleanup their finalizers would do and is only honored when
+	// the controller is started with --allow-force-finalizer-removal.
+	// +optional
+	ForceRemoveFinalizers bool `json:"forceRemoveFinalizers,omitempty"`
+
+	// ForceRemoveFinalizersAfter is how long to wait for objects being
+	// deleted before removing their finalizers. Defaults to 5 minutes.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	ForceRemoveFinalizersAfter *metav1.Duration `json:"forceRemoveFinalizersAfter,omitempty"`
+
+	// GracePeriodSeconds is the duration in seconds the objects of this
+	// target group are given to terminate when deleted. Zero deletes them
+	// immediately, like `kubectl delete --force --grace-period=0`. Objects
+	// already being deleted with a longer grace period are deleted again
+	// to shorten it. Defaults to each object's own grace period.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// StateInclusion is one of Full, MetadataOnly or None and declares how
+	// much of this target group's state is stored on `status.targets` and
+	// `status.previousTargets` and therefore sent on the deletion cloud
+	// event, e.g. to keep Pods' environment variables from being persisted.
+	// Conditions are always evaluated on the full state, but the group
+	// isn't available on `previous` when None. Defaults to Full.
+	// +kubebuilder:default=Full
+	// +optional
+	StateInclusion StateInclusion `json:"stateInclusion,omitempty"`
+
+	// OptionalUntilFound indicates whether the object referenced by Name
+	// is expected to be created after the ConditionalTTL. Until it is
+	// found, the conditions aren't evaluated and evaluation is retried on
+	// the retry period instead of reporting a TargetResolveError. Ignored
+	// for targets using a selector.
+	// +optional
+	OptionalUntilFound bool `json:"optionalUntilFound,omitempty"`
+
+	// DeletionMode is one of Verify or FireAndForget. FireAndForget
+	// deletes this target group's objects with background propagation and
+	// doesn't check whether they are gone, so that the ConditionalTTL is
+	// torn down faster at the cost of confirming their deletion. Later
+	// deletion orders then don't wait for them, and ForceRemoveFinalizers
+	// can't be used. Defaults to Verify.
+	// +kubebuilder:default=Verify
+	// +optional
+	DeletionMode DeletionMode `json:"deletionMode,omitempty"`
+}
+
+// KeySelector selects a key of a ConfigMap or Secret.
+type KeySelector struct {
+	// Name of the ConfigMap or Secret in the ConditionalTTL's namespace.
+	Name string `json:"name"`
+
+	// Key to select.
+	Key string `json:"key"`
+
+	// Optional specifies whether the ConfigMap or Secret and its key
+	// may be missing.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ContextValue declares a ConfigMap or Secret key whose value is included
+// as a string variable when evaluating the set of conditions.
+type ContextValue struct {
+	// Name of the variable holding the value when evaluating the set of
+	// conditions. It must not clash with the name of a target.
+	Name string `json:"name"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *KeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret. The value is never logged
+	// nor included in the ConditionalTTL's status.
+	// +optional
+	SecretKeyRef *KeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// CloudEventConfig overrides the attributes of the CloudEvent sent to
+// the CloudEventSink after deletion takes place.
+type CloudEventConfig struct {
+	// Type of the Deleted event. Defaults to `conditionalTTL.deleted`.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Source of the event. Defaults to `cleaner.vtex.io/finalizer`.
+	// +optional
+	Source string `json:"source,omitempty"`
+
+	// Subject of the event. Defaults to `<namespace>/<name>` of the
+	// ConditionalTTL.
+	// +optional
+	Subject string `json:"subject,omitempty"`
+
+	// Extensions are extension attributes set on the event. Their names
+	// must consist of at most 20 lower-case letters and digits and can't
+	// be those of the event's other attributes.
+	// +optional
+	Extensions map[string]string `json:"extensions,omitempty"`
+
+	// Events selects the lifecycle stages an event is sent at, of types
+	// `conditionalTTL.expired`, `conditionalTTL.conditionsMet`,
+	// `conditionalTTL.deletionFailed` and `conditionalTTL.deleted`. Only
+	// the Deleted event holds deletion back until delivered, subject to
+	// DeliveryPolicy. The others are delivered in the background, in the
+	// order of their stages, and may therefore arrive after it.
+	// Defaults to Deleted.
+	// +listType=set
+	// +optional
+	Events []CloudEventStage `json:"events,omitempty"`
+
+	// Retry sends the Deleted event again, with exponential backoff, when
+	// the sink is unavailable or fails with a transient error. Without
+	// it, the event is sent once on each attempt to delete the
+	// ConditionalTTL.
+	// +optional
+	Retry *CloudEventRetry `json:"retry,omitempty"`
+
+	// DeliveryPolicy is one of Block or BestEffort. BestEffort lets
+	// deletion proceed, with a warning event, once delivering the Deleted
+	// event failed despite its retries. Defaults to Block.
+	// +kubebuilder:default=Block
+	// +optional
+	DeliveryPolicy CloudEventDeliveryPolicy `json:"deliveryPolicy,omitempty"`
+
+	// HeadersFrom sends the keys of a Secret as HTTP headers along with
+	// the events, e.g. an Authorization header the sink requires. The
+	// Secret is read whenever an event is sent, so rotated values are
+	// picked up.
+	// +optional
+	HeadersFrom *SecretHeaders `json:"headersFrom,omitempty"`
+
+	// TLS configures how the certificate of an HTTPS sink is verified and
+	// the certificate presented to it. Without it, the sink's certificate
+	// is verified against the system's CAs.
+	// +optional
+	TLS *CloudEventTLS `json:"tls,omitempty"`
+
+	// Mode is the content mode events are sent in, one of Binary, with
+	// the attributes as `ce-*` headers and the data as the body, or
+	// Structured, with the whole event as an `application/cloudevents+json`
+	// body. Defaults to Binary.
+	// +kubebuilder:default=Binary
+	// +optional
+	Mode CloudEventMode `json:"mode,omitempty"`
+}
+
+// CloudEventTLS configures the TLS connections to the CloudEvent sink.
+type CloudEventTLS struct {
+	// CA references the bundle of PEM encoded certificates the sink's
+	// certificate is verified against, instead of the system's CAs, e.g.
+	// those of a private CA.
+	// +optional
+	CA *CABundle `json:"ca,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the sink's
+	// certificate. It is discouraged, being meant for development only.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+	// ClientCertificateSecret is the name of a Secret of type
+	// kubernetes.io/tls, in the ConditionalTTL's namespace, whose
+	// `tls.crt` and `tls.key` are presented to the sink.
+	// +optional
+	ClientCertificateSecret string `json:"clientCertificateSecret,omitempty"`
+}
+
+// CABundle references the key of either a ConfigMap or a Secret, in the
+// ConditionalTTL's namespace, holding PEM encoded certificates.
+type CABundle struct {
+	// ConfigMap holding the bundle.
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Secret holding the bundle.
+	// +optional
+	Secret string `json:"secret,omitempty"`
+
+	// Key holding the bundle. Defaults to `ca.crt`.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// SecretHeaders references a Secret holding the values of HTTP headers.
+type SecretHeaders struct {
+	// Name of the Secret in the ConditionalTTL's namespace.
+	Name string `json:"name"`
+
+	// Headers maps the names of the headers to the keys of the Secret
+	// holding their values. Defaults to sending each key of the Secret
+	// as the header of the same name.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// CloudEventRetry configures how delivering a CloudEvent is retried.
+type CloudEventRetry struct {
+	// Attempts is how many times the event is sent before giving up,
+	// including the first one.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=5
+	Attempts int32 `json:"attempts"`
+
+	// Backoff is how long to wait after the first failed attempt, doubled
+	// after each one. Defaults to 1s and may be at most 30s. Attempts left
+	// once the controller's --cloud-event-timeout is exceeded are given up.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +kubebuilder:validation:XValidation:rule="duration(self) <= duration('30s')",message="backoff must be at most 30s"
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+}
+
+// CloudEventDeliveryPolicy declares whether failing to deliver the Deleted
+// CloudEvent blocks deletion.
+// +kubebuilder:validation:Enum=Block;BestEffort
+type CloudEventDeliveryPolicy string
+
+const (
+	// CloudEventDeliveryPolicyBlock retries delivering the event until it
+	// succeeds.
+	CloudEventDeliveryPolicyBlock CloudEventDeliveryPolicy = "Block"
+	// CloudEventDeliveryPolicyBestEffort abandons the event once its
+	// retries are exhausted.
+	CloudEventDeliveryPolicyBestEffort CloudEventDeliveryPolicy = "BestEffort"
+)
+
+// CloudEventMode is the content mode of CloudEvents sent over HTTP.
+// +kubebuilder:validation:Enum=Binary;Structured
+type CloudEventMode string
+
+const (
+	// CloudEventModeBinary sends the attributes of events as headers.
+	CloudEventModeBinary CloudEventMode = "Binary"
+	// CloudEventModeStructured sends events as JSON documents.
+	CloudEventModeStructured CloudEventMode = "Structured"
+)
+
+// CloudEventStage is a lifecycle stage of a ConditionalTTL at which a
+// CloudEvent is sent.
+// +kubebuilder:validation:Enum=Expired;ConditionsMet;DeletionFailed;Deleted
+type CloudEventStage string
+
+const (
+	// CloudEventStageExpired is when the TTL expires and conditions start
+	// being evaluated.
+	CloudEventStageExpired CloudEventStage = "Expired"
+	// CloudEventStageConditionsMet is when deletion begins.
+	CloudEventStageConditionsMet CloudEventStage = "ConditionsMet"
+	// CloudEventStageDeletionFailed is when a finalizer starts failing.
+	CloudEventStageDeletionFailed CloudEventStage = "DeletionFailed"
+	// CloudEventStageDeleted is after deletion took place.
+	CloudEventStageDeleted CloudEventStage = "Deleted"
+)
+
+// SinkReference references the object a CloudEvent is delivered to: a
+// Service or an Addressable, e.g. a Knative Broker, whose
+// `status.address.url` is its address.
+type SinkReference struct {
+	// APIVersion of the referenced object. Defaults to `v1`.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the referenced object. Defaults to `Service`. Either a
+	// Service or a Knative Broker, Channel or Service, which the controller
+	// is allowed to read.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced object.
+	Name string `json:"name"`
+
+	// Namespace of the referenced object. Defaults to the
+	// ConditionalTTL's namespace, other namespaces must be allowed by
+	// AllowedNamespaces.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port of the Service. Defaults to the Service's only port.
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+
+	// Path appended to the address of the referenced object.
+	// +optional
+	Path string `json:"path,omitempty"`
 }
 
 // ConditionalTTLSpec represents the configuration for a ConditionalTTL object.
@@ -91,13 +619,17 @@ type Target struct {
 // deletion begins and actions to be taken during it.
 type ConditionalTTLSpec struct {
 	// Duration the controller should wait relative to the ConditionalTTL's CreationTime
-	// before starting deletion.
+	// before starting deletion. When unset, conditions are evaluated right away.
+	// Annotating the ConditionalTTL with `cleaner.vtex.io/cleanup-now: "true"`
+	// skips it.
 	// +kubebuilder:validation:Type=string
 	// +kubebuilder:validation:Format=duration
-	TTL *metav1.Duration `json:"ttl"`
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
 
 	// Specifies how the controller should retry the evaluation of conditions.
-	// This field is required when the list of conditions is not empty.
+	// When omitted, the controller's default retry period is used and missing
+	// targets are resolved again every 30s.
 	// +optional
 	Retry *RetryConfig `json:"retry,omitempty"`
 
@@ -106,25 +638,154 @@ type ConditionalTTLSpec struct {
 	// +optional
 	Helm *HelmConfig `json:"helm,omitempty"`
 
+	// Optional: Like Helm, for environments composed of several releases.
+	// Both may be set, Helm being handled first.
+	// +optional
+	HelmReleases []HelmConfig `json:"helmReleases,omitempty"`
+
 	// List of targets the ConditionalTTL is interested in deleting or that are needed
 	// for evaluating the conditions under which deletion should take place.
 	Targets []Target `json:"targets,omitempty"`
 
+	// Optional list of ConfigMap or Secret keys to be included when evaluating
+	// the set of conditions. Missing optional keys evaluate to an empty string.
+	// +optional
+	ExtraContext []ContextValue `json:"extraContext,omitempty"`
+
 	// Optional list of [Common Expression Language](https://github.com/google/cel-spec) conditions
-	// which should all evaluate to true before deletion takes place.
+	// which should all evaluate to true before deletion takes place, unless
+	// a different ConditionPolicy is set. They may only reference targets
+	// included when evaluating, extra context values, `time`, `history`
+	// and `previous`.
 	// +optional
 	Conditions []string `json:"conditions,omitempty"`
 
+	// Optional list of conditions with a name, evaluated after and like
+	// Conditions, which are named after their index instead.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	NamedConditions []NamedCondition `json:"namedConditions,omitempty"`
+
+	// Optional: Declares how many conditions must evaluate to true before
+	// deletion takes place. Defaults to requiring all of them.
+	// +optional
+	ConditionPolicy *ConditionPolicy `json:"conditionPolicy,omitempty"`
+
+	// Optional: Number of previous evaluations whose target summaries are
+	// kept on `status.history` and exposed as `history` when evaluating
+	// the conditions. Defaults to 0, keeping no history.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	HistoryLimit int32 `json:"historyLimit,omitempty"`
+
+	// Optional: Keeps the state of the targets included when evaluating
+	// the conditions on `status.previousTargets` and exposes it as
+	// `previous` on the next evaluation, e.g. to require a state to be
+	// observed twice in a row. Only the targets selected from `previous`,
+	// e.g. `previous.deploy`, are kept unless it is indexed or iterated.
+	// The stored state is as large as those targets and is reduced to
+	// their metadata beyond the controller's maximum target state size.
+	// +optional
+	KeepPreviousState bool `json:"keepPreviousState,omitempty"`
+
+	// Optional: Declares whether target groups which can't be deleted due to
+	// a permanent error, such as an invalid label selector or missing
+	// permissions, block the deletion of the ConditionalTTL or are skipped.
+	// Defaults to Continue.
+	// +kubebuilder:default=Continue
+	// +optional
+	FinalizerFailurePolicy FinalizerFailurePolicy `json:"finalizerFailurePolicy,omitempty"`
+
+	// Optional: Duration to wait after the target groups of a DeletionOrder
+	// are gone before deleting those of the next one, e.g. to let other
+	// controllers react. Defaults to not waiting.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	DeletionDelay *metav1.Duration `json:"deletionDelay,omitempty"`
+
+	// Optional: Restricts the beginning of deletion to a recurring time
+	// range, e.g. off-hours. When conditions are met outside of it,
+	// deletion waits for the window to open. Defaults to no restriction.
+	// +optional
+	DeletionWindow *DeletionWindow `json:"deletionWindow,omitempty"`
+
+	// Optional: Allows targets to reference ConditionalTTLs, which would
+	// otherwise be rejected to prevent accidental cascades. The
+	// ConditionalTTL itself is never included in its targets.
+	// +optional
+	AllowConditionalTTLTargets bool `json:"allowConditionalTTLTargets,omitempty"`
+
+	// Optional: Restricts the namespaces the ConditionalTTL reaches into.
+	// When set, Helm releases of `helm` and `helmReleases`, including those
+	// found through `fromTarget`, are only read and uninstalled in the
+	// listed namespaces besides the ConditionalTTL's own, on top of
+	// requiring the controller's --allow-cross-namespace-helm. The
+	// ConditionalTTL's namespace, which targets are always looked up in,
+	// must be listed when targets are declared. `cloudEventSinkRef` may
+	// only reference listed namespaces other than its own, even when
+	// unset. Defaults to no restriction on Helm releases.
+	// +listType=set
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
 	// Optional http(s) address the controller should send a [Cloud Event](https://github.com/cloudevents/spec/blob/main/cloudevents/spec.md)
 	// to after deletion takes place.
 	// +optional
 	CloudEventSink *string `json:"cloudEventSink,omitempty"`
+
+	// Optional: a Service or Addressable the controller should send the
+	// Cloud Event to, resolved to its address when the event is sent.
+	// Mutually exclusive with CloudEventSink.
+	// +optional
+	CloudEventSinkRef *SinkReference `json:"cloudEventSinkRef,omitempty"`
+
+	// Optional: overrides the type, source and subject of the Cloud Event
+	// sent to CloudEventSink and sets extension attributes on it.
+	// +optional
+	CloudEvent *CloudEventConfig `json:"cloudEvent,omitempty"`
+
+	// Optional: Declares how the ConditionalTTL is handled once a namespace
+	// it references other than its own, i.e. a Helm release's, is gone or
+	// being deleted. Complete considers the Helm releases in that namespace
+	// uninstalled along with it, proceeding with deletion once expired
+	// without evaluating the conditions when every namespace it references
+	// is gone. Otherwise the conditions are still evaluated, the helmRelease
+	// variable being null when its namespace is gone. Defaults to Fail.
+	// +kubebuilder:default=Fail
+	// +optional
+	OrphanPolicy OrphanPolicy `json:"orphanPolicy,omitempty"`
+
+	// Optional: Whether the ConditionalTTL deletes itself once it deleted
+	// its targets and Helm releases and sent its Cloud Event. When false,
+	// it is kept with a Completed condition recording its final status and
+	// isn't evaluated again. Defaults to true.
+	// +kubebuilder:default=true
+	// +optional
+	DeleteSelf *bool `json:"deleteSelf,omitempty"`
+
+	// Suspend stops the ConditionalTTL from evaluating its conditions and
+	// starting deletion, e.g. during an incident, until it is unset again.
+	// Deletion already under way is not suspended.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
 }
 
 type TargetStatus struct {
 	// Name is the target name as declared on `spec.targets`.
 	Name string `json:"name"`
 
+	// APIVersion is the apiVersion the target was resolved with, which is
+	// the preferred one when it was omitted on `spec.targets`.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind is the kind the target was resolved with.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
 	// Delete matches `.spec.targets.delete` for the target
 	// identified by `name`.
 	Delete bool `json:"delete"`
@@ -134,18 +795,187 @@ type TargetStatus struct {
 	IncludeWhenEvaluating bool `json:"includeWhenEvaluating"`
 
 	// State is the observed state of the target on the cluster
-	// when deletion began.
+	// when deletion began, reduced as declared by the target's
+	// `stateInclusion`. Only the objects' metadata is kept when the full
+	// state is too large, and no state at all when even that doesn't fit.
+	// The data of Secrets is never kept.
 	//+kubebuilder:pruning:PreserveUnknownFields
 	State *unstructured.Unstructured `json:"state,omitempty"`
+
+	// Error is why the target couldn't be resolved on the last
+	// evaluation, in which case it is the only field set along with
+	// `name`, `delete` and `includeWhenEvaluating`.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// TargetSummary is a compact representation of a target's observed state.
+type TargetSummary struct {
+	// Name matches `.spec.targets.name` for the summarized target.
+	Name string `json:"name"`
+
+	// Count is the number of objects found for the target.
+	Count int `json:"count"`
+
+	// Status is the `.status` of the target when it references a single object.
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+optional
+	Status *runtime.RawExtension `json:"status,omitempty"`
+}
+
+// HelmDryRun records what uninstalling a Helm release would remove.
+type HelmDryRun struct {
+	// Release is the name of the Helm release.
+	Release string `json:"release"`
+
+	// Namespace of the Helm release.
+	Namespace string `json:"namespace"`
+
+	// Resources lists the resources of the release's manifest as
+	// `Kind/name`.
+	// +optional
+	Resources []string `json:"resources,omitempty"`
+}
+
+// HelmUninstall records the outcome of uninstalling a Helm release.
+type HelmUninstall struct {
+	// Release is the name of the Helm release.
+	Release string `json:"release"`
+
+	// Namespace of the Helm release.
+	Namespace string `json:"namespace"`
+
+	// Version is the revision of the release which was uninstalled.
+	Version int `json:"version"`
+
+	// UninstalledAt is the time when the uninstall finished.
+	UninstalledAt metav1.Time `json:"uninstalledAt"`
+
+	// Info is Helm's message about the uninstall, if any.
+	// +optional
+	Info string `json:"info,omitempty"`
+
+	// HookFailures lists the names of the release's pre-delete and
+	// post-delete hooks which failed.
+	// +optional
+	HookFailures []string `json:"hookFailures,omitempty"`
+}
+
+// DeletionProgress records how far the deletion of ordered target groups got.
+type DeletionProgress struct {
+	// Order is the last DeletionOrder whose target groups are all gone.
+	Order int32 `json:"order"`
+
+	// CompletedAt is the time when the target groups of Order were found
+	// to be gone.
+	CompletedAt metav1.Time `json:"completedAt"`
+}
+
+// HistoryEntry holds the summaries of the targets included when evaluating
+// the conditions at a given time.
+type HistoryEntry struct {
+	// Time is the time of the evaluation.
+	Time metav1.Time `json:"time"`
+
+	// Targets are the summaries of the targets included in the evaluation.
+	Targets []TargetSummary `json:"targets,omitempty"`
+}
+
+// ConditionResult is the outcome of evaluating a single condition.
+type ConditionResult struct {
+	// Index is the position of the condition on `spec.conditions`
+	// followed by `spec.namedConditions`.
+	Index int `json:"index"`
+
+	// Name of the condition, its index for those on `spec.conditions`.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Met indicates whether the condition evaluated to true.
+	Met bool `json:"met"`
+}
+
+// EvaluationError records the last error evaluating the conditions, e.g.
+// a CEL runtime error, and how many evaluations in a row failed.
+type EvaluationError struct {
+	// Message of the error.
+	Message string `json:"message"`
+
+	// Time of the last failed evaluation.
+	Time metav1.Time `json:"time"`
+
+	// ConsecutiveFailures counts the evaluations which failed in a row.
+	ConsecutiveFailures int32 `json:"consecutiveFailures"`
 }
 
 // ConditionalTTLStatus defines the observed state of ConditionalTTL.
 type ConditionalTTLStatus struct {
+	// Targets holds the state of the targets when deletion began, or the
+	// targets which couldn't be resolved on the last evaluation.
+	// +optional
 	Targets []TargetStatus `json:"targets,omitempty"`
 
+	// TargetsTruncated is set when the targets' state exceeded the
+	// controller's size limit and only their metadata, or for the last
+	// targets no state at all, was stored.
+	// +optional
+	TargetsTruncated bool `json:"targetsTruncated,omitempty"`
+
+	// ExpiresAt is the time when the TTL passes, after which the
+	// conditions are evaluated.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpiredAt is the time when the controller first observed the TTL
+	// had passed and started evaluating the conditions.
+	// +optional
+	ExpiredAt *metav1.Time `json:"expiredAt,omitempty"`
+
 	// EvaluationTime is the time when the conditions for deletion were met.
 	EvaluationTime *metav1.Time `json:"evaluationTime,omitempty"`
 
+	// LastEvaluationError is the last error evaluating the conditions,
+	// cleared once they are evaluated successfully. Unlike the Ready
+	// condition, it tells a one-off failure from a persistent one.
+	// +optional
+	LastEvaluationError *EvaluationError `json:"lastEvaluationError,omitempty"`
+
+	// ConditionResults holds the outcome of each condition on the last
+	// successful evaluation.
+	// +optional
+	ConditionResults []ConditionResult `json:"conditionResults,omitempty"`
+
+	// History holds, from oldest to newest, the summaries of previous
+	// evaluations, up to `spec.historyLimit` entries.
+	// +optional
+	History []HistoryEntry `json:"history,omitempty"`
+
+	// PreviousTargets holds the state of the targets included when
+	// evaluating the conditions on the last evaluation which didn't meet
+	// them, when `spec.keepPreviousState` is set.
+	// +optional
+	PreviousTargets []TargetStatus `json:"previousTargets,omitempty"`
+
+	// DeletionProgress tracks the deletion of ordered target groups when
+	// `spec.deletionDelay` is set.
+	// +optional
+	DeletionProgress *DeletionProgress `json:"deletionProgress,omitempty"`
+
+	// HelmDryRuns holds the outcome of uninstalling the Helm releases
+	// with `dryRun` set.
+	// +optional
+	HelmDryRuns []HelmDryRun `json:"helmDryRuns,omitempty"`
+
+	// EventDeliveryAttempts counts the failed attempts to deliver the
+	// Deleted cloud event.
+	// +optional
+	EventDeliveryAttempts int32 `json:"eventDeliveryAttempts,omitempty"`
+
+	// HelmUninstalls records the Helm releases uninstalled by the
+	// finalizer, also sent on the deletion cloud event.
+	// +optional
+	HelmUninstalls []HelmUninstall `json:"helmUninstalls,omitempty"`
+
 	//+optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
@@ -155,6 +985,7 @@ type ConditionalTTLStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:printcolumn:name="TTL",type=string,format=date-time,JSONPath=`.spec.ttl`
+// +kubebuilder:printcolumn:name="Expires At",type=string,format=date-time,JSONPath=`.status.expiresAt`
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
 
 // ConditionalTTL allows one to declare a set of conditions under which a set of
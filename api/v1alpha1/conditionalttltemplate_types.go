@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConditionalTTLTemplateSpec represents the configuration for a
+// ConditionalTTLTemplate object.
+type ConditionalTTLTemplateSpec struct {
+	// NamespaceSelector selects the namespaces a ConditionalTTL is stamped
+	// in. Namespaces being deleted are ignored.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+
+	// Labels added to each stamped ConditionalTTL.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to each stamped ConditionalTTL.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec of the stamped ConditionalTTLs. The names and label selector
+	// values of target references and the Helm releases may use
+	// `{{ .Namespace }}`, replaced by the namespace the ConditionalTTL is
+	// stamped in.
+	Spec ConditionalTTLSpec `json:"spec"`
+}
+
+// ConditionalTTLTemplateStatus defines the observed state of
+// ConditionalTTLTemplate.
+type ConditionalTTLTemplateStatus struct {
+	// Namespaces lists the namespaces a ConditionalTTL was stamped in.
+	// ConditionalTTLs which are gone from these namespaces, e.g. once their
+	// conditions were met, aren't stamped again unless the namespace is
+	// re-created.
+	// +optional
+	Namespaces []StampedNamespace `json:"namespaces,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// StampedNamespace identifies a namespace a ConditionalTTL was stamped in.
+type StampedNamespace struct {
+	// Name of the namespace.
+	Name string `json:"name"`
+
+	// UID of the namespace, telling it apart from namespaces re-created
+	// with the same name.
+	UID types.UID `json:"uid"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=cttltemplate
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+
+// ConditionalTTLTemplate stamps a ConditionalTTL, named after the template,
+// in each namespace matching a selector. The stamped ConditionalTTLs are
+// owned by the template and therefore deleted along with it.
+type ConditionalTTLTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConditionalTTLTemplateSpec   `json:"spec,omitempty"`
+	Status ConditionalTTLTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ConditionalTTLTemplateList contains a list of ConditionalTTLTemplate.
+type ConditionalTTLTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConditionalTTLTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConditionalTTLTemplate{}, &ConditionalTTLTemplateList{})
+}
@@ -1,16 +1,55 @@
 package v1alpha1
 
 const (
-	ConditionReasonNotExpired           = "NotExpired"
-	ConditionReasonTargetResolveError   = "TargetResolveError"
-	ConditionReasonEnvironmentError     = "ConditionEnvironmentError"
-	ConditionReasonCompileError         = "ConditionCompileError"
-	ConditionReasonEvaluationError      = "ConditionEvaluationError"
-	ConditionReasonResultNotBoolean     = "ConditionResultNotBoolean"
-	ConditionReasonWaitingForConditions = "WaitingForConditions"
-	ConditionReasonTerminating          = "Terminating"
+	ConditionReasonNotExpired             = "NotExpired"
+	ConditionReasonTargetResolveError     = "TargetResolveError"
+	ConditionReasonTargetForbidden        = "TargetForbidden"
+	ConditionReasonUnknownKind            = "UnknownKind"
+	ConditionReasonInvalidTargetReference = "InvalidTargetReference"
+	ConditionReasonContextResolveError    = "ContextResolveError"
+	ConditionReasonEnvironmentError       = "ConditionEnvironmentError"
+	ConditionReasonInvalidConditionPolicy = "InvalidConditionPolicy"
+	ConditionReasonCompileError           = "ConditionCompileError"
+	ConditionReasonReferenceError         = "ConditionReferenceError"
+	ConditionReasonEvaluationError        = "ConditionEvaluationError"
+	ConditionReasonResultNotBoolean       = "ConditionResultNotBoolean"
+	ConditionReasonWaitingForConditions   = "WaitingForConditions"
+	ConditionReasonWaitingForTargets      = "WaitingForTargets"
+	ConditionReasonWaitingForHelmRelease  = "WaitingForHelmRelease"
+	ConditionReasonWaitingForWindow       = "WaitingForWindow"
+	ConditionReasonInvalidDeletionWindow  = "InvalidDeletionWindow"
+	ConditionReasonTerminating            = "Terminating"
+	ConditionReasonNamespaceGone          = "NamespaceGone"
+	ConditionReasonHelmReleaseGone        = "HelmReleaseGone"
+	ConditionReasonCompleted              = "Completed"
+	ConditionReasonSuspended              = "Suspended"
+
+	ConditionReasonInvalidNamespaceSelector = "InvalidNamespaceSelector"
+	ConditionReasonTemplateRenderError      = "TemplateRenderError"
+	ConditionReasonHelmNamespaceNotAllowed  = "HelmNamespaceNotAllowed"
+	ConditionReasonStamped                  = "Stamped"
+
+	ConditionReasonTargetsDeleted           = "TargetsDeleted"
+	ConditionReasonTargetsSkipped           = "TargetsSkipped"
+	ConditionReasonWaitingForTargetDeletion = "WaitingForTargetDeletion"
+	ConditionReasonTargetDeletionFailed     = "TargetDeletionFailed"
+
+	ConditionReasonFinalizerRunning   = "FinalizerRunning"
+	ConditionReasonFinalizerRequeued  = "FinalizerRequeued"
+	ConditionReasonFinalizerFailed    = "FinalizerFailed"
+	ConditionReasonFinalizerCompleted = "FinalizerCompleted"
 )
 
 const (
-	ConditionTypeReady = "Ready"
+	ConditionTypeReady          = "Ready"
+	ConditionTypeTargetsDeleted = "TargetsDeleted"
+	// set once a cTTL which doesn't delete itself is done cleaning up
+	ConditionTypeCompleted = "Completed"
+	// set while a cTTL is suspended
+	ConditionTypeSuspended = "Suspended"
+
+	// set while the finalizer of each phase of deletion runs
+	ConditionTypeTargetsDeleting  = "TargetsDeleting"
+	ConditionTypeHelmUninstalling = "HelmUninstalling"
+	ConditionTypeEventDelivering  = "EventDelivering"
 )
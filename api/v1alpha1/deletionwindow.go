@@ -0,0 +1,100 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Validate reports whether the window's days, times and time zone can be
+// parsed.
+func (w *DeletionWindow) Validate() error {
+	_, err := w.parse()
+	return err
+}
+
+// Next reports whether the window is open at now. When it is, at is the
+// time it closes, otherwise the time it next opens. Times of day skipped
+// or repeated by daylight saving transitions are resolved as time.Date
+// does.
+func (w *DeletionWindow) Next(now time.Time) (open bool, at time.Time, err error) {
+	p, err := w.parse()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now = now.In(p.loc)
+	y, m, d := now.Date()
+	// starting on the previous day since its window may span midnight
+	for i := -1; i <= 7; i++ {
+		// noon is used to find the weekday since midnight may not exist
+		if !p.days[time.Date(y, m, d+i, 12, 0, 0, 0, p.loc).Weekday()] {
+			continue
+		}
+		opens := time.Date(y, m, d+i, p.start/60, p.start%60, 0, 0, p.loc)
+		closeDay := d + i
+		if p.end <= p.start {
+			closeDay++
+		}
+		closes := time.Date(y, m, closeDay, p.end/60, p.end%60, 0, 0, p.loc)
+		if !now.Before(opens) && now.Before(closes) {
+			return true, closes, nil
+		}
+		if opens.After(now) {
+			return false, opens, nil
+		}
+	}
+	return false, time.Time{}, errors.New("deletion window never opens")
+}
+
+type parsedWindow struct {
+	loc        *time.Location
+	days       map[time.Weekday]bool
+	start, end int
+}
+
+func (w *DeletionWindow) parse() (*parsedWindow, error) {
+	p := &parsedWindow{loc: time.UTC, days: map[time.Weekday]bool{}}
+	var err error
+	if w.TimeZone != "" {
+		if p.loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", w.TimeZone, err)
+		}
+	}
+	if p.start, err = parseTimeOfDay(w.Start); err != nil {
+		return nil, err
+	}
+	if p.end, err = parseTimeOfDay(w.End); err != nil {
+		return nil, err
+	}
+	if len(w.Days) == 0 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			p.days[d] = true
+		}
+		return p, nil
+	}
+	for _, day := range w.Days {
+		d, ok := weekdays[day]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", day)
+		}
+		p.days[d] = true
+	}
+	return p, nil
+}
+
+var weekdays = func() map[Weekday]time.Weekday {
+	m := map[Weekday]time.Weekday{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		m[Weekday(d.String())] = d
+	}
+	return m
+}()
+
+// parseTimeOfDay returns the minutes since midnight of a HH:MM time.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,102 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletionWindowNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	overnight := &DeletionWindow{Days: []Weekday{"Saturday"}, Start: "22:00", End: "06:00", TimeZone: "America/Sao_Paulo"}
+	testCases := map[string]struct {
+		window   *DeletionWindow
+		now      time.Time
+		wantOpen bool
+		wantAt   time.Time
+	}{
+		"opens later today": {
+			window: &DeletionWindow{Start: "22:00", End: "23:00"},
+			now:    utc("2026-10-16T12:00:00Z"),
+			wantAt: utc("2026-10-16T22:00:00Z"),
+		},
+		"open": {
+			window:   &DeletionWindow{Start: "10:00", End: "14:00"},
+			now:      utc("2026-10-16T12:00:00Z"),
+			wantOpen: true,
+			wantAt:   utc("2026-10-16T14:00:00Z"),
+		},
+		"opens tomorrow": {
+			window: &DeletionWindow{Start: "10:00", End: "11:00"},
+			now:    utc("2026-10-16T11:00:00Z"),
+			wantAt: utc("2026-10-17T10:00:00Z"),
+		},
+		"open past midnight": {
+			window:   overnight,
+			now:      utc("2026-10-18T06:00:00Z"),
+			wantOpen: true,
+			wantAt:   utc("2026-10-18T09:00:00Z"),
+		},
+		"opens next week": {
+			window: overnight,
+			now:    utc("2026-10-18T13:00:00Z"),
+			wantAt: utc("2026-10-25T01:00:00Z"),
+		},
+		"whole day": {
+			window:   &DeletionWindow{Days: []Weekday{"Monday"}, Start: "00:00", End: "00:00"},
+			now:      utc("2026-10-19T12:00:00Z"),
+			wantOpen: true,
+			wantAt:   utc("2026-10-20T00:00:00Z"),
+		},
+		"shortened by daylight saving": {
+			window:   &DeletionWindow{Start: "01:00", End: "03:00", TimeZone: "America/New_York"},
+			now:      utc("2026-03-08T06:30:00Z"),
+			wantOpen: true,
+			wantAt:   utc("2026-03-08T07:00:00Z"),
+		},
+		"opens after daylight saving ends": {
+			window: &DeletionWindow{Start: "09:00", End: "10:00", TimeZone: "America/New_York"},
+			now:    utc("2026-10-31T16:00:00Z"),
+			wantAt: utc("2026-11-01T14:00:00Z"),
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			open, at, err := tc.window.Next(tc.now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if open != tc.wantOpen || !at.Equal(tc.wantAt) {
+				t.Errorf("got open=%v at=%s, want open=%v at=%s", open, at.UTC(), tc.wantOpen, tc.wantAt)
+			}
+		})
+	}
+}
+
+func TestDeletionWindowValidate(t *testing.T) {
+	testCases := map[string]struct {
+		window  *DeletionWindow
+		wantErr bool
+	}{
+		"valid":             {window: &DeletionWindow{Days: []Weekday{"Sunday"}, Start: "00:00", End: "23:59", TimeZone: "Europe/Lisbon"}},
+		"invalid day":       {window: &DeletionWindow{Days: []Weekday{"Sun"}, Start: "00:00", End: "01:00"}, wantErr: true},
+		"invalid start":     {window: &DeletionWindow{Start: "24:00", End: "01:00"}, wantErr: true},
+		"invalid end":       {window: &DeletionWindow{Start: "00:00", End: ""}, wantErr: true},
+		"invalid time zone": {window: &DeletionWindow{Start: "00:00", End: "01:00", TimeZone: "Nowhere"}, wantErr: true},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			err := tc.window.Validate()
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+			}
+		})
+	}
+}
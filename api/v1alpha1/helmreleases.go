@@ -0,0 +1,9 @@
+package v1alpha1
+
+// AllHelmReleases returns Helm, when set, followed by HelmReleases.
+func (s *ConditionalTTLSpec) AllHelmReleases() []HelmConfig {
+	if s.Helm == nil {
+		return s.HelmReleases
+	}
+	return append([]HelmConfig{*s.Helm}, s.HelmReleases...)
+}
@@ -0,0 +1,25 @@
+package v1alpha1
+
+import "strconv"
+
+// nameConditions returns conditions, named after their index, followed by
+// named.
+func nameConditions(conditions []string, named []NamedCondition) []NamedCondition {
+	out := make([]NamedCondition, 0, len(conditions)+len(named))
+	for i, c := range conditions {
+		out = append(out, NamedCondition{Name: strconv.Itoa(i), Expression: c})
+	}
+	return append(out, named...)
+}
+
+// AllConditions returns the conditions followed by the named conditions of
+// the spec.
+func (s *ConditionalTTLSpec) AllConditions() []NamedCondition {
+	return nameConditions(s.Conditions, s.NamedConditions)
+}
+
+// AllConditions returns the conditions followed by the named conditions of
+// the spec.
+func (s *ClusterConditionalTTLSpec) AllConditions() []NamedCondition {
+	return nameConditions(s.Conditions, s.NamedConditions)
+}
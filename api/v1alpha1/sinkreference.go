@@ -0,0 +1,30 @@
+package v1alpha1
+
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
+// SinkKinds lists the kinds a SinkReference may refer to: Services, whose
+// address is built from their ports, and the Knative Addressables the
+// controller is allowed to read.
+var SinkKinds = []schema.GroupKind{
+	{Kind: "Service"},
+	{Group: "eventing.knative.dev", Kind: "Broker"},
+	{Group: "messaging.knative.dev", Kind: "Channel"},
+	{Group: "serving.knative.dev", Kind: "Service"},
+}
+
+// GroupVersionKind returns the kind of the referenced object, defaulting
+// to a v1 Service.
+func (r *SinkReference) GroupVersionKind() (schema.GroupVersionKind, error) {
+	gv, err := schema.ParseGroupVersion(r.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if r.APIVersion == "" {
+		gv.Version = "v1"
+	}
+	kind := r.Kind
+	if kind == "" {
+		kind = "Service"
+	}
+	return gv.WithKind(kind), nil
+}
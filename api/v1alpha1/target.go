@@ -0,0 +1,7 @@
+package v1alpha1
+
+// IncludedWhenEvaluating reports whether the target group is included in
+// the CEL evaluation context, i.e. IncludeWhenEvaluating is set to true.
+func (t Target) IncludedWhenEvaluating() bool {
+	return t.IncludeWhenEvaluating != nil && *t.IncludeWhenEvaluating
+}
@@ -23,9 +23,333 @@ package v1alpha1
 
 import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnnotationSelector) DeepCopyInto(out *AnnotationSelector) {
+	*out = *in
+	if in.MatchAnnotations != nil {
+		in, out := &in.MatchAnnotations, &out.MatchAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnnotationSelector.
+func (in *AnnotationSelector) DeepCopy() *AnnotationSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(AnnotationSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundle) DeepCopyInto(out *CABundle) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundle.
+func (in *CABundle) DeepCopy() *CABundle {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudEventConfig) DeepCopyInto(out *CloudEventConfig) {
+	*out = *in
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]CloudEventStage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(CloudEventRetry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HeadersFrom != nil {
+		in, out := &in.HeadersFrom, &out.HeadersFrom
+		*out = new(SecretHeaders)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(CloudEventTLS)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudEventConfig.
+func (in *CloudEventConfig) DeepCopy() *CloudEventConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudEventConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudEventRetry) DeepCopyInto(out *CloudEventRetry) {
+	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudEventRetry.
+func (in *CloudEventRetry) DeepCopy() *CloudEventRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudEventRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudEventTLS) DeepCopyInto(out *CloudEventTLS) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(CABundle)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudEventTLS.
+func (in *CloudEventTLS) DeepCopy() *CloudEventTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudEventTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConditionalTTL) DeepCopyInto(out *ClusterConditionalTTL) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConditionalTTL.
+func (in *ClusterConditionalTTL) DeepCopy() *ClusterConditionalTTL {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConditionalTTL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterConditionalTTL) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConditionalTTLList) DeepCopyInto(out *ClusterConditionalTTLList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterConditionalTTL, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConditionalTTLList.
+func (in *ClusterConditionalTTLList) DeepCopy() *ClusterConditionalTTLList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConditionalTTLList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterConditionalTTLList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConditionalTTLSpec) DeepCopyInto(out *ClusterConditionalTTLSpec) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]Target, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamedConditions != nil {
+		in, out := &in.NamedConditions, &out.NamedConditions
+		*out = make([]NamedCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConditionPolicy != nil {
+		in, out := &in.ConditionPolicy, &out.ConditionPolicy
+		*out = new(ConditionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletionDelay != nil {
+		in, out := &in.DeletionDelay, &out.DeletionDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DeletionWindow != nil {
+		in, out := &in.DeletionWindow, &out.DeletionWindow
+		*out = new(DeletionWindow)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConditionalTTLSpec.
+func (in *ClusterConditionalTTLSpec) DeepCopy() *ClusterConditionalTTLSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConditionalTTLSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConditionalTTLStatus) DeepCopyInto(out *ClusterConditionalTTLStatus) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiredAt != nil {
+		in, out := &in.ExpiredAt, &out.ExpiredAt
+		*out = (*in).DeepCopy()
+	}
+	if in.EvaluationTime != nil {
+		in, out := &in.EvaluationTime, &out.EvaluationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ConditionResults != nil {
+		in, out := &in.ConditionResults, &out.ConditionResults
+		*out = make([]ConditionResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletionProgress != nil {
+		in, out := &in.DeletionProgress, &out.DeletionProgress
+		*out = new(DeletionProgress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConditionalTTLStatus.
+func (in *ClusterConditionalTTLStatus) DeepCopy() *ClusterConditionalTTLStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConditionalTTLStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionPolicy) DeepCopyInto(out *ConditionPolicy) {
+	*out = *in
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionPolicy.
+func (in *ConditionPolicy) DeepCopy() *ConditionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionResult) DeepCopyInto(out *ConditionResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionResult.
+func (in *ConditionResult) DeepCopy() *ConditionResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConditionalTTL) DeepCopyInto(out *ConditionalTTL) {
 	*out = *in
@@ -35,18 +359,262 @@ func (in *ConditionalTTL) DeepCopyInto(out *ConditionalTTL) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTL.
-func (in *ConditionalTTL) DeepCopy() *ConditionalTTL {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTL.
+func (in *ConditionalTTL) DeepCopy() *ConditionalTTL {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConditionalTTL) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalTTLList) DeepCopyInto(out *ConditionalTTLList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ConditionalTTL, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLList.
+func (in *ConditionalTTLList) DeepCopy() *ConditionalTTLList {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTLList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConditionalTTLList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalTTLSpec) DeepCopyInto(out *ConditionalTTLSpec) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Helm != nil {
+		in, out := &in.Helm, &out.Helm
+		*out = new(HelmConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HelmReleases != nil {
+		in, out := &in.HelmReleases, &out.HelmReleases
+		*out = make([]HelmConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]Target, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraContext != nil {
+		in, out := &in.ExtraContext, &out.ExtraContext
+		*out = make([]ContextValue, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamedConditions != nil {
+		in, out := &in.NamedConditions, &out.NamedConditions
+		*out = make([]NamedCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConditionPolicy != nil {
+		in, out := &in.ConditionPolicy, &out.ConditionPolicy
+		*out = new(ConditionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletionDelay != nil {
+		in, out := &in.DeletionDelay, &out.DeletionDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DeletionWindow != nil {
+		in, out := &in.DeletionWindow, &out.DeletionWindow
+		*out = new(DeletionWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CloudEventSink != nil {
+		in, out := &in.CloudEventSink, &out.CloudEventSink
+		*out = new(string)
+		**out = **in
+	}
+	if in.CloudEventSinkRef != nil {
+		in, out := &in.CloudEventSinkRef, &out.CloudEventSinkRef
+		*out = new(SinkReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudEvent != nil {
+		in, out := &in.CloudEvent, &out.CloudEvent
+		*out = new(CloudEventConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeleteSelf != nil {
+		in, out := &in.DeleteSelf, &out.DeleteSelf
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLSpec.
+func (in *ConditionalTTLSpec) DeepCopy() *ConditionalTTLSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTLSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalTTLStatus) DeepCopyInto(out *ConditionalTTLStatus) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiredAt != nil {
+		in, out := &in.ExpiredAt, &out.ExpiredAt
+		*out = (*in).DeepCopy()
+	}
+	if in.EvaluationTime != nil {
+		in, out := &in.EvaluationTime, &out.EvaluationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastEvaluationError != nil {
+		in, out := &in.LastEvaluationError, &out.LastEvaluationError
+		*out = new(EvaluationError)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConditionResults != nil {
+		in, out := &in.ConditionResults, &out.ConditionResults
+		*out = make([]ConditionResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]HistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreviousTargets != nil {
+		in, out := &in.PreviousTargets, &out.PreviousTargets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DeletionProgress != nil {
+		in, out := &in.DeletionProgress, &out.DeletionProgress
+		*out = new(DeletionProgress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HelmDryRuns != nil {
+		in, out := &in.HelmDryRuns, &out.HelmDryRuns
+		*out = make([]HelmDryRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HelmUninstalls != nil {
+		in, out := &in.HelmUninstalls, &out.HelmUninstalls
+		*out = make([]HelmUninstall, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLStatus.
+func (in *ConditionalTTLStatus) DeepCopy() *ConditionalTTLStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTLStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalTTLTemplate) DeepCopyInto(out *ConditionalTTLTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLTemplate.
+func (in *ConditionalTTLTemplate) DeepCopy() *ConditionalTTLTemplate {
 	if in == nil {
 		return nil
 	}
-	out := new(ConditionalTTL)
+	out := new(ConditionalTTLTemplate)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ConditionalTTL) DeepCopyObject() runtime.Object {
+func (in *ConditionalTTLTemplate) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -54,31 +622,31 @@ func (in *ConditionalTTL) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConditionalTTLList) DeepCopyInto(out *ConditionalTTLList) {
+func (in *ConditionalTTLTemplateList) DeepCopyInto(out *ConditionalTTLTemplateList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ConditionalTTL, len(*in))
+		*out = make([]ConditionalTTLTemplate, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLList.
-func (in *ConditionalTTLList) DeepCopy() *ConditionalTTLList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLTemplateList.
+func (in *ConditionalTTLTemplateList) DeepCopy() *ConditionalTTLTemplateList {
 	if in == nil {
 		return nil
 	}
-	out := new(ConditionalTTLList)
+	out := new(ConditionalTTLTemplateList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ConditionalTTLList) DeepCopyObject() runtime.Object {
+func (in *ConditionalTTLTemplateList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -86,96 +654,254 @@ func (in *ConditionalTTLList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConditionalTTLSpec) DeepCopyInto(out *ConditionalTTLSpec) {
+func (in *ConditionalTTLTemplateSpec) DeepCopyInto(out *ConditionalTTLTemplateSpec) {
 	*out = *in
-	if in.TTL != nil {
-		in, out := &in.TTL, &out.TTL
-		*out = new(v1.Duration)
-		**out = **in
-	}
-	if in.Retry != nil {
-		in, out := &in.Retry, &out.Retry
-		*out = new(RetryConfig)
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Helm != nil {
-		in, out := &in.Helm, &out.Helm
-		*out = new(HelmConfig)
-		**out = **in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.Targets != nil {
-		in, out := &in.Targets, &out.Targets
-		*out = make([]Target, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLTemplateSpec.
+func (in *ConditionalTTLTemplateSpec) DeepCopy() *ConditionalTTLTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTLTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalTTLTemplateStatus) DeepCopyInto(out *ConditionalTTLTemplateStatus) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]StampedNamespace, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]string, len(*in))
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLTemplateStatus.
+func (in *ConditionalTTLTemplateStatus) DeepCopy() *ConditionalTTLTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalTTLTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContextValue) DeepCopyInto(out *ContextValue) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(KeySelector)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(KeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContextValue.
+func (in *ContextValue) DeepCopy() *ContextValue {
+	if in == nil {
+		return nil
+	}
+	out := new(ContextValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletionProgress) DeepCopyInto(out *DeletionProgress) {
+	*out = *in
+	in.CompletedAt.DeepCopyInto(&out.CompletedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletionProgress.
+func (in *DeletionProgress) DeepCopy() *DeletionProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletionProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletionWindow) DeepCopyInto(out *DeletionWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
 		copy(*out, *in)
 	}
-	if in.CloudEventSink != nil {
-		in, out := &in.CloudEventSink, &out.CloudEventSink
-		*out = new(string)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletionWindow.
+func (in *DeletionWindow) DeepCopy() *DeletionWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletionWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EvaluationError) DeepCopyInto(out *EvaluationError) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EvaluationError.
+func (in *EvaluationError) DeepCopy() *EvaluationError {
+	if in == nil {
+		return nil
+	}
+	out := new(EvaluationError)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmConfig) DeepCopyInto(out *HelmConfig) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLSpec.
-func (in *ConditionalTTLSpec) DeepCopy() *ConditionalTTLSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmConfig.
+func (in *HelmConfig) DeepCopy() *HelmConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(ConditionalTTLSpec)
+	out := new(HelmConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConditionalTTLStatus) DeepCopyInto(out *ConditionalTTLStatus) {
+func (in *HelmDryRun) DeepCopyInto(out *HelmDryRun) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmDryRun.
+func (in *HelmDryRun) DeepCopy() *HelmDryRun {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmDryRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmUninstall) DeepCopyInto(out *HelmUninstall) {
+	*out = *in
+	in.UninstalledAt.DeepCopyInto(&out.UninstalledAt)
+	if in.HookFailures != nil {
+		in, out := &in.HookFailures, &out.HookFailures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmUninstall.
+func (in *HelmUninstall) DeepCopy() *HelmUninstall {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmUninstall)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HistoryEntry) DeepCopyInto(out *HistoryEntry) {
 	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
 	if in.Targets != nil {
 		in, out := &in.Targets, &out.Targets
-		*out = make([]TargetStatus, len(*in))
+		*out = make([]TargetSummary, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.EvaluationTime != nil {
-		in, out := &in.EvaluationTime, &out.EvaluationTime
-		*out = (*in).DeepCopy()
-	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HistoryEntry.
+func (in *HistoryEntry) DeepCopy() *HistoryEntry {
+	if in == nil {
+		return nil
 	}
+	out := new(HistoryEntry)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalTTLStatus.
-func (in *ConditionalTTLStatus) DeepCopy() *ConditionalTTLStatus {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeySelector) DeepCopyInto(out *KeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeySelector.
+func (in *KeySelector) DeepCopy() *KeySelector {
 	if in == nil {
 		return nil
 	}
-	out := new(ConditionalTTLStatus)
+	out := new(KeySelector)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HelmConfig) DeepCopyInto(out *HelmConfig) {
+func (in *NamedCondition) DeepCopyInto(out *NamedCondition) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmConfig.
-func (in *HelmConfig) DeepCopy() *HelmConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedCondition.
+func (in *NamedCondition) DeepCopy() *NamedCondition {
 	if in == nil {
 		return nil
 	}
-	out := new(HelmConfig)
+	out := new(NamedCondition)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -200,10 +926,87 @@ func (in *RetryConfig) DeepCopy() *RetryConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretHeaders) DeepCopyInto(out *SecretHeaders) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretHeaders.
+func (in *SecretHeaders) DeepCopy() *SecretHeaders {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretHeaders)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SinkReference) DeepCopyInto(out *SinkReference) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SinkReference.
+func (in *SinkReference) DeepCopy() *SinkReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SinkReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StampedNamespace) DeepCopyInto(out *StampedNamespace) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StampedNamespace.
+func (in *StampedNamespace) DeepCopy() *StampedNamespace {
+	if in == nil {
+		return nil
+	}
+	out := new(StampedNamespace)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Target) DeepCopyInto(out *Target) {
 	*out = *in
+	if in.IncludeWhenEvaluating != nil {
+		in, out := &in.IncludeWhenEvaluating, &out.IncludeWhenEvaluating
+		*out = new(bool)
+		**out = **in
+	}
 	in.Reference.DeepCopyInto(&out.Reference)
+	if in.DeletionOrder != nil {
+		in, out := &in.DeletionOrder, &out.DeletionOrder
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ForceRemoveFinalizersAfter != nil {
+		in, out := &in.ForceRemoveFinalizersAfter, &out.ForceRemoveFinalizersAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Target.
@@ -230,6 +1033,11 @@ func (in *TargetReference) DeepCopyInto(out *TargetReference) {
 		*out = new(v1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AnnotationSelector != nil {
+		in, out := &in.AnnotationSelector, &out.AnnotationSelector
+		*out = new(AnnotationSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetReference.
@@ -260,3 +1068,23 @@ func (in *TargetStatus) DeepCopy() *TargetStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetSummary) DeepCopyInto(out *TargetSummary) {
+	*out = *in
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetSummary.
+func (in *TargetSummary) DeepCopy() *TargetSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetSummary)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/custom_cel"
+)
+
+// exit codes of the eval command, also used by the others
+const (
+	exitMet    = 0
+	exitNotMet = 1
+	exitError  = 2
+)
+
+const evalUsage = `Usage: cleanerctl eval [flags] < context.yaml
+
+Evaluates conditions the same way the controller does. The YAML context
+read from stdin maps each target name to its state, e.g. the output of
+kubectl get -o yaml, and each extra context name to its string value.
+History and previous keys, when present, are exposed as is unless a
+manifest is passed which doesn't set historyLimit or keepPreviousState
+respectively.
+
+Exits with 0 when the conditions are met, 1 when they are not and 2 on
+errors.
+
+Flags:
+`
+
+// conditionsFlag collects the conditions passed with repeated flags.
+type conditionsFlag []string
+
+func (f *conditionsFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *conditionsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func runEval(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, evalUsage)
+		fs.PrintDefaults()
+	}
+	var conditions conditionsFlag
+	var file, at string
+	fs.StringVar(&file, "f", "", "ConditionalTTL manifest whose targets, extra context, conditions and condition policy are used.")
+	fs.Var(&conditions, "c", "Condition to evaluate instead of the manifest's. May be repeated.")
+	fs.StringVar(&at, "time", "", "RFC 3339 time the conditions are evaluated at. Defaults to now.")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if err := evaluate(file, conditions, at, stdin, stdout); err != nil {
+		var notMet *notMetError
+		if errors.As(err, &notMet) {
+			return exitNotMet
+		}
+		fmt.Fprintln(stderr, "error:", err)
+		return exitError
+	}
+	return exitMet
+}
+
+// notMetError is returned by evaluate when the conditions are not met.
+type notMetError struct{}
+
+func (*notMetError) Error() string { return "conditions not met" }
+
+func evaluate(file string, conditions []string, at string, stdin io.Reader, stdout io.Writer) error {
+	cTTL := &cleanerv1alpha1.ConditionalTTL{}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(b, cTTL); err != nil {
+			return fmt.Errorf("unable to decode %s: %w", file, err)
+		}
+	}
+	if len(conditions) > 0 {
+		cTTL.Spec.Conditions = conditions
+	}
+	if len(cTTL.Spec.AllConditions()) == 0 {
+		return errors.New("no conditions to evaluate, pass them with -c or a manifest with -f")
+	}
+
+	t := time.Now()
+	if at != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, at); err != nil {
+			return fmt.Errorf("invalid time: %w", err)
+		}
+	}
+
+	b, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	values, err := decodeContext(b)
+	if err != nil {
+		return err
+	}
+	celCtx, err := buildContext(cTTL, values, t)
+	if err != nil {
+		return err
+	}
+
+	readyCondition := metav1.Condition{}
+	met, _, results := custom_cel.EvaluateCELConditions(context.Background(), custom_cel.BuildCELOptions(cTTL), celCtx, cTTL.Spec.AllConditions(), cTTL.Spec.ConditionPolicy, &readyCondition)
+	for _, r := range results {
+		fmt.Fprintf(stdout, "condition %s: met=%t\n", r.Name, r.Met)
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", readyCondition.Reason, readyCondition.Message)
+	if readyCondition.Status == metav1.ConditionFalse {
+		return errors.New(readyCondition.Message)
+	}
+	if !met {
+		return &notMetError{}
+	}
+	return nil
+}
+
+// decodeContext decodes the YAML context keeping integers as int64, as
+// the controller gets them from the API server.
+func decodeContext(b []byte) (map[string]interface{}, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode context: %w", err)
+	}
+	values := map[string]interface{}{}
+	if string(j) == "null" {
+		return values, nil
+	}
+	if err := utiljson.Unmarshal(j, &values); err != nil {
+		return nil, fmt.Errorf("unable to decode context: %w", err)
+	}
+	return values, nil
+}
+
+// buildContext builds the CEL context from the decoded values. Without a
+// manifest every value is declared as a target included when evaluating.
+func buildContext(cTTL *cleanerv1alpha1.ConditionalTTL, values map[string]interface{}, t time.Time) (map[string]interface{}, error) {
+	if len(cTTL.Spec.Targets) == 0 && len(cTTL.Spec.ExtraContext) == 0 {
+		// declared rather than exposed as targets, any limit will do
+		if _, ok := values["history"]; ok {
+			cTTL.Spec.HistoryLimit = 1
+		}
+		if _, ok := values["previous"]; ok {
+			cTTL.Spec.KeepPreviousState = true
+		}
+		reserved := custom_cel.ReservedNames(cTTL)
+		names := make([]string, 0, len(values))
+		for name := range values {
+			if !slices.Contains(reserved, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cTTL.Spec.Targets = append(cTTL.Spec.Targets, cleanerv1alpha1.Target{Name: name, IncludeWhenEvaluating: ptr.To(true)})
+		}
+	}
+
+	var ts []cleanerv1alpha1.TargetStatus
+	for _, target := range cTTL.Spec.Targets {
+		if !target.IncludedWhenEvaluating() {
+			continue
+		}
+		v, ok := values[target.Name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("context is missing the state of target %q", target.Name)
+		}
+		ts = append(ts, cleanerv1alpha1.TargetStatus{
+			Name:                  target.Name,
+			IncludeWhenEvaluating: true,
+			State:                 &unstructured.Unstructured{Object: v},
+		})
+	}
+	celCtx := custom_cel.BuildCELContext(ts, nil, nil, t)
+	for _, cv := range cTTL.Spec.ExtraContext {
+		v, ok := values[cv.Name].(string)
+		if !ok {
+			return nil, fmt.Errorf("context is missing the string value of %q", cv.Name)
+		}
+		celCtx[cv.Name] = v
+	}
+	for _, name := range []string{"history", "previous"} {
+		if v, ok := values[name]; ok && slices.Contains(custom_cel.ReservedNames(cTTL), name) {
+			celCtx[name] = v
+		}
+	}
+	return celCtx, nil
+}
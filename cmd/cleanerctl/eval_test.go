@@ -0,0 +1,124 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const podContext = `
+pod:
+  metadata:
+    name: job-pod
+    creationTimestamp: "2022-01-01T00:00:00Z"
+  status:
+    phase: Succeeded
+    containerStatuses:
+    - restartCount: 2
+`
+
+const manifest = `
+apiVersion: cleaner.vtex.io/v1alpha1
+kind: ConditionalTTL
+metadata:
+  name: job
+spec:
+  ttl: 1h
+  targets:
+  - name: pod
+    includeWhenEvaluating: true
+    reference:
+      apiVersion: v1
+      kind: Pod
+      name: job-pod
+  extraContext:
+  - name: cleanup
+    configMapKeyRef:
+      name: flags
+      key: cleanup
+  conditions:
+  - pod.status.phase == "Succeeded"
+  - cleanup == "true"
+`
+
+func Test_runEval(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), "cttl.yaml")
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		args       []string
+		stdin      string
+		wantCode   int
+		wantOutput string
+	}{
+		"met": {
+			args:       []string{"-c", `pod.status.phase == "Succeeded"`, "-c", `pod.status.containerStatuses.all(s, s.restartCount == 2)`},
+			stdin:      podContext,
+			wantCode:   exitMet,
+			wantOutput: "condition 1: met=true",
+		},
+		"not met": {
+			args:       []string{"-c", `pod.status.phase == "Failed"`},
+			stdin:      podContext,
+			wantCode:   exitNotMet,
+			wantOutput: "WaitingForConditions",
+		},
+		"custom functions": {
+			args:     []string{"-c", `[pod].sort_by(p, p.metadata.creationTimestamp)[0].metadata.name == "job-pod"`, "-time", "2022-01-01T01:00:00Z", "-c", `time - timestamp(pod.metadata.creationTimestamp) == duration("1h")`},
+			stdin:    podContext,
+			wantCode: exitMet,
+		},
+		"manifest": {
+			args:     []string{"-f", manifestFile},
+			stdin:    podContext + "cleanup: \"true\"\n",
+			wantCode: exitMet,
+		},
+		"manifest with missing extra context": {
+			args:     []string{"-f", manifestFile},
+			stdin:    podContext,
+			wantCode: exitError,
+		},
+		"compile error": {
+			args:     []string{"-c", `pod.status.phase ==`},
+			stdin:    podContext,
+			wantCode: exitError,
+		},
+		"no conditions": {
+			stdin:    podContext,
+			wantCode: exitError,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(append([]string{"eval"}, tc.args...), strings.NewReader(tc.stdin), &stdout, &stderr)
+			if code != tc.wantCode {
+				t.Fatalf("got exit code %d, want %d\nstdout: %s\nstderr: %s", code, tc.wantCode, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.wantOutput) {
+				t.Errorf("expected output to contain %q, got %s", tc.wantOutput, stdout.String())
+			}
+		})
+	}
+}
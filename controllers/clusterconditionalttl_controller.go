@@ -0,0 +1,455 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/custom_cel"
+	"github.com/vtex/cleaner-controller/kinds"
+	"github.com/vtex/cleaner-controller/targets"
+)
+
+// clusterTargetFinalizer is added to ClusterConditionalTTLs whose targets
+// or namespaces are deleted once their conditions are met.
+const clusterTargetFinalizer = "cleaner.vtex.io/target-finalizer"
+
+// ClusterConditionalTTLReconciler reconciles a ClusterConditionalTTL object.
+// It shares the client and settings of the ConditionalTTL reconciler.
+type ClusterConditionalTTLReconciler struct {
+	*ConditionalTTLReconciler
+}
+
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=clusterconditionalttls,verbs=get;list;watch;update;patch;delete
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=clusterconditionalttls/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=clusterconditionalttls/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;delete
+
+func (r *ClusterConditionalTTLReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	ccTTL := &cleanerv1alpha1.ClusterConditionalTTL{}
+	if err := r.Get(ctx, req.NamespacedName, ccTTL); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log = log.WithValues("generation", ccTTL.GetGeneration())
+	ctx = ctrl.LoggerInto(ctx, log)
+	statusBase := ccTTL.DeepCopy()
+
+	if !ccTTL.DeletionTimestamp.IsZero() {
+		return r.finalizeCluster(ctx, ccTTL)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(ccTTL.Spec.NamespaceSelector)
+	if err != nil {
+		// retrying is pointless until the spec changes
+		return ctrl.Result{}, r.reportPermanentError(ctx, ccTTL, statusBase, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector, "Invalid namespace selector: "+err.Error())
+	}
+	if ccTTL.Spec.NamespaceSelector == nil {
+		// a nil selector would otherwise match nothing
+		return ctrl.Result{}, r.reportPermanentError(ctx, ccTTL, statusBase, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector, "Invalid namespace selector: namespaceSelector is required")
+	}
+	if s := ccTTL.Spec.NamespaceSelector; len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0 {
+		// an empty selector would otherwise match every namespace
+		return ctrl.Result{}, r.reportPermanentError(ctx, ccTTL, statusBase, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector, "Invalid namespace selector: matchLabels or matchExpressions must be set")
+	}
+
+	if err := r.resolver().CheckKinds(ccTTL.Spec.Targets); err != nil {
+		var unknown *kinds.UnknownKindError
+		reason := cleanerv1alpha1.ConditionReasonUnknownKind
+		if !errors.As(err, &unknown) {
+			// the kinds are ambiguous
+			reason = cleanerv1alpha1.ConditionReasonInvalidTargetReference
+		}
+		log.Info("Targets reference invalid kinds", "error", err.Error())
+		if err := r.reportPermanentError(ctx, ccTTL, statusBase, reason, "Invalid target kinds: "+joinedErrorsMessage(err)); err != nil {
+			return ctrl.Result{}, err
+		}
+		// checked again once the kinds' mapping may be refreshed when it
+		// was too recently to pick up new kinds
+		return ctrl.Result{RequeueAfter: kinds.RetryAfter(err)}, nil
+	}
+
+	t := r.now()
+	expiresAt := ccTTL.CreationTimestamp.Time
+	if ccTTL.Spec.TTL != nil {
+		expiresAt = expiresAt.Add(ccTTL.Spec.TTL.Duration)
+	}
+	ccTTL.Status.ExpiresAt = &metav1.Time{Time: expiresAt}
+	log = log.WithValues("expiresAt", expiresAt.UTC())
+	ctx = ctrl.LoggerInto(ctx, log)
+	if t.Before(expiresAt) {
+		log.V(1).Info("Waiting for expiry")
+		ccTTL.Status.ExpiredAt = nil
+		apimeta.SetStatusCondition(&ccTTL.Status.Conditions, metav1.Condition{
+			Status:             metav1.ConditionUnknown,
+			Reason:             cleanerv1alpha1.ConditionReasonNotExpired,
+			Message:            "Waiting for resource to expire",
+			Type:               cleanerv1alpha1.ConditionTypeReady,
+			ObservedGeneration: ccTTL.GetGeneration(),
+		})
+		if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.jitter(r.capRequeueAfter(expiresAt.Sub(t)))}, nil
+	}
+	if ccTTL.Status.ExpiredAt == nil {
+		ccTTL.Status.ExpiredAt = &metav1.Time{Time: t}
+		r.Recorder.Eventf(ccTTL, corev1.EventTypeNormal, "Expired", "TTL expired at %s", expiresAt.UTC().Format(time.RFC3339))
+	}
+
+	namespaces, err := r.resolveNamespaces(ctx, ccTTL, selector)
+	if err != nil && allTargetErrors(err, targets.IsNotFoundYet) {
+		log.V(1).Info("Waiting for targets to be created", "error", err.Error())
+		apimeta.SetStatusCondition(&ccTTL.Status.Conditions, waitingForTargetsCondition(err, ccTTL.GetGeneration()))
+		if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.jitter(missingTargetsRetryPeriod(ccTTL.Spec.Retry))}, nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to resolve target")
+		reason, permanent := targets.ErrorReason(err)
+		message := "Error resolving targets: " + joinedErrorsMessage(err)
+		if permanent {
+			return ctrl.Result{}, r.reportPermanentError(ctx, ccTTL, statusBase, reason, message)
+		}
+		apimeta.SetStatusCondition(&ccTTL.Status.Conditions, metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            message,
+			Type:               cleanerv1alpha1.ConditionTypeReady,
+			ObservedGeneration: ccTTL.GetGeneration(),
+		})
+		if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		// missing targets are expected to show up eventually
+		if allTargetErrors(err, apierrors.IsNotFound) {
+			return ctrl.Result{RequeueAfter: r.jitter(missingTargetsRetryPeriod(ccTTL.Spec.Retry))}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	ccTTL.Status.Namespaces = make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		ccTTL.Status.Namespaces[i] = ns.Namespace.GetName()
+	}
+
+	readyCondition := metav1.Condition{
+		ObservedGeneration: ccTTL.GetGeneration(),
+	}
+	celCtx := custom_cel.BuildClusterCELContext(namespaces, t)
+	condsMet, retryable, results := custom_cel.EvaluateCELConditions(ctx, custom_cel.BuildClusterCELOptions(), celCtx, ccTTL.Spec.AllConditions(), ccTTL.Spec.ConditionPolicy, &readyCondition)
+	if !condsMet && retryable {
+		readyCondition.Message += fmt.Sprintf(", retrying every %s", r.retryPeriod(ccTTL.Spec.Retry))
+	}
+	apimeta.SetStatusCondition(&ccTTL.Status.Conditions, readyCondition)
+	if results != nil {
+		ccTTL.Status.ConditionResults = results
+	}
+	if !condsMet {
+		log.V(1).Info("Conditions not met", "conditionsMet", condsMet, "retryable", retryable, "reason", readyCondition.Reason)
+		if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		if retryable {
+			return ctrl.Result{RequeueAfter: r.jitter(r.retryPeriod(ccTTL.Spec.Retry))}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if w := ccTTL.Spec.DeletionWindow; w != nil {
+		open, at, err := w.Next(t)
+		if err != nil {
+			readyCondition.Status = metav1.ConditionFalse
+			readyCondition.Reason = cleanerv1alpha1.ConditionReasonInvalidDeletionWindow
+			readyCondition.Message = "Invalid deletion window: " + err.Error()
+			apimeta.SetStatusCondition(&ccTTL.Status.Conditions, readyCondition)
+			return ctrl.Result{}, r.patchClusterStatus(ctx, ccTTL, statusBase)
+		}
+		if !open {
+			log.V(1).Info("Conditions met, waiting for the deletion window", "opensAt", at.UTC())
+			readyCondition.Status = metav1.ConditionTrue
+			readyCondition.Reason = cleanerv1alpha1.ConditionReasonWaitingForWindow
+			readyCondition.Message = fmt.Sprintf("Conditions met, waiting for the deletion window opening at %s", at.Format(time.RFC3339))
+			apimeta.SetStatusCondition(&ccTTL.Status.Conditions, readyCondition)
+			if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.capRequeueAfter(at.Sub(t))}, nil
+		}
+	}
+
+	log.Info("Conditions met, starting deletion", "namespaces", len(namespaces))
+	r.Recorder.Event(ccTTL, corev1.EventTypeNormal, "ConditionsMet", "Conditions met, starting deletion")
+	ccTTL.Status.EvaluationTime = &metav1.Time{Time: t}
+	if err := r.patchClusterStatus(ctx, ccTTL, statusBase); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// as for cTTLs, the finalizer is only added once the targets should
+	// be deleted
+	if clusterDeletesTargets(ccTTL) {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := r.Get(ctx, req.NamespacedName, ccTTL); err != nil {
+				return err
+			}
+			base := ccTTL.DeepCopy()
+			if !controllerutil.AddFinalizer(ccTTL, clusterTargetFinalizer) {
+				return nil
+			}
+			return r.Patch(ctx, ccTTL, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, r.Delete(ctx, ccTTL)
+}
+
+// clusterDeletesTargets reports whether any of the ccTTL's target groups or
+// its namespaces should be deleted.
+func clusterDeletesTargets(ccTTL *cleanerv1alpha1.ClusterConditionalTTL) bool {
+	if ccTTL.Spec.DeleteNamespaces {
+		return true
+	}
+	for _, t := range ccTTL.Spec.Targets {
+		if t.Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// reportPermanentError sets the Ready condition for an error which retrying
+// can't fix until the spec or the controller's permissions change. The
+// Warning event is only emitted once per generation since such errors are
+// reported again whenever the ccTTL is reconciled.
+func (r *ClusterConditionalTTLReconciler) reportPermanentError(ctx context.Context, ccTTL, base *cleanerv1alpha1.ClusterConditionalTTL, reason, message string) error {
+	prev := apimeta.FindStatusCondition(ccTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	reported := prev != nil && prev.Reason == reason && prev.ObservedGeneration == ccTTL.GetGeneration()
+	apimeta.SetStatusCondition(&ccTTL.Status.Conditions, metav1.Condition{
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		Type:               cleanerv1alpha1.ConditionTypeReady,
+		ObservedGeneration: ccTTL.GetGeneration(),
+	})
+	if err := r.patchClusterStatus(ctx, ccTTL, base); err != nil {
+		return err
+	}
+	if !reported {
+		r.Recorder.Event(ccTTL, corev1.EventTypeWarning, reason, message)
+	}
+	return nil
+}
+
+// clusterTargetOwner describes ccTTL as the owner of its targets resolved
+// in the given namespace.
+func clusterTargetOwner(ccTTL *cleanerv1alpha1.ClusterConditionalTTL, namespace string) targets.Owner {
+	return targets.Owner{
+		Object:                     ccTTL,
+		Namespace:                  namespace,
+		AllowConditionalTTLTargets: ccTTL.Spec.AllowConditionalTTLTargets,
+		NamespacedOnly:             true,
+	}
+}
+
+// listNamespaces lists the namespaces matching selector, sorted by name,
+// leaving out those being deleted. They are read as unstructured so that
+// they can be exposed to the conditions as is.
+func listNamespaces(ctx context.Context, c client.Reader, selector labels.Selector) ([]unstructured.Unstructured, error) {
+	ul := &unstructured.UnstructuredList{}
+	ul.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := c.List(ctx, ul, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	namespaces := make([]unstructured.Unstructured, 0, len(ul.Items))
+	for _, ns := range ul.Items {
+		if ns.GetDeletionTimestamp() == nil {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].GetName() < namespaces[j].GetName()
+	})
+	return namespaces, nil
+}
+
+// resolveNamespaces resolves the ccTTL's targets in each namespace matching
+// selector. Every namespace is resolved even if some fail so that the
+// errors of all of them are returned, joined.
+func (r *ClusterConditionalTTLReconciler) resolveNamespaces(ctx context.Context, ccTTL *cleanerv1alpha1.ClusterConditionalTTL, selector labels.Selector) ([]custom_cel.NamespaceTargets, error) {
+	namespaces, err := listNamespaces(ctx, r, selector)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing namespaces: %w", err)
+	}
+	resolved := make([]custom_cel.NamespaceTargets, 0, len(namespaces))
+	var errs []error
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if r.isSystemNamespace(ns.GetName()) {
+			continue
+		}
+		ts, err := r.resolver().ResolveAll(ctx, clusterTargetOwner(ccTTL, ns.GetName()), ccTTL.Spec.Targets)
+		if err != nil {
+			// flattened so that each target's error is reported and
+			// matched on its own
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range joined.Unwrap() {
+					errs = append(errs, fmt.Errorf("namespace %q: %w", ns.GetName(), e))
+				}
+			} else {
+				errs = append(errs, fmt.Errorf("namespace %q: %w", ns.GetName(), err))
+			}
+			continue
+		}
+		resolved = append(resolved, custom_cel.NamespaceTargets{Namespace: ns, Targets: ts})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+// isSystemNamespace reports whether the namespace is one ccTTLs never
+// select, whatever their selector, since deleting it or its contents would
+// break the cluster or the controller itself.
+func (r *ClusterConditionalTTLReconciler) isSystemNamespace(name string) bool {
+	return name == metav1.NamespaceDefault || strings.HasPrefix(name, "kube-") || (r.ControllerNamespace != "" && name == r.ControllerNamespace)
+}
+
+// finalizeCluster handles the ccTTL's target finalizer by deleting its
+// target groups in every namespace recorded when its conditions were met,
+// by ascending DeletionOrder, and then the namespaces themselves when
+// requested. The finalizer is removed once all targets are confirmed to
+// be gone.
+func (r *ClusterConditionalTTLReconciler) finalizeCluster(ctx context.Context, ccTTL *cleanerv1alpha1.ClusterConditionalTTL) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(ccTTL, clusterTargetFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	err := r.deleteClusterTargets(ctx, ccTTL)
+	var rqErr *requeueError
+	if errors.As(err, &rqErr) {
+		log.FromContext(ctx).Info("Finalizer requeued", "finalizer", clusterTargetFinalizer, "reason", rqErr.reason)
+		return ctrl.Result{RequeueAfter: rqErr.after}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	base := ccTTL.DeepCopy()
+	controllerutil.RemoveFinalizer(ccTTL, clusterTargetFinalizer)
+	err = r.Patch(ctx, ccTTL, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	// the ccTTL is gone once its last finalizer is removed
+	return ctrl.Result{}, client.IgnoreNotFound(err)
+}
+
+// deleteClusterTargets deletes the ccTTL's target groups in each of its
+// namespaces like the cTTL's target finalizer, reporting the outcome on
+// the TargetsDeleted condition.
+func (r *ClusterConditionalTTLReconciler) deleteClusterTargets(ctx context.Context, ccTTL *cleanerv1alpha1.ClusterConditionalTTL) error {
+	base := ccTTL.DeepCopy()
+	owners := make([]targets.Owner, 0, len(ccTTL.Status.Namespaces))
+	for _, ns := range ccTTL.Status.Namespaces {
+		owners = append(owners, clusterTargetOwner(ccTTL, ns))
+	}
+	res := r.deleteTargetGroups(ctx, targetGroupDeletion{
+		object:        ccTTL,
+		targets:       ccTTL.Spec.Targets,
+		owners:        owners,
+		failurePolicy: ccTTL.Spec.FinalizerFailurePolicy,
+		delay:         ccTTL.Spec.DeletionDelay,
+		progress:      &ccTTL.Status.DeletionProgress,
+	})
+	if res.done() && ccTTL.Spec.DeleteNamespaces {
+		if err := r.deleteNamespaces(ctx, ccTTL); err != nil {
+			res.errs = append(res.errs, err)
+		}
+	}
+
+	condition := res.condition(ccTTL.GetGeneration())
+	apimeta.SetStatusCondition(&ccTTL.Status.Conditions, condition)
+	if err := r.patchClusterStatus(ctx, ccTTL, base); err != nil {
+		res.errs = append(res.errs, err)
+	}
+	return res.err(condition)
+}
+
+// deleteNamespaces deletes the ccTTL's namespaces without waiting for them
+// to be gone, since their remaining contents are deleted along with them.
+// Protected namespaces are skipped.
+func (r *ClusterConditionalTTLReconciler) deleteNamespaces(ctx context.Context, ccTTL *cleanerv1alpha1.ClusterConditionalTTL) error {
+	var errs []error
+	for _, name := range ccTTL.Status.Namespaces {
+		// never recorded since they aren't selected, checked again in
+		// case the status was tampered with
+		if r.isSystemNamespace(name) {
+			continue
+		}
+		ns := &corev1.Namespace{}
+		err := r.Get(ctx, client.ObjectKey{Name: name}, ns)
+		if err == nil && r.resolver().IsProtected(ns) {
+			r.Recorder.Eventf(ccTTL, corev1.EventTypeNormal, "SkippedProtected", "Namespace %s not deleted since it is protected", name)
+			continue
+		}
+		if err == nil {
+			err = r.Delete(ctx, ns)
+		}
+		switch {
+		case err == nil:
+			r.Recorder.Eventf(ccTTL, corev1.EventTypeNormal, "NamespaceDeleted", "Namespace %s deleted", name)
+		case apierrors.IsNotFound(err):
+		default:
+			r.Recorder.Eventf(ccTTL, corev1.EventTypeWarning, "DeleteNamespaceFailed", "Error deleting namespace %s: %s", name, err.Error())
+			errs = append(errs, fmt.Errorf("namespace %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// patchClusterStatus patches the ccTTL status with the changes made since
+// base was read.
+func (r *ClusterConditionalTTLReconciler) patchClusterStatus(ctx context.Context, ccTTL, base *cleanerv1alpha1.ClusterConditionalTTL) error {
+	return r.Status().Patch(ctx, ccTTL, client.MergeFrom(base))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterConditionalTTLReconciler) SetupWithManager(mgr ctrl.Manager, opts controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cleanerv1alpha1.ClusterConditionalTTL{}).
+		WithOptions(opts).
+		Complete(r)
+}
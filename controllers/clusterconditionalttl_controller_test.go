@@ -0,0 +1,282 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+func Test_reconcileClusterConditionalTTL(t *testing.T) {
+	testCases := map[string]struct {
+		conditions       []string
+		deleteNamespaces bool
+		wantDeleted      bool
+	}{
+		"conditions not met": {
+			conditions: []string{`namespaces.all(n, namespaces[n].targets.pods.items.size() == 0)`},
+		},
+		"conditions met": {
+			conditions:  []string{`namespaces.all(n, namespaces[n].namespace.metadata.labels.env == "preview")`},
+			wantDeleted: true,
+		},
+		"deletes namespaces": {
+			conditions:       []string{`namespaces.size() == 2`},
+			deleteNamespaces: true,
+			wantDeleted:      true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			preview := map[string]string{"env": "preview"}
+			objs := []client.Object{
+				newTestNamespace("preview-a", preview),
+				newTestNamespace("preview-b", preview),
+				newTestNamespace("production", map[string]string{"env": "production"}),
+				// never selected whatever their labels
+				newTestNamespace("default", preview),
+				newTestNamespace("kube-system", preview),
+				newTestNamespace("cleaner-system", preview),
+			}
+			for _, ns := range []string{"preview-a", "preview-b", "production"} {
+				pod := newTestPod("web")
+				pod.Namespace = ns
+				pod.Labels = map[string]string{"app": "web"}
+				objs = append(objs, pod)
+			}
+			ccTTL := newTestCCTTL("previews", preview)
+			ccTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodListTarget("pods", map[string]string{"app": "web"})}
+			ccTTL.Spec.Targets[0].IncludeWhenEvaluating = ptr.To(true)
+			ccTTL.Spec.Conditions = tc.conditions
+			ccTTL.Spec.DeleteNamespaces = tc.deleteNamespaces
+			// keeps the ccTTL around to be inspected once deleted
+			ccTTL.Finalizers = []string{"test/keep"}
+			r := &ClusterConditionalTTLReconciler{newTestReconciler(t, append(objs, ccTTL)...)}
+			r.ControllerNamespace = "cleaner-system"
+
+			if _, err := r.Reconcile(context.TODO(), clusterRequestFor(ccTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := &cleanerv1alpha1.ClusterConditionalTTL{}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(ccTTL), found); err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"preview-a", "preview-b"}; !reflect.DeepEqual(found.Status.Namespaces, want) {
+				t.Errorf("got namespaces %v, want %v", found.Status.Namespaces, want)
+			}
+			if !tc.wantDeleted {
+				if found.DeletionTimestamp != nil {
+					t.Fatal("expected the ccTTL not to be deleted")
+				}
+				cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonWaitingForConditions {
+					t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonWaitingForConditions)
+				}
+				return
+			}
+			if found.DeletionTimestamp == nil {
+				t.Fatal("expected the ccTTL to be deleted")
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "ConditionsMet"); got != 1 {
+				t.Errorf("got %d ConditionsMet events, want 1", got)
+			}
+
+			// the target finalizer deletes the pods of the matching
+			// namespaces only
+			if _, err := r.Reconcile(context.TODO(), clusterRequestFor(ccTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, ns := range []string{"preview-a", "preview-b", "production"} {
+				err := r.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: "web"}, &corev1.Pod{})
+				if deleted := apierrors.IsNotFound(err); deleted != (ns != "production") {
+					t.Errorf("namespace %s: got pod deleted=%v (err=%v)", ns, deleted, err)
+				}
+				err = r.Get(context.TODO(), types.NamespacedName{Name: ns}, &corev1.Namespace{})
+				if deleted := apierrors.IsNotFound(err); deleted != (tc.deleteNamespaces && ns != "production") {
+					t.Errorf("got namespace %s deleted=%v (err=%v)", ns, deleted, err)
+				}
+			}
+			for _, ns := range []string{"default", "kube-system", "cleaner-system"} {
+				if err := r.Get(context.TODO(), types.NamespacedName{Name: ns}, &corev1.Namespace{}); err != nil {
+					t.Errorf("got err=%v, want namespace %s kept", err, ns)
+				}
+			}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(ccTTL), found); err != nil {
+				t.Fatal(err)
+			}
+			if controllerutil.ContainsFinalizer(found, clusterTargetFinalizer) {
+				t.Error("expected the target finalizer to be removed")
+			}
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleted)
+			if cond == nil || cond.Status != metav1.ConditionTrue {
+				t.Errorf("got condition %v, want all targets deleted", cond)
+			}
+		})
+	}
+}
+
+func Test_reconcileClusterConditionalTTLRejectsInvalidSelector(t *testing.T) {
+	testCases := map[string]*metav1.LabelSelector{
+		"unknown operator": {MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "env",
+			Operator: "Unknown",
+		}}},
+		"empty selector": {},
+	}
+
+	for description, selector := range testCases {
+		t.Run(description, func(t *testing.T) {
+			ccTTL := newTestCCTTL("invalid", nil)
+			ccTTL.Spec.NamespaceSelector = selector
+			ccTTL.Spec.Conditions = []string{"true"}
+			ccTTL.Spec.DeleteNamespaces = true
+			r := &ClusterConditionalTTLReconciler{newTestReconciler(t, ccTTL, newTestNamespace("preview", nil))}
+
+			for i := 0; i < 2; i++ {
+				res, err := r.Reconcile(context.TODO(), clusterRequestFor(ccTTL))
+				if err != nil || res.RequeueAfter != 0 {
+					t.Fatalf("got res=%v err=%v, want no requeue", res, err)
+				}
+			}
+			found := &cleanerv1alpha1.ClusterConditionalTTL{}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(ccTTL), found); err != nil {
+				t.Fatal(err)
+			}
+			if found.DeletionTimestamp != nil || len(found.Status.Namespaces) != 0 {
+				t.Errorf("got namespaces %v, want the ccTTL not to be deleted", found.Status.Namespaces)
+			}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: "preview"}, &corev1.Namespace{}); err != nil {
+				t.Errorf("got err=%v, want the namespace kept", err)
+			}
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector {
+				t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector); got != 1 {
+				t.Errorf("got %d %s events, want 1", got, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector)
+			}
+		})
+	}
+}
+
+func Test_finalizeClusterWaitsBetweenOrders(t *testing.T) {
+	ccTTL := newTestCCTTL("delayed", map[string]string{"env": "preview"})
+	ccTTL.DeletionTimestamp = ptr.To(metav1.Now())
+	ccTTL.Finalizers = []string{clusterTargetFinalizer}
+	ccTTL.Spec.DeletionDelay = &metav1.Duration{Duration: time.Hour}
+	second := newPodTarget("second", "second-pod")
+	second.DeletionOrder = ptr.To[int32](1)
+	ccTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("first", "first-pod"), second}
+	ccTTL.Status.Namespaces = []string{"preview-a", "preview-b"}
+	objs := []client.Object{ccTTL}
+	for _, ns := range ccTTL.Status.Namespaces {
+		for _, name := range []string{"first-pod", "second-pod"} {
+			pod := newTestPod(name)
+			pod.Namespace = ns
+			objs = append(objs, pod)
+		}
+	}
+	r := &ClusterConditionalTTLReconciler{newTestReconciler(t, objs...)}
+	clock := testingclock.NewFakeClock(time.Now())
+	r.Clock = clock
+
+	res, err := r.Reconcile(context.TODO(), clusterRequestFor(ccTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != time.Hour {
+		t.Errorf("got RequeueAfter=%s, want the deletion delay", res.RequeueAfter)
+	}
+	for _, ns := range ccTTL.Status.Namespaces {
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: "first-pod"}, &corev1.Pod{})
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("namespace %s: got err=%v, want first-pod deleted", ns, err)
+		}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: "second-pod"}, &corev1.Pod{}); err != nil {
+			t.Errorf("namespace %s: got err=%v, want second-pod kept during the delay", ns, err)
+		}
+	}
+	found := &cleanerv1alpha1.ClusterConditionalTTL{}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(ccTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if p := found.Status.DeletionProgress; p == nil || p.Order != 0 {
+		t.Errorf("got deletion progress %v, want order 0 to be recorded", p)
+	}
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleted)
+	if cond == nil || !strings.Contains(cond.Message, `target "second": waiting 1h0m0s`) {
+		t.Errorf("expected the later target to be reported as waiting, got %v", cond)
+	}
+
+	clock.Step(time.Hour)
+	if _, err := r.Reconcile(context.TODO(), clusterRequestFor(ccTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, ns := range ccTTL.Status.Namespaces {
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: "second-pod"}, &corev1.Pod{})
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("namespace %s: got err=%v, want second-pod deleted after the delay", ns, err)
+		}
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(ccTTL), found); !apierrors.IsNotFound(err) {
+		t.Errorf("got err=%v, want the ccTTL gone once its targets are", err)
+	}
+}
+
+func newTestCCTTL(name string, namespaceLabels map[string]string) *cleanerv1alpha1.ClusterConditionalTTL {
+	return &cleanerv1alpha1.ClusterConditionalTTL{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: cleanerv1alpha1.ClusterConditionalTTLSpec{
+			TTL:               &metav1.Duration{Duration: 0},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: namespaceLabels},
+		},
+	}
+}
+
+func newTestNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func clusterRequestFor(ccTTL *cleanerv1alpha1.ClusterConditionalTTL) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: ccTTL.GetName()}}
+}
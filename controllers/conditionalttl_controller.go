@@ -18,15 +18,31 @@ package controllers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/vtex/cleaner-controller/custom_cel"
+	"github.com/vtex/cleaner-controller/kinds"
+	"github.com/vtex/cleaner-controller/targets"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,23 +53,147 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/lru"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
 )
 
+// cleanupNowAnnotation set to "true" on a cTTL makes it expire right away,
+// its conditions still being evaluated. The annotation is left in place,
+// being ignored once the cTTL expired.
+const cleanupNowAnnotation = "cleaner.vtex.io/cleanup-now"
+
+// finalizers are handled in the order they are declared. Each one is only
+// added to cTTLs whose spec requires it. The progress of each handler is
+// reported by a status condition of the given type, since the cTTL sticks
+// around while any finalizer remains.
 var finalizers = []struct {
-	name    string
-	handler func(*ConditionalTTLReconciler, context.Context, *cleanerv1alpha1.ConditionalTTL) error
+	name      string
+	condition string
+	handler   func(*ConditionalTTLReconciler, context.Context, *cleanerv1alpha1.ConditionalTTL) error
+	required  func(*cleanerv1alpha1.ConditionalTTL) bool
 }{
-	{name: "cleaner.vtex.io/target-finalizer", handler: (*ConditionalTTLReconciler).targetFinalizer},
-	{name: "cleaner.vtex.io/release-finalizer", handler: (*ConditionalTTLReconciler).helmReleaseFinalizer},
-	{name: "cleaner.vtex.io/cloud-event-finalizer", handler: (*ConditionalTTLReconciler).cloudEventFinalizer},
+	{
+		name:      "cleaner.vtex.io/target-finalizer",
+		condition: cleanerv1alpha1.ConditionTypeTargetsDeleting,
+		handler:   (*ConditionalTTLReconciler).targetFinalizer,
+		required:  deletesTargets,
+	},
+	{
+		name:      "cleaner.vtex.io/release-finalizer",
+		condition: cleanerv1alpha1.ConditionTypeHelmUninstalling,
+		handler:   (*ConditionalTTLReconciler).helmReleaseFinalizer,
+		required:  deletesHelmReleases,
+	},
+	{
+		name:      "cleaner.vtex.io/cloud-event-finalizer",
+		condition: cleanerv1alpha1.ConditionTypeEventDelivering,
+		handler:   (*ConditionalTTLReconciler).cloudEventFinalizer,
+		required:  sendsDeletedEvent,
+	},
+}
+
+// deletesTargets reports whether any of the cTTL's target groups should be
+// deleted.
+func deletesTargets(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	for _, t := range cTTL.Spec.Targets {
+		if t.Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// deletesHelmReleases reports whether any of the cTTL's Helm releases
+// should be deleted.
+func deletesHelmReleases(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	for _, helm := range cTTL.Spec.AllHelmReleases() {
+		if helm.Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// targetDeletionCheckPeriod is how long the target finalizer waits before
+// checking again whether deleted targets are gone.
+const targetDeletionCheckPeriod = 5 * time.Second
+
+// requeueError is returned by finalizer handlers which made progress but
+// must run again before their finalizer can be removed.
+type requeueError struct {
+	after  time.Duration
+	reason string
+}
+
+func (e *requeueError) Error() string {
+	return fmt.Sprintf("requeue after %s: %s", e.after, e.reason)
+}
+
+// DefaultMaxRequeueAfter is the longest the controller waits before
+// re-checking a cTTL which has not expired yet when MaxRequeueAfter is unset.
+const DefaultMaxRequeueAfter = time.Hour
+
+// DefaultDeleteConcurrency is how many objects of a list target are deleted
+// concurrently when DeleteConcurrency is unset.
+const DefaultDeleteConcurrency = targets.DefaultDeleteConcurrency
+
+// DefaultProtectedLabel is the label protecting targets from deletion when
+// ProtectedLabel is unset.
+const DefaultProtectedLabel = targets.DefaultProtectedLabel
+
+// DefaultForceRemoveFinalizersAfter is how long a target must be stuck in
+// deletion before its finalizers are removed when
+// Target.ForceRemoveFinalizersAfter is unset.
+const DefaultForceRemoveFinalizersAfter = targets.DefaultForceRemoveFinalizersAfter
+
+// DefaultRetryPeriod is how long the controller waits before evaluating a
+// cTTL again when it has no retry config and the reconciler's
+// DefaultRetryPeriod is unset.
+const DefaultRetryPeriod = 5 * time.Minute
+
+// DefaultMissingTargetsRetryPeriod is how long the controller waits before
+// resolving targets again when one of them is missing and the cTTL has no
+// retry config.
+const DefaultMissingTargetsRetryPeriod = 30 * time.Second
+
+// DefaultHelmTimeout is how long uninstalling a Helm release may take when
+// neither HelmConfig.Timeout nor the reconciler's HelmTimeout are set.
+const DefaultHelmTimeout = 5 * time.Minute
+
+// DefaultCloudEventTimeout is how long sending a CloudEvent, including its
+// retries, may take when the reconciler's CloudEventTimeout is unset.
+const DefaultCloudEventTimeout = 30 * time.Second
+
+// DefaultHelmDriver is the storage driver of Helm releases when the
+// reconciler's HelmDriver is unset.
+const DefaultHelmDriver = "secret"
+
+// HelmDrivers lists the supported storage drivers of Helm releases.
+var HelmDrivers = []string{"secret", "configmap", "memory"}
+
+// ValidateHelmDriver returns an error unless driver is one of HelmDrivers.
+func ValidateHelmDriver(driver string) error {
+	if !slices.Contains(HelmDrivers, driver) {
+		return fmt.Errorf("unsupported Helm driver %q, must be one of %s", driver, strings.Join(HelmDrivers, ", "))
+	}
+	return nil
 }
 
+// DefaultMaxTargetStateSize is the serialized size in bytes above which
+// the targets' state is reduced to their metadata.
+const DefaultMaxTargetStateSize = 1 << 20
+
 // ConditionalTTLReconciler reconciles a ConditionalTTL object
 type ConditionalTTLReconciler struct {
 	client.Client
@@ -66,45 +206,226 @@ type ConditionalTTLReconciler struct {
 	// HelmConfig is a pre-initialized Helm client. This is
 	// a hack to make tests work.
 	HelmConfig *action.Configuration
+
+	// HelmTimeout is how long uninstalling a Helm release may take before
+	// the reconcile gives up and retries when the cTTL's HelmConfig has no
+	// timeout. Defaults to DefaultHelmTimeout.
+	HelmTimeout time.Duration
+	// HelmDriver is the storage driver of Helm releases, one of
+	// HelmDrivers. Defaults to DefaultHelmDriver.
+	HelmDriver string
+	// CloudEventTimeout is how long sending a CloudEvent may take, including
+	// its retries, before the reconcile gives up so that the worker isn't
+	// blocked. Defaults to DefaultCloudEventTimeout.
+	CloudEventTimeout time.Duration
+	// lifecycleEvents delivers the CloudEvents of lifecycle stages other
+	// than deletion in the background, so that a sink which is down or
+	// slow doesn't hold reconciles back.
+	lifecycleEvents eventQueue
+	// helmUninstalls holds a channel receiving the uninstallResult of each
+	// uninstall still running, keyed by the release's namespace and name.
+	helmUninstalls sync.Map
+
+	// APIReader reads the metadata of targets declared with MetadataOnly
+	// directly from the API server, so that no informers are started for
+	// them. Defaults to Client.
+	APIReader client.Reader
+
+	// Clock provides the current time when computing expiry, evaluating
+	// conditions and timing deletions. Defaults to the real clock.
+	Clock clock.PassiveClock
+
+	// MaxRequeueAfter caps how long the controller waits before re-checking
+	// a cTTL which has not expired yet, so that very long TTLs are still
+	// re-evaluated periodically and expiry is robust to clock adjustments.
+	// Defaults to DefaultMaxRequeueAfter.
+	MaxRequeueAfter time.Duration
+
+	// RequeueJitter is the maximum fraction by which requeues are randomly
+	// shortened or extended so that cTTLs created at once don't all
+	// reconcile at the same time. Zero disables jitter.
+	RequeueJitter float64
+
+	// Rand is the source of the requeue jitter. Defaults to a source
+	// seeded with the current time.
+	Rand   *rand.Rand
+	randMu sync.Mutex
+
+	// helmConfigs caches the Helm configuration of each namespace.
+	helmConfigs     *lru.Cache
+	helmConfigsOnce sync.Once
+
+	// filteredCounts keeps TargetsFiltered from being recorded on every
+	// reconcile.
+	filteredCounts targets.FilteredCounts
+
+	// DefaultRetryPeriod is how long the controller waits before evaluating
+	// unmet conditions again when the cTTL has no retry config. Defaults to
+	// DefaultRetryPeriod. Missing targets are resolved again after
+	// DefaultMissingTargetsRetryPeriod instead.
+	DefaultRetryPeriod time.Duration
+
+	// DeleteConcurrency is how many objects of a list target are deleted
+	// concurrently. Defaults to DefaultDeleteConcurrency.
+	DeleteConcurrency int
+
+	// AllowForceFinalizerRemoval enables removing the finalizers of targets
+	// stuck in deletion when requested by Target.ForceRemoveFinalizers.
+	AllowForceFinalizerRemoval bool
+
+	// ProtectedLabel is the label which, set to "true", keeps a target
+	// from ever being deleted. Defaults to DefaultProtectedLabel.
+	ProtectedLabel string
+
+	// AllowCrossNamespaceHelm enables uninstalling Helm releases in a
+	// namespace other than the cTTL's, as requested by HelmConfig.Namespace.
+	AllowCrossNamespaceHelm bool
+
+	// KindChecker, when set, checks that the targets' kinds are served
+	// before the cTTL expires, so that typos are reported right away
+	// instead of once resolving the targets.
+	KindChecker *kinds.Checker
+
+	// MaxTargetStateSize caps the serialized size in bytes of the targets'
+	// state stored on the status and sent on the deletion cloud event, so
+	// that large targets don't exceed the API server's request size limit.
+	// Larger states are reduced to the objects' metadata, or dropped when
+	// even that is too large. Defaults to DefaultMaxTargetStateSize.
+	MaxTargetStateSize int
+
+	// ControllerNamespace is the namespace the controller runs in, which
+	// ClusterConditionalTTLs never select.
+	ControllerNamespace string
+
+	// TracerProvider, when set, provides the tracer of the spans started
+	// around reconciles, target resolution, condition evaluation and
+	// finalizers. Spans are no-ops otherwise.
+	TracerProvider trace.TracerProvider
 }
 
 //+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttls,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttls/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttls/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get
 
+// Reconcile reconciles a cTTL within a span.
 func (r *ConditionalTTLReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := r.tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attribute.String("k8s.namespace.name", req.Namespace),
+		attribute.String("cleaner.conditionalttl.name", req.Name),
+	))
+	res, err := r.reconcile(ctx, req)
+	endSpan(span, err)
+	return res, err
+}
+
+func (r *ConditionalTTLReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	cTTL := &cleanerv1alpha1.ConditionalTTL{}
 	if err := r.Get(ctx, req.NamespacedName, cTTL); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// the name and namespace are already included by controller-runtime
+	log = log.WithValues("generation", cTTL.GetGeneration())
+	ctx = ctrl.LoggerInto(ctx, log)
+	// status changes are patched relative to the object as it was read
+	statusBase := cTTL.DeepCopy()
 
 	// object is being deleted
 	if !cTTL.DeletionTimestamp.IsZero() {
-		for _, finalizer := range finalizers {
-			if !controllerutil.ContainsFinalizer(cTTL, finalizer.name) {
-				continue
-			}
-			if err := finalizer.handler(r, ctx, cTTL); err != nil {
-				return ctrl.Result{}, err
-			}
-			controllerutil.RemoveFinalizer(cTTL, finalizer.name)
-			if err := r.Update(ctx, cTTL); err != nil {
-				return ctrl.Result{}, err
-			}
-			// wait for next reconcile due to update above
-			// to continue handling finalizers, otherwise
-			// the reconcile after deletion throws an error
-			// and the last finalizer is run twice
-			return ctrl.Result{}, nil
+		return r.finalize(ctx, cTTL)
+	}
+
+	// kept after cleaning up, it is never evaluated again so that
+	// targets created since aren't deleted
+	if apimeta.IsStatusConditionTrue(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeCompleted) {
+		return ctrl.Result{}, nil
+	}
+	// the finalizers of a cTTL which doesn't delete itself are only added
+	// once it started cleaning up
+	if !deletesSelf(cTTL) && slices.ContainsFunc(cTTL.GetFinalizers(), isKnownFinalizer) {
+		return r.cleanUp(ctx, cTTL)
+	}
+
+	if cTTL.Spec.Suspend {
+		return r.suspend(ctx, cTTL, statusBase)
+	}
+	if apimeta.RemoveStatusCondition(&cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeSuspended) {
+		log.Info("Resumed")
+		r.Recorder.Event(cTTL, corev1.EventTypeNormal, "Resumed", "Resumed evaluating conditions")
+	}
+
+	// conditions referencing undeclared variables would only fail to
+	// compile once expired, they are reported right away instead
+	if errs := custom_cel.CheckReferences(cTTL); len(errs) > 0 {
+		err := errors.Join(errs...)
+		log.Info("Conditions reference undeclared variables", "error", err.Error())
+		readyCondition := metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             cleanerv1alpha1.ConditionReasonReferenceError,
+			Message:            "Invalid condition references: " + joinedErrorsMessage(err),
+			Type:               cleanerv1alpha1.ConditionTypeReady,
+			ObservedGeneration: cTTL.GetGeneration(),
+		}
+		prev := apimeta.FindStatusCondition(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		reported := prev != nil && prev.Reason == readyCondition.Reason && prev.ObservedGeneration == cTTL.GetGeneration()
+		apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
 		}
+		if !reported {
+			r.Recorder.Event(cTTL, corev1.EventTypeWarning, readyCondition.Reason, readyCondition.Message)
+		}
+		// retrying is pointless until the spec changes
 		return ctrl.Result{}, nil
 	}
 
-	t := time.Now()
-	expiresAt := cTTL.CreationTimestamp.Add(cTTL.Spec.TTL.Duration)
-	if !t.After(expiresAt) {
+	if err := r.resolver().CheckKinds(cTTL.Spec.Targets); err != nil {
+		var unknown *kinds.UnknownKindError
+		reason := cleanerv1alpha1.ConditionReasonUnknownKind
+		if !errors.As(err, &unknown) {
+			// the kinds are ambiguous
+			reason = cleanerv1alpha1.ConditionReasonInvalidTargetReference
+		}
+		log.Info("Targets reference invalid kinds", "error", err.Error())
+		readyCondition := metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            "Invalid target kinds: " + joinedErrorsMessage(err),
+			Type:               cleanerv1alpha1.ConditionTypeReady,
+			ObservedGeneration: cTTL.GetGeneration(),
+		}
+		prev := apimeta.FindStatusCondition(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		reported := prev != nil && prev.Reason == reason && prev.ObservedGeneration == cTTL.GetGeneration()
+		apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		if !reported {
+			r.Recorder.Event(cTTL, corev1.EventTypeWarning, reason, readyCondition.Message)
+		}
+		// the kinds are checked again whenever the cTTL is reconciled,
+		// e.g. once its spec changes, or once the kinds' mapping may be
+		// refreshed when it was too recently to pick up new kinds
+		return ctrl.Result{RequeueAfter: kinds.RetryAfter(err)}, nil
+	}
+
+	t := r.now()
+	// without a TTL the cTTL expires as soon as it is created and
+	// only its conditions gate deletion
+	expiresAt := cTTL.CreationTimestamp.Time
+	if cTTL.Spec.TTL != nil {
+		expiresAt = expiresAt.Add(cTTL.Spec.TTL.Duration)
+	}
+	cTTL.Status.ExpiresAt = &metav1.Time{Time: expiresAt}
+	log = log.WithValues("expiresAt", expiresAt.UTC())
+	ctx = ctrl.LoggerInto(ctx, log)
+	forced := cTTL.GetAnnotations()[cleanupNowAnnotation] == "true"
+	if t.Before(expiresAt) && !forced {
+		log.V(1).Info("Waiting for expiry")
+		// the TTL may have been extended after it expired
+		cTTL.Status.ExpiredAt = nil
 		readyCondition := metav1.Condition{
 			Status:             metav1.ConditionUnknown,
 			Reason:             cleanerv1alpha1.ConditionReasonNotExpired,
@@ -113,258 +434,1672 @@ func (r *ConditionalTTLReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			ObservedGeneration: cTTL.GetGeneration(),
 		}
 		apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
-		if err := r.Status().Update(ctx, cTTL); err != nil {
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.jitter(r.capRequeueAfter(expiresAt.Sub(t)))}, nil
+	}
+
+	// expiry is recorded before announcing it, so the Expired event is
+	// not repeated when a later step fails and the cTTL is retried
+	if cTTL.Status.ExpiredAt == nil {
+		cTTL.Status.ExpiredAt = &metav1.Time{Time: t}
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		statusBase = cTTL.DeepCopy()
+		if t.Before(expiresAt) {
+			log.Info("Skipping TTL as annotated", "annotation", cleanupNowAnnotation)
+			r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "ForcedCleanup", "TTL expiring at %s skipped by the %s annotation", expiresAt.UTC().Format(time.RFC3339), cleanupNowAnnotation)
+		} else {
+			r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "Expired", "TTL expired at %s", expiresAt.UTC().Format(time.RFC3339))
+		}
+		r.sendLifecycleEvent(ctx, cTTL, cleanerv1alpha1.CloudEventStageExpired, map[string]interface{}{
+			"expiresAt": metav1.NewTime(expiresAt),
+		})
+	}
+
+	// the conditions are moot once the namespaces they are about are gone,
+	// while releases in some of them only are skipped
+	var orphaned []string
+	if cTTL.Spec.OrphanPolicy == cleanerv1alpha1.OrphanPolicyComplete {
+		gone, all, err := r.goneNamespaces(ctx, cTTL)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: expiresAt.Sub(t)}, nil
+		if all {
+			log.Info("Referenced namespaces are gone, starting deletion", "referencedNamespaces", gone)
+			message := fmt.Sprintf("Namespace %s is gone, starting deletion", gone[0])
+			if len(gone) > 1 {
+				message = fmt.Sprintf("Namespaces %s are gone, starting deletion", strings.Join(gone, ", "))
+			}
+			return r.startOrphanDeletion(ctx, cTTL, statusBase, cleanerv1alpha1.ConditionReasonNamespaceGone, message, t)
+		}
+		orphaned = gone
 	}
 
 	ts, err := r.resolveTargets(ctx, cTTL)
+	if err != nil && allTargetErrors(err, targets.IsNotFoundYet) {
+		log.V(1).Info("Waiting for targets to be created", "error", err.Error())
+		cTTL.Status.Targets = ts
+		apimeta.SetStatusCondition(&cTTL.Status.Conditions, waitingForTargetsCondition(err, cTTL.GetGeneration()))
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.jitter(missingTargetsRetryPeriod(cTTL.Spec.Retry))}, nil
+	}
 	if err != nil {
 		log.Error(err, "Failed to resolve target")
+		reason, permanent := targets.ErrorReason(err)
 		readyCondition := metav1.Condition{
 			Status:             metav1.ConditionFalse,
-			Reason:             cleanerv1alpha1.ConditionReasonTargetResolveError,
-			Message:            "Error resolving targets: " + err.Error(),
+			Reason:             reason,
+			Message:            "Error resolving targets: " + joinedErrorsMessage(err),
 			Type:               cleanerv1alpha1.ConditionTypeReady,
 			ObservedGeneration: cTTL.GetGeneration(),
 		}
+		// the event is only emitted once per generation as permanent
+		// errors are reported again whenever the cTTL is reconciled
+		prev := apimeta.FindStatusCondition(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		reported := prev != nil && prev.Reason == reason && prev.ObservedGeneration == cTTL.GetGeneration()
 		apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
-		if err := r.Status().Update(ctx, cTTL); err != nil {
+		// each failing target reports its own error
+		cTTL.Status.Targets = ts
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
 			return ctrl.Result{}, err
 		}
 
+		// retrying is pointless until the spec or the controller's
+		// permissions change, the former triggering a new reconcile
+		if permanent {
+			if !reported {
+				r.Recorder.Event(cTTL, corev1.EventTypeWarning, reason, readyCondition.Message)
+			}
+			return ctrl.Result{}, nil
+		}
+
+		// missing targets are expected to show up eventually so they are
+		// retried with the user's period instead of the error backoff
 		// TODO: maybe we can carry on with deletion of the CRD
 		// if everything that should be deleted is NotFound after the TTL
+		if allTargetErrors(err, apierrors.IsNotFound) {
+			return ctrl.Result{RequeueAfter: r.jitter(missingTargetsRetryPeriod(cTTL.Spec.Retry))}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// the targets only hold errors until deletion begins
+	cTTL.Status.Targets = nil
+
+	extra, err := r.resolveExtraContext(ctx, cTTL)
+	if err != nil {
+		// the error never includes the resolved values
+		log.Error(err, "Failed to resolve extra context")
+		readyCondition := metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             cleanerv1alpha1.ConditionReasonContextResolveError,
+			Message:            "Error resolving extra context: " + err.Error(),
+			Type:               cleanerv1alpha1.ConditionTypeReady,
+			ObservedGeneration: cTTL.GetGeneration(),
+		}
+		apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, err
 	}
 
-	celCtx := custom_cel.BuildCELContext(ts, t)
+	celCtx := custom_cel.BuildCELContext(ts, cTTL.Status.History, cTTL.Status.PreviousTargets, t)
+	for name, value := range extra {
+		celCtx[name] = value
+	}
+	if helm := cTTL.Spec.Helm; helm != nil && helm.IncludeWhenEvaluating && slices.Contains(orphaned, helm.Namespace) {
+		// uninstalled along with its namespace
+		log.V(1).Info("Skipping Helm release whose namespace is gone", "release", helm.Release, "referencedNamespace", helm.Namespace)
+		celCtx[custom_cel.HelmReleaseVariable] = nil
+	} else if helm != nil && helm.IncludeWhenEvaluating {
+		rel, err := r.resolveHelmRelease(ctx, cTTL)
+		// the conditions are moot once the release they are about is gone
+		if errors.Is(err, errHelmReleaseNotFound) && helm.CompleteWhenNotFound {
+			log.Info("Helm release is gone, starting deletion", "release", helm.Release)
+			return r.startOrphanDeletion(ctx, cTTL, statusBase, cleanerv1alpha1.ConditionReasonHelmReleaseGone, fmt.Sprintf("Helm release %s is gone, starting deletion", helm.Release), t)
+		}
+		if errors.Is(err, errHelmReleaseNotFound) {
+			log.V(1).Info("Waiting for Helm release to be installed", "release", helm.Release)
+			apimeta.SetStatusCondition(&cTTL.Status.Conditions, metav1.Condition{
+				Status:             metav1.ConditionUnknown,
+				Reason:             cleanerv1alpha1.ConditionReasonWaitingForHelmRelease,
+				Message:            "Waiting for Helm release to be installed: " + err.Error(),
+				Type:               cleanerv1alpha1.ConditionTypeReady,
+				ObservedGeneration: cTTL.GetGeneration(),
+			})
+			if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.jitter(r.retryPeriod(cTTL.Spec.Retry))}, nil
+		}
+		if errors.Is(err, errHelmNamespaceNotAllowed) {
+			log.Info("Helm release in another namespace is not allowed", "release", helm.Release, "referencedNamespace", helm.Namespace)
+			readyCondition := metav1.Condition{
+				Status:             metav1.ConditionFalse,
+				Reason:             cleanerv1alpha1.ConditionReasonHelmNamespaceNotAllowed,
+				Message:            "Error resolving Helm release: " + err.Error(),
+				Type:               cleanerv1alpha1.ConditionTypeReady,
+				ObservedGeneration: cTTL.GetGeneration(),
+			}
+			prev := apimeta.FindStatusCondition(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			reported := prev != nil && prev.Reason == readyCondition.Reason && prev.ObservedGeneration == cTTL.GetGeneration()
+			apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+			if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+				return ctrl.Result{}, err
+			}
+			if !reported {
+				r.Recorder.Event(cTTL, corev1.EventTypeWarning, readyCondition.Reason, readyCondition.Message)
+			}
+			// retrying is pointless until the spec or the controller's
+			// flags change
+			return ctrl.Result{}, nil
+		}
+		if err != nil {
+			log.Error(err, "Failed to resolve Helm release")
+			apimeta.SetStatusCondition(&cTTL.Status.Conditions, metav1.Condition{
+				Status:             metav1.ConditionFalse,
+				Reason:             cleanerv1alpha1.ConditionReasonContextResolveError,
+				Message:            "Error resolving Helm release: " + err.Error(),
+				Type:               cleanerv1alpha1.ConditionTypeReady,
+				ObservedGeneration: cTTL.GetGeneration(),
+			})
+			if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, err
+		}
+		celCtx[custom_cel.HelmReleaseVariable] = rel
+	}
 	celOpts := custom_cel.BuildCELOptions(cTTL)
 
 	readyCondition := metav1.Condition{
 		ObservedGeneration: cTTL.GetGeneration(),
 	}
-	condsMet, retryable := custom_cel.EvaluateCELConditions(celOpts, celCtx, cTTL.Spec.Conditions, &readyCondition)
+	condsMet, retryable, results := custom_cel.EvaluateCELConditions(ctx, celOpts, celCtx, cTTL.Spec.AllConditions(), cTTL.Spec.ConditionPolicy, &readyCondition)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cleaner.conditions_met", condsMet))
+	if !condsMet && retryable {
+		readyCondition.Message += fmt.Sprintf(", retrying every %s", r.retryPeriod(cTTL.Spec.Retry))
+	}
 	apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+	recordEvaluationError(cTTL, readyCondition, results, t)
+	if results != nil {
+		cTTL.Status.ConditionResults = results
+		r.recordHistory(ctx, cTTL, ts, t)
+	}
+	// the previous state is only needed while conditions are evaluated
+	cTTL.Status.PreviousTargets = nil
+	if cTTL.Spec.KeepPreviousState && results != nil && !condsMet {
+		r.recordPreviousTargets(cTTL, ts)
+	}
 
 	if !condsMet {
-		if err := r.Status().Update(ctx, cTTL); err != nil {
+		log.V(1).Info("Conditions not met", "conditionsMet", condsMet, "retryable", retryable, "reason", readyCondition.Reason)
+		if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
 			return ctrl.Result{}, err
 		}
-		if retryable && cTTL.Spec.Retry != nil {
-			// TODO: admission webhook should verify Retry is not nil
-			// when conditions are used or we can set a default retry period
-			return ctrl.Result{RequeueAfter: cTTL.Spec.Retry.Period.Duration}, nil
+		if retryable {
+			return ctrl.Result{RequeueAfter: r.jitter(r.retryPeriod(cTTL.Spec.Retry))}, nil
 		}
 		return ctrl.Result{}, nil
 	}
 
+	if w := cTTL.Spec.DeletionWindow; w != nil {
+		open, at, err := w.Next(t)
+		if err != nil {
+			log.Error(err, "invalid deletion window")
+			readyCondition.Status = metav1.ConditionFalse
+			readyCondition.Reason = cleanerv1alpha1.ConditionReasonInvalidDeletionWindow
+			readyCondition.Message = "Invalid deletion window: " + err.Error()
+			apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+			// retrying won't help until the spec is fixed
+			return ctrl.Result{}, r.patchStatus(ctx, cTTL, statusBase)
+		}
+		if !open {
+			log.V(1).Info("Conditions met, waiting for the deletion window", "opensAt", at.UTC())
+			readyCondition.Status = metav1.ConditionTrue
+			readyCondition.Reason = cleanerv1alpha1.ConditionReasonWaitingForWindow
+			readyCondition.Message = fmt.Sprintf("Conditions met, waiting for the deletion window opening at %s", at.Format(time.RFC3339))
+			apimeta.SetStatusCondition(&cTTL.Status.Conditions, readyCondition)
+			if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
+				return ctrl.Result{}, err
+			}
+			// not jittered so that deletion doesn't begin late in
+			// short windows, conditions are evaluated again then
+			return ctrl.Result{RequeueAfter: r.capRequeueAfter(at.Sub(t))}, nil
+		}
+	}
+
+	log.Info("Conditions met, starting deletion", "conditionsMet", condsMet, "retryable", retryable)
+	r.Recorder.Event(cTTL, corev1.EventTypeNormal, "ConditionsMet", "Conditions met, starting deletion")
+	r.sendLifecycleEvent(ctx, cTTL, cleanerv1alpha1.CloudEventStageConditionsMet, map[string]interface{}{
+		"conditionResults": results,
+	})
+
 	// preserve targets' state when conditions were met
 	// to include in the cloudevent
-	cTTL.Status.Targets = ts
+	cTTL.Status.Targets, cTTL.Status.TargetsTruncated = r.limitTargetStates(includedTargetStates(cTTL, ts))
+	if cTTL.Status.TargetsTruncated {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "TargetStateTruncated", "Targets' state exceeds %d bytes, keeping only their metadata", r.maxTargetStateSize())
+	}
 	cTTL.Status.EvaluationTime = &metav1.Time{Time: t}
-	if err := r.Status().Update(ctx, cTTL); err != nil {
+	if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// ensure all finalizers are present.
-	// finalizers are only added once the cTTL and its targets
-	// should be deleted so that a manual deletion of cTTL
-	// does not cause the premature deletion of its targets / helm release
-	{
+	return r.startDeletion(ctx, cTTL)
+}
+
+// resolveTargets resolves the cTTL's targets within a span.
+func (r *ConditionalTTLReconciler) resolveTargets(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) ([]cleanerv1alpha1.TargetStatus, error) {
+	ctx, span := r.tracer().Start(ctx, "ResolveTargets", trace.WithAttributes(attribute.Int("cleaner.targets", len(cTTL.Spec.Targets))))
+	ts, err := r.resolver().ResolveAll(ctx, targetOwner(cTTL), cTTL.Spec.Targets)
+	endSpan(span, err)
+	return ts, err
+}
+
+// suspend records that the cTTL is suspended, neither evaluating its
+// conditions nor starting deletion until its spec changes.
+func (r *ConditionalTTLReconciler) suspend(ctx context.Context, cTTL, statusBase *cleanerv1alpha1.ConditionalTTL) (ctrl.Result, error) {
+	suspended := apimeta.IsStatusConditionTrue(cTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeSuspended)
+	changed := apimeta.SetStatusCondition(&cTTL.Status.Conditions, metav1.Condition{
+		Status:             metav1.ConditionTrue,
+		Reason:             cleanerv1alpha1.ConditionReasonSuspended,
+		Message:            "Suspended, conditions are not evaluated",
+		Type:               cleanerv1alpha1.ConditionTypeSuspended,
+		ObservedGeneration: cTTL.GetGeneration(),
+	})
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+	if !suspended {
+		log.FromContext(ctx).Info("Suspended")
+		r.Recorder.Event(cTTL, corev1.EventTypeNormal, cleanerv1alpha1.ConditionReasonSuspended, "Suspended evaluating conditions")
+	}
+	return ctrl.Result{}, r.patchStatus(ctx, cTTL, statusBase)
+}
+
+// startDeletion adds the finalizers required by the cTTL and deletes it,
+// or cleans up right away when it doesn't delete itself. Finalizers are
+// only added once the cTTL and its targets should be deleted so that a
+// manual deletion of the cTTL does not cause the premature deletion of its
+// targets / helm release.
+func (r *ConditionalTTLReconciler) startDeletion(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (ctrl.Result, error) {
+	err := r.patchFinalizers(ctx, cTTL, func(o *cleanerv1alpha1.ConditionalTTL) bool {
 		needsUpdate := false
 		for _, finalizer := range finalizers {
-			if controllerutil.ContainsFinalizer(cTTL, finalizer.name) {
-				continue
-			}
-			needsUpdate = true
-			controllerutil.AddFinalizer(cTTL, finalizer.name)
-		}
-		if needsUpdate {
-			if err := r.Update(ctx, cTTL); err != nil {
-				return ctrl.Result{}, err
+			if finalizer.required(o) && controllerutil.AddFinalizer(o, finalizer.name) {
+				needsUpdate = true
 			}
 		}
+		return needsUpdate
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !deletesSelf(cTTL) {
+		return r.cleanUp(ctx, cTTL)
 	}
+	return ctrl.Result{}, r.Delete(ctx, cTTL)
+}
 
-	if err := r.Delete(ctx, cTTL); err != nil {
+// startOrphanDeletion starts the deletion of a cTTL without evaluating its
+// conditions since what they are about is gone, recording why with an
+// event and the Ready condition.
+func (r *ConditionalTTLReconciler) startOrphanDeletion(ctx context.Context, cTTL, statusBase *cleanerv1alpha1.ConditionalTTL, reason, message string, t time.Time) (ctrl.Result, error) {
+	r.Recorder.Event(cTTL, corev1.EventTypeNormal, reason, message)
+	apimeta.SetStatusCondition(&cTTL.Status.Conditions, metav1.Condition{
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		Type:               cleanerv1alpha1.ConditionTypeReady,
+		ObservedGeneration: cTTL.GetGeneration(),
+	})
+	cTTL.Status.EvaluationTime = &metav1.Time{Time: t}
+	if err := r.patchStatus(ctx, cTTL, statusBase); err != nil {
 		return ctrl.Result{}, err
 	}
+	return r.startDeletion(ctx, cTTL)
+}
 
-	return ctrl.Result{}, nil
+func deletesSelf(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	return cTTL.Spec.DeleteSelf == nil || *cTTL.Spec.DeleteSelf
 }
 
-// resolveTarget resolves either a single target given its name or a List kind
-// given a labelSelector.
-func (r *ConditionalTTLReconciler) resolveTarget(ctx context.Context, namespace string, t *cleanerv1alpha1.Target) (runtime.Unstructured, error) {
-	log := log.FromContext(ctx)
-	gvk := schema.FromAPIVersionAndKind(t.Reference.APIVersion, t.Reference.Kind)
-	if t.Reference.Name != nil {
-		u := &unstructured.Unstructured{}
-		u.SetGroupVersionKind(gvk)
-		err := r.Get(ctx, types.NamespacedName{Name: *t.Reference.Name, Namespace: namespace}, u)
-		if err != nil {
-			return nil, err
-		}
-		return u, nil
-	}
-	// TODO: remove when we add admission webhook
-	if t.Reference.LabelSelector == nil {
-		return nil, fmt.Errorf("Target %q reference Name and LabelSelector can't both be nil", t.Name)
-	}
-	ul := &unstructured.UnstructuredList{}
-	ul.SetGroupVersionKind(gvk)
-	ls, err := metav1.LabelSelectorAsSelector(t.Reference.LabelSelector)
-	if err != nil {
-		return nil, err
+// cleanUp runs the finalizers of a cTTL which doesn't delete itself, as
+// if it were being deleted, and marks it completed once all of them were
+// removed.
+func (r *ConditionalTTLReconciler) cleanUp(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (ctrl.Result, error) {
+	result, err := r.finalize(ctx, cTTL)
+	if err != nil || slices.ContainsFunc(cTTL.GetFinalizers(), isKnownFinalizer) {
+		return result, err
 	}
-	err = r.List(ctx, ul, &client.ListOptions{
-		LabelSelector: ls,
-		Namespace:     namespace,
-	})
-	if err != nil {
-		return nil, err
+	log.FromContext(ctx).Info("Cleanup completed, keeping the ConditionalTTL")
+	base := cTTL.DeepCopy()
+	for _, conditionType := range []string{cleanerv1alpha1.ConditionTypeReady, cleanerv1alpha1.ConditionTypeCompleted} {
+		apimeta.SetStatusCondition(&cTTL.Status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionTrue,
+			Reason:             cleanerv1alpha1.ConditionReasonCompleted,
+			Message:            "Cleanup completed, the ConditionalTTL is kept since deleteSelf is false",
+			ObservedGeneration: cTTL.GetGeneration(),
+		})
 	}
-	// sanity check
-	if ul.GetContinue() != "" {
-		err = errors.New("r.List: unexpected continuation token")
-		log.Error(err, "", "gvk", gvk, "labelSelector", ls)
-		return nil, err
+	if err := r.patchStatus(ctx, cTTL, base); err != nil {
+		return ctrl.Result{}, err
 	}
-	return ul, nil
+	r.Recorder.Event(cTTL, corev1.EventTypeNormal, cleanerv1alpha1.ConditionReasonCompleted, "Cleanup completed, the ConditionalTTL is kept")
+	return ctrl.Result{}, nil
 }
 
-// resolveTargets resolves a list of cleanerv1alpha1.TargetStatus given
-// the cTTL spec.
-func (r *ConditionalTTLReconciler) resolveTargets(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) ([]cleanerv1alpha1.TargetStatus, error) {
-	ts := make([]cleanerv1alpha1.TargetStatus, len(cTTL.Spec.Targets))
-	for i, t := range cTTL.Spec.Targets {
-		ui, err := r.resolveTarget(ctx, cTTL.GetNamespace(), &t)
+// goneNamespaces returns the namespaces referenced by the cTTL, other than
+// its own, which don't exist or are being deleted, and whether that is the
+// case of every one it references. Only Helm releases may currently live
+// in another namespace.
+func (r *ConditionalTTLReconciler) goneNamespaces(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (gone []string, all bool, err error) {
+	referenced := map[string]bool{}
+	for _, helm := range cTTL.Spec.AllHelmReleases() {
+		ns := helm.Namespace
+		if ns == "" || ns == cTTL.GetNamespace() || referenced[ns] {
+			continue
+		}
+		referenced[ns] = true
+		isGone, err := r.namespaceGone(ctx, ns)
 		if err != nil {
-			return nil, fmt.Errorf("Error resolving target %q: %w", t.Name, err)
+			return nil, false, err
 		}
-		ts[i] = cleanerv1alpha1.TargetStatus{
-			Name:                  t.Name,
-			Delete:                t.Delete,
-			IncludeWhenEvaluating: t.IncludeWhenEvaluating,
-			State: &unstructured.Unstructured{
-				Object: ui.UnstructuredContent(),
-			},
+		if isGone {
+			gone = append(gone, ns)
 		}
 	}
-	return ts, nil
+	return gone, len(gone) > 0 && len(gone) == len(referenced), nil
 }
 
-// deleteTarget deletes a target and publishes events regarding what was done
-// or any errors encountered.
-func (r *ConditionalTTLReconciler) deleteTarget(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, target *unstructured.Unstructured) error {
-	err := r.Delete(ctx, target)
-	if err == nil {
-		r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "TargetDeleted", "Target %s/%s deleted", target.GetKind(), target.GetName())
-		return nil
-	}
+// namespaceGone reports whether the namespace doesn't exist or is being
+// deleted.
+func (r *ConditionalTTLReconciler) namespaceGone(ctx context.Context, name string) (bool, error) {
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: name}, ns)
 	if apierrors.IsNotFound(err) {
-		return nil
+		return true, nil
 	}
-	r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "DeleteTargetFailed", "Error deleting target %s/%s: %s", target.GetKind(), target.GetName(), err.Error())
-	return err
+	if err != nil {
+		return false, err
+	}
+	return !ns.DeletionTimestamp.IsZero(), nil
 }
 
-// targetFinalizer handles cleaner.vtex.io/target-finalizer by either deleting
-// a single target given its Name, or listing targets using a labelSelector
-// and deleting the individual items. NotFound errors are ignored.
-func (r *ConditionalTTLReconciler) targetFinalizer(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) error {
-	for _, t := range cTTL.Spec.Targets {
-		if !t.Delete {
+// finalize runs the handlers of the finalizers present on the cTTL in the
+// order they are declared, regardless of which ones were added, stopping
+// at the first one which fails or must run again. Each handler's condition
+// is set before it runs and updated with its outcome, so that a stuck
+// finalizer can be told apart from the others. Finalizers whose handlers
+// completed are removed with a single patch, along with unknown
+// cleaner.vtex.io finalizers, e.g. left behind by older versions, which
+// would otherwise block the cTTL's deletion forever.
+func (r *ConditionalTTLReconciler) finalize(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	var done []string
+	for _, f := range cTTL.GetFinalizers() {
+		if strings.HasPrefix(f, finalizerPrefix) && !isKnownFinalizer(f) {
+			log.Info("Removing unknown finalizer", "finalizer", f)
+			done = append(done, f)
+		}
+	}
+	var result ctrl.Result
+	var handlerErr error
+	for _, finalizer := range finalizers {
+		if !controllerutil.ContainsFinalizer(cTTL, finalizer.name) {
 			continue
 		}
-		ui, err := r.resolveTarget(ctx, cTTL.GetNamespace(), &t)
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-				continue
+		condition := metav1.Condition{
+			Type:               finalizer.condition,
+			Status:             metav1.ConditionTrue,
+			Reason:             cleanerv1alpha1.ConditionReasonFinalizerRunning,
+			Message:            fmt.Sprintf("Running finalizer %s", finalizer.name),
+			ObservedGeneration: cTTL.GetGeneration(),
+		}
+		// kept as is while a requeued or failed handler is retried
+		prev := apimeta.FindStatusCondition(cTTL.Status.Conditions, finalizer.condition)
+		failing := prev != nil && prev.Reason == cleanerv1alpha1.ConditionReasonFinalizerFailed
+		if prev == nil || prev.Status != metav1.ConditionTrue {
+			if err := r.setFinalizerCondition(ctx, cTTL, condition); err != nil {
+				handlerErr = err
+				break
 			}
-			return err
 		}
-		switch u := ui.(type) {
-		case *unstructured.UnstructuredList:
-			err = u.EachListItem(func(o runtime.Object) error {
-				item := o.(*unstructured.Unstructured)
-				return r.deleteTarget(ctx, cTTL, item)
-			})
-		case *unstructured.Unstructured:
-			err = r.deleteTarget(ctx, cTTL, u)
+		hctx, span := r.tracer().Start(ctx, "Finalizer", trace.WithAttributes(attribute.String("cleaner.finalizer", finalizer.name)))
+		err := finalizer.handler(r, hctx, cTTL)
+		var rqErr *requeueError
+		if errors.As(err, &rqErr) {
+			span.SetAttributes(attribute.String("cleaner.requeue_reason", rqErr.reason))
+			endSpan(span, nil)
+		} else {
+			endSpan(span, err)
 		}
-		if err != nil {
-			return err
+		switch {
+		case errors.As(err, &rqErr):
+			log.Info("Finalizer requeued", "finalizer", finalizer.name, "reason", rqErr.reason)
+			result = ctrl.Result{RequeueAfter: rqErr.after}
+			condition.Reason = cleanerv1alpha1.ConditionReasonFinalizerRequeued
+			condition.Message = rqErr.reason
+		case err != nil:
+			handlerErr = err
+			condition.Reason = cleanerv1alpha1.ConditionReasonFinalizerFailed
+			condition.Message = err.Error()
+			// only sent when the finalizer starts failing, not on retries
+			if !failing {
+				r.sendLifecycleEvent(ctx, cTTL, cleanerv1alpha1.CloudEventStageDeletionFailed, map[string]interface{}{
+					"finalizer": finalizer.name,
+					"error":     err.Error(),
+				})
+			}
+		default:
+			done = append(done, finalizer.name)
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = cleanerv1alpha1.ConditionReasonFinalizerCompleted
+			condition.Message = fmt.Sprintf("Finalizer %s completed", finalizer.name)
+		}
+		// patched before removing the finalizer since the cTTL may be gone
+		// afterwards
+		if err := r.setFinalizerCondition(ctx, cTTL, condition); err != nil && handlerErr == nil {
+			handlerErr = err
+		}
+		if err != nil || handlerErr != nil {
+			break
 		}
 	}
-	return nil
+	if len(done) > 0 {
+		err := r.patchFinalizers(ctx, cTTL, func(o *cleanerv1alpha1.ConditionalTTL) bool {
+			removed := false
+			for _, f := range done {
+				if controllerutil.RemoveFinalizer(o, f) {
+					removed = true
+				}
+			}
+			return removed
+		})
+		// the cTTL is gone once its last finalizer is removed
+		if err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, handlerErr
 }
 
-// helmReleaseFinalizer handles cleaner.vtex.io/release-finalizer by deleting
-// the Helm Release declared on the cTTL spec. NotFound errors are ignored.
-func (r *ConditionalTTLReconciler) helmReleaseFinalizer(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) error {
-	if cTTL.Spec.Helm == nil || !cTTL.Spec.Helm.Delete {
+// setFinalizerCondition sets condition on the cTTL's status, patching it
+// only when it changed.
+func (r *ConditionalTTLReconciler) setFinalizerCondition(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, condition metav1.Condition) error {
+	base := cTTL.DeepCopy()
+	if !apimeta.SetStatusCondition(&cTTL.Status.Conditions, condition) {
 		return nil
 	}
-	log := log.FromContext(ctx)
-	cfg := r.HelmConfig
-	if cfg == nil {
-		// HelmConfig should only be non-nil during tests
-		cfg = new(action.Configuration)
-		// TODO: helm driver (i.e "secret") should be configurable
-		err := cfg.Init(r.clientForNamespace(cTTL.ObjectMeta.Namespace), cTTL.ObjectMeta.Namespace, "secret", func(format string, args ...interface{}) {
-			log.V(1).Info(fmt.Sprintf(format, args...))
-		})
-		if err != nil {
-			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmSetupFailed", "Error initializing Helm client: %s", err.Error())
-			return err
+	return r.patchStatus(ctx, cTTL, base)
+}
+
+// finalizerPrefix is shared by every finalizer the controller adds.
+const finalizerPrefix = "cleaner.vtex.io/"
+
+func isKnownFinalizer(name string) bool {
+	for _, f := range finalizers {
+		if f.name == name {
+			return true
 		}
 	}
-	uninstall := action.NewUninstall(cfg)
-	// TODO: support custom options for uninstall such as Wait and DisableHooks?
-	_, err := uninstall.Run(cTTL.Spec.Helm.Release)
-	if err != nil {
-		if errors.Is(err, driver.ErrReleaseNotFound) {
+	return false
+}
+
+// patchStatus patches the cTTL status with the changes made since base
+// was read. The merge patch doesn't carry a resourceVersion so it doesn't
+// conflict with concurrent changes to other parts of the object.
+func (r *ConditionalTTLReconciler) patchStatus(ctx context.Context, cTTL, base *cleanerv1alpha1.ConditionalTTL) error {
+	return r.Status().Patch(ctx, cTTL, client.MergeFrom(base))
+}
+
+// patchFinalizers applies mutate to cTTL and patches the result using an
+// optimistic lock, since merge patches replace the whole finalizers list.
+// On conflicts cTTL is read again and mutate re-applied. mutate reports
+// whether it changed the object, skipping the patch otherwise.
+func (r *ConditionalTTLReconciler) patchFinalizers(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, mutate func(*cleanerv1alpha1.ConditionalTTL) bool) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !first {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(cTTL), cTTL); err != nil {
+				return err
+			}
+		}
+		first = false
+		base := cTTL.DeepCopy()
+		if !mutate(cTTL) {
 			return nil
 		}
-		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallFailed", "Error uninstalling Helm release %q: %s", cTTL.Spec.Helm.Release, err.Error())
-		return err
+		return r.Patch(ctx, cTTL, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// capRequeueAfter limits d to the configured MaxRequeueAfter.
+func (r *ConditionalTTLReconciler) capRequeueAfter(d time.Duration) time.Duration {
+	max := r.MaxRequeueAfter
+	if max <= 0 {
+		max = DefaultMaxRequeueAfter
 	}
-	r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "HelmReleaseUninstalled", "Helm release %q uninstalled", cTTL.Spec.Helm.Release)
-	return nil
+	if d > max {
+		return max
+	}
+	return d
 }
 
-// cloudEventFinalizer handles cleaner.vtex.io/cloud-event-finalizer by sending
+// now returns the current time according to the reconciler's Clock.
+func (r *ConditionalTTLReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// retryPeriod returns the period of the given retry config, falling back
+// to the reconciler's default when it is unset.
+func (r *ConditionalTTLReconciler) retryPeriod(retry *cleanerv1alpha1.RetryConfig) time.Duration {
+	if retry != nil && retry.Period != nil {
+		return retry.Period.Duration
+	}
+	if r.DefaultRetryPeriod <= 0 {
+		return DefaultRetryPeriod
+	}
+	return r.DefaultRetryPeriod
+}
+
+// missingTargetsRetryPeriod returns the period of the given retry config,
+// falling back to DefaultMissingTargetsRetryPeriod when it is unset.
+func missingTargetsRetryPeriod(retry *cleanerv1alpha1.RetryConfig) time.Duration {
+	if retry != nil && retry.Period != nil {
+		return retry.Period.Duration
+	}
+	return DefaultMissingTargetsRetryPeriod
+}
+
+// jitter shortens or extends d by up to RequeueJitter times d.
+func (r *ConditionalTTLReconciler) jitter(d time.Duration) time.Duration {
+	if r.RequeueJitter <= 0 || d <= 0 {
+		return d
+	}
+	r.randMu.Lock()
+	defer r.randMu.Unlock()
+	if r.Rand == nil {
+		r.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	fraction := 2*r.Rand.Float64() - 1
+	return d + time.Duration(float64(d)*r.RequeueJitter*fraction)
+}
+
+// recordPreviousTargets keeps the state of the targets included when
+// evaluating so that it is exposed as `previous` on the next evaluation.
+// Only the targets the conditions select from previous are kept, unless
+// they use it otherwise.
+func (r *ConditionalTTLReconciler) recordPreviousTargets(cTTL *cleanerv1alpha1.ConditionalTTL, ts []cleanerv1alpha1.TargetStatus) {
+	names, all := custom_cel.PreviousReferences(cTTL)
+	var included []cleanerv1alpha1.TargetStatus
+	for _, t := range includedTargetStates(cTTL, ts) {
+		if t.IncludeWhenEvaluating && t.State != nil && (all || slices.Contains(names, t.Name)) {
+			included = append(included, t)
+		}
+	}
+	previous, truncated := r.limitTargetStates(included)
+	if truncated {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "TargetStateTruncated", "Targets' state exceeds %d bytes, keeping only their metadata as the previous state", r.maxTargetStateSize())
+	}
+	cTTL.Status.PreviousTargets = previous
+}
+
+// recordHistory appends the summary of the evaluated targets to the cTTL's
+// history, keeping at most spec.historyLimit entries.
+func (r *ConditionalTTLReconciler) recordHistory(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, ts []cleanerv1alpha1.TargetStatus, t time.Time) {
+	if cTTL.Spec.HistoryLimit <= 0 {
+		cTTL.Status.History = nil
+		return
+	}
+	e, err := custom_cel.NewHistoryEntry(ts, t)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to summarize targets for history")
+		return
+	}
+	cTTL.Status.History = custom_cel.AppendHistory(cTTL.Status.History, e, int(cTTL.Spec.HistoryLimit))
+}
+
+// recordEvaluationError records an error evaluating the conditions on the
+// cTTL's status, counting consecutive failures, and clears it once they are
+// evaluated successfully. Other errors, e.g. compiling them, leave it as is.
+func recordEvaluationError(cTTL *cleanerv1alpha1.ConditionalTTL, readyCondition metav1.Condition, results []cleanerv1alpha1.ConditionResult, t time.Time) {
+	if readyCondition.Reason != cleanerv1alpha1.ConditionReasonEvaluationError {
+		if results != nil {
+			cTTL.Status.LastEvaluationError = nil
+		}
+		return
+	}
+	var failures int32
+	if e := cTTL.Status.LastEvaluationError; e != nil {
+		failures = e.ConsecutiveFailures
+	}
+	cTTL.Status.LastEvaluationError = &cleanerv1alpha1.EvaluationError{
+		Message:             readyCondition.Message,
+		Time:                metav1.Time{Time: t},
+		ConsecutiveFailures: failures + 1,
+	}
+}
+
+// resolveExtraContext reads the values of the ConfigMap and Secret keys
+// declared on the cTTL's ExtraContext. Objects are read as unstructured so
+// they are not cached by the manager. Secret values are base64 decoded and
+// must never be logged.
+func (r *ConditionalTTLReconciler) resolveExtraContext(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (map[string]string, error) {
+	values := make(map[string]string, len(cTTL.Spec.ExtraContext))
+	for _, v := range cTTL.Spec.ExtraContext {
+		var kind string
+		var sel *cleanerv1alpha1.KeySelector
+		switch {
+		case v.ConfigMapKeyRef != nil:
+			kind, sel = "ConfigMap", v.ConfigMapKeyRef
+		case v.SecretKeyRef != nil:
+			kind, sel = "Secret", v.SecretKeyRef
+		default:
+			return nil, fmt.Errorf("extra context %q must reference either a ConfigMap or a Secret key", v.Name)
+		}
+
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+		err := r.Get(ctx, types.NamespacedName{Name: sel.Name, Namespace: cTTL.GetNamespace()}, u)
+		if apierrors.IsNotFound(err) && sel.Optional {
+			values[v.Name] = ""
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extra context %q: %w", v.Name, err)
+		}
+		value, found, err := unstructured.NestedString(u.Object, "data", sel.Key)
+		if err != nil {
+			return nil, fmt.Errorf("extra context %q: %w", v.Name, err)
+		}
+		if !found {
+			if !sel.Optional {
+				return nil, fmt.Errorf("extra context %q: key %q not found on %s %q", v.Name, sel.Key, kind, sel.Name)
+			}
+			values[v.Name] = ""
+			continue
+		}
+		if kind == "Secret" {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				// don't wrap err as it may include part of the value
+				return nil, fmt.Errorf("extra context %q: key %q of Secret %q is not base64 encoded", v.Name, sel.Key, sel.Name)
+			}
+			value = string(decoded)
+		}
+		values[v.Name] = value
+	}
+	return values, nil
+}
+
+// resolver returns a targets.Resolver sharing the reconciler's client and
+// settings.
+func (r *ConditionalTTLReconciler) resolver() *targets.Resolver {
+	return &targets.Resolver{
+		Client:                     r.Client,
+		APIReader:                  r.APIReader,
+		KindChecker:                r.KindChecker,
+		Recorder:                   r.Recorder,
+		Clock:                      r.Clock,
+		DeleteConcurrency:          r.DeleteConcurrency,
+		AllowForceFinalizerRemoval: r.AllowForceFinalizerRemoval,
+		ProtectedLabel:             r.ProtectedLabel,
+		FilteredCounts:             &r.filteredCounts,
+	}
+}
+
+// targetOwner describes cTTL as the owner of its targets, which are looked
+// up in its own namespace.
+func targetOwner(cTTL *cleanerv1alpha1.ConditionalTTL) targets.Owner {
+	return targets.Owner{
+		Object:                     cTTL,
+		Namespace:                  cTTL.GetNamespace(),
+		AllowConditionalTTLTargets: cTTL.Spec.AllowConditionalTTLTargets,
+		AllowedNamespaces:          cTTL.Spec.AllowedNamespaces,
+	}
+}
+
+// maxReportedErrors caps how many joined errors, e.g. of targets, are
+// included in the Ready condition's message.
+const maxReportedErrors = 5
+
+// joinedErrorsMessage formats joined errors, e.g. those returned by
+// targets.Resolver.ResolveAll, on a single line, summarizing those beyond
+// maxReportedErrors.
+func joinedErrorsMessage(err error) string {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err.Error()
+	}
+	errs := joined.Unwrap()
+	msgs := make([]string, 0, min(len(errs), maxReportedErrors+1))
+	for i, e := range errs {
+		if i == maxReportedErrors {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(errs)-i))
+			break
+		}
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// waitingForTargetsCondition returns the Ready condition reported while
+// the targets with OptionalUntilFound in err are yet to be created.
+func waitingForTargetsCondition(err error, generation int64) metav1.Condition {
+	return metav1.Condition{
+		Status:             metav1.ConditionUnknown,
+		Reason:             cleanerv1alpha1.ConditionReasonWaitingForTargets,
+		Message:            "Waiting for targets to be created: " + joinedErrorsMessage(err),
+		Type:               cleanerv1alpha1.ConditionTypeReady,
+		ObservedGeneration: generation,
+	}
+}
+
+// allTargetErrors reports whether match holds for every error joined by
+// targets.Resolver.ResolveAll.
+func allTargetErrors(err error, match func(error) bool) bool {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return match(err)
+	}
+	for _, e := range joined.Unwrap() {
+		if !match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ConditionalTTLReconciler) maxTargetStateSize() int {
+	if r.MaxTargetStateSize <= 0 {
+		return DefaultMaxTargetStateSize
+	}
+	return r.MaxTargetStateSize
+}
+
+// limitTargetStates returns targets unchanged if their serialized size is
+// within MaxTargetStateSize. Otherwise it returns a copy whose states only
+// keep each object's apiVersion, kind and metadata, and reports it. When
+// the metadata alone is still too large, the states are dropped starting
+// from the last target until the rest fits.
+func (r *ConditionalTTLReconciler) limitTargetStates(targets []cleanerv1alpha1.TargetStatus) ([]cleanerv1alpha1.TargetStatus, bool) {
+	if r.fitsTargetStateSize(targets) {
+		return targets, false
+	}
+	limited := make([]cleanerv1alpha1.TargetStatus, len(targets))
+	for i, ts := range targets {
+		limited[i] = ts
+		if ts.State != nil {
+			limited[i].State = &unstructured.Unstructured{Object: metadataOnly(ts.State.Object)}
+		}
+	}
+	for i := len(limited) - 1; i >= 0 && !r.fitsTargetStateSize(limited); i-- {
+		limited[i].State = nil
+	}
+	return limited, true
+}
+
+func (r *ConditionalTTLReconciler) fitsTargetStateSize(targets []cleanerv1alpha1.TargetStatus) bool {
+	b, err := json.Marshal(targets)
+	return err == nil && len(b) <= r.maxTargetStateSize()
+}
+
+// includedTargetStates returns a copy of ts, in the order returned by
+// targets.Resolver.ResolveAll, whose states are reduced as declared by each
+// target's StateInclusion and never include the contents of Secrets.
+func includedTargetStates(cTTL *cleanerv1alpha1.ConditionalTTL, ts []cleanerv1alpha1.TargetStatus) []cleanerv1alpha1.TargetStatus {
+	included := make([]cleanerv1alpha1.TargetStatus, len(ts))
+	for i, t := range ts {
+		included[i] = t
+		if t.State == nil {
+			continue
+		}
+		if gvk := schema.FromAPIVersionAndKind(t.APIVersion, t.Kind); gvk.Group == "" && strings.TrimSuffix(gvk.Kind, "List") == "Secret" {
+			included[i].State = &unstructured.Unstructured{Object: redactSecret(t.State.Object)}
+		}
+		switch cTTL.Spec.Targets[i].StateInclusion {
+		case cleanerv1alpha1.StateInclusionMetadataOnly:
+			included[i].State = &unstructured.Unstructured{Object: metadataOnly(included[i].State.Object)}
+		case cleanerv1alpha1.StateInclusionNone:
+			included[i].State = nil
+		}
+	}
+	return included
+}
+
+// redactSecret returns a copy of the Secret obj without its data, nor the
+// last applied configuration which may include it, applying the same to
+// each of its items if obj is a list.
+func redactSecret(obj map[string]interface{}) map[string]interface{} {
+	m := runtime.DeepCopyJSON(obj)
+	if items, ok := m["items"].([]interface{}); ok {
+		for i, item := range items {
+			if o, ok := item.(map[string]interface{}); ok {
+				items[i] = redactSecret(o)
+			}
+		}
+		return m
+	}
+	delete(m, "data")
+	delete(m, "stringData")
+	unstructured.RemoveNestedField(m, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+	return m
+}
+
+// metadataOnly returns the apiVersion, kind and metadata of obj, without
+// managedFields, applying the same to each of its items if obj is a list.
+func metadataOnly(obj map[string]interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, k := range []string{"apiVersion", "kind"} {
+		if v, ok := obj[k]; ok {
+			m[k] = v
+		}
+	}
+	if md, ok := obj["metadata"].(map[string]interface{}); ok {
+		md = runtime.DeepCopyJSON(md)
+		delete(md, "managedFields")
+		m["metadata"] = md
+	}
+	if items, ok := obj["items"].([]interface{}); ok {
+		reduced := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if o, ok := item.(map[string]interface{}); ok {
+				reduced = append(reduced, metadataOnly(o))
+			}
+		}
+		m["items"] = reduced
+	}
+	return m
+}
+
+// targetFinalizer handles cleaner.vtex.io/target-finalizer by either deleting
+// a single target given its Name, or listing targets using a labelSelector
+// and deleting the individual items. NotFound errors are ignored.
+//
+// Target groups are deleted as described on deleteTargetGroups, whose
+// outcome is reported on the TargetsDeleted condition. The finalizer is only
+// removed once all targets are confirmed to be gone.
+func (r *ConditionalTTLReconciler) targetFinalizer(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) error {
+	base := cTTL.DeepCopy()
+	res := r.deleteTargetGroups(ctx, targetGroupDeletion{
+		object:        cTTL,
+		targets:       cTTL.Spec.Targets,
+		owners:        []targets.Owner{targetOwner(cTTL)},
+		failurePolicy: cTTL.Spec.FinalizerFailurePolicy,
+		delay:         cTTL.Spec.DeletionDelay,
+		progress:      &cTTL.Status.DeletionProgress,
+	})
+	condition := res.condition(cTTL.GetGeneration())
+	apimeta.SetStatusCondition(&cTTL.Status.Conditions, condition)
+	if err := r.patchStatus(ctx, cTTL, base); err != nil {
+		res.errs = append(res.errs, err)
+	}
+	return res.err(condition)
+}
+
+// targetGroupDeletion describes the target groups of a cTTL or ccTTL to
+// be deleted by deleteTargetGroups.
+type targetGroupDeletion struct {
+	// object is the cTTL or ccTTL, on which events are recorded.
+	object client.Object
+	// targets are the owner's targets, only those with Delete set being
+	// deleted.
+	targets []cleanerv1alpha1.Target
+	// owners holds one owner per namespace the targets are deleted in.
+	owners        []targets.Owner
+	failurePolicy cleanerv1alpha1.FinalizerFailurePolicy
+	delay         *metav1.Duration
+	// progress points to the DeletionProgress on the object's status.
+	progress **cleanerv1alpha1.DeletionProgress
+}
+
+// targetGroupDeletionResult is the outcome of deleteTargetGroups.
+type targetGroupDeletionResult struct {
+	errs             []error
+	pending, skipped []string
+	requeueAfter     time.Duration
+}
+
+// done reports whether every target group is gone or was skipped.
+func (res *targetGroupDeletionResult) done() bool {
+	return len(res.errs) == 0 && len(res.pending) == 0
+}
+
+// condition returns the TargetsDeleted condition reporting the outcome.
+func (res *targetGroupDeletionResult) condition(generation int64) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               cleanerv1alpha1.ConditionTypeTargetsDeleted,
+		Status:             metav1.ConditionTrue,
+		Reason:             cleanerv1alpha1.ConditionReasonTargetsDeleted,
+		Message:            "All targets deleted",
+		ObservedGeneration: generation,
+	}
+	if !res.done() {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = cleanerv1alpha1.ConditionReasonWaitingForTargetDeletion
+		if len(res.errs) > 0 {
+			condition.Reason = cleanerv1alpha1.ConditionReasonTargetDeletionFailed
+		}
+		msgs := append(append([]string{}, res.pending...), res.skipped...)
+		for _, err := range res.errs {
+			msgs = append(msgs, err.Error())
+		}
+		condition.Message = strings.Join(msgs, "; ")
+	} else if len(res.skipped) > 0 {
+		condition.Reason = cleanerv1alpha1.ConditionReasonTargetsSkipped
+		condition.Message = strings.Join(res.skipped, "; ")
+	}
+	return condition
+}
+
+// err returns the joined errors, or a requeueError while targets are
+// pending, given the condition reporting them.
+func (res *targetGroupDeletionResult) err(condition metav1.Condition) error {
+	if len(res.errs) > 0 {
+		return errors.Join(res.errs...)
+	}
+	if len(res.pending) > 0 {
+		return &requeueError{after: res.requeueAfter, reason: condition.Message}
+	}
+	return nil
+}
+
+// deleteTargetGroups deletes the target groups of d in each of its owners'
+// namespaces, by ascending DeletionOrder, groups of a later order waiting
+// until all earlier ones are gone everywhere and then for d.delay.
+//
+// Every target group of an order is attempted even if deleting a previous
+// one failed. Every object of a list target is attempted as well, failures
+// being reported as events and aggregated on the returned errors. Target
+// groups which can't be resolved due to a permanent error, e.g. an invalid
+// label selector or missing permissions, are skipped unless
+// d.failurePolicy is Block.
+func (r *ConditionalTTLReconciler) deleteTargetGroups(ctx context.Context, d targetGroupDeletion) targetGroupDeletionResult {
+	res := targetGroupDeletionResult{requeueAfter: targetDeletionCheckPeriod}
+	groups := make([]cleanerv1alpha1.Target, 0, len(d.targets))
+	for _, t := range d.targets {
+		if t.Delete {
+			groups = append(groups, t)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return deletionOrder(&groups[i]) < deletionOrder(&groups[j])
+	})
+	for i, t := range groups {
+		if i > 0 && deletionOrder(&t) != deletionOrder(&groups[i-1]) {
+			wait := ""
+			if !res.done() {
+				wait = "waiting for earlier targets to be deleted"
+			} else if remaining := r.deletionDelayRemaining(d, deletionOrder(&groups[i-1])); remaining > 0 {
+				wait = fmt.Sprintf("waiting %s after earlier targets were deleted", d.delay.Duration)
+				res.requeueAfter = remaining
+			}
+			if wait != "" {
+				for _, w := range groups[i:] {
+					res.pending = append(res.pending, fmt.Sprintf("target %q: %s", w.Name, wait))
+				}
+				break
+			}
+		}
+		for _, owner := range d.owners {
+			target := fmt.Sprintf("target %q", t.Name)
+			if owner.Namespace != d.object.GetNamespace() {
+				// only named when the owner spans namespaces
+				target += fmt.Sprintf(" in namespace %q", owner.Namespace)
+			}
+			remaining, err := r.resolver().DeleteGroup(ctx, owner, &t)
+			if err != nil && targets.IsPermanentError(err) && d.failurePolicy != cleanerv1alpha1.FinalizerFailurePolicyBlock {
+				r.Recorder.Eventf(d.object, corev1.EventTypeWarning, "TargetSkipped", "Skipping deletion of %s: %s", target, err.Error())
+				res.skipped = append(res.skipped, fmt.Sprintf("%s skipped: %s", target, err.Error()))
+				continue
+			}
+			if err != nil {
+				res.errs = append(res.errs, fmt.Errorf("%s: %w", target, err))
+				continue
+			}
+			if remaining > 0 {
+				res.pending = append(res.pending, fmt.Sprintf("%s: %d object(s) still present", target, remaining))
+			}
+		}
+	}
+	return res
+}
+
+// deletionDelayRemaining returns how long to wait before deleting the target
+// groups following order, whose groups are all gone. The time they were
+// found to be gone is recorded on the status the first time.
+func (r *ConditionalTTLReconciler) deletionDelayRemaining(d targetGroupDeletion, order int32) time.Duration {
+	if d.delay == nil || d.delay.Duration <= 0 {
+		return 0
+	}
+	p := *d.progress
+	if p != nil && p.Order > order {
+		// a later order was already reached
+		return 0
+	}
+	if p == nil || p.Order < order {
+		p = &cleanerv1alpha1.DeletionProgress{Order: order, CompletedAt: metav1.NewTime(r.now())}
+		*d.progress = p
+	}
+	return p.CompletedAt.Add(d.delay.Duration).Sub(r.now())
+}
+
+func deletionOrder(t *cleanerv1alpha1.Target) int32 {
+	if t.DeletionOrder == nil {
+		return 0
+	}
+	return *t.DeletionOrder
+}
+
+// helmReleaseFinalizer handles cleaner.vtex.io/release-finalizer by deleting
+// the Helm releases declared on the cTTL spec. Releases which aren't found
+// or were already uninstalled are skipped, as are failed ones whose
+// failurePolicy is Continue.
+// Every release is handled even when some fail, their errors being joined.
+// The outcome of uninstalls and dry runs is recorded on the status.
+func (r *ConditionalTTLReconciler) helmReleaseFinalizer(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) error {
+	base := cTTL.DeepCopy()
+	cTTL.Status.HelmDryRuns = nil
+	var errs []error
+	for _, helm := range cTTL.Spec.AllHelmReleases() {
+		if helm.Delete {
+			errs = append(errs, r.uninstallRelease(ctx, cTTL, helm))
+		}
+	}
+	if !equality.Semantic.DeepEqual(base.Status, cTTL.Status) {
+		errs = append(errs, r.patchStatus(ctx, cTTL, base))
+	}
+	return errors.Join(errs...)
+}
+
+// uninstallRelease uninstalls a single Helm release of the cTTL, emitting
+// an event with the outcome.
+func (r *ConditionalTTLReconciler) uninstallRelease(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, helm cleanerv1alpha1.HelmConfig) error {
+	log := log.FromContext(ctx)
+	if helm.FromTarget != "" {
+		release, namespace, err := releaseFromTarget(cTTL, helm.FromTarget)
+		if err != nil {
+			// blocking deletion wouldn't help, the stored state won't change
+			log.Info("Unable to resolve Helm release from target", "target", helm.FromTarget, "error", err.Error())
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmReleaseUnresolved", "Helm release not uninstalled: %s", err.Error())
+			return nil
+		}
+		helm.Release = release
+		if namespace != "" {
+			helm.Namespace = namespace
+		}
+	}
+	namespace := cTTL.GetNamespace()
+	if ns := helm.Namespace; ns != "" && ns != namespace {
+		if why := r.disallowedHelmNamespace(cTTL, ns); why != "" {
+			log.Info("Ignoring Helm release in another namespace since it is not allowed", "release", helm.Release, "namespace", ns, "reason", why)
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmNamespaceNotAllowed", "Helm release %q in namespace %q not uninstalled: %s", helm.Release, ns, why)
+			return nil
+		}
+		namespace = ns
+		if cTTL.Spec.OrphanPolicy == cleanerv1alpha1.OrphanPolicyComplete {
+			gone, err := r.namespaceGone(ctx, namespace)
+			if err != nil {
+				return err
+			}
+			if gone {
+				// the release is uninstalled along with its namespace
+				r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "HelmNamespaceGone", "Helm release %q not uninstalled since namespace %q is gone", helm.Release, namespace)
+				return nil
+			}
+		}
+	}
+	cfg, err := r.helmActionConfig(namespace)
+	if err != nil {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmSetupFailed", "Error initializing Helm client: %s", err.Error())
+		return err
+	}
+	if helm.DryRun {
+		return r.dryRunUninstall(ctx, cTTL, cfg, namespace, helm.Release)
+	}
+	timeout := r.helmTimeout(helm)
+	uninstall := action.NewUninstall(cfg)
+	uninstall.Timeout = timeout
+	// TODO: support custom options for uninstall such as Wait and DisableHooks?
+	res, err := r.uninstallHelmRelease(ctx, uninstall, namespace, helm.Release, timeout)
+	r.recordHelmUninstall(cTTL, res)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallTimeout", "Uninstalling Helm release %q did not finish within %s, retrying", helm.Release, timeout)
+			return err
+		}
+		switch classifyHelmError(err) {
+		case helmErrorNotFound:
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallSkipped", "Helm release %q not found, skipping: %s", helm.Release, err.Error())
+			return nil
+		case helmErrorAlreadyUninstalled:
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallSkipped", "Helm release %q already uninstalled, skipping: %s", helm.Release, err.Error())
+			return nil
+		}
+		if helm.FailurePolicy == cleanerv1alpha1.HelmFailurePolicyContinue {
+			log.Info("Skipping Helm release which failed to uninstall", "release", helm.Release, "namespace", namespace, "error", err.Error())
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallSkipped", "Error uninstalling Helm release %q, continuing since its failurePolicy is Continue: %s", helm.Release, err.Error())
+			return nil
+		}
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallFailed", "Error uninstalling Helm release %q: %s", helm.Release, err.Error())
+		return err
+	}
+	r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "HelmReleaseUninstalled", "Helm release %q uninstalled", helm.Release)
+	return nil
+}
+
+// helmErrorClass classifies the errors of Helm uninstalls.
+type helmErrorClass int
+
+const (
+	// helmErrorFailed is any error not known to be benign.
+	helmErrorFailed helmErrorClass = iota
+	// helmErrorNotFound means the release isn't installed, e.g. since it
+	// was uninstalled by someone else.
+	helmErrorNotFound
+	// helmErrorAlreadyUninstalled means the release was uninstalled
+	// keeping its history.
+	helmErrorAlreadyUninstalled
+)
+
+// classifyHelmError classifies err, returned by a Helm uninstall. Helm
+// mostly wraps its errors with github.com/pkg/errors or formats them anew,
+// so those not matched by errors.Is and errors.As are matched by message.
+func classifyHelmError(err error) helmErrorClass {
+	if errors.Is(err, driver.ErrReleaseNotFound) || errors.Is(err, driver.ErrNoDeployedReleases) || apierrors.IsNotFound(err) {
+		return helmErrorNotFound
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "already deleted"), strings.Contains(msg, "already uninstalled"):
+		return helmErrorAlreadyUninstalled
+	case strings.Contains(msg, driver.ErrReleaseNotFound.Error()), strings.Contains(msg, "no release provided"):
+		return helmErrorNotFound
+	}
+	return helmErrorFailed
+}
+
+// maxDryRunEventResources is how many resources of a dry run are listed by
+// its event, all of them being recorded on the status.
+const maxDryRunEventResources = 10
+
+// dryRunUninstall records the resources uninstalling the release would
+// remove on the cTTL status and in an event, leaving the release
+// installed.
+func (r *ConditionalTTLReconciler) dryRunUninstall(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, cfg *action.Configuration, namespace, release string) error {
+	uninstall := action.NewUninstall(cfg)
+	uninstall.DryRun = true
+	res, err := uninstall.Run(release)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil
+	}
+	if err != nil {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "HelmUninstallFailed", "Error uninstalling Helm release %q in dry run: %s", release, err.Error())
+		return err
+	}
+	var resources []string
+	if res != nil && res.Release != nil {
+		resources = manifestResources(res.Release.Manifest)
+	}
+	log.FromContext(ctx).Info("Helm release uninstall dry run", "release", release, "namespace", namespace, "resources", resources)
+	cTTL.Status.HelmDryRuns = append(cTTL.Status.HelmDryRuns, cleanerv1alpha1.HelmDryRun{
+		Release:   release,
+		Namespace: namespace,
+		Resources: resources,
+	})
+	listed := resources
+	if len(listed) > maxDryRunEventResources {
+		listed = append(slices.Clip(listed[:maxDryRunEventResources]), fmt.Sprintf("and %d more", len(resources)-maxDryRunEventResources))
+	}
+	r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "HelmUninstallDryRun", "Helm release %q would be uninstalled, removing %d resource(s): %s", release, len(resources), strings.Join(listed, ", "))
+	return nil
+}
+
+// manifestResources lists the resources of a release manifest as
+// `Kind/name`, sorted. Documents which can't be parsed are skipped.
+func manifestResources(manifest string) []string {
+	var resources []string
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var m struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &m); err != nil || m.Kind == "" {
+			continue
+		}
+		resources = append(resources, m.Kind+"/"+m.Metadata.Name)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// Helm's annotations identifying the release an object belongs to.
+const (
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// releaseFromTarget returns the Helm release annotated on the state of the
+// named target stored when deletion began, using the first annotated item
+// of list targets.
+func releaseFromTarget(cTTL *cleanerv1alpha1.ConditionalTTL, name string) (release, namespace string, err error) {
+	i := slices.IndexFunc(cTTL.Status.Targets, func(ts cleanerv1alpha1.TargetStatus) bool { return ts.Name == name })
+	if i < 0 || cTTL.Status.Targets[i].State == nil {
+		return "", "", fmt.Errorf("target %q has no stored state", name)
+	}
+	state := cTTL.Status.Targets[i].State
+	objs := []unstructured.Unstructured{*state}
+	if state.IsList() {
+		list, err := state.ToList()
+		if err != nil {
+			return "", "", err
+		}
+		objs = list.Items
+	}
+	for _, o := range objs {
+		annotations := o.GetAnnotations()
+		if release := annotations[helmReleaseNameAnnotation]; release != "" {
+			return release, annotations[helmReleaseNamespaceAnnotation], nil
+		}
+	}
+	return "", "", fmt.Errorf("target %q has no %s annotation", name, helmReleaseNameAnnotation)
+}
+
+// maxHelmConfigs caps how many namespaces' Helm configurations are cached,
+// the least recently used ones being dropped first.
+const maxHelmConfigs = 64
+
+// helmActionConfig returns the Helm configuration for releases in
+// namespace, which is cached along with its client and discovery so that
+// they aren't built again on every reconcile.
+func (r *ConditionalTTLReconciler) helmActionConfig(namespace string) (*action.Configuration, error) {
+	// HelmConfig should only be non-nil during tests
+	if r.HelmConfig != nil {
+		return r.HelmConfig, nil
+	}
+	r.helmConfigsOnce.Do(func() {
+		r.helmConfigs = lru.New(maxHelmConfigs)
+	})
+	if cfg, ok := r.helmConfigs.Get(namespace); ok {
+		return cfg.(*action.Configuration), nil
+	}
+	// shared by every cTTL with releases in namespace
+	log := log.Log.WithName("helm").WithValues("namespace", namespace)
+	cfg := new(action.Configuration)
+	err := cfg.Init(r.clientForNamespace(namespace), namespace, r.helmDriver(), func(format string, args ...interface{}) {
+		log.V(1).Info(fmt.Sprintf(format, args...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.helmConfigs.Add(namespace, cfg)
+	return cfg, nil
+}
+
+// errHelmReleaseNotFound is returned by resolveHelmRelease when the release
+// included when evaluating isn't installed and isn't optional.
+var errHelmReleaseNotFound = errors.New("Helm release not found")
+
+// errHelmNamespaceNotAllowed is returned by resolveHelmRelease when the
+// release included when evaluating is in another namespace the cTTL may
+// not reach, see disallowedHelmNamespace.
+var errHelmNamespaceNotAllowed = errors.New("Helm release namespace not allowed")
+
+// disallowedHelmNamespace returns why the cTTL may not reach Helm releases
+// in namespace, which isn't its own, or an empty string when it may. Such
+// releases require AllowCrossNamespaceHelm and, when the cTTL restricts
+// the namespaces it reaches, the namespace to be listed.
+func (r *ConditionalTTLReconciler) disallowedHelmNamespace(cTTL *cleanerv1alpha1.ConditionalTTL, namespace string) string {
+	if !r.AllowCrossNamespaceHelm {
+		return "releases in other namespaces are not allowed"
+	}
+	if allowed := cTTL.Spec.AllowedNamespaces; len(allowed) > 0 && !slices.Contains(allowed, namespace) {
+		return "namespace is not listed in allowedNamespaces"
+	}
+	return ""
+}
+
+// resolveHelmRelease returns the value of the `helmRelease` variable for
+// the cTTL's Helm release.
+func (r *ConditionalTTLReconciler) resolveHelmRelease(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (interface{}, error) {
+	helm := cTTL.Spec.Helm
+	namespace := cTTL.GetNamespace()
+	if ns := helm.Namespace; ns != "" && ns != namespace {
+		if why := r.disallowedHelmNamespace(cTTL, ns); why != "" {
+			return nil, fmt.Errorf("%w: %q in namespace %q, %s", errHelmNamespaceNotAllowed, helm.Release, ns, why)
+		}
+		namespace = ns
+	}
+	cfg, err := r.helmActionConfig(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing Helm client: %w", err)
+	}
+	rel, err := action.NewGet(cfg).Run(helm.Release)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		if helm.OptionalWhenEvaluating {
+			return custom_cel.HelmReleaseContext(nil), nil
+		}
+		return nil, fmt.Errorf("%w: %q in namespace %q", errHelmReleaseNotFound, helm.Release, namespace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Helm release %q: %w", helm.Release, err)
+	}
+	return custom_cel.HelmReleaseContext(rel), nil
+}
+
+func (r *ConditionalTTLReconciler) helmDriver() string {
+	if r.HelmDriver != "" {
+		return r.HelmDriver
+	}
+	return DefaultHelmDriver
+}
+
+func (r *ConditionalTTLReconciler) helmTimeout(helm cleanerv1alpha1.HelmConfig) time.Duration {
+	if t := helm.Timeout; t != nil && t.Duration > 0 {
+		return t.Duration
+	}
+	if r.HelmTimeout > 0 {
+		return r.HelmTimeout
+	}
+	return DefaultHelmTimeout
+}
+
+// uninstallHelmRelease runs uninstall in the background, since Helm doesn't
+// take a context, so that a hung uninstall doesn't block the worker for
+// longer than timeout. An uninstall of the same release still running from
+// a previous reconcile is waited on instead of starting another one.
+func (r *ConditionalTTLReconciler) uninstallHelmRelease(ctx context.Context, uninstall *action.Uninstall, namespace, name string, timeout time.Duration) (*release.UninstallReleaseResponse, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	result := make(chan uninstallResult, 1)
+	running, loaded := r.helmUninstalls.LoadOrStore(key, result)
+	if !loaded {
+		go func() {
+			res, err := uninstall.Run(name)
+			r.helmUninstalls.Delete(key)
+			result <- uninstallResult{res: res, err: err}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	select {
+	case out := <-running.(chan uninstallResult):
+		return out.res, out.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("uninstalling Helm release %q: %w", name, ctx.Err())
+	}
+}
+
+// isDeleteHook reports whether h runs when its release is uninstalled.
+func isDeleteHook(h *release.Hook) bool {
+	return slices.ContainsFunc(h.Events, func(e release.HookEvent) bool {
+		return e == release.HookPreDelete || e == release.HookPostDelete
+	})
+}
+
+// uninstallResult is the outcome of a Helm uninstall run in the background.
+type uninstallResult struct {
+	res *release.UninstallReleaseResponse
+	err error
+}
+
+// recordHelmUninstall records the uninstalled release of res on the cTTL
+// status, replacing an earlier record of the same release, e.g. from an
+// uninstall whose hooks failed.
+func (r *ConditionalTTLReconciler) recordHelmUninstall(cTTL *cleanerv1alpha1.ConditionalTTL, res *release.UninstallReleaseResponse) {
+	if res == nil || res.Release == nil {
+		return
+	}
+	rel := res.Release
+	u := cleanerv1alpha1.HelmUninstall{
+		Release:       rel.Name,
+		Namespace:     rel.Namespace,
+		Version:       rel.Version,
+		UninstalledAt: metav1.NewTime(r.now()),
+		Info:          res.Info,
+	}
+	for _, h := range rel.Hooks {
+		// hooks which failed on install or upgrade aren't the uninstall's
+		if h != nil && h.LastRun.Phase == release.HookPhaseFailed && isDeleteHook(h) {
+			u.HookFailures = append(u.HookFailures, h.Name)
+		}
+	}
+	cTTL.Status.HelmUninstalls = slices.DeleteFunc(cTTL.Status.HelmUninstalls, func(o cleanerv1alpha1.HelmUninstall) bool {
+		return o.Release == u.Release && o.Namespace == u.Namespace
+	})
+	cTTL.Status.HelmUninstalls = append(cTTL.Status.HelmUninstalls, u)
+}
+
+// Default attributes of the CloudEvent sent after deletion, overridden by
+// spec.cloudEvent.
+const (
+	defaultCloudEventType   = "conditionalTTL.deleted"
+	defaultCloudEventSource = "cleaner.vtex.io/finalizer"
+)
+
+// cloudEventTypes are the types of the CloudEvents sent at each lifecycle
+// stage.
+var cloudEventTypes = map[cleanerv1alpha1.CloudEventStage]string{
+	cleanerv1alpha1.CloudEventStageExpired:        "conditionalTTL.expired",
+	cleanerv1alpha1.CloudEventStageConditionsMet:  "conditionalTTL.conditionsMet",
+	cleanerv1alpha1.CloudEventStageDeletionFailed: "conditionalTTL.deletionFailed",
+	cleanerv1alpha1.CloudEventStageDeleted:        defaultCloudEventType,
+}
+
+// sendsDeletedEvent reports whether a CloudEvent is sent once the cTTL's
+// deletion took place.
+func sendsDeletedEvent(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	return hasCloudEventSink(cTTL) && cTTL.Spec.CloudEvent.SendsAt(cleanerv1alpha1.CloudEventStageDeleted)
+}
+
+// newCloudEvent builds the CloudEvent sent at stage with the attributes
+// configured on the cTTL spec.
+func newCloudEvent(cTTL *cleanerv1alpha1.ConditionalTTL, stage cleanerv1alpha1.CloudEventStage) cloudevents.Event {
+	e := cloudevents.NewEvent()
+	e.SetSource(defaultCloudEventSource)
+	e.SetType(cloudEventTypes[stage])
+	e.SetSubject(cTTL.GetNamespace() + "/" + cTTL.GetName())
+	if c := cTTL.Spec.CloudEvent; c != nil {
+		if c.Type != "" && stage == cleanerv1alpha1.CloudEventStageDeleted {
+			e.SetType(c.Type)
+		}
+		if c.Source != "" {
+			e.SetSource(c.Source)
+		}
+		if c.Subject != "" {
+			e.SetSubject(c.Subject)
+		}
+		for name, value := range c.Extensions {
+			e.SetExtension(name, value)
+		}
+	}
+	return e
+}
+
+// sendLifecycleEvent sends the CloudEvent of a lifecycle stage other than
+// Deleted, if selected on the cTTL spec, adding the cTTL's name and
+// namespace to data. Delivery is best effort and happens in the
+// background, failures being reported in an event without holding the cTTL
+// back.
+func (r *ConditionalTTLReconciler) sendLifecycleEvent(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL, stage cleanerv1alpha1.CloudEventStage, data map[string]interface{}) {
+	if !hasCloudEventSink(cTTL) || !cTTL.Spec.CloudEvent.SendsAt(stage) {
+		return
+	}
+	sink, err := r.cloudEventSink(ctx, cTTL)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to resolve cloud event sink", "stage", stage, "error", err.Error())
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventSinkUnresolved", "Error resolving the sink of the %s cloud event: %s", stage, err.Error())
+		return
+	}
+	header, err := r.cloudEventHeaders(ctx, cTTL)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to read cloud event headers", "stage", stage, "error", err.Error())
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventHeadersUnresolved", "Error reading the headers of the %s cloud event: %s", stage, err.Error())
+		return
+	}
+	client, err := r.cloudEventsClient(ctx, cTTL)
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to configure cloud event client", "stage", stage, "error", err.Error())
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventTLSUnresolved", "Error configuring TLS for the %s cloud event: %s", stage, err.Error())
+		return
+	}
+	e := newCloudEvent(cTTL, stage)
+	e.SetTime(r.now())
+	data["name"] = cTTL.GetName()
+	data["namespace"] = cTTL.GetNamespace()
+	e.SetData(cloudevents.ApplicationJSON, data)
+
+	// delivered once the reconcile is done, so neither its context nor the
+	// cTTL it keeps updating are shared
+	ectx := cehttp.WithCustomHeader(cloudevents.ContextWithTarget(context.WithoutCancel(ctx), sink), header)
+	ectx = withCloudEventMode(ectx, cTTL.Spec.CloudEvent)
+	obj := cTTL.DeepCopy()
+	queued := r.lifecycleEvents.push(func() {
+		ectx, cancel := context.WithTimeout(ectx, r.cloudEventTimeout())
+		defer cancel()
+		if res := client.Send(ectx, e); !cloudevents.IsACK(res) {
+			log.FromContext(ectx).Info("Failed to deliver cloud event", "stage", stage, "error", res.Error())
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, "EventDeliveryFailed", "Error delivering %s cloud event: %s", stage, res.Error())
+		}
+	})
+	if !queued {
+		log.FromContext(ctx).Info("Dropping cloud event since too many are waiting to be delivered", "stage", stage)
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventDeliveryFailed", "%s cloud event not sent since %d events are already waiting to be delivered", stage, maxQueuedEvents)
+	}
+}
+
+// cloudEventFinalizer handles cleaner.vtex.io/cloud-event-finalizer by sending
 // a CloudEvent of type conditionalTTL.deleted, from source cleaner.vtex.io/finalizer
-// to the sink configured on the cTTL spec.
+// and with the cTTL's namespace/name as subject, unless overridden, to the
+// sink configured on the cTTL spec.
 func (r *ConditionalTTLReconciler) cloudEventFinalizer(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) error {
-	if cTTL.Spec.CloudEventSink == nil {
+	if !sendsDeletedEvent(cTTL) {
 		return nil
 	}
-	e := cloudevents.NewEvent()
-	e.SetSource("cleaner.vtex.io/finalizer")
-	e.SetType("conditionalTTL.deleted")
-	e.SetTime(cTTL.Status.EvaluationTime.Time)
-	e.SetData(cloudevents.ApplicationJSON, map[string]interface{}{
+	// the status may be missing if the cTTL was deleted before it was
+	// written (or it was wiped), in which case we still send what is known
+	evaluationTime := r.now()
+	if cTTL.Status.EvaluationTime != nil {
+		evaluationTime = cTTL.Status.EvaluationTime.Time
+	}
+	targets, truncated := r.limitTargetStates(cTTL.Status.Targets)
+	if truncated {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "TargetStateTruncated", "Targets' state exceeds %d bytes, sending only their metadata", r.maxTargetStateSize())
+	}
+	if targets == nil {
+		targets = []cleanerv1alpha1.TargetStatus{}
+	}
+
+	e := newCloudEvent(cTTL, cleanerv1alpha1.CloudEventStageDeleted)
+	e.SetTime(evaluationTime)
+	data := map[string]interface{}{
 		"name":      cTTL.GetName(),
 		"namespace": cTTL.GetNamespace(),
-		"targets":   cTTL.Status.Targets,
-	})
+		"targets":   targets,
+	}
+	if len(cTTL.Status.HelmUninstalls) > 0 {
+		data["helm"] = cTTL.Status.HelmUninstalls
+	}
+	e.SetData(cloudevents.ApplicationJSON, data)
 
-	ectx := cloudevents.ContextWithTarget(ctx, *cTTL.Spec.CloudEventSink)
+	sink, err := r.cloudEventSink(ctx, cTTL)
+	if err != nil {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventSinkUnresolved", "Error resolving the sink of the deletion cloud event: %s", err.Error())
+		return err
+	}
+	// read on every attempt so that rotated values are picked up
+	header, err := r.cloudEventHeaders(ctx, cTTL)
+	if err != nil {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventHeadersUnresolved", "Error reading the headers of the deletion cloud event: %s", err.Error())
+		return err
+	}
+	client, err := r.cloudEventsClient(ctx, cTTL)
+	if err != nil {
+		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventTLSUnresolved", "Error configuring TLS for the deletion cloud event: %s", err.Error())
+		return err
+	}
+	ectx := cehttp.WithCustomHeader(cloudevents.ContextWithTarget(ctx, sink), header)
+	ectx = withCloudEventMode(ectx, cTTL.Spec.CloudEvent)
+	ectx = withCloudEventRetries(ectx, cTTL.Spec.CloudEvent)
+	ectx, cancel := context.WithTimeout(ectx, r.cloudEventTimeout())
+	defer cancel()
 	var res cloudevents.Result
 	// the condition should probably be cloudevents.IsUndelivered
 	// but there is an open issue https://github.com/cloudevents/sdk-go/issues/815
-	if res = r.CloudEventsClient.Send(ectx, e); !cloudevents.IsACK(res) {
+	if res = client.Send(ectx, e); !cloudevents.IsACK(res) {
+		base := cTTL.DeepCopy()
+		cTTL.Status.EventDeliveryAttempts += deliveryAttempts(res)
+		if err := r.patchStatus(ctx, cTTL, base); err != nil {
+			log.FromContext(ctx).Error(err, "unable to record the cloud event delivery attempts")
+		}
+		if c := cTTL.Spec.CloudEvent; c != nil && c.DeliveryPolicy == cleanerv1alpha1.CloudEventDeliveryPolicyBestEffort {
+			r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventDeliveryAbandoned", "Abandoned deletion cloud event after %d attempts: %s", cTTL.Status.EventDeliveryAttempts, res.Error())
+			return nil
+		}
 		r.Recorder.Eventf(cTTL, corev1.EventTypeWarning, "EventDeliveryFailed", "Error delivering deletion cloud event: %s", res.Error())
 		return res
 	}
-	r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "EventDelivered", "Event delivered to %q", *cTTL.Spec.CloudEventSink)
+	r.Recorder.Eventf(cTTL, corev1.EventTypeNormal, "EventDelivered", "Event delivered to %q", sink)
 	return nil
 }
 
+func (r *ConditionalTTLReconciler) cloudEventTimeout() time.Duration {
+	if r.CloudEventTimeout > 0 {
+		return r.CloudEventTimeout
+	}
+	return DefaultCloudEventTimeout
+}
+
+// defaultCloudEventBackoff is how long delivering the Deleted CloudEvent
+// waits after the first failed attempt unless configured otherwise.
+const defaultCloudEventBackoff = time.Second
+
+// withCloudEventRetries makes the CloudEvent sent with ctx be retried with
+// exponential backoff as configured by c, if at all.
+func withCloudEventRetries(ctx context.Context, c *cleanerv1alpha1.CloudEventConfig) context.Context {
+	if c == nil || c.Retry == nil || c.Retry.Attempts <= 1 {
+		return ctx
+	}
+	backoff := defaultCloudEventBackoff
+	if b := c.Retry.Backoff; b != nil && b.Duration > 0 {
+		backoff = b.Duration
+	}
+	// the sdk waits twice the period after the first attempt
+	return cloudevents.ContextWithRetriesExponentialBackoff(ctx, backoff/2, int(c.Retry.Attempts)-1)
+}
+
+// withCloudEventMode makes the CloudEvent sent with ctx be encoded in the
+// content mode configured by c.
+func withCloudEventMode(ctx context.Context, c *cleanerv1alpha1.CloudEventConfig) context.Context {
+	if c != nil && c.Mode == cleanerv1alpha1.CloudEventModeStructured {
+		return cloudevents.WithEncodingStructured(ctx)
+	}
+	return cloudevents.WithEncodingBinary(ctx)
+}
+
+// deliveryAttempts returns how many times the CloudEvent whose delivery
+// resulted in res was sent.
+func deliveryAttempts(res cloudevents.Result) int32 {
+	var retries *cehttp.RetriesResult
+	if errors.As(res, &retries) {
+		return int32(retries.Retries) + 1
+	}
+	return 1
+}
+
 // clientForNamespace builds a genericclioptions.RESTClientGetter required by
 // the Helm API
 func (r *ConditionalTTLReconciler) clientForNamespace(namespace string) *genericclioptions.ConfigFlags {
@@ -379,9 +2114,52 @@ func (r *ConditionalTTLReconciler) clientForNamespace(namespace string) *generic
 	return configFlags
 }
 
+// DefaultErrorBackoffBase is how long the controller waits before retrying
+// a failed reconcile, doubled on each consecutive failure.
+const DefaultErrorBackoffBase = 5 * time.Millisecond
+
+// DefaultErrorBackoffMax caps how long the controller waits before retrying
+// a failed reconcile.
+const DefaultErrorBackoffMax = 5 * time.Minute
+
+// ControllerOptions configures how many cTTLs are reconciled at once and how
+// failed reconciles are retried.
+type ControllerOptions struct {
+	// MaxConcurrentReconciles is how many cTTLs are reconciled at once.
+	// Defaults to the manager's setting, or 1.
+	MaxConcurrentReconciles int
+
+	// ErrorBackoffBase defaults to DefaultErrorBackoffBase.
+	ErrorBackoffBase time.Duration
+
+	// ErrorBackoffMax defaults to DefaultErrorBackoffMax.
+	ErrorBackoffMax time.Duration
+}
+
+// Build returns the controller.Options described by o. Failed reconciles
+// back off exponentially per cTTL and are limited to 10 qps overall, like
+// controller-runtime's default rate limiter.
+func (o ControllerOptions) Build() controller.Options {
+	base, max := o.ErrorBackoffBase, o.ErrorBackoffMax
+	if base <= 0 {
+		base = DefaultErrorBackoffBase
+	}
+	if max <= 0 {
+		max = DefaultErrorBackoffMax
+	}
+	return controller.Options{
+		MaxConcurrentReconciles: o.MaxConcurrentReconciles,
+		RateLimiter: workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](base, max),
+			&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		),
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
-func (r *ConditionalTTLReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *ConditionalTTLReconciler) SetupWithManager(mgr ctrl.Manager, opts controller.Options) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cleanerv1alpha1.ConditionalTTL{}).
+		WithOptions(opts).
 		Complete(r)
 }
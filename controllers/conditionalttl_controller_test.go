@@ -0,0 +1,4048 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	pkgerrors "github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"helm.sh/helm/v3/pkg/action"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/kinds"
+)
+
+func Test_reconcileLongTTLRequeuesAtCap(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cTTL := newTestCTTL("long-ttl")
+	cTTL.CreationTimestamp = metav1.NewTime(created)
+	cTTL.Spec.TTL = &metav1.Duration{Duration: 365 * 24 * time.Hour}
+	expiresAt := created.Add(cTTL.Spec.TTL.Duration)
+
+	r := newTestReconciler(t, cTTL)
+	r.MaxRequeueAfter = 30 * time.Minute
+	clock := testingclock.NewFakeClock(created)
+	r.Clock = clock
+
+	for i := 0; i < 3; i++ {
+		res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		if err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		if res.RequeueAfter != r.MaxRequeueAfter {
+			t.Errorf("reconcile %d: got RequeueAfter=%s, want %s", i, res.RequeueAfter, r.MaxRequeueAfter)
+		}
+
+		found := &cleanerv1alpha1.ConditionalTTL{}
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+			t.Fatalf("reconcile %d: cTTL should not be deleted: %s", i, err)
+		}
+		if len(found.Finalizers) != 0 {
+			t.Errorf("reconcile %d: got finalizers %v, want none", i, found.Finalizers)
+		}
+		if found.Status.ExpiresAt == nil || !found.Status.ExpiresAt.Time.Equal(expiresAt) {
+			t.Errorf("reconcile %d: got expiresAt %v, want %s", i, found.Status.ExpiresAt, expiresAt)
+		}
+		clock.Step(res.RequeueAfter)
+	}
+
+	// the last requeue before expiry is not capped
+	clock.SetTime(expiresAt.Add(-10 * time.Minute))
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != 10*time.Minute {
+		t.Errorf("got RequeueAfter=%s, want the time left until expiry", res.RequeueAfter)
+	}
+}
+
+func Test_reconcileExpiryBoundary(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		ttl         *metav1.Duration
+		now         time.Time
+		wantRequeue time.Duration
+		wantDeleted bool
+	}{
+		"without TTL": {
+			now:         created,
+			wantDeleted: true,
+		},
+		"zero TTL": {
+			ttl:         &metav1.Duration{},
+			now:         created,
+			wantDeleted: true,
+		},
+		"before expiry": {
+			ttl:         &metav1.Duration{Duration: time.Hour},
+			now:         created.Add(time.Hour - time.Second),
+			wantRequeue: time.Second,
+		},
+		"at expiry": {
+			ttl:         &metav1.Duration{Duration: time.Hour},
+			now:         created.Add(time.Hour),
+			wantDeleted: true,
+		},
+		"after expiry": {
+			ttl:         &metav1.Duration{Duration: time.Hour},
+			now:         created.Add(time.Hour + time.Second),
+			wantDeleted: true,
+		},
+		"very long TTL": {
+			ttl:         &metav1.Duration{Duration: 100 * 365 * 24 * time.Hour},
+			now:         created,
+			wantRequeue: DefaultMaxRequeueAfter,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("boundary")
+			cTTL.CreationTimestamp = metav1.NewTime(created)
+			cTTL.Spec.TTL = tc.ttl
+			r := newTestReconciler(t, cTTL)
+			r.Clock = testingclock.NewFakeClock(tc.now)
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if res.RequeueAfter != tc.wantRequeue {
+				t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, tc.wantRequeue)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if tc.wantDeleted == found.DeletionTimestamp.IsZero() {
+				t.Errorf("got deleted=%v, want %v", !found.DeletionTimestamp.IsZero(), tc.wantDeleted)
+			}
+			if tc.wantDeleted && !found.Status.EvaluationTime.Time.Equal(tc.now) {
+				t.Errorf("got evaluationTime %s, want %s", found.Status.EvaluationTime, tc.now)
+			}
+		})
+	}
+}
+
+func Test_reconcileEmitsExpiredOnce(t *testing.T) {
+	cTTL := newTestCTTL("expired-once")
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+	cTTL.Spec.Conditions = []string{"false"}
+	// the namespace lookup after expiry fails once, which must not
+	// announce the expiry again on the retry
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Namespace: "preview"}
+	cTTL.Spec.OrphanPolicy = cleanerv1alpha1.OrphanPolicyComplete
+
+	var failed atomic.Bool
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Namespace); ok && failed.CompareAndSwap(false, true) {
+				return apierrors.NewServiceUnavailable("namespace lookup failed")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}, cTTL, newTestNamespace("preview", nil))
+	r.AllowCrossNamespaceHelm = true
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected the failed namespace lookup to be returned")
+	}
+	var expiredAt *metav1.Time
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		found := getTestCTTL(t, r, cTTL)
+		if found.Status.ExpiredAt == nil {
+			t.Fatalf("reconcile %d: expected expiredAt to be set", i)
+		}
+		if expiredAt != nil && !found.Status.ExpiredAt.Equal(expiredAt) {
+			t.Errorf("reconcile %d: expiredAt changed from %s to %s", i, expiredAt, found.Status.ExpiredAt)
+		}
+		expiredAt = found.Status.ExpiredAt
+	}
+	events := drainEvents(recorder)
+	if got := countEvents(events, "Expired"); got != 1 {
+		t.Errorf("got %d Expired events, want 1: %v", got, events)
+	}
+	if got := countEvents(events, "ConditionsMet"); got != 0 {
+		t.Errorf("got %d ConditionsMet events, want 0: %v", got, events)
+	}
+
+	found := getTestCTTL(t, r, cTTL)
+	found.Spec.Conditions = []string{"true"}
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	events = drainEvents(recorder)
+	if got := countEvents(events, "Expired"); got != 0 {
+		t.Errorf("got %d Expired events after conditions were met, want 0: %v", got, events)
+	}
+	if got := countEvents(events, "ConditionsMet"); got != 1 {
+		t.Errorf("got %d ConditionsMet events, want 1: %v", got, events)
+	}
+}
+
+func Test_reconcileCleanupNow(t *testing.T) {
+	cTTL := newKeptTestCTTL("cleanup-now")
+	cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+	cTTL.Spec.Conditions = []string{"false"}
+	cTTL.Annotations = map[string]string{cleanupNowAnnotation: "true"}
+
+	r := newTestReconciler(t, cTTL)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	// conditions are still honored
+	for i := 0; i < 2; i++ {
+		res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		if err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		if res.RequeueAfter != time.Second {
+			t.Errorf("reconcile %d: got RequeueAfter=%s, want the retry period", i, res.RequeueAfter)
+		}
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonWaitingForConditions {
+		t.Fatalf("got condition %v, want conditions to be evaluated before the TTL", cond)
+	}
+	events := drainEvents(recorder)
+	if got := countEvents(events, "ForcedCleanup"); got != 1 {
+		t.Errorf("got %d ForcedCleanup events, want 1: %v", got, events)
+	}
+	if got := countEvents(events, "Expired"); got != 0 {
+		t.Errorf("got %d Expired events, want 0: %v", got, events)
+	}
+
+	found.Spec.Conditions = nil
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if found.DeletionTimestamp.IsZero() {
+		t.Error("expected the cTTL to be deleted before its TTL")
+	}
+
+	t.Run("other values", func(t *testing.T) {
+		cTTL := newTestCTTL("cleanup-later")
+		cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+		cTTL.Annotations = map[string]string{cleanupNowAnnotation: "false"}
+		r := newTestReconciler(t, cTTL)
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		found := getTestCTTL(t, r, cTTL)
+		cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonNotExpired {
+			t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonNotExpired)
+		}
+	})
+}
+
+func Test_reconcileSuspend(t *testing.T) {
+	cTTL := newKeptTestCTTL("suspended")
+	cTTL.Spec.Conditions = []string{"true"}
+	cTTL.Spec.Suspend = true
+
+	r := newTestReconciler(t, cTTL)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for i := 0; i < 2; i++ {
+		res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		if err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		if res != (ctrl.Result{}) {
+			t.Errorf("reconcile %d: got %+v, want no requeue", i, res)
+		}
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if !found.DeletionTimestamp.IsZero() {
+		t.Fatal("expected a suspended cTTL not to be deleted")
+	}
+	if !apimeta.IsStatusConditionTrue(found.Status.Conditions, cleanerv1alpha1.ConditionTypeSuspended) {
+		t.Errorf("got conditions %v, want Suspended", found.Status.Conditions)
+	}
+	if found.Status.ExpiredAt != nil || found.Status.ConditionResults != nil {
+		t.Error("expected a suspended cTTL not to be evaluated")
+	}
+	events := drainEvents(recorder)
+	if got := countEvents(events, cleanerv1alpha1.ConditionReasonSuspended); got != 1 {
+		t.Errorf("got %d Suspended events, want 1: %v", got, events)
+	}
+
+	found.Spec.Suspend = false
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if found.DeletionTimestamp.IsZero() {
+		t.Error("expected the cTTL to be deleted once resumed")
+	}
+	if apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeSuspended) != nil {
+		t.Errorf("got conditions %v, want Suspended cleared", found.Status.Conditions)
+	}
+	events = drainEvents(recorder)
+	if got := countEvents(events, "Resumed"); got != 1 {
+		t.Errorf("got %d Resumed events, want 1: %v", got, events)
+	}
+}
+
+func Test_reconcileWithoutTTL(t *testing.T) {
+	cTTL := newKeptTestCTTL("without-ttl")
+	cTTL.Spec.TTL = nil
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+	cTTL.Spec.Conditions = []string{"false"}
+
+	r := newTestReconciler(t, cTTL)
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != time.Second {
+		t.Errorf("got RequeueAfter=%s, want the retry period", res.RequeueAfter)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonWaitingForConditions {
+		t.Fatalf("got condition %v, want conditions to be evaluated right away", cond)
+	}
+
+	found.Spec.Conditions = []string{"true"}
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if found.DeletionTimestamp.IsZero() {
+		t.Error("expected the cTTL to be deleted once its conditions are met")
+	}
+}
+
+func Test_reconcileRetriesUnmetConditions(t *testing.T) {
+	testCases := map[string]struct {
+		retry         *cleanerv1alpha1.RetryConfig
+		defaultPeriod time.Duration
+		want          time.Duration
+	}{
+		"retry period": {
+			retry:         &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: 7 * time.Second}},
+			defaultPeriod: time.Minute,
+			want:          7 * time.Second,
+		},
+		"reconciler default": {
+			defaultPeriod: time.Minute,
+			want:          time.Minute,
+		},
+		"package default": {
+			want: DefaultRetryPeriod,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("unmet")
+			cTTL.Spec.Retry = tc.retry
+			cTTL.Spec.Conditions = []string{"false"}
+			r := newTestReconciler(t, cTTL)
+			r.DefaultRetryPeriod = tc.defaultPeriod
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if res.RequeueAfter != tc.want {
+				t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, tc.want)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || !strings.HasSuffix(cond.Message, "retrying every "+tc.want.String()) {
+				t.Errorf("expected the condition to report the retry period, got %v", cond)
+			}
+		})
+	}
+}
+
+func Test_reconcileWaitsForDeletionWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		window      *cleanerv1alpha1.DeletionWindow
+		wantReason  string
+		wantRequeue time.Duration
+		wantDeleted bool
+	}{
+		"closed": {
+			window:      &cleanerv1alpha1.DeletionWindow{Start: "12:30", End: "13:00"},
+			wantReason:  cleanerv1alpha1.ConditionReasonWaitingForWindow,
+			wantRequeue: 30 * time.Minute,
+		},
+		"open": {
+			window:      &cleanerv1alpha1.DeletionWindow{Start: "00:00", End: "00:00"},
+			wantDeleted: true,
+		},
+		"invalid": {
+			window:     &cleanerv1alpha1.DeletionWindow{Start: "00:00", End: "00:00", TimeZone: "Nowhere"},
+			wantReason: cleanerv1alpha1.ConditionReasonInvalidDeletionWindow,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("window")
+			cTTL.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+			cTTL.Spec.Conditions = []string{"true"}
+			cTTL.Spec.DeletionWindow = tc.window
+			r := newTestReconciler(t, cTTL)
+			r.Clock = testingclock.NewFakeClock(now)
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if res.RequeueAfter != tc.wantRequeue {
+				t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, tc.wantRequeue)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if tc.wantDeleted == found.DeletionTimestamp.IsZero() {
+				t.Errorf("got deleted=%v, want %v", !found.DeletionTimestamp.IsZero(), tc.wantDeleted)
+			}
+			if tc.wantReason == "" {
+				return
+			}
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != tc.wantReason {
+				t.Errorf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+		})
+	}
+}
+
+func Test_reconcileToleratesConcurrentChanges(t *testing.T) {
+	cTTL := newTestCTTL("concurrent-changes")
+	// require every finalizer
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "concurrent-pod")}
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true}
+	cTTL.Spec.CloudEventSink = ptr.To("http://localhost")
+
+	// addLabel simulates another actor changing the cTTL between the
+	// reconciler's read and its write
+	addLabel := func(ctx context.Context, c client.Client, key string) error {
+		o := &cleanerv1alpha1.ConditionalTTL{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(cTTL), o); err != nil {
+			return err
+		}
+		labels := o.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = "true"
+		o.SetLabels(labels)
+		return c.Update(ctx, o)
+	}
+	patches, statusPatches := 0, 0
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patches++
+			if patches == 1 {
+				if err := addLabel(ctx, c, "patched"); err != nil {
+					return err
+				}
+			}
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			statusPatches++
+			if statusPatches == 1 {
+				if err := addLabel(ctx, c, "status-patched"); err != nil {
+					return err
+				}
+			}
+			return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+		},
+	}, cTTL, newTestPod("concurrent-pod"))
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if patches < 2 {
+		t.Errorf("expected the conflicting finalizer patch to be retried, got %d patches", patches)
+	}
+
+	found := getTestCTTL(t, r, cTTL)
+	if found.DeletionTimestamp.IsZero() {
+		t.Error("expected cTTL to be deleted")
+	}
+	if len(found.Finalizers) != len(finalizers) {
+		t.Errorf("got finalizers %v, want %d", found.Finalizers, len(finalizers))
+	}
+	for _, l := range []string{"patched", "status-patched"} {
+		if _, ok := found.GetLabels()[l]; !ok {
+			t.Errorf("concurrently added label %q was lost: %v", l, found.GetLabels())
+		}
+	}
+	if found.Status.EvaluationTime == nil {
+		t.Error("expected status to be patched")
+	}
+	for _, e := range drainEvents(r.Recorder.(*record.FakeRecorder)) {
+		if strings.HasPrefix(e, corev1.EventTypeWarning) {
+			t.Errorf("unexpected warning event: %s", e)
+		}
+	}
+}
+
+func Test_targetFinalizerPartialFailure(t *testing.T) {
+	cTTL := newDeletedTestCTTL("partial-failure", "cleaner.vtex.io/target-finalizer")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{
+		newPodTarget("stuck", "stuck-pod"),
+		newPodTarget("ok", "ok-pod"),
+	}
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if obj.GetName() == "stuck-pod" {
+				return apierrors.NewInternalError(errors.New("denied by webhook"))
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, cTTL, newTestPod("stuck-pod"), newTestPod("ok-pod"))
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected an error while a target can't be deleted")
+	}
+
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "ok-pod", Namespace: "default"}, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected ok-pod to be deleted despite the previous target failing, got %v", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if !controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/target-finalizer") {
+		t.Error("target finalizer should not be removed while a target is not deleted")
+	}
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleted)
+	if cond == nil {
+		t.Fatal("expected TargetsDeleted condition")
+	}
+	if cond.Status != metav1.ConditionFalse || cond.Reason != cleanerv1alpha1.ConditionReasonTargetDeletionFailed {
+		t.Errorf("got condition %s/%s, want False/%s", cond.Status, cond.Reason, cleanerv1alpha1.ConditionReasonTargetDeletionFailed)
+	}
+	if !strings.Contains(cond.Message, `target "stuck"`) || strings.Contains(cond.Message, `target "ok"`) {
+		t.Errorf("condition message should only report the stuck target: %s", cond.Message)
+	}
+}
+
+func Test_targetFinalizerWaitsForTargetsToBeGone(t *testing.T) {
+	cTTL := newDeletedTestCTTL("pending-deletion", "cleaner.vtex.io/target-finalizer")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "finalized-pod")}
+	pod := newTestPod("finalized-pod")
+	pod.Finalizers = []string{"example.com/keep"}
+	r := newTestReconciler(t, cTTL, pod)
+
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != targetDeletionCheckPeriod {
+		t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, targetDeletionCheckPeriod)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleted)
+	if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonWaitingForTargetDeletion {
+		t.Fatalf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonWaitingForTargetDeletion)
+	}
+
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(pod), pod); err != nil {
+		t.Fatal(err)
+	}
+	pod.Finalizers = nil
+	if err := r.Update(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); !apierrors.IsNotFound(err) {
+		t.Errorf("expected cTTL to be gone once its targets are, got %v", err)
+	}
+}
+
+func Test_targetFinalizerFireAndForget(t *testing.T) {
+	cTTL := newDeletedTestCTTL("fire-and-forget", "cleaner.vtex.io/target-finalizer")
+	first := newPodTarget("first", "finalized-pod")
+	first.DeletionMode = cleanerv1alpha1.DeletionModeFireAndForget
+	second := newPodTarget("second", "later-pod")
+	second.DeletionOrder = ptr.To(int32(1))
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{first, second}
+	pod := newTestPod("finalized-pod")
+	pod.Finalizers = []string{"example.com/keep"}
+	r := newTestReconciler(t, cTTL, pod, newTestPod("later-pod"))
+
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != 0 {
+		t.Errorf("got RequeueAfter=%s, want no requeue", res.RequeueAfter)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), &cleanerv1alpha1.ConditionalTTL{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected cTTL to be gone without waiting for its targets, got %v", err)
+	}
+	// the pod is left to its own finalizer
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(pod), pod); err != nil || pod.DeletionTimestamp.IsZero() {
+		t.Errorf("expected the pod to be deleting, got %v (err=%v)", pod.DeletionTimestamp, err)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "later-pod"}, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the later target not to wait for the earlier one, got %v", err)
+	}
+}
+
+func Test_targetFinalizerDeletesInOrder(t *testing.T) {
+	cTTL := newDeletedTestCTTL("ordered", "cleaner.vtex.io/target-finalizer")
+	pvc := newPodTarget("pvc", "pvc-pod")
+	pvc.DeletionOrder = ptr.To[int32](1)
+	deploy := newPodTarget("deploy", "deploy-pod")
+	// declared last but deleted first
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{pvc, deploy}
+	deployPod := newTestPod("deploy-pod")
+	deployPod.Finalizers = []string{"example.com/keep"}
+	var deleted []string
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deleted = append(deleted, obj.GetName())
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, cTTL, deployPod, newTestPod("pvc-pod"))
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"deploy-pod"}) {
+		t.Fatalf("expected only the first target to be deleted while it is still present, got %v", deleted)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleted)
+	if cond == nil || !strings.Contains(cond.Message, `target "pvc": waiting for earlier targets`) {
+		t.Errorf("expected the later target to be reported as waiting, got %v", cond)
+	}
+
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(deployPod), deployPod); err != nil {
+		t.Fatal(err)
+	}
+	deployPod.Finalizers = nil
+	if err := r.Update(context.TODO(), deployPod); err != nil {
+		t.Fatal(err)
+	}
+	reconcileUntilGone(t, r, cTTL)
+	if want := []string{"deploy-pod", "pvc-pod"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("got deletes %v, want %v", deleted, want)
+	}
+}
+
+func Test_targetFinalizerWaitsBetweenOrders(t *testing.T) {
+	cTTL := newDeletedTestCTTL("delayed", "cleaner.vtex.io/target-finalizer")
+	cTTL.Spec.DeletionDelay = &metav1.Duration{Duration: time.Hour}
+	second := newPodTarget("second", "second-pod")
+	second.DeletionOrder = ptr.To[int32](1)
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("first", "first-pod"), second}
+	r := newTestReconciler(t, cTTL, newTestPod("first-pod"), newTestPod("second-pod"))
+	clock := testingclock.NewFakeClock(time.Now())
+	r.Clock = clock
+
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != time.Hour {
+		t.Errorf("got RequeueAfter=%s, want the deletion delay", res.RequeueAfter)
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "second-pod" {
+		t.Fatalf("expected only second-pod to be left during the delay, got %v", pods.Items)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if p := found.Status.DeletionProgress; p == nil || p.Order != 0 {
+		t.Fatalf("got deletion progress %v, want order 0 to be recorded", p)
+	}
+
+	// the delay is still running on the next reconcile
+	clock.Step(30 * time.Minute)
+	res, err = r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter > 30*time.Minute {
+		t.Errorf("got RequeueAfter=%s, want what is left of the delay", res.RequeueAfter)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(&pods.Items[0]), &corev1.Pod{}); err != nil {
+		t.Fatalf("expected second-pod to be kept during the delay: %s", err)
+	}
+
+	clock.Step(30 * time.Minute)
+	reconcileUntilGone(t, r, cTTL)
+	if err := r.List(context.TODO(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Errorf("expected every pod to be deleted after the delay, got %d", len(pods.Items))
+	}
+}
+
+func Test_targetFinalizerSkipsInvalidSelector(t *testing.T) {
+	badSelector := cleanerv1alpha1.Target{
+		Name:   "bad",
+		Delete: true,
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			LabelSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: "Bogus", Values: []string{"x"}},
+				},
+			},
+		},
+	}
+
+	t.Run("continue", func(t *testing.T) {
+		cTTL := newDeletedTestCTTL("invalid-selector", "cleaner.vtex.io/target-finalizer")
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{badSelector, newPodTarget("ok", "ok-pod")}
+		r := newTestReconciler(t, cTTL, newTestPod("ok-pod"))
+
+		reconcileUntilGone(t, r, cTTL)
+
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: "ok-pod", Namespace: "default"}, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+			t.Errorf("expected ok-pod to be deleted, got %v", err)
+		}
+		if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "TargetSkipped"); got != 1 {
+			t.Errorf("got %d TargetSkipped events, want 1", got)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		cTTL := newDeletedTestCTTL("invalid-selector-block", "cleaner.vtex.io/target-finalizer")
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{badSelector, newPodTarget("ok", "ok-pod")}
+		cTTL.Spec.FinalizerFailurePolicy = cleanerv1alpha1.FinalizerFailurePolicyBlock
+		r := newTestReconciler(t, cTTL, newTestPod("ok-pod"))
+
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+			t.Fatal("expected an error when the failure policy is Block")
+		}
+		found := getTestCTTL(t, r, cTTL)
+		if !controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/target-finalizer") {
+			t.Error("target finalizer should not be removed when the failure policy is Block")
+		}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: "ok-pod", Namespace: "default"}, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+			t.Errorf("expected ok-pod to be deleted, got %v", err)
+		}
+	})
+}
+
+func Test_deleteTargetsConcurrently(t *testing.T) {
+	const items, concurrency = 20, 4
+	var inFlight, maxInFlight atomic.Int32
+	objs := []client.Object{}
+	for i := 0; i < items; i++ {
+		pod := newTestPod(fmt.Sprintf("listed-%d", i))
+		pod.Labels = map[string]string{"app": "listed"}
+		objs = append(objs, pod)
+	}
+	cTTL := newDeletedTestCTTL("concurrent", "cleaner.vtex.io/target-finalizer")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodListTarget("pods", map[string]string{"app": "listed"})}
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if obj.GetName() == "listed-7" {
+				return apierrors.NewInternalError(errors.New("denied by webhook"))
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, append(objs, cTTL)...)
+	r.DeleteConcurrency = concurrency
+
+	start := time.Now()
+	_, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	elapsed := time.Since(start)
+	if err == nil || !strings.Contains(err.Error(), "denied by webhook") {
+		t.Fatalf("expected the failed delete to be reported, got %v", err)
+	}
+	if got := maxInFlight.Load(); got != concurrency {
+		t.Errorf("got %d deletes in flight, want %d", got, concurrency)
+	}
+	if serial := items * 10 * time.Millisecond; elapsed >= serial {
+		t.Errorf("deleting took %s, expected less than the serial %s", elapsed, serial)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "listed-7" {
+		t.Errorf("expected only listed-7 to be left, got %d pods", len(pods.Items))
+	}
+	events := drainEvents(r.Recorder.(*record.FakeRecorder))
+	if got := countEvents(events, "TargetDeleted"); got != items-1 {
+		t.Errorf("got %d TargetDeleted events, want %d", got, items-1)
+	}
+	if got := countEvents(events, "DeleteTargetFailed"); got != 1 {
+		t.Errorf("got %d DeleteTargetFailed events, want 1", got)
+	}
+}
+
+func Test_targetFinalizerUsesGracePeriod(t *testing.T) {
+	testCases := map[string]struct {
+		gracePeriod *int64
+		wantEvent   string
+	}{
+		"unset": {wantEvent: "Target Pod/graceful deleted"},
+		"long":  {gracePeriod: ptr.To[int64](600), wantEvent: "Target Pod/graceful deleted with grace period 600s"},
+		"force": {gracePeriod: ptr.To[int64](0), wantEvent: "Target Pod/graceful deleted with grace period 0s"},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			var got *client.DeleteOptions
+			cTTL := newDeletedTestCTTL("grace-period", "cleaner.vtex.io/target-finalizer")
+			target := newPodTarget("pod", "graceful")
+			target.GracePeriodSeconds = tc.gracePeriod
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			r := newInterceptedTestReconciler(t, interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					if obj.GetName() == "graceful" {
+						got = (&client.DeleteOptions{}).ApplyOptions(opts)
+					}
+					return c.Delete(ctx, obj, opts...)
+				},
+			}, cTTL, newTestPod("graceful"))
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got == nil {
+				t.Fatal("expected the pod to be deleted")
+			}
+			if !reflect.DeepEqual(got.GracePeriodSeconds, tc.gracePeriod) {
+				t.Errorf("got grace period %v, want %v", ptr.Deref(got.GracePeriodSeconds, -1), ptr.Deref(tc.gracePeriod, -1))
+			}
+			events := drainEvents(r.Recorder.(*record.FakeRecorder))
+			found := false
+			for _, e := range events {
+				if strings.HasSuffix(e, tc.wantEvent) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected event %q, got %v", tc.wantEvent, events)
+			}
+		})
+	}
+}
+
+func Test_targetFinalizerForceRemovesFinalizers(t *testing.T) {
+	testCases := map[string]struct {
+		allowed     bool
+		wantRemoved bool
+	}{
+		"allowed":     {allowed: true, wantRemoved: true},
+		"not allowed": {allowed: false, wantRemoved: false},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("force", "cleaner.vtex.io/target-finalizer")
+			target := newPodTarget("pod", "stuck-pod")
+			target.ForceRemoveFinalizers = true
+			target.ForceRemoveFinalizersAfter = &metav1.Duration{Duration: 10 * time.Millisecond}
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			pod := newTestPod("stuck-pod")
+			pod.Finalizers = []string{"example.com/keep"}
+			r := newTestReconciler(t, cTTL, pod)
+			r.AllowForceFinalizerRemoval = tc.allowed
+			clock := testingclock.NewFakeClock(time.Now())
+			r.Clock = clock
+
+			// the first reconcile deletes the pod, which gets stuck
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if res.RequeueAfter != targetDeletionCheckPeriod {
+				t.Fatalf("got RequeueAfter=%s, want %s", res.RequeueAfter, targetDeletionCheckPeriod)
+			}
+
+			clock.Step(time.Second)
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			err = r.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{})
+			if gotRemoved := apierrors.IsNotFound(err); gotRemoved != tc.wantRemoved {
+				t.Errorf("pod removed: got=%v want=%v (err=%v)", gotRemoved, tc.wantRemoved, err)
+			}
+			events := drainEvents(r.Recorder.(*record.FakeRecorder))
+			if got, want := countEvents(events, "FinalizersForceRemoved"), map[bool]int{true: 1}[tc.wantRemoved]; got != want {
+				t.Errorf("got %d FinalizersForceRemoved events, want %d", got, want)
+			}
+		})
+	}
+}
+
+func Test_targetFinalizerContinuesAfterDeniedItem(t *testing.T) {
+	objs := []client.Object{newTestPod("single-pod")}
+	for i := 0; i < 5; i++ {
+		pod := newTestPod(fmt.Sprintf("listed-%d", i))
+		pod.Labels = map[string]string{"app": "listed"}
+		objs = append(objs, pod)
+	}
+	cTTL := newDeletedTestCTTL("denied-item", "cleaner.vtex.io/target-finalizer")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{
+		newPodListTarget("pods", map[string]string{"app": "listed"}),
+		newPodTarget("single", "single-pod"),
+	}
+	deleted := map[string]int{}
+	deny := true
+	r := newInterceptedTestReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deleted[obj.GetName()]++
+			if deny && obj.GetName() == "listed-2" {
+				return apierrors.NewForbidden(corev1.Resource("pods"), obj.GetName(), errors.New("denied by webhook"))
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, append(objs, cTTL)...)
+	// delete serially so the denied item is in the middle of the pass
+	r.DeleteConcurrency = 1
+
+	_, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err == nil || !strings.Contains(err.Error(), "Pod/listed-2") {
+		t.Fatalf("expected the denied item to be reported, got %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "listed-2" {
+		t.Errorf("expected only listed-2 to be left after the first pass, got %d pods", len(pods.Items))
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "DeleteTargetFailed"); got != 1 {
+		t.Errorf("got %d DeleteTargetFailed events, want 1", got)
+	}
+
+	// the retry only deletes what is left
+	deny = false
+	for name := range deleted {
+		delete(deleted, name)
+	}
+	reconcileUntilGone(t, r, cTTL)
+	if len(deleted) != 1 || deleted["listed-2"] != 1 {
+		t.Errorf("expected the retry to only delete listed-2, got %v", deleted)
+	}
+}
+
+// hangingKubeClient blocks Helm actions until unblocked.
+type hangingKubeClient struct {
+	kubefake.PrintingKubeClient
+	calls   atomic.Int32
+	unblock chan struct{}
+}
+
+func (c *hangingKubeClient) IsReachable() error {
+	c.calls.Add(1)
+	<-c.unblock
+	return nil
+}
+
+func Test_releaseFinalizerTimesOut(t *testing.T) {
+	cTTL := newDeletedTestCTTL("hung-uninstall", "cleaner.vtex.io/release-finalizer")
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{
+		Release: "my-release",
+		Delete:  true,
+		Timeout: &metav1.Duration{Duration: 10 * time.Millisecond},
+	}
+	kc := &hangingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}, unblock: make(chan struct{})}
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases:   storage.Init(driver.NewMemory()),
+		KubeClient: kc,
+		Log:        func(string, ...interface{}) {},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got err=%v, want a timeout", err)
+		}
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "HelmUninstallTimeout"); got != 2 {
+		t.Errorf("got %d HelmUninstallTimeout events, want 2", got)
+	}
+	if got := kc.calls.Load(); got != 1 {
+		t.Errorf("got %d uninstalls, want the hung one to be waited on", got)
+	}
+
+	close(kc.unblock)
+	// the next reconcile starts a new uninstall once the hung one is over
+	for {
+		if _, running := r.helmUninstalls.Load(types.NamespacedName{Namespace: "default", Name: "my-release"}); !running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), &cleanerv1alpha1.ConditionalTTL{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the cTTL to be gone once the uninstall finished, got %v", err)
+	}
+}
+
+func Test_releaseFinalizerNamespace(t *testing.T) {
+	testCases := map[string]struct {
+		allow         bool
+		allowed       []string
+		wantUninstall bool
+	}{
+		"not allowed":    {},
+		"allowed":        {allow: true, wantUninstall: true},
+		"listed":         {allow: true, allowed: []string{"default", "other"}, wantUninstall: true},
+		"not listed":     {allow: true, allowed: []string{"default", "staging"}},
+		"listed but off": {allowed: []string{"default", "other"}},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("other-namespace", "cleaner.vtex.io/release-finalizer")
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Namespace: "other", Delete: true}
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			releases := storage.Init(driver.NewMemory())
+			if err := releases.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "other",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusDeployed},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			r := newTestReconciler(t, cTTL)
+			r.AllowCrossNamespaceHelm = tc.allow
+			r.HelmConfig = &action.Configuration{
+				Releases:   releases,
+				KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+				Log:        func(string, ...interface{}) {},
+			}
+
+			reconcileUntilGone(t, r, cTTL)
+			_, err := releases.Last("my-release")
+			if gotUninstall := errors.Is(err, driver.ErrReleaseNotFound); gotUninstall != tc.wantUninstall {
+				t.Errorf("got err=%v, wantUninstall=%v", err, tc.wantUninstall)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "HelmNamespaceNotAllowed"); (got == 1) == tc.wantUninstall {
+				t.Errorf("got %d HelmNamespaceNotAllowed events, wantUninstall=%v", got, tc.wantUninstall)
+			}
+		})
+	}
+}
+
+func Test_releaseFinalizerStorageDrivers(t *testing.T) {
+	testCases := map[string]func(clientset *k8sfake.Clientset) driver.Driver{
+		"secret": func(clientset *k8sfake.Clientset) driver.Driver {
+			return driver.NewSecrets(clientset.CoreV1().Secrets("default"))
+		},
+		"configmap": func(clientset *k8sfake.Clientset) driver.Driver {
+			return driver.NewConfigMaps(clientset.CoreV1().ConfigMaps("default"))
+		},
+	}
+
+	for description, newDriver := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("storage-driver", "cleaner.vtex.io/release-finalizer")
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true}
+			releases := storage.Init(newDriver(k8sfake.NewSimpleClientset()))
+			if err := releases.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusDeployed},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			r := newTestReconciler(t, cTTL)
+			r.HelmConfig = &action.Configuration{
+				Releases:   releases,
+				KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+				Log:        func(string, ...interface{}) {},
+			}
+
+			reconcileUntilGone(t, r, cTTL)
+			if _, err := releases.Last("my-release"); !errors.Is(err, driver.ErrReleaseNotFound) {
+				t.Errorf("expected the release to be uninstalled, got err=%v", err)
+			}
+		})
+	}
+}
+
+func Test_releaseFinalizerMultipleReleases(t *testing.T) {
+	cTTL := newDeletedTestCTTL("multiple-releases", "cleaner.vtex.io/release-finalizer")
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "app", Delete: true}
+	cTTL.Spec.HelmReleases = []cleanerv1alpha1.HelmConfig{
+		{Release: "db", Delete: true},
+		{Release: "monitoring", Delete: true},
+		{Release: "shared"},
+	}
+	releases := storage.Init(driver.NewMemory())
+	// db is already gone
+	for _, name := range []string{"app", "monitoring", "shared"} {
+		if err := releases.Create(&release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases:   releases,
+		KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+		Log:        func(string, ...interface{}) {},
+	}
+
+	reconcileUntilGone(t, r, cTTL)
+	for _, name := range []string{"app", "monitoring"} {
+		if _, err := releases.Last(name); !errors.Is(err, driver.ErrReleaseNotFound) {
+			t.Errorf("release %s: expected it to be uninstalled, got err=%v", name, err)
+		}
+	}
+	if _, err := releases.Last("shared"); err != nil {
+		t.Errorf("expected the release not marked for deletion to be kept, got err=%v", err)
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "HelmReleaseUninstalled"); got != 2 {
+		t.Errorf("got %d HelmReleaseUninstalled events, want 2", got)
+	}
+}
+
+func Test_releaseFinalizerFailurePolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy    cleanerv1alpha1.HelmFailurePolicy
+		installed bool
+		wantErr   bool
+		wantEvent string
+	}{
+		"block": {
+			policy:    cleanerv1alpha1.HelmFailurePolicyBlock,
+			installed: true,
+			wantErr:   true,
+			wantEvent: "HelmUninstallFailed",
+		},
+		"continue": {
+			policy:    cleanerv1alpha1.HelmFailurePolicyContinue,
+			installed: true,
+			wantEvent: "HelmUninstallSkipped",
+		},
+		"not found": {
+			policy:    cleanerv1alpha1.HelmFailurePolicyBlock,
+			wantEvent: "HelmUninstallSkipped",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("failure-policy", "cleaner.vtex.io/release-finalizer", "test/keep")
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true, FailurePolicy: tc.policy}
+			releases := storage.Init(driver.NewMemory())
+			if tc.installed {
+				if err := releases.Create(&release.Release{
+					Name:      "my-release",
+					Namespace: "default",
+					Version:   1,
+					Info:      &release.Info{Status: release.StatusDeployed},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := newTestReconciler(t, cTTL)
+			r.HelmConfig = &action.Configuration{
+				Releases: releases,
+				KubeClient: &kubefake.FailingKubeClient{
+					PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+					BuildError:         errors.New("forbidden"),
+				},
+				Log: func(string, ...interface{}) {},
+			}
+
+			_, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), tc.wantEvent); got != 1 {
+				t.Errorf("got %d %s events, want 1", got, tc.wantEvent)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if got := controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/release-finalizer"); got != tc.wantErr {
+				t.Errorf("got release finalizer=%v, want it kept only when blocked", got)
+			}
+		})
+	}
+}
+
+func Test_classifyHelmError(t *testing.T) {
+	testCases := map[string]struct {
+		err  error
+		want helmErrorClass
+	}{
+		"release not found": {
+			err:  driver.ErrReleaseNotFound,
+			want: helmErrorNotFound,
+		},
+		"release not loaded": {
+			err:  pkgerrors.Wrapf(driver.ErrReleaseNotFound, "uninstall: Release not loaded: %s", "my-release"),
+			want: helmErrorNotFound,
+		},
+		"not found message": {
+			err:  fmt.Errorf("query: %s", driver.ErrReleaseNotFound.Error()),
+			want: helmErrorNotFound,
+		},
+		"no deployed releases": {
+			err:  fmt.Errorf("my-release: %w", driver.ErrNoDeployedReleases),
+			want: helmErrorNotFound,
+		},
+		"storage object not found": {
+			err:  apierrors.NewNotFound(corev1.Resource("secrets"), "sh.helm.release.v1.my-release.v1"),
+			want: helmErrorNotFound,
+		},
+		"no release provided": {
+			err:  errors.New("no release provided"),
+			want: helmErrorNotFound,
+		},
+		"already deleted": {
+			err:  pkgerrors.Errorf("the release named %q is already deleted", "my-release"),
+			want: helmErrorAlreadyUninstalled,
+		},
+		"already uninstalled": {
+			err:  errors.New("release: already uninstalled"),
+			want: helmErrorAlreadyUninstalled,
+		},
+		"corrupt release": {
+			err:  pkgerrors.Wrapf(errors.New("illegal base64 data at input byte 4"), "uninstall: Release not loaded: %s", "my-release"),
+			want: helmErrorFailed,
+		},
+		"failed deletion": {
+			err:  errors.New("uninstallation completed with 1 error(s): forbidden"),
+			want: helmErrorFailed,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			if got := classifyHelmError(tc.err); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_releaseFinalizerFromTarget(t *testing.T) {
+	testCases := map[string]struct {
+		annotations   map[string]string
+		wantUninstall bool
+	}{
+		"annotated target": {
+			annotations:   map[string]string{"meta.helm.sh/release-name": "my-release", "meta.helm.sh/release-namespace": "default"},
+			wantUninstall: true,
+		},
+		"target without annotations": {},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("from-target")
+			target := newPodTarget("pod", "release-pod")
+			target.Delete = false
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{FromTarget: "pod", Delete: true}
+			pod := newTestPod("release-pod")
+			pod.Annotations = tc.annotations
+			releases := storage.Init(driver.NewMemory())
+			if err := releases.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusDeployed},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			r := newTestReconciler(t, cTTL, pod)
+			r.HelmConfig = &action.Configuration{
+				Releases:   releases,
+				KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+				Log:        func(string, ...interface{}) {},
+			}
+
+			reconcileUntilGone(t, r, cTTL)
+			_, err := releases.Last("my-release")
+			if gotUninstall := errors.Is(err, driver.ErrReleaseNotFound); gotUninstall != tc.wantUninstall {
+				t.Errorf("got err=%v, wantUninstall=%v", err, tc.wantUninstall)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "HelmReleaseUnresolved"); (got == 1) == tc.wantUninstall {
+				t.Errorf("got %d HelmReleaseUnresolved events, wantUninstall=%v", got, tc.wantUninstall)
+			}
+		})
+	}
+}
+
+func Test_reconcileHelmReleaseCondition(t *testing.T) {
+	testCases := map[string]struct {
+		lastDeployed time.Duration
+		missing      bool
+		optional     bool
+		complete     bool
+		namespace    string
+		allow        bool
+		allowed      []string
+		wantDeleted  bool
+		wantReason   string
+	}{
+		"not upgraded in 30 days": {
+			lastDeployed: 45 * 24 * time.Hour,
+			wantDeleted:  true,
+		},
+		"upgraded recently": {
+			lastDeployed: 24 * time.Hour,
+			wantReason:   cleanerv1alpha1.ConditionReasonWaitingForConditions,
+		},
+		"missing release": {
+			missing:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonWaitingForHelmRelease,
+		},
+		"missing optional release": {
+			missing:     true,
+			optional:    true,
+			wantDeleted: true,
+		},
+		"missing release completed": {
+			missing:     true,
+			complete:    true,
+			wantDeleted: true,
+			wantReason:  cleanerv1alpha1.ConditionReasonHelmReleaseGone,
+		},
+		"present release not completed": {
+			lastDeployed: 24 * time.Hour,
+			complete:     true,
+			wantReason:   cleanerv1alpha1.ConditionReasonWaitingForConditions,
+		},
+		"release in another namespace": {
+			lastDeployed: 45 * 24 * time.Hour,
+			namespace:    "staging",
+			wantReason:   cleanerv1alpha1.ConditionReasonHelmNamespaceNotAllowed,
+		},
+		"release in a namespace not listed": {
+			lastDeployed: 45 * 24 * time.Hour,
+			namespace:    "staging",
+			allow:        true,
+			allowed:      []string{"default", "production"},
+			wantReason:   cleanerv1alpha1.ConditionReasonHelmNamespaceNotAllowed,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("helm-condition")
+			cTTL.Finalizers = []string{"test/keep"}
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Namespace: tc.namespace, IncludeWhenEvaluating: true, OptionalWhenEvaluating: tc.optional, CompleteWhenNotFound: tc.complete}
+			cTTL.Spec.Conditions = []string{`helmRelease == null || time - helmRelease.lastDeployed > duration("720h")`}
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			releases := storage.Init(driver.NewMemory())
+			if !tc.missing {
+				if err := releases.Create(&release.Release{
+					Name:      "my-release",
+					Namespace: "default",
+					Version:   1,
+					Info:      &release.Info{Status: release.StatusDeployed, LastDeployed: helmtime.Time{Time: time.Now().Add(-tc.lastDeployed)}},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := newTestReconciler(t, cTTL)
+			r.AllowCrossNamespaceHelm = tc.allow
+			r.HelmConfig = &action.Configuration{
+				Releases:   releases,
+				KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+				Log:        func(string, ...interface{}) {},
+			}
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.namespace != "" && res != (ctrl.Result{}) {
+				t.Errorf("got %+v, want no requeue", res)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if deleted := !found.DeletionTimestamp.IsZero(); deleted != tc.wantDeleted {
+				t.Errorf("got deleted=%v, want %v", deleted, tc.wantDeleted)
+			}
+			if tc.wantReason == "" {
+				return
+			}
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != tc.wantReason {
+				t.Errorf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+		})
+	}
+}
+
+func Test_helmActionConfigCache(t *testing.T) {
+	r := &ConditionalTTLReconciler{Config: &rest.Config{Host: "https://127.0.0.1:6443"}}
+	cfg, err := r.helmActionConfig("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if again, err := r.helmActionConfig("default"); err != nil || again != cfg {
+		t.Errorf("got %p, err=%v, want the cached configuration %p", again, err, cfg)
+	}
+	if other, err := r.helmActionConfig("staging"); err != nil || other == cfg {
+		t.Errorf("got %p, err=%v, want another namespace to have its own configuration", other, err)
+	}
+}
+
+func Test_reconcileHelmReleaseGone(t *testing.T) {
+	cTTL := newTestCTTL("release-gone")
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true, IncludeWhenEvaluating: true, CompleteWhenNotFound: true}
+	cTTL.Spec.Conditions = []string{`helmRelease.status == "superseded"`}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases:   storage.Init(driver.NewMemory()),
+		KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+		Log:        func(string, ...interface{}) {},
+	}
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	reconcileUntilGone(t, r, cTTL)
+	if len(ce.sent) != 1 || ce.sent[0].Type() != "conditionalTTL.deleted" {
+		t.Errorf("got cloud events %v, want the deletion one", ce.sent)
+	}
+	events := drainEvents(r.Recorder.(*record.FakeRecorder))
+	if countEvents(events, cleanerv1alpha1.ConditionReasonHelmReleaseGone) != 1 {
+		t.Errorf("got events %v, want one %s", events, cleanerv1alpha1.ConditionReasonHelmReleaseGone)
+	}
+}
+
+func Test_releaseFinalizerDryRun(t *testing.T) {
+	cTTL := newDeletedTestCTTL("dry-run", "cleaner.vtex.io/release-finalizer", "test/keep")
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true, DryRun: true}
+	releases := storage.Init(driver.NewMemory())
+	if err := releases.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Manifest: `---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases:   releases,
+		KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+		Log:        func(string, ...interface{}) {},
+	}
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := releases.Last("my-release"); err != nil {
+		t.Errorf("expected the release to still exist after a dry run, got err=%v", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	want := []cleanerv1alpha1.HelmDryRun{{Release: "my-release", Namespace: "default", Resources: []string{"Deployment/app", "Service/app"}}}
+	if !reflect.DeepEqual(found.Status.HelmDryRuns, want) {
+		t.Errorf("got dry runs %v, want %v", found.Status.HelmDryRuns, want)
+	}
+	if controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/release-finalizer") {
+		t.Error("expected the release finalizer to be removed after the dry run")
+	}
+	events := drainEvents(r.Recorder.(*record.FakeRecorder))
+	if got := countEvents(events, "HelmUninstallDryRun"); got != 1 {
+		t.Errorf("got %d HelmUninstallDryRun events, want 1: %v", got, events)
+	}
+	if got := countEvents(events, "HelmReleaseUninstalled"); got != 0 {
+		t.Errorf("got %d HelmReleaseUninstalled events, want none", got)
+	}
+}
+
+func Test_validateHelmDriver(t *testing.T) {
+	for _, d := range HelmDrivers {
+		if err := ValidateHelmDriver(d); err != nil {
+			t.Errorf("driver %s: unexpected error: %s", d, err)
+		}
+	}
+	if err := ValidateHelmDriver("sql"); err == nil {
+		t.Error("expected an error for an unsupported driver")
+	}
+}
+
+func Test_reconcileOrphanPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy      cleanerv1alpha1.OrphanPolicy
+		namespace   *corev1.Namespace
+		releases    []cleanerv1alpha1.HelmConfig
+		evaluate    bool
+		wantDeleted bool
+		wantReason  string
+	}{
+		"fail": {
+			policy:     cleanerv1alpha1.OrphanPolicyFail,
+			wantReason: cleanerv1alpha1.ConditionReasonWaitingForConditions,
+		},
+		"complete with namespace present": {
+			policy:     cleanerv1alpha1.OrphanPolicyComplete,
+			namespace:  newTestNamespace("preview", nil),
+			wantReason: cleanerv1alpha1.ConditionReasonWaitingForConditions,
+		},
+		"complete with namespace gone": {
+			policy:      cleanerv1alpha1.OrphanPolicyComplete,
+			wantDeleted: true,
+			wantReason:  cleanerv1alpha1.ConditionReasonNamespaceGone,
+		},
+		"complete with namespace terminating": {
+			policy: cleanerv1alpha1.OrphanPolicyComplete,
+			namespace: func() *corev1.Namespace {
+				ns := newTestNamespace("preview", nil)
+				ns.DeletionTimestamp = ptr.To(metav1.Now())
+				ns.Finalizers = []string{"kubernetes"}
+				return ns
+			}(),
+			wantDeleted: true,
+			wantReason:  cleanerv1alpha1.ConditionReasonNamespaceGone,
+		},
+		"complete with one of two namespaces gone": {
+			policy:     cleanerv1alpha1.OrphanPolicyComplete,
+			namespace:  newTestNamespace("staging", nil),
+			releases:   []cleanerv1alpha1.HelmConfig{{Release: "other-release", Namespace: "staging"}},
+			evaluate:   true,
+			wantReason: cleanerv1alpha1.ConditionReasonWaitingForConditions,
+		},
+		"complete with both namespaces gone": {
+			policy:      cleanerv1alpha1.OrphanPolicyComplete,
+			releases:    []cleanerv1alpha1.HelmConfig{{Release: "other-release", Namespace: "staging"}},
+			wantDeleted: true,
+			wantReason:  cleanerv1alpha1.ConditionReasonNamespaceGone,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("orphan")
+			cTTL.Spec.Conditions = []string{"false"}
+			cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Namespace: "preview", IncludeWhenEvaluating: tc.evaluate}
+			cTTL.Spec.HelmReleases = tc.releases
+			cTTL.Spec.OrphanPolicy = tc.policy
+			objs := []client.Object{cTTL}
+			if tc.namespace != nil {
+				objs = append(objs, tc.namespace)
+			}
+			r := newTestReconciler(t, objs...)
+			r.AllowCrossNamespaceHelm = true
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if deleted := !found.DeletionTimestamp.IsZero(); deleted != tc.wantDeleted {
+				t.Errorf("got deleted=%v, want %v", deleted, tc.wantDeleted)
+			}
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != tc.wantReason {
+				t.Errorf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+		})
+	}
+}
+
+func Test_reconcileHandlesPartialFinalizers(t *testing.T) {
+	testCases := map[string]struct {
+		finalizers []string
+		wantLeft   []string
+		wantEvents int
+	}{
+		"all finalizers": {
+			finalizers: finalizerNames(),
+			wantEvents: 1,
+		},
+		"cloud event finalizer only": {
+			finalizers: []string{"cleaner.vtex.io/cloud-event-finalizer"},
+			wantEvents: 1,
+		},
+		"release finalizer removed manually": {
+			finalizers: []string{"cleaner.vtex.io/target-finalizer", "cleaner.vtex.io/cloud-event-finalizer"},
+			wantEvents: 1,
+		},
+		"unknown cleaner finalizer": {
+			finalizers: []string{"cleaner.vtex.io/legacy-finalizer", "cleaner.vtex.io/cloud-event-finalizer"},
+			wantEvents: 1,
+		},
+		"foreign finalizer": {
+			finalizers: []string{"cleaner.vtex.io/legacy-finalizer", "example.com/keep"},
+			wantLeft:   []string{"example.com/keep"},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("partial", tc.finalizers...)
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "partial-pod")}
+			cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+			r := newTestReconciler(t, cTTL)
+			ce := &fakeCloudEventsClient{}
+			r.CloudEventsClient = ce
+
+			// every finalizer is handled in a single reconcile
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := &cleanerv1alpha1.ConditionalTTL{}
+			err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found)
+			if tc.wantLeft == nil {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected the cTTL to be gone, got err=%v finalizers=%v", err, found.Finalizers)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(found.Finalizers, tc.wantLeft) {
+				t.Errorf("got finalizers %v, want %v", found.Finalizers, tc.wantLeft)
+			}
+			if len(ce.sent) != tc.wantEvents {
+				t.Errorf("got %d cloud events, want %d", len(ce.sent), tc.wantEvents)
+			}
+		})
+	}
+}
+
+func Test_cloudEventFinalizerRetries(t *testing.T) {
+	testCases := map[string]struct {
+		failures     int
+		policy       cleanerv1alpha1.CloudEventDeliveryPolicy
+		wantGone     bool
+		wantRequests int
+		wantEvent    string
+	}{
+		"recovers within the attempts": {
+			failures:     2,
+			wantGone:     true,
+			wantRequests: 3,
+			wantEvent:    "EventDelivered",
+		},
+		"blocks once exhausted": {
+			failures:     5,
+			wantRequests: 3,
+			wantEvent:    "EventDeliveryFailed",
+		},
+		"abandoned once exhausted": {
+			failures:     5,
+			policy:       cleanerv1alpha1.CloudEventDeliveryPolicyBestEffort,
+			wantGone:     true,
+			wantRequests: 3,
+			wantEvent:    "EventDeliveryAbandoned",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			tap := &tapHandler{failures: tc.failures}
+			tap.handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				e, err := cehttp.NewEventFromHTTPRequest(req)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				tap.receive(*e)
+			})
+			server := httptest.NewServer(tap)
+			defer server.Close()
+			ce, err := cloudevents.NewClientHTTP()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cTTL := newDeletedTestCTTL("retried", "cleaner.vtex.io/cloud-event-finalizer")
+			if !tc.wantGone {
+				cTTL.Finalizers = append(cTTL.Finalizers, "test/keep")
+			}
+			cTTL.Spec.CloudEventSink = ptr.To(server.URL)
+			cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+				Retry: &cleanerv1alpha1.CloudEventRetry{
+					Attempts: 3,
+					Backoff:  &metav1.Duration{Duration: time.Millisecond},
+				},
+				DeliveryPolicy: tc.policy,
+			}
+			r := newTestReconciler(t, cTTL)
+			r.CloudEventsClient = ce
+
+			_, err = r.Reconcile(context.TODO(), requestFor(cTTL))
+			if gotErr := err != nil; gotErr == tc.wantGone {
+				t.Errorf("got err=%v, want error %v", err, !tc.wantGone)
+			}
+			if tap.requests != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", tap.requests, tc.wantRequests)
+			}
+			events := drainEvents(r.Recorder.(*record.FakeRecorder))
+			if countEvents(events, tc.wantEvent) != 1 {
+				t.Errorf("got events %v, want one %s", events, tc.wantEvent)
+			}
+			found := &cleanerv1alpha1.ConditionalTTL{}
+			err = r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found)
+			if tc.wantGone {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected the cTTL to be gone, got err=%v finalizers=%v", err, found.Finalizers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.Status.EventDeliveryAttempts != 3 {
+				t.Errorf("got %d delivery attempts, want 3", found.Status.EventDeliveryAttempts)
+			}
+			if !controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/cloud-event-finalizer") {
+				t.Errorf("got finalizers %v, want the cloud event one left", found.Finalizers)
+			}
+		})
+	}
+}
+
+func Test_cloudEventFinalizerTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ce, err := cloudevents.NewClientHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cTTL := newDeletedTestCTTL("timeout", "cleaner.vtex.io/cloud-event-finalizer", "test/keep")
+	cTTL.Spec.CloudEventSink = ptr.To(server.URL)
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+		Retry: &cleanerv1alpha1.CloudEventRetry{
+			Attempts: 5,
+			Backoff:  &metav1.Duration{Duration: 30 * time.Second},
+		},
+	}
+	r := newTestReconciler(t, cTTL)
+	r.CloudEventsClient = ce
+	r.CloudEventTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Error("expected an error once the timeout is exceeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("reconcile took %s, want it bounded by the timeout", elapsed)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if !controllerutil.ContainsFinalizer(found, "cleaner.vtex.io/cloud-event-finalizer") {
+		t.Errorf("got finalizers %v, want the cloud event one left", found.Finalizers)
+	}
+}
+
+func Test_cloudEventFinalizerMode(t *testing.T) {
+	testCases := map[string]struct {
+		mode            cleanerv1alpha1.CloudEventMode
+		wantContentType string
+		wantCEType      string
+	}{
+		"binary by default": {
+			wantContentType: "application/json",
+			wantCEType:      "conditionalTTL.deleted",
+		},
+		"binary": {
+			mode:            cleanerv1alpha1.CloudEventModeBinary,
+			wantContentType: "application/json",
+			wantCEType:      "conditionalTTL.deleted",
+		},
+		"structured": {
+			mode:            cleanerv1alpha1.CloudEventModeStructured,
+			wantContentType: "application/cloudevents+json",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			tap, ce, url := newTestTap(t)
+			cTTL := newDeletedTestCTTL("mode", "cleaner.vtex.io/cloud-event-finalizer")
+			cTTL.Spec.CloudEventSink = ptr.To(url)
+			cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{Mode: tc.mode}
+			r := newTestReconciler(t, cTTL)
+			r.CloudEventsClient = ce
+
+			reconcileUntilGone(t, r, cTTL)
+			if len(tap.headers) != 1 || len(tap.events) != 1 {
+				t.Fatalf("got %d requests and %d events, want one of each", len(tap.headers), len(tap.events))
+			}
+			if got := tap.headers[0].Get("Content-Type"); !strings.HasPrefix(got, tc.wantContentType) {
+				t.Errorf("got Content-Type %q, want %q", got, tc.wantContentType)
+			}
+			if got := tap.headers[0].Get("Ce-Type"); got != tc.wantCEType {
+				t.Errorf("got Ce-Type %q, want %q", got, tc.wantCEType)
+			}
+			e := tap.events[0]
+			if e.Type() != "conditionalTTL.deleted" || e.Subject() != "default/mode" {
+				t.Errorf("got event of type %q and subject %q", e.Type(), e.Subject())
+			}
+			var data map[string]interface{}
+			if err := e.DataAs(&data); err != nil || data["name"] != "mode" {
+				t.Errorf("got data %v, err=%v", data, err)
+			}
+		})
+	}
+}
+
+func Test_reconcileReportsFinalizerPhases(t *testing.T) {
+	cTTL := newDeletedTestCTTL("phases", "cleaner.vtex.io/target-finalizer", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "phases-pod")}
+	cTTL.Spec.Targets[0].Delete = true
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	r := newTestReconciler(t, cTTL)
+	ce := &fakeCloudEventsClient{result: errors.New("connection refused")}
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected an error delivering the cloud event")
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if !reflect.DeepEqual(found.Finalizers, []string{"cleaner.vtex.io/cloud-event-finalizer"}) {
+		t.Errorf("got finalizers %v, want only the cloud event one left", found.Finalizers)
+	}
+	deleting := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeTargetsDeleting)
+	if deleting == nil || deleting.Status != metav1.ConditionFalse || deleting.Reason != cleanerv1alpha1.ConditionReasonFinalizerCompleted {
+		t.Errorf("got %s condition %v, want it completed", cleanerv1alpha1.ConditionTypeTargetsDeleting, deleting)
+	}
+	delivering := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeEventDelivering)
+	if delivering == nil || delivering.Status != metav1.ConditionTrue || delivering.Reason != cleanerv1alpha1.ConditionReasonFinalizerFailed {
+		t.Fatalf("got %s condition %v, want it failed", cleanerv1alpha1.ConditionTypeEventDelivering, delivering)
+	}
+	if !strings.Contains(delivering.Message, "connection refused") {
+		t.Errorf("got message %q, want it to include the delivery error", delivering.Message)
+	}
+	if apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeHelmUninstalling) != nil {
+		t.Errorf("got a %s condition without the release finalizer", cleanerv1alpha1.ConditionTypeHelmUninstalling)
+	}
+
+	// the sink is back
+	ce.result = nil
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the cTTL to be gone, got err=%v", err)
+	}
+}
+
+func Test_reconcileDeletionWithEmptyStatus(t *testing.T) {
+	cTTL := newDeletedTestCTTL("empty-status", finalizerNames()...)
+	cTTL.Status = cleanerv1alpha1.ConditionalTTLStatus{}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+
+	r := newTestReconciler(t, cTTL)
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	reconcileUntilGone(t, r, cTTL)
+
+	if len(ce.sent) != 1 {
+		t.Fatalf("got %d cloud events, want 1", len(ce.sent))
+	}
+	e := ce.sent[0]
+	if e.Time().IsZero() {
+		t.Error("expected event time to fall back to the deletion time")
+	}
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(e.Data(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["name"] != cTTL.GetName() {
+		t.Errorf("got name %v, want %s", data["name"], cTTL.GetName())
+	}
+	if targets, ok := data["targets"].([]interface{}); !ok || len(targets) != 0 {
+		t.Errorf("got targets %v, want an empty list", data["targets"])
+	}
+}
+
+func Test_reconcileTruncatesLargeTargetStates(t *testing.T) {
+	const items = 5
+	objs := []client.Object{}
+	for i := 0; i < items; i++ {
+		objs = append(objs, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("large-%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"app": "large"},
+			},
+			Data: map[string]string{"blob": strings.Repeat("x", 4096)},
+		})
+	}
+	cTTL := newTestCTTL("large-state")
+	target := cleanerv1alpha1.Target{
+		Name:                  "configmaps",
+		Delete:                true,
+		IncludeWhenEvaluating: ptr.To(true),
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "large"}},
+		},
+	}
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+	// conditions are still evaluated on the full state
+	cTTL.Spec.Conditions = []string{`configmaps.items.all(cm, size(cm.data.blob) == 4096)`}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	r := newTestReconciler(t, append(objs, cTTL)...)
+	r.MaxTargetStateSize = 8 * 1024
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if found.Status.EvaluationTime == nil {
+		t.Fatal("expected conditions to be met")
+	}
+	if !found.Status.TargetsTruncated {
+		t.Error("expected targetsTruncated to be set")
+	}
+	stored, _, _ := unstructured.NestedSlice(found.Status.Targets[0].State.Object, "items")
+	if len(stored) != items {
+		t.Fatalf("got %d items in the stored state, want %d", len(stored), items)
+	}
+	for _, item := range stored {
+		obj := item.(map[string]interface{})
+		if _, ok := obj["data"]; ok {
+			t.Errorf("expected only metadata to be stored, got %v", obj)
+		}
+		if name, _, _ := unstructured.NestedString(obj, "metadata", "name"); !strings.HasPrefix(name, "large-") {
+			t.Errorf("expected metadata to be kept, got %v", obj)
+		}
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "TargetStateTruncated"); got != 1 {
+		t.Errorf("got %d TargetStateTruncated events, want 1", got)
+	}
+
+	reconcileUntilGone(t, r, cTTL)
+	cms := &corev1.ConfigMapList{}
+	if err := r.List(context.TODO(), cms); err != nil {
+		t.Fatal(err)
+	}
+	if len(cms.Items) != 0 {
+		t.Errorf("expected the configmaps to be deleted, got %d", len(cms.Items))
+	}
+	if len(ce.sent) != 1 {
+		t.Fatalf("got %d cloud events, want 1", len(ce.sent))
+	}
+}
+
+func Test_limitTargetStates(t *testing.T) {
+	state := func(name string, labels int) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"data":       map[string]interface{}{"blob": strings.Repeat("x", 4096)},
+		}}
+		u.SetName(name)
+		l := map[string]string{}
+		for i := 0; i < labels; i++ {
+			l[fmt.Sprintf("label-%d", i)] = strings.Repeat("v", 60)
+		}
+		u.SetLabels(l)
+		return u
+	}
+	targets := []cleanerv1alpha1.TargetStatus{
+		{Name: "small", APIVersion: "v1", Kind: "ConfigMap", State: state("small", 1)},
+		{Name: "large", APIVersion: "v1", Kind: "ConfigMap", State: state("large", 60)},
+	}
+	testCases := map[string]struct {
+		maxSize       int
+		wantTruncated bool
+		wantData      bool
+		wantStates    []string
+	}{
+		"fits": {
+			maxSize:    16 * 1024,
+			wantData:   true,
+			wantStates: []string{"small", "large"},
+		},
+		"metadata fits": {
+			maxSize:       8 * 1024,
+			wantTruncated: true,
+			wantStates:    []string{"small", "large"},
+		},
+		"metadata of the first target fits": {
+			maxSize:       1024,
+			wantTruncated: true,
+			wantStates:    []string{"small"},
+		},
+		"nothing fits": {
+			maxSize:       100,
+			wantTruncated: true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			r := &ConditionalTTLReconciler{MaxTargetStateSize: tc.maxSize}
+			got, truncated := r.limitTargetStates(targets)
+			if truncated != tc.wantTruncated {
+				t.Errorf("got truncated=%t, want %t", truncated, tc.wantTruncated)
+			}
+			var states []string
+			for _, ts := range got {
+				if ts.Name == "" || ts.Kind != "ConfigMap" {
+					t.Errorf("expected the target to be kept, got %v", ts)
+				}
+				if ts.State == nil {
+					continue
+				}
+				states = append(states, ts.State.GetName())
+				if _, ok := ts.State.Object["data"]; ok != tc.wantData {
+					t.Errorf("got data in the state of %s=%t, want %t", ts.Name, ok, tc.wantData)
+				}
+			}
+			if !reflect.DeepEqual(states, tc.wantStates) {
+				t.Errorf("got states %v, want %v", states, tc.wantStates)
+			}
+			if targets[1].State.Object["data"] == nil {
+				t.Error("expected the given targets to be left unchanged")
+			}
+		})
+	}
+}
+
+func Test_cloudEventTruncatesLargeTargetStates(t *testing.T) {
+	cTTL := newDeletedTestCTTL("large-event", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	cTTL.Status.Targets = []cleanerv1alpha1.TargetStatus{{
+		Name: "configmap",
+		State: &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "large"},
+			"data":       map[string]interface{}{"blob": strings.Repeat("x", 4096)},
+		}},
+	}}
+	r := newTestReconciler(t, cTTL)
+	r.MaxTargetStateSize = 1024
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	reconcileUntilGone(t, r, cTTL)
+
+	if len(ce.sent) != 1 {
+		t.Fatalf("got %d cloud events, want 1", len(ce.sent))
+	}
+	if size := len(ce.sent[0].Data()); size > r.MaxTargetStateSize {
+		t.Errorf("got %d bytes of event data, want at most %d", size, r.MaxTargetStateSize)
+	}
+	if !strings.Contains(string(ce.sent[0].Data()), `"name":"large"`) {
+		t.Errorf("expected the metadata to be sent, got %s", ce.sent[0].Data())
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "TargetStateTruncated"); got != 1 {
+		t.Errorf("got %d TargetStateTruncated events, want 1", got)
+	}
+}
+
+func Test_cloudEventIncludesHelmUninstall(t *testing.T) {
+	cTTL := newDeletedTestCTTL("helm-event", "cleaner.vtex.io/release-finalizer", "cleaner.vtex.io/cloud-event-finalizer", "test/keep")
+	// continues past the failed hook so that the event is sent
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true, FailurePolicy: cleanerv1alpha1.HelmFailurePolicyContinue}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	releases := storage.Init(driver.NewMemory())
+	if err := releases.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   4,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Hooks: []*release.Hook{{
+			Name:    "cleanup",
+			Kind:    "Job",
+			Events:  []release.HookEvent{release.HookPostDelete},
+			LastRun: release.HookExecution{Phase: release.HookPhaseUnknown},
+		}, {
+			// failed long before the uninstall
+			Name:    "migrate",
+			Kind:    "Job",
+			Events:  []release.HookEvent{release.HookPostInstall},
+			LastRun: release.HookExecution{Phase: release.HookPhaseFailed},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases: releases,
+		KubeClient: &kubefake.FailingKubeClient{
+			PrintingKubeClient:   kubefake.PrintingKubeClient{Out: io.Discard},
+			WatchUntilReadyError: errors.New("job failed"),
+		},
+		Log: func(string, ...interface{}) {},
+	}
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if len(found.Status.HelmUninstalls) != 1 {
+		t.Fatalf("got uninstalls %v, want 1", found.Status.HelmUninstalls)
+	}
+	u := found.Status.HelmUninstalls[0]
+	if u.Release != "my-release" || u.Namespace != "default" || u.Version != 4 || u.UninstalledAt.IsZero() || !reflect.DeepEqual(u.HookFailures, []string{"cleanup"}) {
+		t.Errorf("got uninstall %+v", u)
+	}
+
+	if len(ce.sent) != 1 {
+		t.Fatalf("got %d cloud events, want 1", len(ce.sent))
+	}
+	var data struct {
+		Helm []struct {
+			Release       string   `json:"release"`
+			Namespace     string   `json:"namespace"`
+			Version       int      `json:"version"`
+			UninstalledAt string   `json:"uninstalledAt"`
+			HookFailures  []string `json:"hookFailures"`
+		} `json:"helm"`
+	}
+	if err := json.Unmarshal(ce.sent[0].Data(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Helm) != 1 || data.Helm[0].Release != "my-release" || data.Helm[0].Version != 4 || data.Helm[0].UninstalledAt == "" || !reflect.DeepEqual(data.Helm[0].HookFailures, []string{"cleanup"}) {
+		t.Errorf("got helm section %+v in %s", data.Helm, ce.sent[0].Data())
+	}
+}
+
+func Test_cloudEventAttributes(t *testing.T) {
+	testCases := map[string]struct {
+		config         *cleanerv1alpha1.CloudEventConfig
+		wantType       string
+		wantSource     string
+		wantSubject    string
+		wantExtensions map[string]interface{}
+	}{
+		"defaults": {
+			wantType:    "conditionalTTL.deleted",
+			wantSource:  "cleaner.vtex.io/finalizer",
+			wantSubject: "default/attributes",
+		},
+		"overridden": {
+			config: &cleanerv1alpha1.CloudEventConfig{
+				Type:       "io.vtex.payments.preview.deleted",
+				Source:     "payments/previews",
+				Subject:    "store1",
+				Extensions: map[string]string{"team": "payments"},
+			},
+			wantType:       "io.vtex.payments.preview.deleted",
+			wantSource:     "payments/previews",
+			wantSubject:    "store1",
+			wantExtensions: map[string]interface{}{"team": "payments"},
+		},
+		"only type": {
+			config:      &cleanerv1alpha1.CloudEventConfig{Type: "io.vtex.payments.preview.deleted"},
+			wantType:    "io.vtex.payments.preview.deleted",
+			wantSource:  "cleaner.vtex.io/finalizer",
+			wantSubject: "default/attributes",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newDeletedTestCTTL("attributes", "cleaner.vtex.io/cloud-event-finalizer")
+			cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+			cTTL.Spec.CloudEvent = tc.config
+			r := newTestReconciler(t, cTTL)
+			ce := &fakeCloudEventsClient{}
+			r.CloudEventsClient = ce
+
+			reconcileUntilGone(t, r, cTTL)
+			if len(ce.sent) != 1 {
+				t.Fatalf("got %d cloud events, want 1", len(ce.sent))
+			}
+			e := ce.sent[0]
+			if e.Type() != tc.wantType || e.Source() != tc.wantSource || e.Subject() != tc.wantSubject {
+				t.Errorf("got type=%q source=%q subject=%q, want %q, %q and %q", e.Type(), e.Source(), e.Subject(), tc.wantType, tc.wantSource, tc.wantSubject)
+			}
+			if got := e.Extensions(); len(got)+len(tc.wantExtensions) > 0 && !reflect.DeepEqual(got, tc.wantExtensions) {
+				t.Errorf("got extensions %v, want %v", got, tc.wantExtensions)
+			}
+		})
+	}
+}
+
+func Test_cloudEventLifecycle(t *testing.T) {
+	allStages := []cleanerv1alpha1.CloudEventStage{
+		cleanerv1alpha1.CloudEventStageExpired,
+		cleanerv1alpha1.CloudEventStageConditionsMet,
+		cleanerv1alpha1.CloudEventStageDeletionFailed,
+		cleanerv1alpha1.CloudEventStageDeleted,
+	}
+	testCases := map[string]struct {
+		events    []cleanerv1alpha1.CloudEventStage
+		result    cloudevents.Result
+		wantTypes []string
+	}{
+		"default": {
+			wantTypes: []string{"conditionalTTL.deleted"},
+		},
+		"every stage": {
+			events:    allStages,
+			wantTypes: []string{"conditionalTTL.expired", "conditionalTTL.conditionsMet", "conditionalTTL.deleted"},
+		},
+		"undelivered lifecycle events": {
+			events:    []cleanerv1alpha1.CloudEventStage{cleanerv1alpha1.CloudEventStageExpired, cleanerv1alpha1.CloudEventStageConditionsMet},
+			result:    errors.New("connection refused"),
+			wantTypes: []string{"conditionalTTL.expired", "conditionalTTL.conditionsMet"},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("lifecycle")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "lifecycle-pod")}
+			cTTL.Spec.Conditions = []string{`true`}
+			cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+			cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{Events: tc.events}
+			r := newTestReconciler(t, cTTL, newTestPod("lifecycle-pod"))
+			ce := &fakeCloudEventsClient{result: tc.result}
+			r.CloudEventsClient = ce
+
+			// lifecycle events which can't be delivered don't hold the cTTL back
+			reconcileUntilGone(t, r, cTTL)
+			r.lifecycleEvents.wait()
+			gotTypes := []string{}
+			for _, e := range ce.sent {
+				gotTypes = append(gotTypes, e.Type())
+			}
+			// the deleted event may be sent before the lifecycle events
+			// delivered in the background, which keep their order
+			deleted := func(types []string) (int, []string) {
+				others := slices.DeleteFunc(slices.Clone(types), func(t string) bool { return t == "conditionalTTL.deleted" })
+				return len(types) - len(others), others
+			}
+			gotDeleted, gotOthers := deleted(gotTypes)
+			wantDeleted, wantOthers := deleted(tc.wantTypes)
+			if gotDeleted != wantDeleted || !slices.Equal(gotOthers, wantOthers) {
+				t.Errorf("got event types %v, want %v", gotTypes, tc.wantTypes)
+			}
+			wantFailures := 0
+			if tc.result != nil {
+				wantFailures = len(tc.wantTypes)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "EventDeliveryFailed"); got != wantFailures {
+				t.Errorf("got %d EventDeliveryFailed events, want %d", got, wantFailures)
+			}
+		})
+	}
+}
+
+func Test_cloudEventLifecycleHangingSink(t *testing.T) {
+	cTTL := newKeptTestCTTL("hanging-sink")
+	cTTL.Spec.Conditions = []string{"false"}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{Events: []cleanerv1alpha1.CloudEventStage{cleanerv1alpha1.CloudEventStageExpired}}
+	r := newTestReconciler(t, cTTL)
+	r.CloudEventTimeout = time.Hour
+	ce := &fakeCloudEventsClient{block: make(chan struct{})}
+	r.CloudEventsClient = ce
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		close(ce.block)
+		t.Fatal("expected the reconcile not to wait for the sink")
+	}
+	if found := getTestCTTL(t, r, cTTL); found.Status.ExpiredAt == nil {
+		t.Error("expected the expiry to be recorded while the event is pending")
+	}
+
+	close(ce.block)
+	r.lifecycleEvents.wait()
+	if len(ce.sent) != 1 || ce.sent[0].Type() != "conditionalTTL.expired" {
+		t.Errorf("got cloud events %v, want the expired event once the sink answers", ce.sent)
+	}
+}
+
+func Test_cloudEventDeletionFailed(t *testing.T) {
+	cTTL := newDeletedTestCTTL("deletion-failed", "cleaner.vtex.io/release-finalizer", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: true}
+	cTTL.Spec.CloudEventSink = ptr.To("http://sink.example.com")
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{Events: []cleanerv1alpha1.CloudEventStage{
+		cleanerv1alpha1.CloudEventStageDeletionFailed,
+		cleanerv1alpha1.CloudEventStageDeleted,
+	}}
+	releases := storage.Init(driver.NewMemory())
+	if err := releases.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info:      &release.Info{Status: release.StatusDeployed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(t, cTTL)
+	r.HelmConfig = &action.Configuration{
+		Releases: releases,
+		KubeClient: &kubefake.FailingKubeClient{
+			PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard},
+			BuildError:         errors.New("forbidden"),
+		},
+		Log: func(string, ...interface{}) {},
+	}
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+			t.Fatalf("reconcile %d: expected the uninstall to fail", i)
+		}
+	}
+	r.lifecycleEvents.wait()
+	if len(ce.sent) != 1 {
+		t.Fatalf("got %d cloud events, want one when the finalizer started failing", len(ce.sent))
+	}
+	e := ce.sent[0]
+	if e.Type() != "conditionalTTL.deletionFailed" {
+		t.Errorf("got type %q, want conditionalTTL.deletionFailed", e.Type())
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(e.Data(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["finalizer"] != "cleaner.vtex.io/release-finalizer" || data["error"] == "" || data["name"] != "deletion-failed" {
+		t.Errorf("got data %v", data)
+	}
+}
+
+func Test_reconcileConditionalTTLTargets(t *testing.T) {
+	testCases := map[string]struct {
+		allow bool
+	}{
+		"not allowed": {},
+		"allowed":     {allow: true},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			labels := map[string]string{"cleanup": "batch"}
+			sibling := newTestCTTL("sibling")
+			sibling.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+			sibling.Labels = labels
+			cTTL := newKeptTestCTTL("self")
+			cTTL.Labels = labels
+			cTTL.Spec.AllowConditionalTTLTargets = tc.allow
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{{
+				Name:   "cttls",
+				Delete: true,
+				Reference: cleanerv1alpha1.TargetReference{
+					TypeMeta:      metav1.TypeMeta{APIVersion: "cleaner.vtex.io/v1alpha1", Kind: "ConditionalTTL"},
+					LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+				},
+			}}
+			r := newTestReconciler(t, cTTL, sibling)
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if !tc.allow {
+				cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonInvalidTargetReference || !strings.Contains(cond.Message, "allowConditionalTTLTargets") {
+					t.Errorf("got condition %v, want targeting ConditionalTTLs to be rejected", cond)
+				}
+				return
+			}
+			items, _, _ := unstructured.NestedSlice(found.Status.Targets[0].State.Object, "items")
+			if len(items) != 1 || items[0].(map[string]interface{})["metadata"].(map[string]interface{})["name"] != "sibling" {
+				t.Errorf("expected only the sibling to be targeted, got %v", items)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "SelfTargetSkipped"); got != 1 {
+				t.Errorf("got %d SelfTargetSkipped events, want 1", got)
+			}
+
+			// the target finalizer deletes the sibling
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			err := r.Get(context.TODO(), client.ObjectKeyFromObject(sibling), &cleanerv1alpha1.ConditionalTTL{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("expected the sibling to be deleted, got err=%v", err)
+			}
+		})
+	}
+}
+
+func Test_reconcileMetadataOnlyTargets(t *testing.T) {
+	testCases := map[string]struct {
+		target    cleanerv1alpha1.Target
+		condition string
+		wantLeft  int
+	}{
+		"single": {
+			target:    newPodTarget("pod", "meta-0"),
+			condition: `pod.metadata.labels.app == "meta" && !has(pod.spec)`,
+			wantLeft:  1,
+		},
+		"list": {
+			target:    newPodListTarget("pod", map[string]string{"app": "meta"}),
+			condition: `size(pod.items) == 2 && pod.items.all(p, p.metadata.labels.app == "meta" && !has(p.spec))`,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			objs := []client.Object{}
+			for i := 0; i < 2; i++ {
+				pod := newTestPod(fmt.Sprintf("meta-%d", i))
+				pod.Labels = map[string]string{"app": "meta"}
+				pod.Spec.Containers = []corev1.Container{{Name: "c", Image: "i"}}
+				objs = append(objs, pod)
+			}
+			cTTL := newTestCTTL("metadata-only")
+			target := tc.target
+			target.MetadataOnly = true
+			target.IncludeWhenEvaluating = ptr.To(true)
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+			cTTL.Spec.Conditions = []string{tc.condition}
+			r := newTestReconciler(t, append(objs, cTTL)...)
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if found.Status.EvaluationTime == nil {
+				cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				t.Fatalf("expected conditions on metadata to be met, got %v", cond)
+			}
+			state := found.Status.Targets[0].State
+			if _, ok := state.Object["spec"]; ok {
+				t.Errorf("expected spec to be absent from the state, got %v", state.Object)
+			}
+
+			reconcileUntilGone(t, r, cTTL)
+			pods := &corev1.PodList{}
+			if err := r.List(context.TODO(), pods); err != nil {
+				t.Fatal(err)
+			}
+			if len(pods.Items) != tc.wantLeft {
+				t.Errorf("got %d pods left, want %d", len(pods.Items), tc.wantLeft)
+			}
+		})
+	}
+}
+
+func Test_reconcileStateInclusion(t *testing.T) {
+	testCases := map[string]struct {
+		inclusion cleanerv1alpha1.StateInclusion
+		wantState bool
+		wantSpec  bool
+	}{
+		"default":       {wantState: true, wantSpec: true},
+		"full":          {inclusion: cleanerv1alpha1.StateInclusionFull, wantState: true, wantSpec: true},
+		"metadata only": {inclusion: cleanerv1alpha1.StateInclusionMetadataOnly, wantState: true},
+		"none":          {inclusion: cleanerv1alpha1.StateInclusionNone},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("state-inclusion")
+			target := newPodTarget("pod", "private-pod")
+			target.IncludeWhenEvaluating = ptr.To(true)
+			target.StateInclusion = tc.inclusion
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			// conditions are still evaluated on the full state
+			cTTL.Spec.Conditions = []string{`pod.spec.containers[0].env[0].value == "secret"`}
+			pod := newTestPod("private-pod")
+			pod.Spec.Containers = []corev1.Container{{
+				Name: "app",
+				Env:  []corev1.EnvVar{{Name: "TOKEN", Value: "secret"}},
+			}}
+			r := newTestReconciler(t, cTTL, pod)
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if found.Status.EvaluationTime == nil {
+				t.Fatal("expected conditions to be met")
+			}
+			state := found.Status.Targets[0].State
+			if gotState := state != nil; gotState != tc.wantState {
+				t.Fatalf("got state %v, wantState=%v", state, tc.wantState)
+			}
+			if state == nil {
+				return
+			}
+			if _, gotSpec := state.Object["spec"]; gotSpec != tc.wantSpec {
+				t.Errorf("got state %v, wantSpec=%v", state.Object, tc.wantSpec)
+			}
+			if state.GetName() != "private-pod" {
+				t.Errorf("expected metadata to be kept, got %v", state.Object)
+			}
+		})
+	}
+}
+
+func Test_reconcileRedactsSecrets(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "credentials",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "job"},
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"data":{"token":"c2VjcmV0"}}`,
+				"team":                             "cleaner",
+			},
+		},
+		Data: map[string][]byte{"token": []byte("secret")},
+	}
+	cTTL := newKeptTestCTTL("secrets")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{
+		{
+			Name:                  "secret",
+			IncludeWhenEvaluating: ptr.To(true),
+			Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				Name:     ptr.To("credentials"),
+			},
+		},
+		{
+			Name:                  "secrets",
+			IncludeWhenEvaluating: ptr.To(true),
+			Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "SecretList"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "job"}},
+			},
+		},
+	}
+	// conditions are still evaluated on the Secrets' data
+	cTTL.Spec.Conditions = []string{`has(secret.data.token) && secrets.items.all(s, has(s.data.token))`}
+	r := newTestReconciler(t, cTTL, secret)
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if found.Status.EvaluationTime == nil {
+		t.Fatal("expected conditions to be met")
+	}
+	items, _, _ := unstructured.NestedSlice(found.Status.Targets[1].State.Object, "items")
+	if len(items) != 1 {
+		t.Fatalf("got %d listed Secrets, want 1", len(items))
+	}
+	for _, obj := range []map[string]interface{}{found.Status.Targets[0].State.Object, items[0].(map[string]interface{})} {
+		if _, ok := obj["data"]; ok {
+			t.Errorf("expected data to be redacted, got %v", obj)
+		}
+		annotations, _, _ := unstructured.NestedStringMap(obj, "metadata", "annotations")
+		if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok || annotations["team"] != "cleaner" {
+			t.Errorf("expected only the last applied configuration to be redacted, got %v", annotations)
+		}
+	}
+}
+
+func Test_reconcileRejectsUndeclaredReferences(t *testing.T) {
+	cTTL := newTestCTTL("undeclared")
+	// reported before expiring
+	cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+	target := newPodListTarget("pods", map[string]string{"app": "job"})
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Conditions = []string{`pods.items.all(p, p.status.phase == "Succeeded")`}
+	r := newTestReconciler(t, cTTL)
+
+	// reported once per generation
+	for i := 0; i < 2; i++ {
+		res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if res != (ctrl.Result{}) {
+			t.Errorf("got %+v, want no requeue", res)
+		}
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonReferenceError {
+		t.Fatalf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonReferenceError)
+	}
+	want := `condition 0 references target "pods" which is not included when evaluating, set includeWhenEvaluating on it`
+	if !strings.Contains(cond.Message, want) {
+		t.Errorf("got message %q, want it to name the target and condition", cond.Message)
+	}
+	events := drainEvents(r.Recorder.(*record.FakeRecorder))
+	if got := countEvents(events, cleanerv1alpha1.ConditionReasonReferenceError); got != 1 {
+		t.Errorf("got %d %s events, want 1: %v", got, cleanerv1alpha1.ConditionReasonReferenceError, events)
+	}
+}
+
+func Test_reconcileNamePattern(t *testing.T) {
+	testCases := map[string]struct {
+		pod        string
+		wantDelete bool
+	}{
+		"matching name":     {pod: "preview-1234", wantDelete: true},
+		"not matching name": {pod: "production"},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("name-pattern")
+			target := newPodTarget("pod", tc.pod)
+			target.IncludeWhenEvaluating = ptr.To(true)
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			cTTL.Spec.Conditions = []string{`pod.metadata.name.matches("^preview-[0-9]+$")`}
+			cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Minute}}
+			cTTL.Finalizers = []string{"test/keep"}
+			r := newTestReconciler(t, cTTL, newTestPod(tc.pod))
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if gotDelete := !found.DeletionTimestamp.IsZero(); gotDelete != tc.wantDelete {
+				cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				t.Errorf("got deletion=%v, want %v (condition %v)", gotDelete, tc.wantDelete, cond)
+			}
+		})
+	}
+}
+
+func Test_reconcileLastEvaluationError(t *testing.T) {
+	cTTL := newTestCTTL("evaluation-error")
+	target := newPodTarget("pod", "evaluated-pod")
+	target.IncludeWhenEvaluating = ptr.To(true)
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Conditions = []string{`pod.metadata.labels["missing"] == "value"`}
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Minute}}
+	r := newTestReconciler(t, cTTL, newTestPod("evaluated-pod"))
+
+	get := func() *cleanerv1alpha1.ConditionalTTL {
+		found := getTestCTTL(t, r, cTTL)
+		return found
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	found := get()
+	e := found.Status.LastEvaluationError
+	if e == nil {
+		t.Fatal("expected the evaluation error to be recorded")
+	}
+	if e.ConsecutiveFailures != 2 {
+		t.Errorf("got %d consecutive failures, want 2", e.ConsecutiveFailures)
+	}
+	if !strings.Contains(e.Message, "no such key") || e.Time.IsZero() {
+		t.Errorf("unexpected evaluation error %+v", e)
+	}
+
+	found.Spec.Conditions = []string{`pod.metadata.name == "other-pod"`}
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := get().Status.LastEvaluationError; e != nil {
+		t.Errorf("expected the evaluation error to be cleared, got %+v", e)
+	}
+}
+
+func Test_reconcileEmptyListTarget(t *testing.T) {
+	cTTL := newTestCTTL("empty-list")
+	target := newPodListTarget("pods", map[string]string{"app": "none"})
+	target.IncludeWhenEvaluating = ptr.To(true)
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Conditions = []string{`size(pods.items) == 0`}
+	cTTL.Finalizers = []string{"test/keep"}
+	r := newTestReconciler(t, cTTL, newTestPod("unrelated"))
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if found.DeletionTimestamp.IsZero() {
+		cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		t.Errorf("expected the conditions to be met on an empty list, got condition %v", cond)
+	}
+}
+
+func Test_reconcileTracing(t *testing.T) {
+	cTTL := newTestCTTL("traced")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "traced-pod")}
+	cTTL.Spec.Conditions = []string{`true`}
+	r := newTestReconciler(t, cTTL, newTestPod("traced-pod"))
+	sr := tracetest.NewSpanRecorder()
+	r.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	reconcileUntilGone(t, r, cTTL)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range sr.Ended() {
+		spans[s.Name()] = append(spans[s.Name()], s)
+	}
+	for _, name := range []string{"Reconcile", "ResolveTargets", "EvaluateCELConditions", "Finalizer"} {
+		if len(spans[name]) == 0 {
+			t.Fatalf("expected a %s span, got %v", name, spans)
+		}
+	}
+	reconcile := spans["Reconcile"][0]
+	if got := spanAttribute(reconcile, "cleaner.conditionalttl.name"); got.AsString() != "traced" {
+		t.Errorf("expected the reconcile span to name the cTTL, got %v", got)
+	}
+	evaluation := spans["EvaluateCELConditions"][0]
+	if evaluation.Parent().SpanID() != reconcile.SpanContext().SpanID() {
+		t.Error("expected the evaluation span to be a child of the reconcile span")
+	}
+	if !spanAttribute(evaluation, "cleaner.conditions_met").AsBool() {
+		t.Error("expected the evaluation span to record the conditions were met")
+	}
+	handled := []string{}
+	for _, s := range spans["Finalizer"] {
+		handled = append(handled, spanAttribute(s, "cleaner.finalizer").AsString())
+	}
+	if want := []string{"cleaner.vtex.io/target-finalizer"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("expected a span per finalizer %v, got %v", want, handled)
+	}
+}
+
+// spanAttribute returns the value of the span's attribute named key.
+func spanAttribute(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func Test_reconcileAnnotationSelector(t *testing.T) {
+	testCases := map[string]struct {
+		labelSelector *metav1.LabelSelector
+		wantLeft      []string
+	}{
+		"with label selector": {
+			labelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "annotated"}},
+			wantLeft:      []string{"other-app", "unannotated"},
+		},
+		"without label selector": {
+			wantLeft: []string{"unannotated"},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			objs := []client.Object{}
+			for name, app := range map[string]string{"annotated": "annotated", "unannotated": "annotated", "other-app": "other"} {
+				pod := newTestPod(name)
+				pod.Labels = map[string]string{"app": app}
+				if name != "unannotated" {
+					pod.Annotations = map[string]string{"cleaner.vtex.io/cleanup": "true"}
+				}
+				objs = append(objs, pod)
+			}
+			cTTL := newTestCTTL("annotations")
+			target := newPodListTarget("pods", nil)
+			target.Reference.LabelSelector = tc.labelSelector
+			target.Reference.AnnotationSelector = &cleanerv1alpha1.AnnotationSelector{
+				MatchAnnotations: map[string]string{"cleaner.vtex.io/cleanup": "true"},
+			}
+			target.IncludeWhenEvaluating = ptr.To(true)
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			cTTL.Spec.Conditions = []string{fmt.Sprintf("size(pods.items) == %d", 3-len(tc.wantLeft))}
+			r := newTestReconciler(t, append(objs, cTTL)...)
+
+			reconcileUntilGone(t, r, cTTL)
+			pods := &corev1.PodList{}
+			if err := r.List(context.TODO(), pods); err != nil {
+				t.Fatal(err)
+			}
+			var left []string
+			for _, p := range pods.Items {
+				left = append(left, p.Name)
+			}
+			sort.Strings(left)
+			if !reflect.DeepEqual(left, tc.wantLeft) {
+				t.Errorf("got pods %v left, want %v", left, tc.wantLeft)
+			}
+			if countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "TargetsFiltered") == 0 {
+				t.Error("expected an event reporting the filtered out objects")
+			}
+		})
+	}
+}
+
+func Test_reconcileClusterScopedTarget(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "expired-ns", Labels: map[string]string{"app": "scoped"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other-ns"}}
+	name := ns.Name
+	cTTL := newTestCTTL("cluster-scoped")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{
+		{
+			Name:   "ns",
+			Delete: true,
+			Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+				Name:     &name,
+			},
+		},
+		{
+			Name:                  "nsList",
+			IncludeWhenEvaluating: ptr.To(true),
+			Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "NamespaceList"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "scoped"}},
+			},
+		},
+	}
+	cTTL.Spec.Conditions = []string{`size(nsList.items) == 1`}
+	r := newTestReconciler(t, ns, other, cTTL)
+
+	reconcileUntilGone(t, r, cTTL)
+	err := r.Get(context.TODO(), client.ObjectKeyFromObject(ns), &corev1.Namespace{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the expired Namespace to be deleted, got %v", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(other), &corev1.Namespace{}); err != nil {
+		t.Errorf("expected other Namespace to be kept, got %v", err)
+	}
+}
+
+func Test_reconcileAccumulatesHistory(t *testing.T) {
+	cTTL := newKeptTestCTTL("history")
+	target := newPodTarget("pod", "history-pod")
+	target.Delete = false
+	target.IncludeWhenEvaluating = ptr.To(true)
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+	cTTL.Spec.HistoryLimit = 2
+	cTTL.Spec.Conditions = []string{`size(history) == 2 && history.all(h, h.targets.pod.count == 1)`}
+	r := newTestReconciler(t, cTTL, newTestPod("history-pod"))
+
+	found := &cleanerv1alpha1.ConditionalTTL{}
+	for i, wantLen := range []int{1, 2} {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(found.Status.History); got != wantLen {
+			t.Fatalf("reconcile %d: got %d history entries, want %d", i, got, wantLen)
+		}
+		if found.Status.EvaluationTime != nil {
+			t.Fatalf("reconcile %d: conditions should not be met before history accumulates", i)
+		}
+	}
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.EvaluationTime == nil {
+		t.Error("expected conditions referencing history to be met")
+	}
+	if got := len(found.Status.History); got != 2 {
+		t.Errorf("got %d history entries, want it capped at 2", got)
+	}
+}
+
+func Test_reconcileExposesPreviousState(t *testing.T) {
+	testCases := map[string]struct {
+		keep    bool
+		wantMet bool
+	}{
+		"kept":     {keep: true, wantMet: true},
+		"not kept": {keep: false, wantMet: false},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("previous")
+			target := newPodTarget("pod", "previous-pod")
+			target.Delete = false
+			target.IncludeWhenEvaluating = ptr.To(true)
+			// not selected from previous so its state isn't kept
+			other := newPodTarget("other", "other-pod")
+			other.Delete = false
+			other.IncludeWhenEvaluating = ptr.To(true)
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target, other}
+			cTTL.Spec.KeepPreviousState = tc.keep
+			cTTL.Spec.Conditions = []string{`has(previous.pod) && previous.pod.status.phase == "Succeeded" && pod.status.phase == "Succeeded"`}
+			pod := newTestPod("previous-pod")
+			pod.Status.Phase = corev1.PodSucceeded
+			r := newTestReconciler(t, cTTL, pod, newTestPod("other-pod"))
+
+			found := &cleanerv1alpha1.ConditionalTTL{}
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+				t.Fatal(err)
+			}
+			if found.Status.EvaluationTime != nil {
+				t.Fatal("conditions should not be met without a previous state")
+			}
+			if got := found.Status.PreviousTargets; tc.keep != (len(got) == 1 && got[0].Name == "pod") {
+				t.Fatalf("got previous targets %v, keep=%v", got, tc.keep)
+			}
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+				t.Fatal(err)
+			}
+			if gotMet := found.Status.EvaluationTime != nil; gotMet != tc.wantMet {
+				t.Errorf("got conditions met=%v, want %v", gotMet, tc.wantMet)
+			}
+			if tc.wantMet && len(found.Status.PreviousTargets) != 0 {
+				t.Errorf("expected the previous state to be dropped once conditions are met, got %v", found.Status.PreviousTargets)
+			}
+		})
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	d := time.Minute
+	min, max := d-d/10, d+d/10
+	r := &ConditionalTTLReconciler{RequeueJitter: 0.1, Rand: rand.New(rand.NewSource(1))}
+	seeded := &ConditionalTTLReconciler{RequeueJitter: 0.1, Rand: rand.New(rand.NewSource(1))}
+
+	shortened, extended := false, false
+	for i := 0; i < 1000; i++ {
+		got := r.jitter(d)
+		if got < min || got > max {
+			t.Fatalf("got %s, want it within [%s, %s]", got, min, max)
+		}
+		if again := seeded.jitter(d); again != got {
+			t.Fatalf("jitter is not deterministic for the same seed: got %s and %s", got, again)
+		}
+		shortened = shortened || got < d
+		extended = extended || got > d
+	}
+	if !shortened || !extended {
+		t.Errorf("expected requeues to be both shortened and extended, got shortened=%v extended=%v", shortened, extended)
+	}
+
+	disabled := &ConditionalTTLReconciler{}
+	if got := disabled.jitter(d); got != d {
+		t.Errorf("got %s with jitter disabled, want %s", got, d)
+	}
+}
+
+func Test_reconcileExtraContext(t *testing.T) {
+	testCases := map[string]struct {
+		flag        string
+		wantMet     bool
+		wantReason  string
+		noConfigMap bool
+	}{
+		"enabled":  {flag: "true", wantMet: true, wantReason: cleanerv1alpha1.ConditionReasonTerminating},
+		"disabled": {flag: "false", wantMet: false, wantReason: cleanerv1alpha1.ConditionReasonWaitingForConditions},
+		"missing":  {noConfigMap: true, wantReason: cleanerv1alpha1.ConditionReasonContextResolveError},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("extra-context")
+			cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: time.Second}}
+			cTTL.Spec.ExtraContext = []cleanerv1alpha1.ContextValue{
+				{Name: "cleanup", ConfigMapKeyRef: &cleanerv1alpha1.KeySelector{Name: "flags", Key: "cleanup"}},
+				{Name: "token", SecretKeyRef: &cleanerv1alpha1.KeySelector{Name: "creds", Key: "token"}},
+				{Name: "missing", ConfigMapKeyRef: &cleanerv1alpha1.KeySelector{Name: "nope", Key: "x", Optional: true}},
+			}
+			cTTL.Spec.Conditions = []string{`cleanup == "true" && token == "s3cr3t" && missing == ""`}
+			objs := []client.Object{cTTL, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+				Data:       map[string][]byte{"token": []byte("s3cr3t")},
+			}}
+			if !tc.noConfigMap {
+				objs = append(objs, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "flags", Namespace: "default"},
+					Data:       map[string]string{"cleanup": tc.flag},
+				})
+			}
+			r := newTestReconciler(t, objs...)
+
+			_, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if tc.noConfigMap != (err != nil) {
+				t.Fatalf("got err=%v", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != tc.wantReason {
+				t.Fatalf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+			if strings.Contains(cond.Message, "s3cr3t") {
+				t.Errorf("condition message leaks the secret value: %s", cond.Message)
+			}
+			if gotMet := found.Status.EvaluationTime != nil; gotMet != tc.wantMet {
+				t.Errorf("conditions met: got=%v want=%v", gotMet, tc.wantMet)
+			}
+		})
+	}
+}
+
+func Test_reconcileAddsRequiredFinalizers(t *testing.T) {
+	testCases := map[string]struct {
+		delete     bool
+		helm       bool
+		helmDelete bool
+		releases   bool
+		sink       bool
+		want       []string
+	}{
+		"nothing to clean up": {},
+		"targets kept": {
+			helm: true,
+		},
+		"targets deleted": {
+			delete: true,
+			want:   []string{"cleaner.vtex.io/target-finalizer"},
+		},
+		"helm release": {
+			helm:       true,
+			helmDelete: true,
+			want:       []string{"cleaner.vtex.io/release-finalizer"},
+		},
+		"helm release list": {
+			releases: true,
+			want:     []string{"cleaner.vtex.io/release-finalizer"},
+		},
+		"cloud event sink": {
+			sink: true,
+			want: []string{"cleaner.vtex.io/cloud-event-finalizer"},
+		},
+		"all": {
+			delete:     true,
+			helm:       true,
+			helmDelete: true,
+			sink:       true,
+			want:       finalizerNames(),
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("required-finalizers")
+			target := newPodTarget("pod", "required-pod")
+			target.Delete = tc.delete
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+			if tc.helm {
+				cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "my-release", Delete: tc.helmDelete}
+			}
+			if tc.releases {
+				cTTL.Spec.HelmReleases = []cleanerv1alpha1.HelmConfig{{Release: "kept"}, {Release: "deleted", Delete: true}}
+			}
+			if tc.sink {
+				cTTL.Spec.CloudEventSink = ptr.To("http://localhost")
+			}
+			r := newTestReconciler(t, cTTL, newTestPod("required-pod"))
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			found := &cleanerv1alpha1.ConditionalTTL{}
+			err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found)
+			if len(tc.want) == 0 {
+				// without finalizers the cTTL is removed right away
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected the cTTL to be gone, got err=%v finalizers=%v", err, found.Finalizers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.DeletionTimestamp.IsZero() {
+				t.Fatal("expected the cTTL to be deleted")
+			}
+			if !reflect.DeepEqual(found.Finalizers, tc.want) {
+				t.Errorf("got finalizers %v, want %v", found.Finalizers, tc.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileKeepsTargets(t *testing.T) {
+	cTTL := newTestCTTL("gate")
+	target := newPodTarget("pod", "gated-pod")
+	target.Delete = false
+	target.IncludeWhenEvaluating = ptr.To(true)
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	cTTL.Spec.Conditions = []string{`pod.metadata.name == "gated-pod"`}
+	cTTL.Spec.CloudEventSink = ptr.To("http://localhost")
+	r := newTestReconciler(t, cTTL, newTestPod("gated-pod"))
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if want := []string{"cleaner.vtex.io/cloud-event-finalizer"}; !reflect.DeepEqual(found.Finalizers, want) {
+		t.Errorf("got finalizers %v, want %v", found.Finalizers, want)
+	}
+
+	reconcileUntilGone(t, r, cTTL)
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "gated-pod"}, &corev1.Pod{}); err != nil {
+		t.Errorf("expected the target to be kept, got err=%v", err)
+	}
+	if len(ce.sent) != 1 {
+		t.Errorf("got %d cloud events, want 1", len(ce.sent))
+	}
+}
+
+func Test_reconcileWithoutDeletingSelf(t *testing.T) {
+	cTTL := newTestCTTL("audited")
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "audited-pod")}
+	cTTL.Spec.Conditions = []string{"true"}
+	cTTL.Spec.CloudEventSink = ptr.To("http://localhost")
+	cTTL.Spec.DeleteSelf = ptr.To(false)
+	r := newTestReconciler(t, cTTL, newTestPod("audited-pod"))
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	found := &cleanerv1alpha1.ConditionalTTL{}
+	for i := 0; i <= len(finalizers); i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+			t.Fatal(err)
+		}
+		if apimeta.IsStatusConditionTrue(found.Status.Conditions, cleanerv1alpha1.ConditionTypeCompleted) {
+			break
+		}
+	}
+	if !apimeta.IsStatusConditionTrue(found.Status.Conditions, cleanerv1alpha1.ConditionTypeCompleted) {
+		t.Fatalf("expected the cTTL to be completed, got conditions %v", found.Status.Conditions)
+	}
+	if !found.DeletionTimestamp.IsZero() || len(found.Finalizers) != 0 {
+		t.Errorf("expected the cTTL to be kept without finalizers, got deletionTimestamp=%v finalizers=%v", found.DeletionTimestamp, found.Finalizers)
+	}
+	if cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady); cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonCompleted {
+		t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonCompleted)
+	}
+	podKey := types.NamespacedName{Namespace: "default", Name: "audited-pod"}
+	if err := r.Get(context.TODO(), podKey, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the target to be deleted, got err=%v", err)
+	}
+
+	// a completed cTTL doesn't trigger again
+	if err := r.Create(context.TODO(), newTestPod("audited-pod")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), podKey, &corev1.Pod{}); err != nil {
+		t.Errorf("expected the recreated target to be kept, got err=%v", err)
+	}
+	if len(ce.sent) != 1 {
+		t.Errorf("got %d cloud events, want 1", len(ce.sent))
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), cleanerv1alpha1.ConditionReasonCompleted); got != 1 {
+		t.Errorf("got %d %s events, want 1", got, cleanerv1alpha1.ConditionReasonCompleted)
+	}
+}
+
+func Test_reconcileRequeuesMissingTargets(t *testing.T) {
+	testCases := map[string]struct {
+		retry   *cleanerv1alpha1.RetryConfig
+		getErr  error
+		want    time.Duration
+		wantErr bool
+	}{
+		"retry period": {
+			retry: &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: 7 * time.Second}},
+			want:  7 * time.Second,
+		},
+		"default period": {
+			want: DefaultMissingTargetsRetryPeriod,
+		},
+		"api failure": {
+			retry:   &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: 7 * time.Second}},
+			getErr:  apierrors.NewServiceUnavailable("unavailable"),
+			wantErr: true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("missing-target")
+			cTTL.Spec.Retry = tc.retry
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "missing-pod")}
+			r := newInterceptedTestReconciler(t, interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if tc.getErr != nil && key.Name == "missing-pod" {
+						return tc.getErr
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			}, cTTL)
+			// only unmet conditions wait for the reconciler's default
+			r.DefaultRetryPeriod = time.Hour
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+			}
+			if res.RequeueAfter != tc.want {
+				t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, tc.want)
+			}
+
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonTargetResolveError {
+				t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonTargetResolveError)
+			}
+		})
+	}
+}
+
+func Test_reconcileWaitsForOptionalTargets(t *testing.T) {
+	cTTL := newKeptTestCTTL("optional-target")
+	cTTL.Spec.Retry = &cleanerv1alpha1.RetryConfig{Period: &metav1.Duration{Duration: 7 * time.Second}}
+	target := newPodTarget("pod", "late-pod")
+	target.OptionalUntilFound = true
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+	r := newTestReconciler(t, cTTL)
+
+	res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.RequeueAfter != 7*time.Second {
+		t.Errorf("got RequeueAfter=%s, want the retry period", res.RequeueAfter)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	if cond == nil || cond.Status != metav1.ConditionUnknown || cond.Reason != cleanerv1alpha1.ConditionReasonWaitingForTargets {
+		t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonWaitingForTargets)
+	}
+	for _, e := range drainEvents(r.Recorder.(*record.FakeRecorder)) {
+		if strings.HasPrefix(e, corev1.EventTypeWarning) {
+			t.Errorf("unexpected warning event %q", e)
+		}
+	}
+
+	// the target shows up and the conditions are evaluated
+	if err := r.Create(context.TODO(), newTestPod("late-pod")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if found.DeletionTimestamp == nil {
+		t.Error("expected the cTTL to be deleted once its target was found")
+	}
+}
+
+func Test_reconcileReportsEveryTargetError(t *testing.T) {
+	invalid := cleanerv1alpha1.Target{
+		Name: "invalid",
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+		},
+	}
+	testCases := map[string]struct {
+		targets     []cleanerv1alpha1.Target
+		wantReason  string
+		wantRequeue bool
+		wantMessage []string
+	}{
+		"missing targets": {
+			targets:     []cleanerv1alpha1.Target{newPodTarget("first", "missing-0"), newPodTarget("second", "missing-1")},
+			wantReason:  cleanerv1alpha1.ConditionReasonTargetResolveError,
+			wantRequeue: true,
+			wantMessage: []string{`target "first"`, `target "second"`},
+		},
+		"missing and invalid targets": {
+			targets:     []cleanerv1alpha1.Target{newPodTarget("first", "missing-0"), invalid},
+			wantReason:  cleanerv1alpha1.ConditionReasonInvalidTargetReference,
+			wantMessage: []string{`target "first"`, `target "invalid"`},
+		},
+		"many targets": {
+			targets: func() []cleanerv1alpha1.Target {
+				var ts []cleanerv1alpha1.Target
+				for i := 0; i < 7; i++ {
+					ts = append(ts, newPodTarget(fmt.Sprintf("target-%d", i), fmt.Sprintf("missing-%d", i)))
+				}
+				return ts
+			}(),
+			wantReason:  cleanerv1alpha1.ConditionReasonTargetResolveError,
+			wantRequeue: true,
+			wantMessage: []string{`target "target-4"`, "; and 2 more"},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("bad-targets")
+			cTTL.Spec.Targets = tc.targets
+			r := newTestReconciler(t, cTTL)
+
+			res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.wantRequeue != (res.RequeueAfter > 0) {
+				t.Errorf("got RequeueAfter=%s, wantRequeue=%v", res.RequeueAfter, tc.wantRequeue)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Reason != tc.wantReason {
+				t.Fatalf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+			for _, want := range tc.wantMessage {
+				if !strings.Contains(cond.Message, want) {
+					t.Errorf("expected message to contain %q, got %s", want, cond.Message)
+				}
+			}
+			if strings.Contains(cond.Message, `target "target-5"`) {
+				t.Errorf("expected message to be truncated, got %s", cond.Message)
+			}
+			// unlike the message, the targets' statuses are never truncated
+			if len(found.Status.Targets) != len(tc.targets) {
+				t.Fatalf("got %d target statuses, want %d", len(found.Status.Targets), len(tc.targets))
+			}
+			for i, ts := range found.Status.Targets {
+				if ts.Name != tc.targets[i].Name || ts.Error == "" || ts.State != nil {
+					t.Errorf("got target status %+v, want the error of target %q", ts, tc.targets[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func Test_reconcileClearsTargetErrors(t *testing.T) {
+	cTTL := newTestCTTL("resolved-targets")
+	cTTL.Spec.Conditions = []string{"false"}
+	cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", "late")}
+	r := newTestReconciler(t, cTTL)
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	found := getTestCTTL(t, r, cTTL)
+	if len(found.Status.Targets) != 1 || found.Status.Targets[0].Error == "" {
+		t.Fatalf("got target statuses %+v, want the error of target %q", found.Status.Targets, "pod")
+	}
+
+	if err := r.Create(context.TODO(), newTestPod("late")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Status.Targets) != 0 {
+		t.Errorf("got target statuses %+v, want none once resolved", found.Status.Targets)
+	}
+}
+
+func Test_reconcileStopsOnPermanentTargetErrors(t *testing.T) {
+	testCases := map[string]struct {
+		target     cleanerv1alpha1.Target
+		getErr     error
+		wantReason string
+	}{
+		"forbidden": {
+			target:     newPodTarget("pod", "forbidden-pod"),
+			getErr:     apierrors.NewForbidden(corev1.Resource("pods"), "forbidden-pod", errors.New("RBAC denied")),
+			wantReason: cleanerv1alpha1.ConditionReasonTargetForbidden,
+		},
+		"unknown kind": {
+			target: cleanerv1alpha1.Target{
+				Name: "widget",
+				Reference: cleanerv1alpha1.TargetReference{
+					TypeMeta: metav1.TypeMeta{APIVersion: "example.com/v1", Kind: "Widget"},
+					Name:     ptr.To("widget"),
+				},
+			},
+			wantReason: cleanerv1alpha1.ConditionReasonUnknownKind,
+		},
+		"invalid selector": {
+			target: cleanerv1alpha1.Target{
+				Name: "pods",
+				Reference: cleanerv1alpha1.TargetReference{
+					TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+					LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "app", Operator: "Like"},
+					}},
+				},
+			},
+			wantReason: cleanerv1alpha1.ConditionReasonInvalidTargetReference,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("permanent")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{tc.target}
+			r := newInterceptedTestReconciler(t, interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if tc.getErr != nil && key.Name == "forbidden-pod" {
+						return tc.getErr
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			}, cTTL)
+
+			// the second reconcile stands for the one triggered by the
+			// status update
+			for i := 0; i < 2; i++ {
+				res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if res != (ctrl.Result{}) {
+					t.Errorf("got %+v, want no requeue", res)
+				}
+			}
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != tc.wantReason {
+				t.Errorf("got condition %v, want reason %s", cond, tc.wantReason)
+			}
+			if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), tc.wantReason); got != 1 {
+				t.Errorf("got %d %s events, want 1", got, tc.wantReason)
+			}
+		})
+	}
+}
+
+func Test_reconcileChecksTargetKinds(t *testing.T) {
+	widget := cleanerv1alpha1.Target{
+		Name: "widget",
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta: metav1.TypeMeta{APIVersion: "example.com/v1", Kind: "Widget"},
+			Name:     ptr.To("widget"),
+		},
+	}
+	deploy := cleanerv1alpha1.Target{
+		Name: "deploy",
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deploymnet"},
+			Name:     ptr.To("deploy"),
+		},
+	}
+	testCases := map[string]struct {
+		target      cleanerv1alpha1.Target
+		wantMessage string
+	}{
+		"served kind": {target: newPodTarget("pod", "pod")},
+		"misspelled kind": {
+			target:      deploy,
+			wantMessage: `target "deploy": kind "Deploymnet" is not served by apps/v1, did you mean "Deployment"?`,
+		},
+		"not installed kind": {
+			target:      widget,
+			wantMessage: `target "widget": apiVersion "example.com/v1" is not served`,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("kinds")
+			cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{tc.target}
+			r := newTestReconciler(t, cTTL)
+			r.KindChecker, _ = newTestKindChecker()
+
+			// the second reconcile stands for the one triggered by the
+			// status update
+			for i := 0; i < 2; i++ {
+				res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if tc.wantMessage != "" && res != (ctrl.Result{}) {
+					t.Errorf("got %+v, want no requeue", res)
+				}
+			}
+			found := getTestCTTL(t, r, cTTL)
+			cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			events := drainEvents(r.Recorder.(*record.FakeRecorder))
+			if tc.wantMessage == "" {
+				if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonNotExpired {
+					t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonNotExpired)
+				}
+				return
+			}
+			if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != cleanerv1alpha1.ConditionReasonUnknownKind {
+				t.Fatalf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonUnknownKind)
+			}
+			if !strings.Contains(cond.Message, tc.wantMessage) {
+				t.Errorf("got message %q, want it to contain %q", cond.Message, tc.wantMessage)
+			}
+			if got := countEvents(events, cleanerv1alpha1.ConditionReasonUnknownKind); got != 1 {
+				t.Errorf("got %d %s events, want 1", got, cleanerv1alpha1.ConditionReasonUnknownKind)
+			}
+		})
+	}
+
+	t.Run("kind installed after the cTTL", func(t *testing.T) {
+		cTTL := newTestCTTL("installed-later")
+		cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{widget}
+		r := newTestReconciler(t, cTTL)
+		var disc *fakediscovery.FakeDiscovery
+		r.KindChecker, disc = newTestKindChecker()
+		clock := testingclock.NewFakeClock(time.Now())
+		r.KindChecker.Clock = clock
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		disc.Resources = append(disc.Resources, &metav1.APIResourceList{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+		})
+		// the kinds' mapping is only refreshed once in a while
+		clock.Step(kinds.MinResetInterval)
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		found := getTestCTTL(t, r, cTTL)
+		cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonNotExpired {
+			t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonNotExpired)
+		}
+	})
+
+	t.Run("kinds' mapping refreshed too recently", func(t *testing.T) {
+		cTTL := newTestCTTL("refreshed-recently")
+		cTTL.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{widget}
+		r := newTestReconciler(t, cTTL)
+		r.KindChecker, _ = newTestKindChecker()
+		clock := testingclock.NewFakeClock(time.Now())
+		r.KindChecker.Clock = clock
+		// refreshes the mapping for another kind
+		if err := r.KindChecker.Check(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}); err == nil {
+			t.Fatal("expected the kind not to be served")
+		}
+
+		res, err := r.Reconcile(context.TODO(), requestFor(cTTL))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if res.RequeueAfter != kinds.MinResetInterval {
+			t.Errorf("got RequeueAfter=%s, want %s", res.RequeueAfter, kinds.MinResetInterval)
+		}
+	})
+}
+
+func Test_reconcileResolvesKindOnlyTargets(t *testing.T) {
+	testCases := map[string]struct {
+		kind           string
+		objs           []client.Object
+		wantAPIVersion string
+		wantReason     string
+	}{
+		"core kind": {
+			kind:           "Pod",
+			objs:           []client.Object{newTestPod("target")},
+			wantAPIVersion: "v1",
+		},
+		"group kind": {
+			kind: "Job",
+			objs: []client.Object{&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "default"},
+			}},
+			wantAPIVersion: "batch/v1",
+		},
+		"served by more than one group": {
+			kind:       "Event",
+			wantReason: cleanerv1alpha1.ConditionReasonInvalidTargetReference,
+		},
+		"unknown kind": {
+			kind:       "Widget",
+			wantReason: cleanerv1alpha1.ConditionReasonUnknownKind,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newKeptTestCTTL("kind-only")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{{
+				Name:                  "target",
+				IncludeWhenEvaluating: ptr.To(true),
+				Reference: cleanerv1alpha1.TargetReference{
+					TypeMeta: metav1.TypeMeta{Kind: tc.kind},
+					Name:     ptr.To("target"),
+				},
+			}}
+			cTTL.Spec.Conditions = []string{"true"}
+			r := newTestReconciler(t, append(tc.objs, cTTL)...)
+
+			if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			found := getTestCTTL(t, r, cTTL)
+			if tc.wantReason != "" {
+				cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				if cond == nil || cond.Reason != tc.wantReason {
+					t.Errorf("got condition %v, want reason %s", cond, tc.wantReason)
+				}
+				return
+			}
+			if len(found.Status.Targets) != 1 {
+				t.Fatalf("got targets %v, want 1", found.Status.Targets)
+			}
+			if got := found.Status.Targets[0]; got.APIVersion != tc.wantAPIVersion || got.Kind != tc.kind {
+				t.Errorf("got %s %s, want %s %s", got.APIVersion, got.Kind, tc.wantAPIVersion, tc.kind)
+			}
+		})
+	}
+}
+
+func Test_capRequeueAfter(t *testing.T) {
+	testCases := map[string]struct {
+		max  time.Duration
+		d    time.Duration
+		want time.Duration
+	}{
+		"below cap":           {max: time.Hour, d: time.Minute, want: time.Minute},
+		"above cap":           {max: time.Hour, d: 48 * time.Hour, want: time.Hour},
+		"unset uses default":  {d: 48 * time.Hour, want: DefaultMaxRequeueAfter},
+		"unset below default": {d: time.Second, want: time.Second},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			r := &ConditionalTTLReconciler{MaxRequeueAfter: tc.max}
+			if got := r.capRequeueAfter(tc.d); got != tc.want {
+				t.Errorf("got=%s want=%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_controllerOptionsBuild(t *testing.T) {
+	testCases := map[string]struct {
+		opts      ControllerOptions
+		wantFirst time.Duration
+		wantMax   time.Duration
+	}{
+		"defaults": {
+			wantFirst: DefaultErrorBackoffBase,
+			wantMax:   DefaultErrorBackoffMax,
+		},
+		"configured": {
+			opts:      ControllerOptions{MaxConcurrentReconciles: 4, ErrorBackoffBase: time.Second, ErrorBackoffMax: time.Minute},
+			wantFirst: time.Second,
+			wantMax:   time.Minute,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			opts := tc.opts.Build()
+			if opts.MaxConcurrentReconciles != tc.opts.MaxConcurrentReconciles {
+				t.Errorf("got MaxConcurrentReconciles=%d, want %d", opts.MaxConcurrentReconciles, tc.opts.MaxConcurrentReconciles)
+			}
+			req := requestFor(newTestCTTL("backoff"))
+			if got := opts.RateLimiter.When(req); got != tc.wantFirst {
+				t.Errorf("got first backoff %s, want %s", got, tc.wantFirst)
+			}
+			var got time.Duration
+			for i := 0; i < 100; i++ {
+				got = opts.RateLimiter.When(req)
+			}
+			if got != tc.wantMax {
+				t.Errorf("got backoff %s after many failures, want %s", got, tc.wantMax)
+			}
+		})
+	}
+}
+
+// Test_reconcileConcurrently reconciles many cTTLs at once with a single
+// reconciler, as with MaxConcurrentReconciles > 1, so that running it with
+// -race reports any state shared across reconciles.
+func Test_reconcileConcurrently(t *testing.T) {
+	const n = 20
+	var objs []client.Object
+	var cTTLs []*cleanerv1alpha1.ConditionalTTL
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("concurrent-%d", i)
+		cTTL := newTestCTTL(name)
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{newPodTarget("pod", name)}
+		cTTL.Spec.Conditions = []string{"true"}
+		cTTLs = append(cTTLs, cTTL)
+		objs = append(objs, cTTL, newTestPod(name))
+	}
+	r := newTestReconciler(t, objs...)
+	r.Recorder = &record.FakeRecorder{}
+	r.RequeueJitter = 0.1
+
+	errs := make(chan error, n)
+	for _, cTTL := range cTTLs {
+		go func() {
+			for i := 0; i <= len(finalizers); i++ {
+				if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+					errs <- fmt.Errorf("%s: reconcile %d: %w", cTTL.Name, i, err)
+					return
+				}
+				err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), &cleanerv1alpha1.ConditionalTTL{})
+				if apierrors.IsNotFound(err) {
+					errs <- nil
+					return
+				}
+			}
+			errs <- fmt.Errorf("%s: not removed after handling all finalizers", cTTL.Name)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Errorf("got %d pods left, want all of them deleted", len(pods.Items))
+	}
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(cleanerv1alpha1.AddToScheme(s))
+	return s
+}
+
+// newTestReconciler builds a reconciler backed by a fake client
+// pre-populated with objs.
+func newTestReconciler(t *testing.T, objs ...client.Object) *ConditionalTTLReconciler {
+	t.Helper()
+	return newInterceptedTestReconciler(t, interceptor.Funcs{}, objs...)
+}
+
+// newInterceptedTestReconciler is like newTestReconciler but routes client
+// calls through funcs.
+func newInterceptedTestReconciler(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *ConditionalTTLReconciler {
+	t.Helper()
+	s := newTestScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(s).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(s)).
+		WithObjects(objs...).
+		WithStatusSubresource(&cleanerv1alpha1.ConditionalTTL{}, &cleanerv1alpha1.ClusterConditionalTTL{}, &cleanerv1alpha1.ConditionalTTLTemplate{}).
+		WithInterceptorFuncs(funcs).
+		Build()
+	return &ConditionalTTLReconciler{
+		Client:   c,
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// newTestKindChecker returns a kinds.Checker backed by a fake discovery
+// serving pods and deployments, to which more resources can be added.
+func newTestKindChecker() (*kinds.Checker, *fakediscovery.FakeDiscovery) {
+	disc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	disc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disc))
+	return kinds.NewChecker(mapper, disc), disc
+}
+
+func newTestCTTL(name string) *cleanerv1alpha1.ConditionalTTL {
+	return &cleanerv1alpha1.ConditionalTTL{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: cleanerv1alpha1.ConditionalTTLSpec{
+			TTL: &metav1.Duration{Duration: 0},
+		},
+	}
+}
+
+// newKeptTestCTTL returns a cTTL with a finalizer keeping it around to be
+// inspected once deleted.
+func newKeptTestCTTL(name string) *cleanerv1alpha1.ConditionalTTL {
+	cTTL := newTestCTTL(name)
+	cTTL.Finalizers = []string{"test/keep"}
+	return cTTL
+}
+
+// newDeletedTestCTTL returns a cTTL which is being deleted and still has
+// the given finalizers.
+func newDeletedTestCTTL(name string, finalizers ...string) *cleanerv1alpha1.ConditionalTTL {
+	cTTL := newTestCTTL(name)
+	now := metav1.Now()
+	cTTL.DeletionTimestamp = &now
+	cTTL.Finalizers = finalizers
+	cTTL.Status.EvaluationTime = &now
+	return cTTL
+}
+
+func newPodTarget(targetName, podName string) cleanerv1alpha1.Target {
+	return cleanerv1alpha1.Target{
+		Name:   targetName,
+		Delete: true,
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			Name:     &podName,
+		},
+	}
+}
+
+func newPodListTarget(targetName string, labels map[string]string) cleanerv1alpha1.Target {
+	return cleanerv1alpha1.Target{
+		Name:   targetName,
+		Delete: true,
+		Reference: cleanerv1alpha1.TargetReference{
+			TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+}
+
+func newTestPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+}
+
+func finalizerNames() []string {
+	names := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// reconcileUntilGone reconciles a cTTL being deleted until all of its
+// finalizers are handled and it is removed.
+func reconcileUntilGone(t *testing.T, r *ConditionalTTLReconciler, cTTL *cleanerv1alpha1.ConditionalTTL) {
+	t.Helper()
+	for i := 0; i <= len(finalizers); i++ {
+		if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err != nil {
+			t.Fatalf("reconcile %d: unexpected error: %s", i, err)
+		}
+		err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), &cleanerv1alpha1.ConditionalTTL{})
+		if apierrors.IsNotFound(err) {
+			return
+		}
+	}
+	t.Fatal("cTTL was not removed after handling all finalizers")
+}
+
+// fakeCloudEventsClient records the events sent through it and their
+// targets.
+type fakeCloudEventsClient struct {
+	// mu guards sent and targets, as lifecycle events are sent in the
+	// background
+	mu      sync.Mutex
+	sent    []cloudevents.Event
+	targets []string
+	result  cloudevents.Result
+	// block, when set, holds sends back until it is closed
+	block chan struct{}
+}
+
+func (c *fakeCloudEventsClient) Send(ctx context.Context, e cloudevents.Event) cloudevents.Result {
+	if c.block != nil {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, e)
+	if target := cloudevents.TargetFromContext(ctx); target != nil {
+		c.targets = append(c.targets, target.String())
+	}
+	if c.result != nil {
+		return c.result
+	}
+	return cloudevents.ResultACK
+}
+
+func (c *fakeCloudEventsClient) Request(ctx context.Context, e cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+	return nil, c.Send(ctx, e)
+}
+
+func (c *fakeCloudEventsClient) StartReceiver(ctx context.Context, fn interface{}) error {
+	return errors.New("not implemented")
+}
+
+// getTestCTTL returns the current version of cTTL.
+func getTestCTTL(t *testing.T, r *ConditionalTTLReconciler, cTTL *cleanerv1alpha1.ConditionalTTL) *cleanerv1alpha1.ConditionalTTL {
+	t.Helper()
+	found := &cleanerv1alpha1.ConditionalTTL{}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(cTTL), found); err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func requestFor(cTTL *cleanerv1alpha1.ConditionalTTL) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{
+		Name:      cTTL.GetName(),
+		Namespace: cTTL.GetNamespace(),
+	}}
+}
+
+// drainEvents returns all events currently buffered by the recorder.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// countEvents counts events with the given reason. FakeRecorder
+// formats events as "<type> <reason> <message>".
+func countEvents(events []string, reason string) int {
+	n := 0
+	for _, e := range events {
+		if fields := strings.Fields(e); len(fields) > 1 && fields[1] == reason {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+const (
+	// templateLabel is set on stamped cTTLs to the name of their template.
+	templateLabel = "cleaner.vtex.io/template"
+	// templateHashAnnotation holds the hash of the spec a cTTL was stamped
+	// with, so that it is only updated when the template changes rather
+	// than whenever the API server defaults one of its fields.
+	templateHashAnnotation = "cleaner.vtex.io/template-hash"
+)
+
+// ConditionalTTLTemplateReconciler reconciles a ConditionalTTLTemplate
+// object. It shares the client and settings of the ConditionalTTL
+// reconciler.
+type ConditionalTTLTemplateReconciler struct {
+	*ConditionalTTLReconciler
+}
+
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttltemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttltemplates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cleaner.vtex.io,resources=conditionalttltemplates/finalizers,verbs=update
+
+// Reconcile stamps a cTTL in each namespace selected by the template which
+// hasn't had one yet, updates those whose spec is outdated and deletes
+// those of namespaces which are no longer selected.
+func (r *ConditionalTTLTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	tmpl := &cleanerv1alpha1.ConditionalTTLTemplate{}
+	if err := r.Get(ctx, req.NamespacedName, tmpl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// stamped cTTLs are garbage collected through their owner reference
+	if !tmpl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	log = log.WithValues("generation", tmpl.GetGeneration())
+	ctx = ctrl.LoggerInto(ctx, log)
+	statusBase := tmpl.DeepCopy()
+
+	if tmpl.Spec.NamespaceSelector == nil {
+		return ctrl.Result{}, r.reportTemplateError(ctx, tmpl, statusBase, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector, "Invalid namespace selector: namespaceSelector is required")
+	}
+	selector, err := metav1.LabelSelectorAsSelector(tmpl.Spec.NamespaceSelector)
+	if err != nil {
+		return ctrl.Result{}, r.reportTemplateError(ctx, tmpl, statusBase, cleanerv1alpha1.ConditionReasonInvalidNamespaceSelector, "Invalid namespace selector: "+err.Error())
+	}
+	namespaces, err := listNamespaces(ctx, r, selector)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("Error listing namespaces: %w", err)
+	}
+	cTTLs := &cleanerv1alpha1.ConditionalTTLList{}
+	if err := r.List(ctx, cTTLs, client.MatchingLabels{templateLabel: tmpl.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	existing := make(map[string]*cleanerv1alpha1.ConditionalTTL, len(cTTLs.Items))
+	for i := range cTTLs.Items {
+		if metav1.IsControlledBy(&cTTLs.Items[i], tmpl) {
+			existing[cTTLs.Items[i].Namespace] = &cTTLs.Items[i]
+		}
+	}
+	// namespaces are told apart by UID so that re-created ones are
+	// stamped again
+	wasStamped := make(map[types.UID]bool, len(tmpl.Status.Namespaces))
+	stampedUIDs := make(map[string]types.UID, len(tmpl.Status.Namespaces))
+	for _, ns := range tmpl.Status.Namespaces {
+		wasStamped[ns.UID] = true
+		stampedUIDs[ns.Name] = ns.UID
+	}
+
+	var errs []error
+	var renderErrs []string
+	stamped := make([]cleanerv1alpha1.StampedNamespace, 0, len(namespaces))
+	selected := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		name := ns.GetName()
+		selected[name] = true
+		record := cleanerv1alpha1.StampedNamespace{Name: name, UID: ns.GetUID()}
+		cTTL, err := r.renderConditionalTTL(tmpl, name)
+		if err != nil {
+			renderErrs = append(renderErrs, fmt.Sprintf("namespace %q: %s", name, err.Error()))
+			if wasStamped[record.UID] {
+				stamped = append(stamped, record)
+			}
+			continue
+		}
+		current, ok := existing[name]
+		switch {
+		case ok:
+			stamped = append(stamped, record)
+			if current.Annotations[templateHashAnnotation] == cTTL.Annotations[templateHashAnnotation] || !current.DeletionTimestamp.IsZero() {
+				continue
+			}
+			current.Labels = cTTL.Labels
+			current.Annotations = cTTL.Annotations
+			current.Spec = cTTL.Spec
+			if err := r.Update(ctx, current); err != nil {
+				errs = append(errs, fmt.Errorf("namespace %q: %w", name, err))
+				continue
+			}
+			log.Info("Updated stamped ConditionalTTL", "namespace", name)
+		case wasStamped[record.UID]:
+			// the cTTL is done, e.g. its conditions were met
+			stamped = append(stamped, record)
+		default:
+			if err := r.Create(ctx, cTTL); err != nil {
+				errs = append(errs, fmt.Errorf("namespace %q: %w", name, err))
+				continue
+			}
+			stamped = append(stamped, record)
+			r.Recorder.Eventf(tmpl, corev1.EventTypeNormal, "Stamped", "ConditionalTTL stamped in namespace %s", name)
+		}
+	}
+	for ns, cTTL := range existing {
+		if selected[ns] {
+			continue
+		}
+		if err := r.Delete(ctx, cTTL); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("namespace %q: %w", ns, err))
+			stamped = append(stamped, cleanerv1alpha1.StampedNamespace{Name: ns, UID: stampedUIDs[ns]})
+			continue
+		}
+		r.Recorder.Eventf(tmpl, corev1.EventTypeNormal, "Unstamped", "ConditionalTTL deleted from namespace %s which is no longer selected", ns)
+	}
+
+	sort.Slice(stamped, func(i, j int) bool { return stamped[i].Name < stamped[j].Name })
+	tmpl.Status.Namespaces = stamped
+	if len(renderErrs) > 0 {
+		// rendering fails the same way until the template changes
+		message := "Error rendering template: " + strings.Join(renderErrs, "; ")
+		if err := r.reportTemplateError(ctx, tmpl, statusBase, cleanerv1alpha1.ConditionReasonTemplateRenderError, message); err != nil {
+			errs = append(errs, err)
+		}
+		return ctrl.Result{}, errors.Join(errs...)
+	}
+	apimeta.SetStatusCondition(&tmpl.Status.Conditions, metav1.Condition{
+		Status:             metav1.ConditionTrue,
+		Reason:             cleanerv1alpha1.ConditionReasonStamped,
+		Message:            fmt.Sprintf("ConditionalTTL stamped in %d namespace(s)", len(stamped)),
+		Type:               cleanerv1alpha1.ConditionTypeReady,
+		ObservedGeneration: tmpl.GetGeneration(),
+	})
+	if err := r.Status().Patch(ctx, tmpl, client.MergeFrom(statusBase)); err != nil {
+		errs = append(errs, err)
+	}
+	return ctrl.Result{}, errors.Join(errs...)
+}
+
+// reportTemplateError sets the Ready condition for an error which retrying
+// can't fix until the template changes. The Warning event is only emitted
+// once per generation since such errors are reported again whenever the
+// template is reconciled.
+func (r *ConditionalTTLTemplateReconciler) reportTemplateError(ctx context.Context, tmpl, base *cleanerv1alpha1.ConditionalTTLTemplate, reason, message string) error {
+	prev := apimeta.FindStatusCondition(tmpl.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	reported := prev != nil && prev.Reason == reason && prev.ObservedGeneration == tmpl.GetGeneration()
+	apimeta.SetStatusCondition(&tmpl.Status.Conditions, metav1.Condition{
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		Type:               cleanerv1alpha1.ConditionTypeReady,
+		ObservedGeneration: tmpl.GetGeneration(),
+	})
+	if err := r.Status().Patch(ctx, tmpl, client.MergeFrom(base)); err != nil {
+		return err
+	}
+	if !reported {
+		r.Recorder.Event(tmpl, corev1.EventTypeWarning, reason, message)
+	}
+	return nil
+}
+
+// renderConditionalTTL returns the cTTL the template stamps in namespace,
+// controlled by the template.
+func (r *ConditionalTTLTemplateReconciler) renderConditionalTTL(tmpl *cleanerv1alpha1.ConditionalTTLTemplate, namespace string) (*cleanerv1alpha1.ConditionalTTL, error) {
+	spec, err := renderConditionalTTLSpec(&tmpl.Spec.Spec, namespace)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := specHash(spec)
+	if err != nil {
+		return nil, err
+	}
+	cTTL := &cleanerv1alpha1.ConditionalTTL{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        tmpl.Name,
+			Namespace:   namespace,
+			Labels:      maps.Clone(tmpl.Spec.Labels),
+			Annotations: maps.Clone(tmpl.Spec.Annotations),
+		},
+		Spec: spec,
+	}
+	if cTTL.Labels == nil {
+		cTTL.Labels = map[string]string{}
+	}
+	cTTL.Labels[templateLabel] = tmpl.Name
+	if cTTL.Annotations == nil {
+		cTTL.Annotations = map[string]string{}
+	}
+	cTTL.Annotations[templateHashAnnotation] = hash
+	if err := controllerutil.SetControllerReference(tmpl, cTTL, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cTTL, nil
+}
+
+// renderConditionalTTLSpec returns a copy of spec in which `{{ .Namespace }}`
+// is replaced by namespace in the names and label selector values of target
+// references and in the Helm release.
+func renderConditionalTTLSpec(spec *cleanerv1alpha1.ConditionalTTLSpec, namespace string) (cleanerv1alpha1.ConditionalTTLSpec, error) {
+	out := *spec.DeepCopy()
+	data := struct{ Namespace string }{Namespace: namespace}
+	var errs []error
+	render := func(s *string) {
+		if !strings.Contains(*s, "{{") {
+			return
+		}
+		t, err := template.New("").Parse(*s)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		*s = b.String()
+	}
+	for i := range out.Targets {
+		ref := &out.Targets[i].Reference
+		if ref.Name != nil {
+			render(ref.Name)
+		}
+		if ls := ref.LabelSelector; ls != nil {
+			for k, v := range ls.MatchLabels {
+				render(&v)
+				ls.MatchLabels[k] = v
+			}
+			for j := range ls.MatchExpressions {
+				for k := range ls.MatchExpressions[j].Values {
+					render(&ls.MatchExpressions[j].Values[k])
+				}
+			}
+		}
+	}
+	if out.Helm != nil {
+		render(&out.Helm.Release)
+	}
+	for i := range out.HelmReleases {
+		render(&out.HelmReleases[i].Release)
+	}
+	return out, errors.Join(errs...)
+}
+
+// specHash returns a short hash of spec identifying what a cTTL was stamped
+// with.
+func specHash(spec cleanerv1alpha1.ConditionalTTLSpec) (string, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Every template
+// is reconciled whenever a namespace changes, since any of them may select
+// it.
+func (r *ConditionalTTLTemplateReconciler) SetupWithManager(mgr ctrl.Manager, opts controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cleanerv1alpha1.ConditionalTTLTemplate{}).
+		Owns(&cleanerv1alpha1.ConditionalTTL{}).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.allTemplates)).
+		WithOptions(opts).
+		Complete(r)
+}
+
+// allTemplates maps any object to a request for each template.
+func (r *ConditionalTTLTemplateReconciler) allTemplates(ctx context.Context, _ client.Object) []reconcile.Request {
+	tmpls := &cleanerv1alpha1.ConditionalTTLTemplateList{}
+	if err := r.List(ctx, tmpls); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ConditionalTTLTemplates")
+		return nil
+	}
+	reqs := make([]reconcile.Request, len(tmpls.Items))
+	for i := range tmpls.Items {
+		reqs[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&tmpls.Items[i])}
+	}
+	return reqs
+}
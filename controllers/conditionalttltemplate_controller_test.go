@@ -0,0 +1,184 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+func Test_reconcileConditionalTTLTemplate(t *testing.T) {
+	preview := map[string]string{"env": "preview"}
+	tmpl := newTestTemplate("previews", preview)
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		ns := newTestNamespace(name, labels)
+		ns.UID = types.UID(name + "-uid")
+		return ns
+	}
+	r := &ConditionalTTLTemplateReconciler{newTestReconciler(t,
+		tmpl,
+		namespace("preview-a", preview),
+		namespace("preview-b", preview),
+		namespace("production", map[string]string{"env": "production"}),
+	)}
+	reconcile := func() *cleanerv1alpha1.ConditionalTTLTemplate {
+		t.Helper()
+		if _, err := r.Reconcile(context.TODO(), templateRequestFor(tmpl)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		found := &cleanerv1alpha1.ConditionalTTLTemplate{}
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(tmpl), found); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+	getStamped := func(namespace string) (*cleanerv1alpha1.ConditionalTTL, error) {
+		cTTL := &cleanerv1alpha1.ConditionalTTL{}
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: tmpl.Name}, cTTL)
+		return cTTL, err
+	}
+
+	found := reconcile()
+	if want := []cleanerv1alpha1.StampedNamespace{{Name: "preview-a", UID: "preview-a-uid"}, {Name: "preview-b", UID: "preview-b-uid"}}; !reflect.DeepEqual(found.Status.Namespaces, want) {
+		t.Errorf("got namespaces %v, want %v", found.Status.Namespaces, want)
+	}
+	for _, ns := range []string{"preview-a", "preview-b"} {
+		cTTL, err := getStamped(ns)
+		if err != nil {
+			t.Fatalf("namespace %s: %s", ns, err)
+		}
+		if got := *cTTL.Spec.Targets[0].Reference.Name; got != ns+"-app" {
+			t.Errorf("namespace %s: got target name %q, want it rendered", ns, got)
+		}
+		if !metav1.IsControlledBy(cTTL, found) {
+			t.Errorf("namespace %s: expected the cTTL to be controlled by the template", ns)
+		}
+	}
+	if _, err := getStamped("production"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no cTTL in an unselected namespace, got err=%v", err)
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "Stamped"); got != 2 {
+		t.Errorf("got %d Stamped events, want 2", got)
+	}
+
+	// template changes are applied to the stamped cTTLs
+	found.Spec.Spec.TTL = &metav1.Duration{Duration: 2 * time.Hour}
+	if err := r.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if cTTL, err := getStamped("preview-a"); err != nil || cTTL.Spec.TTL.Duration != 2*time.Hour {
+		t.Errorf("expected the stamped cTTL to be updated, got %v (err=%v)", cTTL.Spec.TTL, err)
+	}
+
+	// a cTTL which is done isn't stamped again
+	cTTL, _ := getStamped("preview-a")
+	if err := r.Delete(context.TODO(), cTTL); err != nil {
+		t.Fatal(err)
+	}
+	// a namespace no longer selected has its cTTL deleted
+	ns := &corev1.Namespace{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "preview-b"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	ns.Labels = nil
+	if err := r.Update(context.TODO(), ns); err != nil {
+		t.Fatal(err)
+	}
+	found = reconcile()
+	if _, err := getStamped("preview-a"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the completed cTTL not to be stamped again, got err=%v", err)
+	}
+	if _, err := getStamped("preview-b"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the unselected namespace's cTTL to be deleted, got err=%v", err)
+	}
+	if want := []cleanerv1alpha1.StampedNamespace{{Name: "preview-a", UID: "preview-a-uid"}}; !reflect.DeepEqual(found.Status.Namespaces, want) {
+		t.Errorf("got namespaces %v, want %v", found.Status.Namespaces, want)
+	}
+
+	// a namespace re-created with the same name is stamped again
+	if err := r.Delete(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "preview-a"}}); err != nil {
+		t.Fatal(err)
+	}
+	recreated := namespace("preview-a", preview)
+	recreated.UID = "preview-a-recreated-uid"
+	if err := r.Create(context.TODO(), recreated); err != nil {
+		t.Fatal(err)
+	}
+	found = reconcile()
+	if _, err := getStamped("preview-a"); err != nil {
+		t.Errorf("expected the re-created namespace to be stamped again, got err=%v", err)
+	}
+	if want := []cleanerv1alpha1.StampedNamespace{{Name: "preview-a", UID: "preview-a-recreated-uid"}}; !reflect.DeepEqual(found.Status.Namespaces, want) {
+		t.Errorf("got namespaces %v, want %v", found.Status.Namespaces, want)
+	}
+}
+
+func Test_reconcileConditionalTTLTemplateRenderError(t *testing.T) {
+	preview := map[string]string{"env": "preview"}
+	tmpl := newTestTemplate("previews", preview)
+	tmpl.Spec.Spec.Targets[0].Reference.Name = ptr.To("{{ .Unknown }}")
+	r := &ConditionalTTLTemplateReconciler{newTestReconciler(t, tmpl, newTestNamespace("preview-a", preview))}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), templateRequestFor(tmpl)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	found := &cleanerv1alpha1.ConditionalTTLTemplate{}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(tmpl), found); err != nil {
+		t.Fatal(err)
+	}
+	cond := apimeta.FindStatusCondition(found.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+	if cond == nil || cond.Reason != cleanerv1alpha1.ConditionReasonTemplateRenderError {
+		t.Errorf("got condition %v, want reason %s", cond, cleanerv1alpha1.ConditionReasonTemplateRenderError)
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), cleanerv1alpha1.ConditionReasonTemplateRenderError); got != 1 {
+		t.Errorf("got %d %s events, want 1", got, cleanerv1alpha1.ConditionReasonTemplateRenderError)
+	}
+}
+
+func newTestTemplate(name string, namespaceLabels map[string]string) *cleanerv1alpha1.ConditionalTTLTemplate {
+	return &cleanerv1alpha1.ConditionalTTLTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: cleanerv1alpha1.ConditionalTTLTemplateSpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: namespaceLabels},
+			Spec: cleanerv1alpha1.ConditionalTTLSpec{
+				TTL:     &metav1.Duration{Duration: time.Hour},
+				Targets: []cleanerv1alpha1.Target{newPodTarget("app", "{{ .Namespace }}-app")},
+			},
+		},
+	}
+}
+
+func templateRequestFor(tmpl *cleanerv1alpha1.ConditionalTTLTemplate) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: tmpl.GetName()}}
+}
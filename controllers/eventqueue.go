@@ -0,0 +1,74 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import "sync"
+
+// maxQueuedEvents bounds the lifecycle CloudEvents waiting to be delivered,
+// so that a sink which is down doesn't pile them up in memory.
+const maxQueuedEvents = 1024
+
+// eventQueue delivers lifecycle CloudEvents one at a time, in the order
+// they were queued, on a goroutine which only runs while some are pending.
+// The zero value is ready to use.
+type eventQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	running bool
+	// idle is done once every queued delivery has run.
+	idle sync.WaitGroup
+}
+
+// push queues send, returning false without queuing it when
+// maxQueuedEvents are already waiting.
+func (q *eventQueue) push(send func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) >= maxQueuedEvents {
+		return false
+	}
+	q.pending = append(q.pending, send)
+	if !q.running {
+		q.running = true
+		q.idle.Add(1)
+		go q.run()
+	}
+	return true
+}
+
+// run delivers the queued events until none is left.
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			q.idle.Done()
+			return
+		}
+		send := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		send()
+	}
+}
+
+// wait blocks until every queued event was delivered.
+func (q *eventQueue) wait() {
+	q.idle.Wait()
+}
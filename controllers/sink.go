@@ -0,0 +1,302 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups="",resources=services,verbs=get
+//+kubebuilder:rbac:groups=eventing.knative.dev,resources=brokers,verbs=get
+//+kubebuilder:rbac:groups=messaging.knative.dev,resources=channels,verbs=get
+//+kubebuilder:rbac:groups=serving.knative.dev,resources=services,verbs=get
+
+// hasCloudEventSink reports whether the cTTL declares where CloudEvents
+// are sent.
+func hasCloudEventSink(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	return cTTL.Spec.CloudEventSink != nil || cTTL.Spec.CloudEventSinkRef != nil
+}
+
+// cloudEventSink returns the address CloudEvents of the cTTL are sent to,
+// resolving its sink reference, if any.
+func (r *ConditionalTTLReconciler) cloudEventSink(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (string, error) {
+	if cTTL.Spec.CloudEventSink != nil {
+		return *cTTL.Spec.CloudEventSink, nil
+	}
+	ref := cTTL.Spec.CloudEventSinkRef
+	if ref == nil {
+		return "", errors.New("no cloud event sink")
+	}
+	namespace := cTTL.GetNamespace()
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		if !slices.Contains(cTTL.Spec.AllowedNamespaces, ref.Namespace) {
+			return "", fmt.Errorf("cloud event sink namespace %q is not allowed", ref.Namespace)
+		}
+		namespace = ref.Namespace
+	}
+	gvk, err := ref.GroupVersionKind()
+	if err != nil {
+		return "", fmt.Errorf("cloud event sink: %w", err)
+	}
+	// the controller is only allowed to read those
+	if !slices.Contains(cleanerv1alpha1.SinkKinds, gvk.GroupKind()) {
+		return "", fmt.Errorf("cloud event sink kind %s is not supported", gvk.GroupKind())
+	}
+	kind := gvk.Kind
+
+	// read as unstructured so sinks are not cached by the manager
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, u); err != nil {
+		return "", fmt.Errorf("cloud event sink %s %q: %w", kind, ref.Name, err)
+	}
+	var address *url.URL
+	if gvk.Group == "" && kind == "Service" {
+		address, err = serviceAddress(u, ref.Port)
+	} else {
+		address, err = addressableAddress(u)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cloud event sink %s %q: %w", kind, ref.Name, err)
+	}
+	if ref.Path != "" {
+		address = address.JoinPath(ref.Path)
+	}
+	return address.String(), nil
+}
+
+// serviceAddress returns the in-cluster address of port of the Service,
+// which may be omitted when it declares a single port.
+func serviceAddress(svc *unstructured.Unstructured, port *int32) (*url.URL, error) {
+	ports, _, err := unstructured.NestedSlice(svc.Object, "spec", "ports")
+	if err != nil {
+		return nil, err
+	}
+	var declared []int64
+	for _, p := range ports {
+		if p, ok := p.(map[string]interface{}); ok {
+			if n, ok := p["port"].(int64); ok {
+				declared = append(declared, n)
+			}
+		}
+	}
+	var n int64
+	switch {
+	case port != nil:
+		if !slices.Contains(declared, int64(*port)) {
+			return nil, fmt.Errorf("port %d is not declared", *port)
+		}
+		n = int64(*port)
+	case len(declared) == 1:
+		n = declared[0]
+	default:
+		return nil, fmt.Errorf("port must be set as %d ports are declared", len(declared))
+	}
+	host := svc.GetName() + "." + svc.GetNamespace() + ".svc"
+	if n != 80 {
+		host += ":" + strconv.FormatInt(n, 10)
+	}
+	return &url.URL{Scheme: "http", Host: host, Path: "/"}, nil
+}
+
+// addressableAddress returns the `status.address.url` of an Addressable.
+func addressableAddress(u *unstructured.Unstructured) (*url.URL, error) {
+	address, _, err := unstructured.NestedString(u.Object, "status", "address", "url")
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		return nil, fmt.Errorf("status.address.url is not set")
+	}
+	return url.Parse(address)
+}
+
+// cloudEventHeaders returns the HTTP headers sent along with the cTTL's
+// CloudEvents, read from the Secret referenced by its headersFrom, if any.
+// Errors never include the values of the headers.
+func (r *ConditionalTTLReconciler) cloudEventHeaders(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (http.Header, error) {
+	header := http.Header{}
+	c := cTTL.Spec.CloudEvent
+	if c == nil || c.HeadersFrom == nil {
+		return header, nil
+	}
+	ref := c.HeadersFrom
+	// read as unstructured so Secrets are not cached by the manager
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cTTL.GetNamespace()}, u); err != nil {
+		return nil, fmt.Errorf("cloud event headers: %w", err)
+	}
+	data, _, err := unstructured.NestedStringMap(u.Object, "data")
+	if err != nil {
+		return nil, fmt.Errorf("cloud event headers: Secret %q: %w", ref.Name, err)
+	}
+	keys := ref.Headers
+	if len(keys) == 0 {
+		keys = make(map[string]string, len(data))
+		for key := range data {
+			keys[key] = key
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := keys[name]
+		encoded, ok := data[key]
+		if !ok {
+			return nil, fmt.Errorf("cloud event headers: key %q not found on Secret %q", key, ref.Name)
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			// don't wrap err as it may include part of the value
+			return nil, fmt.Errorf("cloud event headers: key %q of Secret %q is not base64 encoded", key, ref.Name)
+		}
+		header.Set(name, string(value))
+	}
+	return header, nil
+}
+
+// defaultCAKey is the key of the CA bundle unless configured otherwise.
+const defaultCAKey = "ca.crt"
+
+// cloudEventsClient returns the client sending the cTTL's CloudEvents,
+// which is a dedicated one, built whenever an event is sent, when it
+// configures TLS and the shared one otherwise.
+func (r *ConditionalTTLReconciler) cloudEventsClient(ctx context.Context, cTTL *cleanerv1alpha1.ConditionalTTL) (cloudevents.Client, error) {
+	c := cTTL.Spec.CloudEvent
+	if c == nil || c.TLS == nil {
+		return r.CloudEventsClient, nil
+	}
+	config, err := r.cloudEventTLSConfig(ctx, cTTL.GetNamespace(), c.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("cloud event tls: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	// the client is dropped once the event is sent, so connections
+	// must not outlive it
+	transport.DisableKeepAlives = true
+	return cloudevents.NewClientHTTP(cehttp.WithRoundTripper(transport))
+}
+
+// cloudEventTLSConfig builds the configuration of the TLS connections to
+// a sink, reading its CA bundle and client certificate.
+func (r *ConditionalTTLReconciler) cloudEventTLSConfig(ctx context.Context, namespace string, t *cleanerv1alpha1.CloudEventTLS) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if ca := t.CA; ca != nil {
+		key := ca.Key
+		if key == "" {
+			key = defaultCAKey
+		}
+		var bundle []byte
+		var err error
+		if ca.Secret != "" {
+			bundle, err = r.secretKey(ctx, namespace, ca.Secret, key)
+		} else {
+			bundle, err = r.configMapKey(ctx, namespace, ca.ConfigMap, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("key %q of the CA bundle holds no PEM encoded certificate", key)
+		}
+		config.RootCAs = pool
+	}
+	if name := t.ClientCertificateSecret; name != "" {
+		crt, err := r.secretKey(ctx, namespace, name, corev1.TLSCertKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := r.secretKey(ctx, namespace, name, corev1.TLSPrivateKeyKey)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(crt, key)
+		if err != nil {
+			// don't wrap err as it may include part of the key
+			return nil, fmt.Errorf("Secret %q holds no valid client certificate", name)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// secretKey returns the decoded value of key of a Secret, read as
+// unstructured so that Secrets are not cached by the manager.
+func (r *ConditionalTTLReconciler) secretKey(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, u); err != nil {
+		return nil, err
+	}
+	encoded, found, err := unstructured.NestedString(u.Object, "data", key)
+	if err != nil {
+		return nil, fmt.Errorf("Secret %q: %w", name, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("key %q not found on Secret %q", key, name)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key %q of Secret %q is not base64 encoded", key, name)
+	}
+	return value, nil
+}
+
+// configMapKey returns the value of key of a ConfigMap, read as
+// unstructured so that ConfigMaps are not cached by the manager.
+func (r *ConditionalTTLReconciler) configMapKey(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, u); err != nil {
+		return nil, err
+	}
+	value, found, err := unstructured.NestedString(u.Object, "data", key)
+	if err != nil {
+		return nil, fmt.Errorf("ConfigMap %q: %w", name, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("key %q not found on ConfigMap %q", key, name)
+	}
+	return []byte(value), nil
+}
@@ -0,0 +1,491 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+func newTestService(name, namespace string, ports ...int32) *corev1.Service {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	for _, p := range ports {
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Port: p})
+	}
+	return svc
+}
+
+func newTestSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Data: map[string][]byte{}}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+func Test_cloudEventSink(t *testing.T) {
+	testCases := map[string]struct {
+		ref         cleanerv1alpha1.SinkReference
+		allowed     []string
+		want        string
+		wantMessage string
+	}{
+		"only port": {
+			ref:  cleanerv1alpha1.SinkReference{Name: "events"},
+			want: "http://events.default.svc:8080/",
+		},
+		"default http port": {
+			ref:  cleanerv1alpha1.SinkReference{Name: "web"},
+			want: "http://web.default.svc/",
+		},
+		"path": {
+			ref:  cleanerv1alpha1.SinkReference{Name: "events", Path: "/ingest/deleted"},
+			want: "http://events.default.svc:8080/ingest/deleted",
+		},
+		"selected port": {
+			ref:  cleanerv1alpha1.SinkReference{Name: "multi", Port: ptr.To[int32](9090)},
+			want: "http://multi.default.svc:9090/",
+		},
+		"explicit kind": {
+			ref:  cleanerv1alpha1.SinkReference{APIVersion: "v1", Kind: "Service", Name: "events"},
+			want: "http://events.default.svc:8080/",
+		},
+		"allowed namespace": {
+			ref:     cleanerv1alpha1.SinkReference{Name: "events", Namespace: "eventing"},
+			allowed: []string{"default", "eventing"},
+			want:    "http://events.eventing.svc/",
+		},
+		"namespace not allowed": {
+			ref:         cleanerv1alpha1.SinkReference{Name: "events", Namespace: "eventing"},
+			wantMessage: `namespace "eventing" is not allowed`,
+		},
+		"undeclared port": {
+			ref:         cleanerv1alpha1.SinkReference{Name: "events", Port: ptr.To[int32](9090)},
+			wantMessage: "port 9090 is not declared",
+		},
+		"ambiguous port": {
+			ref:         cleanerv1alpha1.SinkReference{Name: "multi"},
+			wantMessage: "port must be set as 2 ports are declared",
+		},
+		"unsupported kind": {
+			ref:         cleanerv1alpha1.SinkReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "events"},
+			wantMessage: "cloud event sink kind Deployment.apps is not supported",
+		},
+		"missing service": {
+			ref:         cleanerv1alpha1.SinkReference{Name: "missing"},
+			wantMessage: `cloud event sink Service "missing"`,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("sink")
+			cTTL.Spec.CloudEventSinkRef = &tc.ref
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			r := newTestReconciler(t, cTTL,
+				newTestService("events", "default", 8080),
+				newTestService("web", "default", 80),
+				newTestService("multi", "default", 8080, 9090),
+				newTestService("events", "eventing", 80),
+			)
+			got, err := r.cloudEventSink(context.TODO(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+			if got != tc.want {
+				t.Errorf("got sink %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_addressableAddress(t *testing.T) {
+	broker := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "eventing.knative.dev/v1",
+		"kind":       "Broker",
+		"status": map[string]interface{}{
+			"address": map[string]interface{}{"url": "http://broker-ingress.knative-eventing.svc.cluster.local/default/default"},
+		},
+	}}
+	got, err := addressableAddress(broker)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.String() != "http://broker-ingress.knative-eventing.svc.cluster.local/default/default" {
+		t.Errorf("got address %q", got)
+	}
+
+	unaddressed := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Broker"}}
+	if _, err := addressableAddress(unaddressed); err == nil || !strings.Contains(err.Error(), "status.address.url is not set") {
+		t.Errorf("got err=%v, want the address to be missing", err)
+	}
+}
+
+func Test_cloudEventFinalizerSinkRef(t *testing.T) {
+	cTTL := newDeletedTestCTTL("sink-ref", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSinkRef = &cleanerv1alpha1.SinkReference{Name: "events", Path: "deleted"}
+	r := newTestReconciler(t, cTTL, newTestService("events", "default", 8080))
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	reconcileUntilGone(t, r, cTTL)
+	if want := []string{"http://events.default.svc:8080/deleted"}; !reflect.DeepEqual(ce.targets, want) {
+		t.Errorf("got targets %v, want %v", ce.targets, want)
+	}
+}
+
+func Test_cloudEventFinalizerUnresolvedSink(t *testing.T) {
+	cTTL := newDeletedTestCTTL("unresolved-sink", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSinkRef = &cleanerv1alpha1.SinkReference{Name: "missing"}
+	r := newTestReconciler(t, cTTL)
+	ce := &fakeCloudEventsClient{}
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected an error resolving the sink")
+	}
+	if len(ce.sent) != 0 {
+		t.Errorf("got %d cloud events sent, want none", len(ce.sent))
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "EventSinkUnresolved"); got != 1 {
+		t.Errorf("got %d EventSinkUnresolved events, want 1", got)
+	}
+}
+
+// newTestTap returns a tap handler recording the events it receives and an
+// HTTP cloudevents client, along with the server's URL.
+// newCloudEventTap returns a tapHandler receiving CloudEvents.
+func newCloudEventTap() *tapHandler {
+	tap := &tapHandler{}
+	tap.handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		e, err := cehttp.NewEventFromHTTPRequest(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tap.receive(*e)
+	})
+	return tap
+}
+
+func newTestTap(t *testing.T) (*tapHandler, cloudevents.Client, string) {
+	t.Helper()
+	tap := newCloudEventTap()
+	server := httptest.NewServer(tap)
+	t.Cleanup(server.Close)
+	ce, err := cloudevents.NewClientHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tap, ce, server.URL
+}
+
+func Test_cloudEventHeaders(t *testing.T) {
+	testCases := map[string]struct {
+		headers map[string]string
+		want    http.Header
+	}{
+		"mapped keys": {
+			headers: map[string]string{"Authorization": "token"},
+			want:    http.Header{"Authorization": {"Bearer one"}},
+		},
+		"every key": {
+			want: http.Header{"Token": {"Bearer one"}, "X-Team": {"payments"}},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := newTestCTTL("headers")
+			cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+				HeadersFrom: &cleanerv1alpha1.SecretHeaders{Name: "sink-auth", Headers: tc.headers},
+			}
+			r := newTestReconciler(t, cTTL, newTestSecret("sink-auth", map[string]string{"token": "Bearer one", "x-team": "payments"}))
+			got, err := r.cloudEventHeaders(context.TODO(), cTTL)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got headers %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_cloudEventFinalizerHeaders(t *testing.T) {
+	tap, ce, url := newTestTap(t)
+	// the first attempt fails so that the Secret is rotated in between
+	tap.failures = 1
+	cTTL := newDeletedTestCTTL("authenticated", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSink = ptr.To(url)
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+		HeadersFrom: &cleanerv1alpha1.SecretHeaders{Name: "sink-auth", Headers: map[string]string{"Authorization": "token"}},
+	}
+	secret := newTestSecret("sink-auth", map[string]string{"token": "Bearer one"})
+	r := newTestReconciler(t, cTTL, secret)
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected an error delivering the cloud event")
+	}
+	secret.Data["token"] = []byte("Bearer two")
+	if err := r.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	reconcileUntilGone(t, r, cTTL)
+
+	var got []string
+	for _, h := range tap.headers {
+		got = append(got, h.Get("Authorization"))
+	}
+	if want := []string{"Bearer one", "Bearer two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Authorization headers %v, want %v", got, want)
+	}
+	if len(tap.events) != 1 {
+		t.Errorf("got %d events delivered, want 1", len(tap.events))
+	}
+}
+
+func Test_cloudEventFinalizerMissingHeaders(t *testing.T) {
+	tap, ce, url := newTestTap(t)
+	cTTL := newDeletedTestCTTL("unauthenticated", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSink = ptr.To(url)
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+		HeadersFrom: &cleanerv1alpha1.SecretHeaders{Name: "sink-auth"},
+		// a missing Secret is retried regardless of the delivery policy
+		DeliveryPolicy: cleanerv1alpha1.CloudEventDeliveryPolicyBestEffort,
+	}
+	r := newTestReconciler(t, cTTL)
+	r.CloudEventsClient = ce
+
+	if _, err := r.Reconcile(context.TODO(), requestFor(cTTL)); err == nil {
+		t.Fatal("expected an error reading the headers")
+	}
+	if tap.requests != 0 {
+		t.Errorf("got %d requests, want none", tap.requests)
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "EventHeadersUnresolved"); got != 1 {
+		t.Errorf("got %d EventHeadersUnresolved events, want 1", got)
+	}
+
+	if err := r.Create(context.TODO(), newTestSecret("sink-auth", map[string]string{"authorization": "Bearer one"})); err != nil {
+		t.Fatal(err)
+	}
+	reconcileUntilGone(t, r, cTTL)
+	if len(tap.headers) != 1 || tap.headers[0].Get("Authorization") != "Bearer one" {
+		t.Errorf("got headers %v, want the Authorization one", tap.headers)
+	}
+}
+
+// newTestTLSTap starts an HTTPS sink whose certificate is signed by a
+// private CA, returning the tap, its URL and the PEM encoded CA. Clients
+// must present a certificate signed by clientCA, if set.
+func newTestTLSTap(t *testing.T, clientCA []byte) (*tapHandler, string, []byte) {
+	t.Helper()
+	tap := newCloudEventTap()
+	server := httptest.NewUnstartedServer(tap)
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(clientCA)
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return tap, server.URL, ca
+}
+
+// newTestClientCertificate returns a PEM encoded self-signed client
+// certificate and its key.
+func newTestClientCertificate(t *testing.T) (crt, key []byte) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cleaner-controller"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func newTestConfigMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Data: data}
+}
+
+func Test_cloudEventFinalizerTLS(t *testing.T) {
+	tap, url, ca := newTestTLSTap(t, nil)
+	sharedClient, err := cloudevents.NewClientHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the shared client only trusts the system's CAs
+	untrusted := newDeletedTestCTTL("untrusted", "cleaner.vtex.io/cloud-event-finalizer")
+	untrusted.Spec.CloudEventSink = ptr.To(url)
+	r := newTestReconciler(t, untrusted)
+	r.CloudEventsClient = sharedClient
+	if _, err := r.Reconcile(context.TODO(), requestFor(untrusted)); err == nil {
+		t.Fatal("expected an error delivering the cloud event to a sink with a private CA")
+	}
+	if tap.requests != 0 {
+		t.Errorf("got %d requests, want none", tap.requests)
+	}
+	if got := countEvents(drainEvents(r.Recorder.(*record.FakeRecorder)), "EventDeliveryFailed"); got != 1 {
+		t.Errorf("got %d EventDeliveryFailed events, want 1", got)
+	}
+
+	trusted := newDeletedTestCTTL("trusted", "cleaner.vtex.io/cloud-event-finalizer")
+	trusted.Spec.CloudEventSink = ptr.To(url)
+	trusted.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+		TLS: &cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca"}},
+	}
+	r = newTestReconciler(t, trusted, newTestConfigMap("sink-ca", map[string]string{"ca.crt": string(ca)}))
+	r.CloudEventsClient = sharedClient
+	reconcileUntilGone(t, r, trusted)
+	if len(tap.events) != 1 {
+		t.Errorf("got %d events delivered, want 1", len(tap.events))
+	}
+}
+
+func Test_cloudEventFinalizerClientCertificate(t *testing.T) {
+	crt, key := newTestClientCertificate(t)
+	tap, url, ca := newTestTLSTap(t, crt)
+	cTTL := newDeletedTestCTTL("mutual", "cleaner.vtex.io/cloud-event-finalizer")
+	cTTL.Spec.CloudEventSink = ptr.To(url)
+	cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{
+		TLS: &cleanerv1alpha1.CloudEventTLS{
+			CA:                      &cleanerv1alpha1.CABundle{Secret: "sink-tls", Key: "ca.pem"},
+			ClientCertificateSecret: "sink-client",
+		},
+	}
+	r := newTestReconciler(t, cTTL,
+		newTestSecret("sink-tls", map[string]string{"ca.pem": string(ca)}),
+		newTestSecret("sink-client", map[string]string{"tls.crt": string(crt), "tls.key": string(key)}),
+	)
+	reconcileUntilGone(t, r, cTTL)
+	if len(tap.events) != 1 {
+		t.Errorf("got %d events delivered, want 1", len(tap.events))
+	}
+}
+
+func Test_cloudEventTLSConfig(t *testing.T) {
+	crt, key := newTestClientCertificate(t)
+	objs := []client.Object{
+		newTestConfigMap("sink-ca", map[string]string{"ca.crt": string(crt), "other": "not a certificate"}),
+		newTestSecret("sink-client", map[string]string{"tls.crt": string(crt), "tls.key": string(key)}),
+		newTestSecret("mismatched", map[string]string{"tls.crt": string(crt), "tls.key": "not a key"}),
+	}
+	testCases := map[string]struct {
+		tls          cleanerv1alpha1.CloudEventTLS
+		wantRootCAs  bool
+		wantCerts    int
+		wantInsecure bool
+		wantMessage  string
+	}{
+		"CA bundle": {
+			tls:         cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca"}},
+			wantRootCAs: true,
+		},
+		"client certificate": {
+			tls:       cleanerv1alpha1.CloudEventTLS{ClientCertificateSecret: "sink-client"},
+			wantCerts: 1,
+		},
+		"insecure": {
+			tls:          cleanerv1alpha1.CloudEventTLS{InsecureSkipVerify: true},
+			wantInsecure: true,
+		},
+		"missing ConfigMap": {
+			tls:         cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "missing"}},
+			wantMessage: `"missing" not found`,
+		},
+		"missing key": {
+			tls:         cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca", Key: "ca.pem"}},
+			wantMessage: `key "ca.pem" not found on ConfigMap "sink-ca"`,
+		},
+		"not a certificate": {
+			tls:         cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca", Key: "other"}},
+			wantMessage: "holds no PEM encoded certificate",
+		},
+		"invalid client certificate": {
+			tls:         cleanerv1alpha1.CloudEventTLS{ClientCertificateSecret: "mismatched"},
+			wantMessage: `Secret "mismatched" holds no valid client certificate`,
+		},
+	}
+
+	r := newTestReconciler(t, objs...)
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			got, err := r.cloudEventTLSConfig(context.TODO(), "default", &tc.tls)
+			if tc.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantMessage) {
+					t.Fatalf("got err=%v, want it to contain %q", err, tc.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if (got.RootCAs != nil) != tc.wantRootCAs || len(got.Certificates) != tc.wantCerts || got.InsecureSkipVerify != tc.wantInsecure {
+				t.Errorf("got RootCAs=%t Certificates=%d InsecureSkipVerify=%t", got.RootCAs != nil, len(got.Certificates), got.InsecureSkipVerify)
+			}
+		})
+	}
+}
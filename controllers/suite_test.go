@@ -24,7 +24,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -37,6 +40,7 @@ import (
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
@@ -49,17 +53,20 @@ import (
 	memcached "k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/kinds"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -75,6 +82,7 @@ var (
 	helmCfg   *action.Configuration
 	server    *httptest.Server
 	tap       *tapHandler
+	tracker   *concurrencyTracker
 )
 
 func TestAPIs(t *testing.T) {
@@ -84,11 +92,54 @@ func TestAPIs(t *testing.T) {
 }
 
 type tapHandler struct {
-	handler   http.Handler
-	lastEvent cloudevents.Event
+	handler http.Handler
+	// deletedEvent is the last conditionalTTL.deleted event received.
+	deletedEvent cloudevents.Event
+
+	mu     sync.Mutex
+	events []cloudevents.Event
+	// failures is how many of the next requests fail with 503 before
+	// they are handled again.
+	failures int
+	requests int
+	// headers are those of every request, in order.
+	headers []http.Header
+}
+
+// receive records e, along with deletedEvent when it is one.
+func (t *tapHandler) receive(e cloudevents.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, e)
+	if e.Type() == "conditionalTTL.deleted" {
+		t.deletedEvent = e
+	}
+}
+
+// types lists the types of the events received so far, in order.
+func (t *tapHandler) types() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	types := make([]string, 0, len(t.events))
+	for _, e := range t.events {
+		types = append(types, e.Type())
+	}
+	return types
 }
 
 func (t *tapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	t.requests++
+	t.headers = append(t.headers, r.Header.Clone())
+	failing := t.failures > 0
+	if failing {
+		t.failures--
+	}
+	t.mu.Unlock()
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	if t.handler == nil {
 		w.WriteHeader(500)
 		return
@@ -137,13 +188,20 @@ var _ = BeforeSuite(func() {
 	cec, err := cloudevents.NewClientHTTP()
 	Expect(err).ToNot(HaveOccurred())
 
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	Expect(err).ToNot(HaveOccurred())
+	cdc := memcached.NewMemCacheClient(dc)
+	kindChecker := kinds.NewChecker(restmapper.NewDeferredDiscoveryRESTMapper(cdc), cdc)
+
+	tracker = &concurrencyTracker{Client: k8sManager.GetClient(), prefix: "parallel-"}
 	err = (&ConditionalTTLReconciler{
-		Client:            k8sManager.GetClient(),
+		Client:            tracker,
 		Scheme:            k8sManager.GetScheme(),
 		Recorder:          k8sManager.GetEventRecorderFor("cleaner-controller"),
 		HelmConfig:        helmCfg,
 		CloudEventsClient: cec,
-	}).SetupWithManager(k8sManager)
+		KindChecker:       kindChecker,
+	}).SetupWithManager(k8sManager, ControllerOptions{MaxConcurrentReconciles: 4}.Build())
 	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
@@ -206,7 +264,7 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 		go func() {
 			defer GinkgoRecover()
 			err := ce.StartReceiver(ctx, func(e cloudevents.Event) cloudevents.Result {
-				tap.lastEvent = e
+				tap.receive(e)
 				return cloudevents.ResultACK
 			})
 			Expect(err).ToNot(HaveOccurred())
@@ -286,10 +344,18 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 						Delete:  true,
 					},
 					CloudEventSink: pointer.String(server.URL),
+					CloudEvent: &cleanerv1alpha1.CloudEventConfig{
+						Events: []cleanerv1alpha1.CloudEventStage{
+							cleanerv1alpha1.CloudEventStageExpired,
+							cleanerv1alpha1.CloudEventStageConditionsMet,
+							cleanerv1alpha1.CloudEventStageDeletionFailed,
+							cleanerv1alpha1.CloudEventStageDeleted,
+						},
+					},
 					Targets: []cleanerv1alpha1.Target{
 						{
 							Name:                  "pod",
-							IncludeWhenEvaluating: true,
+							IncludeWhenEvaluating: pointer.Bool(true),
 							Delete:                true,
 							Reference: cleanerv1alpha1.TargetReference{
 								TypeMeta: metav1.TypeMeta{
@@ -301,7 +367,7 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 						},
 						{
 							Name:                  "pods",
-							IncludeWhenEvaluating: true,
+							IncludeWhenEvaluating: pointer.Bool(true),
 							Delete:                true,
 							Reference: cleanerv1alpha1.TargetReference{
 								TypeMeta: metav1.TypeMeta{
@@ -353,11 +419,12 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 
 			Expect(readyCondition.Status).Should(Equal(metav1.ConditionFalse))
 			Expect(readyCondition.Reason).Should(Equal(cleanerv1alpha1.ConditionReasonTargetResolveError))
+			Expect(readyCondition.Message).Should(ContainSubstring(`Error resolving target "pod"`))
 			Expect(len(createdCTTL.Finalizers)).Should(Equal(0))
 		})
 
-		// this happens because a target not found is a reconcile error,
-		// hence it is retried. In the future we could watch for target
+		// this happens because a target not found is requeued after
+		// the retry period. In the future we could watch for target
 		// changes
 		It("Picks up the creation of targets", func() {
 			By("By creating single target pod")
@@ -460,14 +527,27 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 			Expect(err).To(Equal(driver.ErrReleaseNotFound))
 		})
 
+		It("Delivers lifecycle cloudevents in order", func() {
+			// lifecycle events are delivered in the background, keeping
+			// their order, and may arrive after the deleted one
+			Eventually(tap.types, timeout, interval).Should(ConsistOf(
+				"conditionalTTL.expired",
+				"conditionalTTL.conditionsMet",
+				"conditionalTTL.deleted",
+			))
+			types := tap.types()
+			Expect(slices.Index(types, "conditionalTTL.expired")).To(BeNumerically("<", slices.Index(types, "conditionalTTL.conditionsMet")))
+		})
+
 		It("Delivers cloudevent on deletion", func() {
-			Expect(tap.lastEvent).ToNot(BeNil())
-			Expect(tap.lastEvent.Type()).To(Equal("conditionalTTL.deleted"))
-			Expect(tap.lastEvent.Source()).To(Equal("cleaner.vtex.io/finalizer"))
-			Expect(tap.lastEvent.DataContentType()).To(Equal("application/json"))
+			Expect(tap.deletedEvent).ToNot(BeNil())
+			Expect(tap.deletedEvent.Type()).To(Equal("conditionalTTL.deleted"))
+			Expect(tap.deletedEvent.Source()).To(Equal("cleaner.vtex.io/finalizer"))
+			Expect(tap.deletedEvent.Subject()).To(Equal(ConditionalTTLNamespace + "/" + ConditionalTTLName))
+			Expect(tap.deletedEvent.DataContentType()).To(Equal("application/json"))
 
 			data := make(map[string]interface{})
-			err := json.Unmarshal(tap.lastEvent.Data(), &data)
+			err := json.Unmarshal(tap.deletedEvent.Data(), &data)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(data["name"]).To(Equal(ConditionalTTLName))
@@ -510,6 +590,10 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 		}{
 			{
 				wantedReason: cleanerv1alpha1.ConditionReasonCompileError,
+				condition:    "size(targets.items) ==",
+			},
+			{
+				wantedReason: cleanerv1alpha1.ConditionReasonReferenceError,
 				condition:    "size(invalidTargetName) == 2",
 			},
 			{
@@ -544,7 +628,7 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 						Targets: []cleanerv1alpha1.Target{
 							{
 								Name:                  "targets",
-								IncludeWhenEvaluating: true,
+								IncludeWhenEvaluating: pointer.Bool(true),
 								Reference: cleanerv1alpha1.TargetReference{
 									TypeMeta: metav1.TypeMeta{
 										APIVersion: "v1",
@@ -591,6 +675,275 @@ var _ = Describe("ConditionalTTL controller", Ordered, func() {
 			})
 		}
 	})
+
+	Context("After expiring with an unknown target kind", func() {
+		It("Reports UnknownKind without retrying", func() {
+			By("By creating a cTTL targeting a kind the API server doesn't serve")
+			name := "unknown-kind"
+			cTTL := &cleanerv1alpha1.ConditionalTTL{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cleaner.vtex.io/v1alpha1",
+					Kind:       "ConditionalTTL",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ConditionalTTLNamespace,
+				},
+				Spec: cleanerv1alpha1.ConditionalTTLSpec{
+					TTL: &metav1.Duration{Duration: 0 * time.Second},
+					Targets: []cleanerv1alpha1.Target{
+						{
+							Name:                  "widget",
+							IncludeWhenEvaluating: pointer.Bool(true),
+							Reference: cleanerv1alpha1.TargetReference{
+								TypeMeta: metav1.TypeMeta{
+									APIVersion: "example.com/v1",
+									Kind:       "Widget",
+								},
+								Name: pointer.String("my-widget"),
+							},
+						},
+					},
+					Conditions: []string{"true"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cTTL)).Should(Succeed())
+
+			cTTLLookupKey := types.NamespacedName{
+				Name:      name,
+				Namespace: ConditionalTTLNamespace,
+			}
+			createdCTTL := &cleanerv1alpha1.ConditionalTTL{}
+			var readyCondition *metav1.Condition
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, cTTLLookupKey, createdCTTL)
+				if err != nil {
+					return false
+				}
+				readyCondition = apimeta.FindStatusCondition(createdCTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+				return readyCondition != nil
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(readyCondition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(readyCondition.Reason).Should(Equal(cleanerv1alpha1.ConditionReasonUnknownKind))
+			Expect(readyCondition.Message).Should(ContainSubstring(`target "widget"`))
+
+			By("By checking the cTTL is left alone afterwards")
+			Consistently(func() string {
+				if err := k8sClient.Get(ctx, cTTLLookupKey, createdCTTL); err != nil {
+					return err.Error()
+				}
+				return createdCTTL.GetResourceVersion()
+			}, 2*time.Second, interval).Should(Equal(createdCTTL.GetResourceVersion()))
+
+			Expect(k8sClient.Delete(ctx, cTTL)).Should(Succeed())
+		})
+	})
+
+	Context("With targets omitting their apiVersion", func() {
+		It("Resolves them to the preferred version", func() {
+			By("By creating a pod and a lease")
+			pod := buildPod("kind-only-pod")
+			Expect(k8sClient.Create(ctx, pod)).Should(Succeed())
+			lease := &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kind-only-lease",
+					Namespace: TargetPodNamespace,
+				},
+			}
+			Expect(k8sClient.Create(ctx, lease)).Should(Succeed())
+
+			By("By creating a cTTL referencing them by kind only")
+			name := "kind-only"
+			cTTL := &cleanerv1alpha1.ConditionalTTL{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cleaner.vtex.io/v1alpha1",
+					Kind:       "ConditionalTTL",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ConditionalTTLNamespace,
+					// keeps the cTTL around to be inspected once deleted
+					Finalizers: []string{"test/keep"},
+				},
+				Spec: cleanerv1alpha1.ConditionalTTLSpec{
+					TTL: &metav1.Duration{Duration: 0},
+					Targets: []cleanerv1alpha1.Target{
+						{
+							Name:                  "pod",
+							IncludeWhenEvaluating: pointer.Bool(true),
+							Reference: cleanerv1alpha1.TargetReference{
+								TypeMeta: metav1.TypeMeta{Kind: "Pod"},
+								Name:     pointer.String("kind-only-pod"),
+							},
+						},
+						{
+							Name:                  "lease",
+							IncludeWhenEvaluating: pointer.Bool(true),
+							Reference: cleanerv1alpha1.TargetReference{
+								TypeMeta: metav1.TypeMeta{Kind: "Lease"},
+								Name:     pointer.String("kind-only-lease"),
+							},
+						},
+					},
+					Conditions: []string{`pod.apiVersion == "v1" && lease.apiVersion == "coordination.k8s.io/v1"`},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cTTL)).Should(Succeed())
+
+			cTTLLookupKey := types.NamespacedName{
+				Name:      name,
+				Namespace: ConditionalTTLNamespace,
+			}
+			createdCTTL := &cleanerv1alpha1.ConditionalTTL{}
+
+			By("By verifying the resolved kinds are recorded")
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, cTTLLookupKey, createdCTTL); err != nil {
+					return 0
+				}
+				return len(createdCTTL.Status.Targets)
+			}, timeout, interval).Should(Equal(2))
+			Expect(createdCTTL.Status.Targets[0].APIVersion).Should(Equal("v1"))
+			Expect(createdCTTL.Status.Targets[0].Kind).Should(Equal("Pod"))
+			Expect(createdCTTL.Status.Targets[1].APIVersion).Should(Equal("coordination.k8s.io/v1"))
+			Expect(createdCTTL.Status.Targets[1].Kind).Should(Equal("Lease"))
+
+			controllerutil.RemoveFinalizer(createdCTTL, "test/keep")
+			Expect(k8sClient.Update(ctx, createdCTTL)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, pod)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, lease)).Should(Succeed())
+		})
+	})
+
+	Context("With history", func() {
+		It("Accumulates a bounded history referenceable by conditions", func() {
+			By("By creating a target pod")
+			pod := buildPod("history-pod")
+			Expect(k8sClient.Create(ctx, pod)).Should(Succeed())
+
+			By("By creating a cTTL keeping history")
+			cTTL := &cleanerv1alpha1.ConditionalTTL{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "cleaner.vtex.io/v1alpha1",
+					Kind:       "ConditionalTTL",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "history",
+					Namespace: ConditionalTTLNamespace,
+				},
+				Spec: cleanerv1alpha1.ConditionalTTLSpec{
+					TTL: &metav1.Duration{Duration: 0},
+					Retry: &cleanerv1alpha1.RetryConfig{
+						Period: &metav1.Duration{Duration: 1 * time.Second},
+					},
+					HistoryLimit: 3,
+					Targets: []cleanerv1alpha1.Target{
+						{
+							Name:                  "pod",
+							IncludeWhenEvaluating: pointer.Bool(true),
+							Reference: cleanerv1alpha1.TargetReference{
+								TypeMeta: metav1.TypeMeta{
+									APIVersion: "v1",
+									Kind:       "Pod",
+								},
+								Name: pointer.String("history-pod"),
+							},
+						},
+					},
+					Conditions: []string{
+						// fails to evaluate if entries don't hold the pod summary
+						`history.exists(h, h.targets.pod.count != 1)`,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cTTL)).Should(Succeed())
+
+			cTTLLookupKey := types.NamespacedName{
+				Name:      "history",
+				Namespace: ConditionalTTLNamespace,
+			}
+			createdCTTL := &cleanerv1alpha1.ConditionalTTL{}
+
+			By("By verifying history accumulates up to the limit")
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, cTTLLookupKey, createdCTTL); err != nil {
+					return 0
+				}
+				return len(createdCTTL.Status.History)
+			}, timeout, interval).Should(Equal(3))
+			Consistently(func() int {
+				if err := k8sClient.Get(ctx, cTTLLookupKey, createdCTTL); err != nil {
+					return 0
+				}
+				return len(createdCTTL.Status.History)
+			}, 2*time.Second, interval).Should(Equal(3))
+
+			readyCondition := apimeta.FindStatusCondition(createdCTTL.Status.Conditions, cleanerv1alpha1.ConditionTypeReady)
+			Expect(readyCondition).ToNot(BeNil())
+			Expect(readyCondition.Reason).Should(Equal(cleanerv1alpha1.ConditionReasonWaitingForConditions))
+			Expect(createdCTTL.Status.History[0].Targets).To(HaveLen(1))
+			Expect(createdCTTL.Status.History[0].Targets[0].Name).To(Equal("pod"))
+
+			Expect(k8sClient.Delete(ctx, cTTL)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, pod)).Should(Succeed())
+		})
+	})
+
+	Context("With many cTTLs", func() {
+		It("Reconciles independent cTTLs in parallel", func() {
+			const n = 4
+			By("By creating cTTLs each targeting its own pod")
+			for i := 0; i < n; i++ {
+				name := fmt.Sprintf("parallel-%d", i)
+				pod := buildPod(name)
+				pod.Labels = nil
+				Expect(k8sClient.Create(ctx, pod)).Should(Succeed())
+				cTTL := &cleanerv1alpha1.ConditionalTTL{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: ConditionalTTLNamespace,
+					},
+					Spec: cleanerv1alpha1.ConditionalTTLSpec{
+						TTL: &metav1.Duration{Duration: 0},
+						Targets: []cleanerv1alpha1.Target{
+							{
+								Name:   "pod",
+								Delete: true,
+								Reference: cleanerv1alpha1.TargetReference{
+									TypeMeta: metav1.TypeMeta{
+										APIVersion: "v1",
+										Kind:       "Pod",
+									},
+									Name: pointer.String(name),
+								},
+							},
+						},
+					},
+				}
+				Expect(k8sClient.Create(ctx, cTTL)).Should(Succeed())
+			}
+
+			By("By verifying every cTTL is deleted")
+			Eventually(func() int {
+				cTTLs := &cleanerv1alpha1.ConditionalTTLList{}
+				if err := k8sClient.List(ctx, cTTLs, client.InNamespace(ConditionalTTLNamespace)); err != nil {
+					return -1
+				}
+				count := 0
+				for _, c := range cTTLs.Items {
+					if strings.HasPrefix(c.Name, "parallel-") {
+						count++
+					}
+				}
+				return count
+			}, timeout, interval).Should(Equal(0))
+
+			By("By verifying targets were resolved concurrently")
+			Expect(tracker.maxInFlight.Load()).Should(BeNumerically(">", 1))
+		})
+	})
 })
 
 var _ = AfterSuite(func() {
@@ -625,6 +978,32 @@ func buildPod(name string) *v1.Pod {
 
 }
 
+// concurrencyTracker records how many Gets of objects whose name has
+// prefix are in flight at once, slowing them down so that concurrent
+// reconciles overlap.
+type concurrencyTracker struct {
+	client.Client
+	prefix      string
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (c *concurrencyTracker) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*cleanerv1alpha1.ConditionalTTL); ok || !strings.HasPrefix(key.Name, c.prefix) {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		m := c.maxInFlight.Load()
+		if n <= m || c.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
 // wrappers required for the Helm client to work with envtest
 var _ genericclioptions.RESTClientGetter = &clientWrapper{}
 
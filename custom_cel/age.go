@@ -0,0 +1,113 @@
+package custom_cel
+
+import (
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/parser"
+)
+
+// Age returns a cel.EnvOption to configure helper functions for the age of
+// objects.
+//
+// # Age
+//
+// Returns the duration between the evaluation time, the `time` variable,
+// and the object's metadata.creationTimestamp. Evaluation fails when the
+// timestamp is missing or can't be parsed.
+//
+// age(<dyn>) ==> <duration>
+//
+// Examples:
+//
+// age(deployment) > duration("24h")
+//
+// pods.items.all(p, age(p) > duration("1h"))
+//
+// # AgeAt
+//
+// Like age, but relative to the given time instead of the evaluation time.
+//
+// age_at(<dyn>, <timestamp>) ==> <duration>
+//
+// Examples:
+//
+// age_at(deployment, timestamp("2024-01-01T00:00:00Z")) > duration("24h")
+func Age() cel.EnvOption {
+	return cel.Lib(ageLib{})
+}
+
+type ageLib struct{}
+
+// ageDecls declares the age macro and the age_at function.
+var ageDecls = libraryDecls{
+	// a macro, since functions can't read the time variable
+	macros: []cel.Macro{parser.NewGlobalMacro("age", 1, makeAge)},
+	functions: map[string][]cel.FunctionOpt{
+		"age_at": {
+			cel.Overload(
+				"age_at_dyn_timestamp",
+				[]*cel.Type{cel.DynType, cel.TimestampType},
+				cel.DurationType,
+				cel.BinaryBinding(ageAt),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u ageLib) CompileOptions() []cel.EnvOption {
+	return ageDecls.options()
+}
+
+// ProgramOptions implements the Library interface method defining the basic program options
+func (u ageLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+// makeAge expands age(obj) to age_at(obj, time).
+func makeAge(eh parser.ExprHelper, _ ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+	return eh.NewCall("age_at", args[0], eh.NewIdent("time")), nil
+}
+
+var (
+	metadataKey          = types.String("metadata")
+	creationTimestampKey = types.String("creationTimestamp")
+)
+
+func ageAt(objVal, tVal ref.Val) ref.Val {
+	t, ok := tVal.(types.Timestamp)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(tVal)
+	}
+	obj, ok := objVal.(traits.Mapper)
+	if !ok {
+		return types.NewErr("age: expected an object but got %s", objVal.Type().TypeName())
+	}
+	metadata, found := obj.Find(metadataKey)
+	if !found {
+		return types.NewErr("age: object has no metadata.creationTimestamp")
+	}
+	m, ok := metadata.(traits.Mapper)
+	if !ok {
+		return types.NewErr("age: expected metadata to be a map but got %s", metadata.Type().TypeName())
+	}
+	created, found := m.Find(creationTimestampKey)
+	if !found {
+		return types.NewErr("age: object has no metadata.creationTimestamp")
+	}
+	s, ok := created.(types.String)
+	if !ok {
+		return types.NewErr("age: expected metadata.creationTimestamp to be a string but got %s", created.Type().TypeName())
+	}
+	createdAt, err := time.Parse(time.RFC3339, string(s))
+	if err != nil {
+		return types.NewErr("age: unable to parse metadata.creationTimestamp: %s", err)
+	}
+	return types.Duration{Duration: t.Time.Sub(createdAt)}
+}
@@ -0,0 +1,101 @@
+package custom_cel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+func Test_age(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	createdAt := func(ts interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "obj", "creationTimestamp": ts},
+		}
+	}
+
+	testCases := map[string]struct {
+		expr    string
+		obj     map[string]interface{}
+		want    time.Duration
+		wantErr string
+	}{
+		"age": {
+			expr: `age(obj)`,
+			obj:  createdAt("2024-01-02T10:30:00Z"),
+			want: 90 * time.Minute,
+		},
+		"age compared to a duration": {
+			expr: `age(obj) > duration("1h") ? duration("1s") : duration("0s")`,
+			obj:  createdAt("2024-01-02T10:30:00Z"),
+			want: time.Second,
+		},
+		"age at a given time": {
+			expr: `age_at(obj, timestamp("2024-01-03T10:30:00Z"))`,
+			obj:  createdAt("2024-01-02T10:30:00Z"),
+			want: 24 * time.Hour,
+		},
+		"created after the evaluation time": {
+			expr: `age(obj)`,
+			obj:  createdAt("2024-01-02T12:00:05Z"),
+			want: -5 * time.Second,
+		},
+		"missing metadata": {
+			expr:    `age(obj)`,
+			obj:     map[string]interface{}{"kind": "Pod"},
+			wantErr: "no metadata.creationTimestamp",
+		},
+		"missing creationTimestamp": {
+			expr:    `age(obj)`,
+			obj:     map[string]interface{}{"metadata": map[string]interface{}{"name": "obj"}},
+			wantErr: "no metadata.creationTimestamp",
+		},
+		"unparseable creationTimestamp": {
+			expr:    `age(obj)`,
+			obj:     createdAt("yesterday"),
+			wantErr: "unable to parse metadata.creationTimestamp",
+		},
+		"creationTimestamp not a string": {
+			expr:    `age(obj)`,
+			obj:     createdAt(int64(1)),
+			wantErr: "to be a string",
+		},
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("obj", cel.DynType),
+		Age(),
+	)
+	if err != nil {
+		t.Fatalf("unable to create new env: %s", err)
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			ast, issues := env.Compile(tc.expr)
+			if issues != nil && issues.Err() != nil {
+				t.Fatalf("compile error: %s", issues.Err())
+			}
+			prg, err := env.Program(ast)
+			if err != nil {
+				t.Fatalf("program error: %s", err)
+			}
+			got, _, err := prg.Eval(map[string]interface{}{"time": now, "obj": tc.obj})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got err=%v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("eval error: %s", err)
+			}
+			if d, ok := got.Value().(time.Duration); !ok || d != tc.want {
+				t.Errorf("got=%v want=%s", got, tc.want)
+			}
+		})
+	}
+}
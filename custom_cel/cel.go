@@ -1,37 +1,143 @@
 package custom_cel
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/ext"
 	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apiserver/pkg/cel/library"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// tracerName is the instrumentation scope of the spans started when
+// evaluating conditions.
+const tracerName = "github.com/vtex/cleaner-controller/custom_cel"
+
+// ReservedNames returns the variables declared when evaluating the
+// conditions of cTTL, which therefore can't be used as target names:
+// history and previous are only declared when the cTTL keeps them.
+func ReservedNames(cTTL *cleanerv1alpha1.ConditionalTTL) []string {
+	names := []string{"time"}
+	if cTTL.Spec.HistoryLimit > 0 {
+		names = append(names, "history")
+	}
+	if cTTL.Spec.KeepPreviousState {
+		names = append(names, "previous")
+	}
+	return names
+}
+
 // BuildCELOptions builds the list of env options to be used when
 // building the CEL environment used to evaluated the conditions
 // of a given cTTL.
 func BuildCELOptions(cTTL *cleanerv1alpha1.ConditionalTTL) []cel.EnvOption {
-	r := []cel.EnvOption{
-		ext.Strings(),  // helper string functions
-		ext.Bindings(), // helper binding functions
-		Lists(),        // custom VTEX helper for list functions
-		cel.Variable("time", cel.TimestampType),
+	r := append(libraries(), cel.Variable("time", cel.TimestampType))
+	if cTTL.Spec.HistoryLimit > 0 {
+		r = append(r, cel.Variable("history", cel.ListType(cel.DynType)))
+	}
+	if cTTL.Spec.KeepPreviousState {
+		r = append(r, cel.Variable("previous", cel.MapType(cel.StringType, cel.DynType)))
 	}
 	for _, t := range cTTL.Spec.Targets {
-		if t.IncludeWhenEvaluating {
+		if t.IncludedWhenEvaluating() {
 			r = append(r, cel.Variable(t.Name, cel.DynType))
 		}
 	}
+	for _, v := range cTTL.Spec.ExtraContext {
+		r = append(r, cel.Variable(v.Name, cel.StringType))
+	}
+	if includesHelmRelease(cTTL) {
+		r = append(r, cel.Variable(HelmReleaseVariable, cel.DynType))
+	}
 	return r
 }
 
+// BuildClusterCELOptions builds the list of env options used when building
+// the CEL environment the conditions of a ClusterConditionalTTL are
+// evaluated in, whose targets are only exposed grouped by namespace.
+func BuildClusterCELOptions() []cel.EnvOption {
+	return append(libraries(),
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("namespaces", cel.MapType(cel.StringType, cel.DynType)),
+	)
+}
+
+// libraries returns the libraries available to every condition.
+func libraries() []cel.EnvOption {
+	return []cel.EnvOption{
+		ext.Strings(),      // helper string functions
+		ext.Bindings(),     // helper binding functions
+		Lists(),            // custom VTEX helper for list functions
+		Lookup(),           // custom VTEX helper for reading nested fields with a default
+		Pods(),             // custom VTEX helper for pod functions
+		Conditions(),       // custom VTEX helper for status conditions
+		Age(),              // custom VTEX helper for the age of objects
+		Timestamps(),       // custom VTEX helper for comparing timestamps
+		library.Quantity(), // resource.Quantity parsing and comparison, e.g. quantity("10Gi")
+		library.Regex(),    // regular expression extraction, e.g. name.find("[0-9]+")
+	}
+}
+
+// ownLibraries returns the declarations of the libraries returned by
+// libraries which this repository owns, as described by DescribeEnv.
+func ownLibraries() []libraryDecls {
+	return []libraryDecls{listsDecls, lookupDecls, podsDecls, conditionsDecls, ageDecls, timestampsDecls}
+}
+
+// EnvCheck builds the CEL environment used to evaluate conditions and
+// returns a readiness check which fails if it couldn't be built, e.g.
+// because two libraries declare the same function. The environment is only
+// built once since it can't change while the controller runs.
+func EnvCheck() healthz.Checker {
+	err := checkEnv(BuildCELOptions(&cleanerv1alpha1.ConditionalTTL{
+		Spec: cleanerv1alpha1.ConditionalTTLSpec{
+			Targets:           []cleanerv1alpha1.Target{{Name: "target", IncludeWhenEvaluating: ptr.To(true)}},
+			ExtraContext:      []cleanerv1alpha1.ContextValue{{Name: "value"}},
+			HistoryLimit:      1,
+			KeepPreviousState: true,
+		},
+	}))
+	return func(_ *http.Request) error {
+		return err
+	}
+}
+
+func checkEnv(opts []cel.EnvOption) error {
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return fmt.Errorf("unable to build CEL environment: %w", err)
+	}
+	if _, issues := env.Compile("true"); issues != nil && issues.Err() != nil {
+		return fmt.Errorf("unable to compile with CEL environment: %w", issues.Err())
+	}
+	return nil
+}
+
 // BuildCELContext builds the map of parameters to be passed to the CEL
-// evaluation given a list of TargetStatus and an evaluation time.
-func BuildCELContext(targets []cleanerv1alpha1.TargetStatus, time time.Time) map[string]interface{} {
+// evaluation given a list of TargetStatus, the history of previous
+// evaluations, the targets' state on the previous evaluation and an
+// evaluation time. Targets named history or previous take precedence, those
+// names only being declared when the cTTL keeps them.
+func BuildCELContext(targets []cleanerv1alpha1.TargetStatus, history []cleanerv1alpha1.HistoryEntry, previous []cleanerv1alpha1.TargetStatus, time time.Time) map[string]interface{} {
 	ctx := make(map[string]interface{})
+	ctx["history"] = historyContext(history)
+	prev := make(map[string]interface{}, len(previous))
+	for _, ts := range previous {
+		if ts.State != nil {
+			prev[ts.Name] = ts.State.UnstructuredContent()
+		}
+	}
+	ctx["previous"] = prev
 	for _, ts := range targets {
 		if !ts.IncludeWhenEvaluating {
 			continue
@@ -42,24 +148,79 @@ func BuildCELContext(targets []cleanerv1alpha1.TargetStatus, time time.Time) map
 	return ctx
 }
 
+// NamespaceTargets holds a namespace selected by a ClusterConditionalTTL
+// and the targets resolved in it.
+type NamespaceTargets struct {
+	Namespace *unstructured.Unstructured
+	Targets   []cleanerv1alpha1.TargetStatus
+}
+
+// BuildClusterCELContext builds the map of parameters passed to the CEL
+// evaluation of a ClusterConditionalTTL's conditions, exposing each
+// namespace and its targets included when evaluating as
+// namespaces[name].namespace and namespaces[name].targets.
+func BuildClusterCELContext(namespaces []NamespaceTargets, time time.Time) map[string]interface{} {
+	byName := make(map[string]interface{}, len(namespaces))
+	for _, ns := range namespaces {
+		targets := make(map[string]interface{}, len(ns.Targets))
+		for _, ts := range ns.Targets {
+			if ts.IncludeWhenEvaluating {
+				targets[ts.Name] = ts.State.UnstructuredContent()
+			}
+		}
+		byName[ns.Namespace.GetName()] = map[string]interface{}{
+			"namespace": ns.Namespace.UnstructuredContent(),
+			"targets":   targets,
+		}
+	}
+	return map[string]interface{}{
+		"time":       time,
+		"namespaces": byName,
+	}
+}
+
 // EvaluateCELConditions compiles and evaluates all the conditions on the passed CEL context,
-// returning true only when all conditions evaluate to true. It stops evaluating on the first
-// encountered error but otherwise all conditions are evaluated in order to find and report
-// compilation and/or evaluation errors early. It also updates the passed
-// readyCondition Status, Type, Reason and Message fields.
-func EvaluateCELConditions(opts []cel.EnvOption, celCtx map[string]interface{}, conditions []string, readyCondition *metav1.Condition) (conditionsMet bool, retryable bool) {
+// returning true only when enough conditions evaluate to true according to the passed
+// policy (all of them when policy is nil). It stops evaluating on the first encountered
+// error but otherwise all conditions are evaluated in order to find and report
+// compilation and/or evaluation errors early, returning the result of each condition.
+// Errors reference conditions by name.
+// It also updates the passed readyCondition Status, Type, Reason and Message fields.
+// The outcome is logged using the logger from ctx without including the context's
+// values, which may be large.
+func EvaluateCELConditions(ctx context.Context, opts []cel.EnvOption, celCtx map[string]interface{}, conditions []cleanerv1alpha1.NamedCondition, policy *cleanerv1alpha1.ConditionPolicy, readyCondition *metav1.Condition) (conditionsMet bool, retryable bool, results []cleanerv1alpha1.ConditionResult) {
+	log := log.FromContext(ctx)
+	// the span is a no-op unless the caller's is recorded
+	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "EvaluateCELConditions",
+		trace.WithAttributes(attribute.Int("cleaner.conditions", len(conditions))))
+	defer func() {
+		log.V(1).Info("Evaluated conditions", "conditionsMet", conditionsMet, "retryable", retryable, "reason", readyCondition.Reason, "results", results)
+		span.SetAttributes(
+			attribute.Bool("cleaner.conditions_met", conditionsMet),
+			attribute.Bool("cleaner.retryable", retryable),
+			attribute.String("cleaner.reason", readyCondition.Reason),
+		)
+		span.End()
+	}()
 	readyCondition.Status = metav1.ConditionFalse
 	readyCondition.Type = cleanerv1alpha1.ConditionTypeReady
 	env, err := cel.NewEnv(opts...)
 	if err != nil {
 		readyCondition.Reason = cleanerv1alpha1.ConditionReasonEnvironmentError
 		readyCondition.Message = "Error preparing CEL environment: " + err.Error()
-		return false, false
+		return false, false, nil
 	}
-	condsMet := true
+	required, err := requiredConditions(policy, len(conditions))
+	if err != nil {
+		readyCondition.Reason = cleanerv1alpha1.ConditionReasonInvalidConditionPolicy
+		readyCondition.Message = "Invalid condition policy: " + err.Error()
+		return false, false, nil
+	}
+	met := 0
+	results = make([]cleanerv1alpha1.ConditionResult, 0, len(conditions))
 	for cID, c := range conditions {
 		compileProgram := func() (cel.Program, error) {
-			ast, issues := env.Compile(c)
+			ast, issues := env.Compile(c.Expression)
 			if issues != nil && issues.Err() != nil {
 				return nil, issues.Err()
 			}
@@ -72,8 +233,8 @@ func EvaluateCELConditions(opts []cel.EnvOption, celCtx map[string]interface{},
 		prg, err := compileProgram()
 		if err != nil {
 			readyCondition.Reason = cleanerv1alpha1.ConditionReasonCompileError
-			readyCondition.Message = fmt.Sprintf("Error compiling condition %d: %s", cID, err.Error())
-			return false, false
+			readyCondition.Message = fmt.Sprintf("Error compiling condition %s: %s", c.Name, err.Error())
+			return false, false, nil
 		}
 
 		// second return value (details) is always nil without
@@ -81,31 +242,56 @@ func EvaluateCELConditions(opts []cel.EnvOption, celCtx map[string]interface{},
 		out, _, err := prg.Eval(celCtx)
 		if err != nil {
 			readyCondition.Reason = cleanerv1alpha1.ConditionReasonEvaluationError
-			readyCondition.Message = fmt.Sprintf("Error evaluating condition %d: %s", cID, err.Error())
+			readyCondition.Message = fmt.Sprintf("Error evaluating condition %s: %s", c.Name, err.Error())
 			// it is possible for a less than careful condition
 			// to have runtime errors sometimes so we must retry
-			return false, true
+			return false, true, nil
 		}
 
 		res, ok := out.Value().(bool)
 		if !ok {
 			readyCondition.Reason = cleanerv1alpha1.ConditionReasonResultNotBoolean
-			readyCondition.Message = fmt.Sprintf("Condition %d result is not a boolean value", cID)
-			return false, false
+			readyCondition.Message = fmt.Sprintf("Condition %s result is not a boolean value", c.Name)
+			return false, false, nil
 		}
-		if !res {
-			condsMet = false
+		if res {
+			met++
 		}
+		results = append(results, cleanerv1alpha1.ConditionResult{Index: cID, Name: c.Name, Met: res})
 	}
 
 	readyCondition.Status = metav1.ConditionTrue
-	if !condsMet {
+	if met < required {
 		readyCondition.Reason = cleanerv1alpha1.ConditionReasonWaitingForConditions
-		readyCondition.Message = "Waiting for conditions to be met"
-		return false, true
+		readyCondition.Message = fmt.Sprintf("Waiting for conditions to be met: %d of %d met, %d required", met, len(conditions), required)
+		return false, true, results
 	}
 
 	readyCondition.Reason = cleanerv1alpha1.ConditionReasonTerminating
 	readyCondition.Message = "Targets resolved and conditions met"
-	return true, false
+	return true, false, results
+}
+
+// requiredConditions returns how many of n conditions must be met to
+// satisfy the policy.
+func requiredConditions(policy *cleanerv1alpha1.ConditionPolicy, n int) (int, error) {
+	if policy == nil {
+		return n, nil
+	}
+	switch policy.Type {
+	case cleanerv1alpha1.ConditionPolicyAll, "":
+		return n, nil
+	case cleanerv1alpha1.ConditionPolicyAny:
+		return min(1, n), nil
+	case cleanerv1alpha1.ConditionPolicyAtLeast:
+		if policy.Count == nil || *policy.Count < 1 {
+			return 0, fmt.Errorf("count must be a positive number when type is %s", policy.Type)
+		}
+		if int(*policy.Count) > n {
+			return 0, fmt.Errorf("count %d is greater than the number of conditions (%d)", *policy.Count, n)
+		}
+		return int(*policy.Count), nil
+	default:
+		return 0, fmt.Errorf("unknown type %q", policy.Type)
+	}
 }
@@ -0,0 +1,297 @@
+package custom_cel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+func Test_quantity(t *testing.T) {
+	pod := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{
+								"memory": "6Gi",
+							},
+						},
+					},
+					map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{
+								"memory": "512Mi",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		condition     string
+		wantMet       bool
+		wantReason    string
+		wantRetryable bool
+	}{
+		"request below threshold": {
+			condition:  `quantity(pod.spec.containers[0].resources.requests.memory).isLessThan(quantity("10Gi"))`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"request above threshold": {
+			condition:     `quantity(pod.spec.containers[0].resources.requests.memory).isLessThan(quantity("1Gi"))`,
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonWaitingForConditions,
+			wantRetryable: true,
+		},
+		"sum of requests below threshold": {
+			condition: `quantity(pod.spec.containers[0].resources.requests.memory)
+				.add(quantity(pod.spec.containers[1].resources.requests.memory))
+				.compareTo(quantity("10Gi")) < 0`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"invalid quantity": {
+			condition:     `quantity("ten gigs").isLessThan(quantity("10Gi"))`,
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonEvaluationError,
+			wantRetryable: true,
+		},
+		"isQuantity on invalid quantity": {
+			condition:  `!isQuantity("ten gigs")`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			gotMet, gotRetryable, gotCondition := evaluateWithPod(pod, tc.condition)
+			if gotMet != tc.wantMet {
+				t.Errorf("conditionsMet: got=%v want=%v (%s)", gotMet, tc.wantMet, gotCondition.Message)
+			}
+			if gotRetryable != tc.wantRetryable {
+				t.Errorf("retryable: got=%v want=%v", gotRetryable, tc.wantRetryable)
+			}
+			if gotCondition.Reason != tc.wantReason {
+				t.Errorf("reason: got=%s want=%s (%s)", gotCondition.Reason, tc.wantReason, gotCondition.Message)
+			}
+		})
+	}
+}
+
+func Test_regex(t *testing.T) {
+	pod := &unstructured.Unstructured{}
+	pod.SetName("preview-1234-web")
+
+	testCases := map[string]struct {
+		condition     string
+		wantMet       bool
+		wantReason    string
+		wantRetryable bool
+	}{
+		"name matches pattern": {
+			condition:  `pod.metadata.name.matches("^preview-[0-9]+-")`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"name doesn't match pattern": {
+			condition:     `pod.metadata.name.matches("^staging-")`,
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonWaitingForConditions,
+			wantRetryable: true,
+		},
+		"find": {
+			condition:  `pod.metadata.name.find("[0-9]+") == "1234"`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"find all": {
+			condition:  `pod.metadata.name.findAll("[a-z]+") == ["preview", "web"]`,
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"invalid pattern": {
+			condition:  `pod.metadata.name.find("[0-9") == ""`,
+			wantMet:    false,
+			wantReason: cleanerv1alpha1.ConditionReasonCompileError,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			gotMet, gotRetryable, gotCondition := evaluateWithPod(pod, tc.condition)
+			if gotMet != tc.wantMet {
+				t.Errorf("conditionsMet: got=%v want=%v (%s)", gotMet, tc.wantMet, gotCondition.Message)
+			}
+			if gotRetryable != tc.wantRetryable {
+				t.Errorf("retryable: got=%v want=%v", gotRetryable, tc.wantRetryable)
+			}
+			if gotCondition.Reason != tc.wantReason {
+				t.Errorf("reason: got=%s want=%s (%s)", gotCondition.Reason, tc.wantReason, gotCondition.Message)
+			}
+		})
+	}
+}
+
+func Test_conditionPolicy(t *testing.T) {
+	count := func(n int32) *int32 { return &n }
+	spec := cleanerv1alpha1.ConditionalTTLSpec{
+		Conditions:      []string{"true", "false"},
+		NamedConditions: []cleanerv1alpha1.NamedCondition{{Name: "equal", Expression: "1 == 1"}},
+	}
+	conditions := spec.AllConditions()
+	wantResults := []cleanerv1alpha1.ConditionResult{
+		{Index: 0, Name: "0", Met: true},
+		{Index: 1, Name: "1", Met: false},
+		{Index: 2, Name: "equal", Met: true},
+	}
+
+	testCases := map[string]struct {
+		policy        *cleanerv1alpha1.ConditionPolicy
+		wantMet       bool
+		wantReason    string
+		wantRetryable bool
+	}{
+		"nil policy requires all": {
+			policy:        nil,
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonWaitingForConditions,
+			wantRetryable: true,
+		},
+		"all": {
+			policy:        &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAll},
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonWaitingForConditions,
+			wantRetryable: true,
+		},
+		"any": {
+			policy:     &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAny},
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"at least 2": {
+			policy:     &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAtLeast, Count: count(2)},
+			wantMet:    true,
+			wantReason: cleanerv1alpha1.ConditionReasonTerminating,
+		},
+		"at least 3": {
+			policy:        &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAtLeast, Count: count(3)},
+			wantMet:       false,
+			wantReason:    cleanerv1alpha1.ConditionReasonWaitingForConditions,
+			wantRetryable: true,
+		},
+		"at least without count": {
+			policy:     &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAtLeast},
+			wantMet:    false,
+			wantReason: cleanerv1alpha1.ConditionReasonInvalidConditionPolicy,
+		},
+		"at least more than the number of conditions": {
+			policy:     &cleanerv1alpha1.ConditionPolicy{Type: cleanerv1alpha1.ConditionPolicyAtLeast, Count: count(4)},
+			wantMet:    false,
+			wantReason: cleanerv1alpha1.ConditionReasonInvalidConditionPolicy,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			readyCondition := metav1.Condition{}
+			gotMet, gotRetryable, gotResults := EvaluateCELConditions(context.TODO(), nil, nil, conditions, tc.policy, &readyCondition)
+			if gotMet != tc.wantMet {
+				t.Errorf("conditionsMet: got=%v want=%v (%s)", gotMet, tc.wantMet, readyCondition.Message)
+			}
+			if gotRetryable != tc.wantRetryable {
+				t.Errorf("retryable: got=%v want=%v", gotRetryable, tc.wantRetryable)
+			}
+			if readyCondition.Reason != tc.wantReason {
+				t.Errorf("reason: got=%s want=%s (%s)", readyCondition.Reason, tc.wantReason, readyCondition.Message)
+			}
+			if tc.wantReason == cleanerv1alpha1.ConditionReasonInvalidConditionPolicy {
+				return
+			}
+			if !reflect.DeepEqual(gotResults, wantResults) {
+				t.Errorf("results: got=%v want=%v", gotResults, wantResults)
+			}
+		})
+	}
+}
+
+func Test_conditionErrorsByName(t *testing.T) {
+	testCases := map[string]struct {
+		spec        cleanerv1alpha1.ConditionalTTLSpec
+		wantMessage string
+	}{
+		"unnamed compile error": {
+			spec:        cleanerv1alpha1.ConditionalTTLSpec{Conditions: []string{"true", "true &&"}},
+			wantMessage: "Error compiling condition 1: ",
+		},
+		"named compile error": {
+			spec: cleanerv1alpha1.ConditionalTTLSpec{
+				Conditions:      []string{"true"},
+				NamedConditions: []cleanerv1alpha1.NamedCondition{{Name: "broken", Expression: "true &&"}},
+			},
+			wantMessage: "Error compiling condition broken: ",
+		},
+		"named evaluation error": {
+			spec: cleanerv1alpha1.ConditionalTTLSpec{
+				NamedConditions: []cleanerv1alpha1.NamedCondition{{Name: "divide", Expression: "1 / 0 == 1"}},
+			},
+			wantMessage: "Error evaluating condition divide: ",
+		},
+		"named result not boolean": {
+			spec: cleanerv1alpha1.ConditionalTTLSpec{
+				NamedConditions: []cleanerv1alpha1.NamedCondition{{Name: "number", Expression: "1"}},
+			},
+			wantMessage: "Condition number result is not a boolean value",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			readyCondition := metav1.Condition{}
+			EvaluateCELConditions(context.TODO(), nil, nil, tc.spec.AllConditions(), nil, &readyCondition)
+			if !strings.HasPrefix(readyCondition.Message, tc.wantMessage) {
+				t.Errorf("got message %q, want it to start with %q", readyCondition.Message, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func evaluateWithPod(pod *unstructured.Unstructured, condition string) (bool, bool, metav1.Condition) {
+	cTTL := &cleanerv1alpha1.ConditionalTTL{
+		Spec: cleanerv1alpha1.ConditionalTTLSpec{
+			Targets: []cleanerv1alpha1.Target{
+				{Name: "pod", IncludeWhenEvaluating: ptr.To(true)},
+			},
+			Conditions: []string{condition},
+		},
+	}
+	ts := []cleanerv1alpha1.TargetStatus{
+		{Name: "pod", IncludeWhenEvaluating: true, State: pod},
+	}
+	readyCondition := metav1.Condition{}
+	met, retryable, _ := EvaluateCELConditions(context.TODO(), BuildCELOptions(cTTL), BuildCELContext(ts, nil, nil, time.Now()), cTTL.Spec.AllConditions(), nil, &readyCondition)
+	return met, retryable, readyCondition
+}
+
+func Test_checkEnv(t *testing.T) {
+	if err := EnvCheck()(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// redeclaring a variable with a different type breaks the environment
+	opts := append(BuildCELOptions(&cleanerv1alpha1.ConditionalTTL{}), cel.Variable("time", cel.StringType))
+	if err := checkEnv(opts); err == nil {
+		t.Error("expected an error for a broken environment")
+	}
+}
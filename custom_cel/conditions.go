@@ -0,0 +1,78 @@
+package custom_cel
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Conditions returns a cel.EnvOption to configure helper functions for
+// objects reporting conditions on their status.
+//
+// # HasCondition
+//
+// Returns whether the object has a condition of the given type with the
+// given status on status.conditions. Objects without conditions have none.
+//
+// hasCondition(<dyn>, <string>, <string>) ==> <bool>
+//
+// Examples:
+//
+// hasCondition(deploy, "Available", "True")
+//
+// pods.items.all(p, hasCondition(p, "Ready", "False"))
+func Conditions() cel.EnvOption {
+	return cel.Lib(conditionsLib{})
+}
+
+type conditionsLib struct{}
+
+// conditionsDecls declares the hasCondition function.
+var conditionsDecls = libraryDecls{
+	functions: map[string][]cel.FunctionOpt{
+		"hasCondition": {
+			cel.Overload(
+				"has_condition_dyn_string_string",
+				[]*cel.Type{cel.DynType, cel.StringType, cel.StringType},
+				cel.BoolType,
+				cel.FunctionBinding(hasCondition),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u conditionsLib) CompileOptions() []cel.EnvOption {
+	return conditionsDecls.options()
+}
+
+// ProgramOptions implements the Library interface method defining the basic program options
+func (u conditionsLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+func hasCondition(args ...ref.Val) ref.Val {
+	obj, condType, condStatus := args[0], args[1], args[2]
+	native, err := obj.ConvertToNative(unstructuredType)
+	if err != nil {
+		return types.NewErr("hasCondition: unable to convert %s to an object: %s", obj.Type().TypeName(), err)
+	}
+	conditions, found, err := unstructured.NestedSlice(native.(map[string]interface{}), "status", "conditions")
+	if err != nil {
+		return types.NewErr("hasCondition: %s", err)
+	}
+	if !found {
+		return types.False
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == string(condType.(types.String)) && cond["status"] == string(condStatus.(types.String)) {
+			return types.True
+		}
+	}
+	return types.False
+}
@@ -0,0 +1,76 @@
+package custom_cel
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+func Test_hasCondition(t *testing.T) {
+	withConditions := func(conditions ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": conditions,
+			},
+		}
+	}
+	condition := func(condType, status string) interface{} {
+		return map[string]interface{}{"type": condType, "status": status}
+	}
+
+	testCases := map[string]struct {
+		obj  map[string]interface{}
+		want bool
+	}{
+		"present and true": {
+			obj:  withConditions(condition("Progressing", "True"), condition("Ready", "True")),
+			want: true,
+		},
+		"present and false": {
+			obj:  withConditions(condition("Ready", "False")),
+			want: false,
+		},
+		"other conditions only": {
+			obj:  withConditions(condition("Progressing", "True")),
+			want: false,
+		},
+		"empty conditions": {
+			obj:  withConditions(),
+			want: false,
+		},
+		"absent conditions": {
+			obj:  map[string]interface{}{"status": map[string]interface{}{}},
+			want: false,
+		},
+		"absent status": {
+			obj:  map[string]interface{}{},
+			want: false,
+		},
+	}
+
+	env, err := cel.NewEnv(cel.Variable("obj", cel.DynType), Conditions())
+	if err != nil {
+		t.Fatalf("unable to create new env: %s", err)
+	}
+	ast, issues := env.Compile(`hasCondition(obj, "Ready", "True")`)
+	if issues != nil && issues.Err() != nil {
+		t.Fatalf("compile error: %s", issues.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		t.Fatalf("program error: %s", err)
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			got, _, err := prg.Eval(map[string]interface{}{"obj": tc.obj})
+			if err != nil {
+				t.Fatalf("eval error: %s", err)
+			}
+			if got.Equal(types.Bool(tc.want)) != types.True {
+				t.Errorf("got=%v want=%v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package custom_cel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/decls"
+	"github.com/google/cel-go/common/stdlib"
+	"github.com/google/cel-go/parser"
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+// FunctionDescription describes a CEL function and the signatures of
+// its overloads.
+type FunctionDescription struct {
+	Name       string   `json:"name"`
+	Signatures []string `json:"signatures"`
+}
+
+// MacroDescription describes a CEL macro.
+type MacroDescription struct {
+	Name          string `json:"name"`
+	Signature     string `json:"signature"`
+	ReceiverStyle bool   `json:"receiverStyle"`
+}
+
+// EnvDescription lists the functions and macros available when
+// evaluating conditions.
+type EnvDescription struct {
+	Functions []FunctionDescription `json:"functions"`
+	Macros    []MacroDescription    `json:"macros"`
+	// Libraries names the extension libraries, whose functions and macros
+	// are documented upstream.
+	Libraries []string `json:"libraries"`
+}
+
+// libraryDecls holds the declarations of a library this repository owns,
+// so that it can be described without inspecting a CEL environment.
+type libraryDecls struct {
+	functions map[string][]cel.FunctionOpt
+	macros    []cel.Macro
+}
+
+// options returns the env options declaring the library's functions and
+// macros.
+func (l libraryDecls) options() []cel.EnvOption {
+	var opts []cel.EnvOption
+	if len(l.macros) > 0 {
+		opts = append(opts, cel.Macros(l.macros...))
+	}
+	names := make([]string, 0, len(l.functions))
+	for name := range l.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts = append(opts, cel.Function(name, l.functions[name]...))
+	}
+	return opts
+}
+
+// DescribeEnv returns the functions and macros of the standard library and
+// of the libraries this repository owns, sorted by name, along with the
+// names of the extension libraries available when evaluating conditions.
+func DescribeEnv() (*EnvDescription, error) {
+	env, err := cel.NewEnv(BuildCELOptions(&cleanerv1alpha1.ConditionalTTL{})...)
+	if err != nil {
+		return nil, err
+	}
+	fns := stdlib.Functions()
+	macros := slices.Clone(parser.AllMacros)
+	for _, l := range ownLibraries() {
+		for name, opts := range l.functions {
+			fn, err := decls.NewFunction(name, opts...)
+			if err != nil {
+				return nil, err
+			}
+			fns = append(fns, fn)
+		}
+		macros = append(macros, l.macros...)
+	}
+
+	d := &EnvDescription{}
+	for _, fn := range fns {
+		name := fn.Name()
+		// operators such as _+_ and @in are not callable by name
+		if strings.HasPrefix(name, "_") || strings.HasPrefix(name, "@") {
+			continue
+		}
+		fd := FunctionDescription{Name: name}
+		for _, o := range fn.OverloadDecls() {
+			fd.Signatures = append(fd.Signatures, overloadSignature(name, o))
+		}
+		sort.Strings(fd.Signatures)
+		d.Functions = append(d.Functions, fd)
+	}
+	sort.Slice(d.Functions, func(i, j int) bool { return d.Functions[i].Name < d.Functions[j].Name })
+
+	for _, m := range macros {
+		d.Macros = append(d.Macros, MacroDescription{
+			Name:          m.Function(),
+			Signature:     macroSignature(m),
+			ReceiverStyle: m.IsReceiverStyle(),
+		})
+	}
+	sort.Slice(d.Macros, func(i, j int) bool { return d.Macros[i].Signature < d.Macros[j].Signature })
+
+	for _, name := range env.Libraries() {
+		// described above
+		if name != "cel.lib.std" {
+			d.Libraries = append(d.Libraries, name)
+		}
+	}
+	sort.Strings(d.Libraries)
+	return d, nil
+}
+
+// FunctionsHandler returns an http.Handler responding with the JSON
+// encoded description of the environment used to evaluate conditions.
+func FunctionsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		d, err := DescribeEnv()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d)
+	})
+}
+
+func overloadSignature(name string, o *decls.OverloadDecl) string {
+	args := make([]string, 0, len(o.ArgTypes()))
+	for _, a := range o.ArgTypes() {
+		args = append(args, a.String())
+	}
+	if o.IsMemberFunction() && len(args) > 0 {
+		return fmt.Sprintf("<%s>.%s(%s) -> %s", args[0], name, strings.Join(args[1:], ", "), o.ResultType())
+	}
+	return fmt.Sprintf("%s(%s) -> %s", name, strings.Join(args, ", "), o.ResultType())
+}
+
+func macroSignature(m parser.Macro) string {
+	var args string
+	if m.ArgCount() == 0 && strings.Contains(m.MacroKey(), "*") {
+		args = "..."
+	} else {
+		args = strings.TrimSuffix(strings.Repeat("_, ", m.ArgCount()), ", ")
+	}
+	if m.IsReceiverStyle() {
+		return fmt.Sprintf("<target>.%s(%s)", m.Function(), args)
+	}
+	return fmt.Sprintf("%s(%s)", m.Function(), args)
+}
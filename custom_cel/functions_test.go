@@ -0,0 +1,61 @@
+package custom_cel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func Test_FunctionsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	FunctionsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cel/functions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got=%d want=%d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	d := &EnvDescription{}
+	if err := json.Unmarshal(rec.Body.Bytes(), d); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+
+	functions := map[string][]string{}
+	for _, f := range d.Functions {
+		functions[f.Name] = f.Signatures
+	}
+	for _, name := range []string{"reverse_list", "lookup", "qos_class", "withinDuration", "age_at", "size"} {
+		if len(functions[name]) == 0 {
+			t.Errorf("function %s not listed", name)
+		}
+	}
+	if got, want := functions["lookup"], "lookup(dyn, string, dyn) -> dyn"; len(got) != 1 || got[0] != want {
+		t.Errorf("lookup signatures: got=%v want=[%s]", got, want)
+	}
+	if got, want := functions["reverse_list"], "<list(dyn)>.reverse_list() -> list(dyn)"; len(got) != 1 || got[0] != want {
+		t.Errorf("reverse_list signatures: got=%v want=[%s]", got, want)
+	}
+	for _, f := range d.Functions {
+		if f.Name[0] == '_' || f.Name[0] == '@' {
+			t.Errorf("operator %s should not be listed", f.Name)
+		}
+	}
+
+	for _, name := range []string{"cel.lib.ext.strings", "k8s.quantity", "k8s.regex"} {
+		if !slices.Contains(d.Libraries, name) {
+			t.Errorf("library %s not listed in %v", name, d.Libraries)
+		}
+	}
+
+	macros := map[string]string{}
+	for _, m := range d.Macros {
+		macros[m.Name] = m.Signature
+	}
+	if got, want := macros["sort_by"], "<target>.sort_by(_, _)"; got != want {
+		t.Errorf("sort_by signature: got=%s want=%s", got, want)
+	}
+	for _, name := range []string{"filter", "age"} {
+		if _, ok := macros[name]; !ok {
+			t.Errorf("macro %s not listed", name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package custom_cel
+
+import (
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// HelmReleaseVariable is the variable the Helm release of a cTTL is exposed
+// as when included when evaluating.
+const HelmReleaseVariable = "helmRelease"
+
+// includesHelmRelease reports whether the cTTL's Helm release is exposed to
+// its conditions.
+func includesHelmRelease(cTTL *cleanerv1alpha1.ConditionalTTL) bool {
+	return cTTL.Spec.Helm != nil && cTTL.Spec.Helm.IncludeWhenEvaluating
+}
+
+// HelmReleaseContext converts the release to the value of the `helmRelease`
+// variable, e.g. `helmRelease.lastDeployed` or `helmRelease.chart.version`.
+// A nil release, one which wasn't found, is converted to null.
+func HelmReleaseContext(rel *release.Release) interface{} {
+	if rel == nil {
+		return nil
+	}
+	r := map[string]interface{}{
+		"name":      rel.Name,
+		"namespace": rel.Namespace,
+		"version":   int64(rel.Version),
+	}
+	if info := rel.Info; info != nil {
+		r["status"] = info.Status.String()
+		r["firstDeployed"] = info.FirstDeployed.Time
+		r["lastDeployed"] = info.LastDeployed.Time
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		r["chart"] = map[string]interface{}{
+			"name":       rel.Chart.Metadata.Name,
+			"version":    rel.Chart.Metadata.Version,
+			"appVersion": rel.Chart.Metadata.AppVersion,
+		}
+	}
+	return r
+}
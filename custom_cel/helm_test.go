@@ -0,0 +1,78 @@
+package custom_cel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_helmReleaseContext(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	rel := &release.Release{
+		Name:      "app",
+		Namespace: "preview",
+		Version:   3,
+		Info: &release.Info{
+			Status:        release.StatusDeployed,
+			FirstDeployed: helmtime.Time{Time: now.Add(-60 * 24 * time.Hour)},
+			LastDeployed:  helmtime.Time{Time: now.Add(-45 * 24 * time.Hour)},
+		},
+		Chart: &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.2.0", AppVersion: "2.0"}},
+	}
+	cTTL := &cleanerv1alpha1.ConditionalTTL{}
+	cTTL.Spec.Helm = &cleanerv1alpha1.HelmConfig{Release: "app", IncludeWhenEvaluating: true}
+
+	testCases := map[string]struct {
+		release   *release.Release
+		condition string
+		wantMet   bool
+	}{
+		"not upgraded in 30 days": {
+			release:   rel,
+			condition: `time - helmRelease.lastDeployed > duration("720h")`,
+			wantMet:   true,
+		},
+		"fields": {
+			release:   rel,
+			condition: `helmRelease.name == "app" && helmRelease.namespace == "preview" && helmRelease.version == 3 && helmRelease.status == "deployed"`,
+			wantMet:   true,
+		},
+		"chart": {
+			release:   rel,
+			condition: `helmRelease.chart.name == "web" && helmRelease.chart.version == "1.2.0" && helmRelease.chart.appVersion == "2.0"`,
+			wantMet:   true,
+		},
+		"first deployed": {
+			release:   rel,
+			condition: `helmRelease.firstDeployed < helmRelease.lastDeployed`,
+			wantMet:   true,
+		},
+		"without info nor chart": {
+			release:   &release.Release{Name: "app", Namespace: "preview", Version: 1},
+			condition: `!has(helmRelease.lastDeployed) && !has(helmRelease.chart)`,
+			wantMet:   true,
+		},
+		"not found": {
+			condition: `helmRelease == null`,
+			wantMet:   true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			celCtx := BuildCELContext(nil, nil, nil, now)
+			celCtx[HelmReleaseVariable] = HelmReleaseContext(tc.release)
+			readyCondition := metav1.Condition{}
+			met, _, _ := EvaluateCELConditions(context.TODO(), BuildCELOptions(cTTL), celCtx, []cleanerv1alpha1.NamedCondition{{Name: "0", Expression: tc.condition}}, nil, &readyCondition)
+			if met != tc.wantMet {
+				t.Errorf("got met=%v, want %v (%s)", met, tc.wantMet, readyCondition.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package custom_cel
+
+import (
+	"time"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// NewHistoryEntry summarizes the targets included when evaluating the
+// conditions at the given time. Single object targets keep their status
+// while list targets only keep how many items were found.
+func NewHistoryEntry(targets []cleanerv1alpha1.TargetStatus, t time.Time) (cleanerv1alpha1.HistoryEntry, error) {
+	e := cleanerv1alpha1.HistoryEntry{Time: metav1.Time{Time: t}}
+	for _, ts := range targets {
+		if !ts.IncludeWhenEvaluating || ts.State == nil {
+			continue
+		}
+		s := cleanerv1alpha1.TargetSummary{Name: ts.Name}
+		if ts.State.IsList() {
+			items, _, _ := unstructured.NestedSlice(ts.State.Object, "items")
+			s.Count = len(items)
+		} else {
+			s.Count = 1
+			if status, found, _ := unstructured.NestedFieldNoCopy(ts.State.Object, "status"); found {
+				raw, err := json.Marshal(status)
+				if err != nil {
+					return e, err
+				}
+				s.Status = &runtime.RawExtension{Raw: raw}
+			}
+		}
+		e.Targets = append(e.Targets, s)
+	}
+	return e, nil
+}
+
+// AppendHistory appends the entry to the history, dropping the oldest
+// entries so that at most limit entries are kept.
+func AppendHistory(history []cleanerv1alpha1.HistoryEntry, e cleanerv1alpha1.HistoryEntry, limit int) []cleanerv1alpha1.HistoryEntry {
+	if limit <= 0 {
+		return nil
+	}
+	history = append(history, e)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// historyContext converts the history to the value of the `history`
+// variable, e.g. `history[0].targets.deploy.status.replicas`.
+func historyContext(history []cleanerv1alpha1.HistoryEntry) []interface{} {
+	r := make([]interface{}, 0, len(history))
+	for _, e := range history {
+		targets := make(map[string]interface{}, len(e.Targets))
+		for _, s := range e.Targets {
+			summary := map[string]interface{}{"count": int64(s.Count)}
+			if s.Status != nil {
+				var status interface{}
+				// numbers are decoded as int64 when possible, matching
+				// the targets' state
+				if err := json.Unmarshal(s.Status.Raw, &status); err == nil {
+					summary["status"] = status
+				}
+			}
+			targets[s.Name] = summary
+		}
+		r = append(r, map[string]interface{}{
+			"time":    e.Time.Time,
+			"targets": targets,
+		})
+	}
+	return r
+}
@@ -0,0 +1,123 @@
+package custom_cel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+func Test_history(t *testing.T) {
+	deploy := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"status":     map[string]interface{}{"replicas": replicas},
+		}}
+	}
+	pods := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "PodList",
+		"items":      []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+	}}
+	targets := func(replicas int64) []cleanerv1alpha1.TargetStatus {
+		return []cleanerv1alpha1.TargetStatus{
+			{Name: "deploy", IncludeWhenEvaluating: true, State: deploy(replicas)},
+			{Name: "pods", IncludeWhenEvaluating: true, State: pods},
+			{Name: "ignored", State: deploy(replicas)},
+		}
+	}
+
+	var history []cleanerv1alpha1.HistoryEntry
+	start := time.Now().Truncate(time.Second)
+	for i, replicas := range []int64{5, 4, 3, 2} {
+		e, err := NewHistoryEntry(targets(replicas), start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("unable to build history entry: %s", err)
+		}
+		history = AppendHistory(history, e, 3)
+	}
+	if len(history) != 3 {
+		t.Fatalf("got %d history entries, want 3", len(history))
+	}
+	if len(history[0].Targets) != 2 {
+		t.Errorf("got %d target summaries, want 2", len(history[0].Targets))
+	}
+	if got := AppendHistory(history, cleanerv1alpha1.HistoryEntry{}, 0); got != nil {
+		t.Errorf("expected no history with a zero limit, got %v", got)
+	}
+
+	testCases := map[string]struct {
+		condition string
+		wantMet   bool
+	}{
+		"oldest entry is dropped": {
+			condition: `history[0].targets.deploy.status.replicas == 4`,
+			wantMet:   true,
+		},
+		"replicas decreasing": {
+			condition: `history.all(h, h.targets.deploy.status.replicas > deploy.status.replicas)`,
+			wantMet:   true,
+		},
+		"list targets keep their count": {
+			condition: `history.all(h, h.targets.pods.count == 2)`,
+			wantMet:   true,
+		},
+		"entries are ordered by time": {
+			condition: `history[0].time < history[2].time && history[2].time < time`,
+			wantMet:   true,
+		},
+		"excluded targets are not kept": {
+			condition: `!("ignored" in history[0].targets)`,
+			wantMet:   true,
+		},
+	}
+
+	cTTL := &cleanerv1alpha1.ConditionalTTL{
+		Spec: cleanerv1alpha1.ConditionalTTLSpec{
+			Targets: []cleanerv1alpha1.Target{
+				{Name: "deploy", IncludeWhenEvaluating: ptr.To(true)},
+				{Name: "pods", IncludeWhenEvaluating: ptr.To(true)},
+			},
+			HistoryLimit: 3,
+		},
+	}
+	celCtx := BuildCELContext(targets(1), history, nil, start.Add(time.Hour))
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			readyCondition := metav1.Condition{}
+			gotMet, _, _ := EvaluateCELConditions(context.TODO(), BuildCELOptions(cTTL), celCtx, []cleanerv1alpha1.NamedCondition{{Name: "0", Expression: tc.condition}}, nil, &readyCondition)
+			if gotMet != tc.wantMet {
+				t.Errorf("conditionsMet: got=%v want=%v (%s)", gotMet, tc.wantMet, readyCondition.Message)
+			}
+		})
+	}
+}
+
+func Test_historyAndPreviousNotKept(t *testing.T) {
+	cTTL := &cleanerv1alpha1.ConditionalTTL{
+		Spec: cleanerv1alpha1.ConditionalTTLSpec{
+			Targets: []cleanerv1alpha1.Target{
+				{Name: "history", IncludeWhenEvaluating: ptr.To(true)},
+				{Name: "previous", IncludeWhenEvaluating: ptr.To(true)},
+			},
+		},
+	}
+	state := func(kind string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": kind}}
+	}
+	ts := []cleanerv1alpha1.TargetStatus{
+		{Name: "history", IncludeWhenEvaluating: true, State: state("ConfigMap")},
+		{Name: "previous", IncludeWhenEvaluating: true, State: state("Secret")},
+	}
+	readyCondition := metav1.Condition{}
+	conditions := []cleanerv1alpha1.NamedCondition{{Name: "0", Expression: `history.kind == "ConfigMap" && previous.kind == "Secret"`}}
+	met, _, _ := EvaluateCELConditions(context.TODO(), BuildCELOptions(cTTL), BuildCELContext(ts, nil, nil, time.Now()), conditions, nil, &readyCondition)
+	if !met {
+		t.Errorf("expected targets named history and previous to be evaluated (%s)", readyCondition.Message)
+	}
+}
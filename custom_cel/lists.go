@@ -10,8 +10,11 @@ import (
 	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/parser"
 	"k8s.io/apiserver/pkg/cel/library"
+	"math"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 // Lists returns a cel.EnvOption to configure extended functions Lists manipulation.
@@ -29,6 +32,19 @@ import (
 //
 // [{Name: "c", Age: 10}, {Name: "a", Age: 30}, {Name: "b", Age: 1}].sort_by(obj, obj.age) ==> [{Name: "b", Age: 1}, {Name: "c", Age: 10}, {Name: "a", Age: 30}]
 //
+// String keys which all parse as numbers, such as the values of a revision
+// label, are compared as numbers so that "2" sorts before "10". As soon as
+// one of them doesn't parse every key is compared as a string, so that the
+// order doesn't depend on which keys are compared.
+//
+// ["2", "10", "1"].sort_by(i, i) ==> ["1", "2", "10"]
+//
+// ["2", "10", "b"].sort_by(i, i) ==> ["10", "2", "b"]
+//
+// Sorting fails with an error identifying the offending element index when its
+// sort key can't be computed (e.g. a missing metadata.creationTimestamp) or is
+// not comparable with the other keys, e.g. mixing strings and ints.
+//
 // # ReverseList
 //
 // Returns a new list in reverse order.
@@ -41,47 +57,85 @@ import (
 // [1,2,3].reverse_list() ==> [3,2,1]
 //
 // ["x", "y", "z"].reverse_list() ==> ["z", "y", "x"]
+//
+// # CountWhere
+//
+// Returns how many elements of the list satisfy the predicate.
+//
+// <list>.count_where(obj, <predicate>) ==> <int>
+//
+// Examples:
+//
+// [1,2,3,4].count_where(i, i > 2) ==> 2
+//
+// pods.items.count_where(p, p.status.phase == "Succeeded") >= 3
+//
+// # Flatten
+//
+// Returns a new list with the elements of each list of the list, one level
+// deep. Evaluation fails when an element is not a list.
+//
+// <list(list)>.flatten() ==> <list>
+//
+// Examples:
+//
+// [[1, 2], [3], []].flatten() ==> [1, 2, 3]
+//
+// pods.items.map(p, p.spec.containers).flatten().all(c, has(c.resources.limits))
 func Lists() cel.EnvOption {
 	return cel.Lib(listsLib{})
 }
 
 type listsLib struct{}
 
-// CompileOptions implements the Library interface method defining the basic compile configuration
-func (u listsLib) CompileOptions() []cel.EnvOption {
-	dynListType := cel.ListType(cel.DynType)
-	sortByMacro := parser.NewReceiverMacro("sort_by", 2, makeSortBy)
-	return []cel.EnvOption{
-		library.Lists(),
-		cel.Macros(sortByMacro),
-		cel.Function(
-			"pair",
+// listsDecls declares the list macros and functions.
+var listsDecls = libraryDecls{
+	macros: []cel.Macro{
+		parser.NewReceiverMacro("sort_by", 2, makeSortBy),
+		parser.NewReceiverMacro("count_where", 2, makeCountWhere),
+	},
+	functions: map[string][]cel.FunctionOpt{
+		"pair": {
 			cel.Overload(
 				"make_pair",
 				[]*cel.Type{cel.DynType, cel.DynType},
 				cel.DynType,
 				cel.BinaryBinding(makePair),
+				// non-strict so that errors computing the sort key are
+				// reported by sort along with the element index
+				cel.OverloadIsNonStrict(),
 			),
-		),
-		cel.Function(
-			"sort",
+		},
+		"sort": {
 			cel.Overload(
 				"sort_list",
-				[]*cel.Type{dynListType},
-				dynListType,
+				[]*cel.Type{cel.ListType(cel.DynType)},
+				cel.ListType(cel.DynType),
 				cel.UnaryBinding(makeSort),
 			),
-		),
-		cel.Function(
-			"reverse_list",
+		},
+		"reverse_list": {
 			cel.MemberOverload(
 				"reverse_list_id",
 				[]*cel.Type{cel.ListType(cel.DynType)},
 				cel.ListType(cel.DynType),
 				cel.UnaryBinding(makeReverse),
 			),
-		),
-	}
+		},
+		"flatten": {
+			cel.MemberOverload(
+				"flatten_list",
+				[]*cel.Type{cel.ListType(cel.ListType(cel.DynType))},
+				cel.ListType(cel.DynType),
+				cel.UnaryBinding(flatten),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u listsLib) CompileOptions() []cel.EnvOption {
+	return append([]cel.EnvOption{library.Lists()}, listsDecls.options()...)
 }
 
 // ProgramOptions implements the Library interface method defining the basic program options
@@ -97,11 +151,19 @@ type pair struct {
 var (
 	orderKey = types.DefaultTypeAdapter.NativeToValue("order")
 	valueKey = types.DefaultTypeAdapter.NativeToValue("value")
+	errorKey = types.DefaultTypeAdapter.NativeToValue("error")
 )
 
 func makePair(order ref.Val, value ref.Val) ref.Val {
-	if _, ok := order.(traits.Comparer); !ok {
-		return types.ValOrErr(order, "unable to build ordered pair with value %v", order.Value())
+	if types.IsUnknown(order) {
+		return order
+	}
+	if err, ok := order.(*types.Err); ok {
+		// keep the error so that sort can report which element failed
+		return types.NewStringInterfaceMap(types.DefaultTypeAdapter, map[string]any{
+			"error": err.String(),
+			"value": value.Value(),
+		})
 	}
 	return types.NewStringInterfaceMap(types.DefaultTypeAdapter, map[string]any{
 		"order": order.Value(),
@@ -118,19 +180,51 @@ func makeSort(itemsVal ref.Val) ref.Val {
 	pairs := make([]pair, 0, items.Size().Value().(int64))
 	index := 0
 	for it := items.Iterator(); it.HasNext().(types.Bool); {
-		curr, ok := it.Next().(traits.Mapper)
+		// elements are built by sort_by wrapping each value, scalars
+		// included, with pair. Anything else was passed to sort directly
+		elem := it.Next()
+		if types.IsError(elem) {
+			return types.NewErr("unable to sort elem %d: %v", index, elem)
+		}
+		curr, ok := elem.(traits.Mapper)
 		if !ok {
-			return types.NewErr("unable to convert elem %d to traits.Mapper", index)
+			return types.NewErr("unable to sort elem %d: expected a pair but got %s", index, elem.Type().TypeName())
+		}
+		if msg, found := curr.Find(errorKey); found {
+			return types.NewErr("unable to sort elem %d: %v", index, msg.Value())
 		}
 
+		order, foundOrder := curr.Find(orderKey)
+		value, foundValue := curr.Find(valueKey)
+		if !foundOrder || !foundValue {
+			return types.NewErr("unable to sort elem %d: expected a pair but got a map without order and value", index)
+		}
+		if _, ok := order.(traits.Comparer); !ok {
+			return types.NewErr("unable to sort elem %d: sort key of type %s is not comparable", index, order.Type().TypeName())
+		}
 		pairs = append(pairs, pair{
-			order: curr.Get(orderKey),
-			value: curr.Get(valueKey),
+			order: order,
+			value: value,
 		})
 		index++
 	}
 
-	sort.Slice(pairs, func(i, j int) bool {
+	if keys, ok := numericKeys(pairs); ok {
+		for i := range pairs {
+			pairs[i].order = keys[i]
+		}
+	}
+
+	// keys of different types, such as int and string, don't compare
+	// and would otherwise leave the list silently unsorted
+	for i := 1; i < len(pairs); i++ {
+		if cmp := pairs[0].order.(traits.Comparer).Compare(pairs[i].order); types.IsError(cmp) {
+			return types.NewErr("unable to sort elem %d: sort key of type %s is not comparable with %s",
+				i, pairs[i].order.Type().TypeName(), pairs[0].order.Type().TypeName())
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
 		return pairs[i].order.(traits.Comparer).Compare(pairs[j].order) == types.IntNegOne
 	})
 
@@ -142,6 +236,24 @@ func makeSort(itemsVal ref.Val) ref.Val {
 	return types.NewDynamicList(types.DefaultTypeAdapter, ordered)
 }
 
+// numericKeys returns the sort keys of pairs parsed as numbers when they
+// are all strings which parse as such.
+func numericKeys(pairs []pair) ([]ref.Val, bool) {
+	keys := make([]ref.Val, len(pairs))
+	for i, p := range pairs {
+		s, ok := p.order.(types.String)
+		if !ok {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(s)), 64)
+		if err != nil || math.IsNaN(f) {
+			return nil, false
+		}
+		keys[i] = types.Double(f)
+	}
+	return keys, true
+}
+
 func extractIdent(e ast.Expr) (string, bool) {
 	if e.Kind() == ast.IdentKind {
 		return e.AsIdent(), true
@@ -187,6 +299,36 @@ func makeSortBy(eh parser.ExprHelper, target ast.Expr, args []ast.Expr) (ast.Exp
 	return mapped, nil
 }
 
+func makeCountWhere(eh parser.ExprHelper, target ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+	v, found := extractIdent(args[0])
+	if !found {
+		return nil, eh.NewError(args[0].ID(), "argument is not an identifier")
+	}
+
+	/*
+	   This comprehension is expanded to:
+	   __result__ = 0 # init expr
+	   for $v in $target:
+	       __result__ = predicate(v) ? __result__ + 1 : __result__ # step expr
+	   return __result__ # result expr
+	*/
+	step := eh.NewCall(
+		operators.Conditional,
+		args[1],
+		eh.NewCall(operators.Add, eh.NewAccuIdent(), eh.NewLiteral(types.Int(1))),
+		eh.NewAccuIdent(),
+	)
+	return eh.NewComprehension(
+		target,
+		v,
+		parser.AccumulatorName,
+		eh.NewLiteral(types.Int(0)),
+		eh.NewLiteral(types.True),
+		step,
+		eh.NewAccuIdent(),
+	), nil
+}
+
 func makeReverse(itemsVal ref.Val) ref.Val {
 	items, ok := itemsVal.(traits.Lister)
 	if !ok {
@@ -202,3 +344,24 @@ func makeReverse(itemsVal ref.Val) ref.Val {
 
 	return types.NewDynamicList(types.DefaultTypeAdapter, orderedItems)
 }
+
+func flatten(listsVal ref.Val) ref.Val {
+	lists, ok := listsVal.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(listsVal, "unable to convert to traits.Lister")
+	}
+
+	var items []ref.Val
+	for i, it := 0, lists.Iterator(); it.HasNext().(types.Bool); i++ {
+		elem := it.Next()
+		l, ok := elem.(traits.Lister)
+		if !ok {
+			return types.NewErr("flatten: expected element %d to be a list but got %s", i, elem.Type().TypeName())
+		}
+		for it := l.Iterator(); it.HasNext().(types.Bool); {
+			items = append(items, it.Next())
+		}
+	}
+
+	return types.NewDynamicList(types.DefaultTypeAdapter, items)
+}
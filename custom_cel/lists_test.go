@@ -5,6 +5,7 @@ import (
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
 	"testing"
 	"time"
 )
@@ -72,11 +73,32 @@ func Test_sort(t *testing.T) {
 			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.Bool{false, true, true}),
 		},
 
+		"sort mixed numeric list": {
+			condition: `[2, 1.5, uint(3)].sort_by(i,i)`,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []any{1.5, 2, uint(3)}),
+		},
+
 		"sort string list": {
 			condition: `["c", "a", "b"].sort_by(i,i)`,
 			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.String{"a", "b", "c"}),
 		},
 
+		"sort numeric string list": {
+			condition: `["2", "10", "1"].sort_by(i, i)`,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.String{"1", "2", "10"}),
+		},
+
+		"sort partially numeric string list": {
+			condition: `["2", "10", "b"].sort_by(i, i)`,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.String{"10", "2", "b"}),
+		},
+
+		"sort unstructured list by numeric label": {
+			condition: `objects.items.sort_by(o, o.metadata.labels["app.kubernetes.io/revision"])`,
+			list:      generateRevisionUl("2", "10", "1"),
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, generateRevisionUl("1", "2", "10")["items"]),
+		},
+
 		"sort unstructured list by timestamp": {
 			condition: `objects.items.sort_by(o, o.metadata.creationTimestamp)`,
 			list:      generateUnorderedUl(t, first.Format(time.RFC3339Nano), second.Format(time.RFC3339Nano), third.Format(time.RFC3339Nano)),
@@ -87,6 +109,65 @@ func Test_sort(t *testing.T) {
 	evaluateTestCases(t, testCases)
 }
 
+func Test_sortErrors(t *testing.T) {
+	first, _, third := getDates()
+
+	testCases := map[string]struct {
+		condition string
+		list      any
+		wantErr   string
+	}{
+		"sort unstructured list with missing timestamp": {
+			condition: `objects.items.sort_by(o, o.metadata.creationTimestamp)`,
+			list: (&unstructured.UnstructuredList{
+				Items: []unstructured.Unstructured{
+					{Object: map[string]interface{}{"metadata": map[string]interface{}{"creationTimestamp": third.Format(time.RFC3339Nano)}}},
+					{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "no-timestamp"}}},
+					{Object: map[string]interface{}{"metadata": map[string]interface{}{"creationTimestamp": first.Format(time.RFC3339Nano)}}},
+				},
+			}).UnstructuredContent(),
+			wantErr: "unable to sort elem 1: no such key: creationTimestamp",
+		},
+
+		"sort list with null sort key": {
+			condition: `[1, 2].sort_by(i, i == 1 ? dyn(null) : dyn(i))`,
+			wantErr:   "unable to sort elem 0: sort key of type null_type is not comparable",
+		},
+
+		"sort mixed-type list": {
+			condition: `[2, "a", 1].sort_by(i, i)`,
+			wantErr:   "unable to sort elem 1: sort key of type string is not comparable with int",
+		},
+
+		"sort list of values which aren't pairs": {
+			condition: `sort([2, 1])`,
+			wantErr:   "unable to sort elem 0: expected a pair but got int",
+		},
+
+		"sort list of maps which aren't pairs": {
+			condition: `sort([{"name": "a"}])`,
+			wantErr:   "unable to sort elem 0: expected a pair but got a map without order and value",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			prg := setupProgram(t, varName, tc.condition)
+
+			_, _, gotErr := prg.Eval(map[string]interface{}{
+				varName: tc.list,
+			})
+
+			if gotErr == nil {
+				t.Fatalf("expected error %q, got nil", tc.wantErr)
+			}
+			if gotErr.Error() != tc.wantErr {
+				t.Errorf("\ngot=%s\nwant=%s", gotErr, tc.wantErr)
+			}
+		})
+	}
+}
+
 func Test_reverse(t *testing.T) {
 	first, second, third := getDates()
 
@@ -157,6 +238,103 @@ func Test_reverse(t *testing.T) {
 	evaluateTestCases(t, testCases)
 }
 
+func Test_countWhere(t *testing.T) {
+	pod := func(phase string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"phase": phase}}}
+	}
+	pods := (&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{pod("Succeeded"), pod("Running"), pod("Succeeded"), pod("Succeeded")},
+	}).UnstructuredContent()
+
+	testCases := map[string]struct {
+		condition string
+		list      any
+		wantList  ref.Val
+	}{
+		"count int list": {
+			condition: `[1, 2, 3, 4].count_where(i, i > 2)`,
+			wantList:  types.Int(2),
+		},
+		"count empty list": {
+			condition: `[].count_where(i, i > 2)`,
+			wantList:  types.Int(0),
+		},
+		"count unstructured list": {
+			condition: `objects.items.count_where(p, p.status.phase == "Succeeded")`,
+			list:      pods,
+			wantList:  types.Int(3),
+		},
+		"compare count": {
+			condition: `objects.items.count_where(p, p.status.phase == "Succeeded") >= 3`,
+			list:      pods,
+			wantList:  types.True,
+		},
+		"count nested": {
+			condition: `[[1, 2], [3], []].count_where(l, l.count_where(i, i > 1) > 0)`,
+			wantList:  types.Int(2),
+		},
+	}
+
+	evaluateTestCases(t, testCases)
+
+	prg := setupProgram(t, varName, `objects.items.count_where(p, p.status.ready)`)
+	if _, _, err := prg.Eval(map[string]interface{}{varName: pods}); err == nil {
+		t.Error("expected an error for a predicate referencing a missing key")
+	}
+	env, err := cel.NewEnv(Lists())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, issues := env.Compile(`[1].count_where(i + 1, i > 0)`); issues == nil || issues.Err() == nil {
+		t.Error("expected a compile error when the variable isn't an identifier")
+	}
+}
+
+func Test_flatten(t *testing.T) {
+	pod := func(containers ...string) unstructured.Unstructured {
+		var cs []interface{}
+		for _, c := range containers {
+			cs = append(cs, map[string]interface{}{"name": c})
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"containers": cs}}}
+	}
+	pods := (&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{pod("app", "sidecar"), pod("job")},
+	}).UnstructuredContent()
+
+	testCases := map[string]struct {
+		condition string
+		list      any
+		wantList  ref.Val
+	}{
+		"flatten int lists": {
+			condition: `[[1, 2], [3], []].flatten()`,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.Int{1, 2, 3}),
+		},
+		"flatten empty list": {
+			condition: `[].flatten()`,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.Int{}),
+		},
+		"flatten one level deep": {
+			condition: `[[[1], [2]], [[3]]].flatten().size()`,
+			wantList:  types.Int(3),
+		},
+		"flatten containers of pods": {
+			condition: `objects.items.map(p, p.spec.containers).flatten().map(c, c.name)`,
+			list:      pods,
+			wantList:  types.NewDynamicList(types.DefaultTypeAdapter, []types.String{"app", "sidecar", "job"}),
+		},
+	}
+
+	evaluateTestCases(t, testCases)
+
+	prg := setupProgram(t, varName, `objects.flatten()`)
+	_, _, err := prg.Eval(map[string]interface{}{varName: []interface{}{[]interface{}{1}, 2}})
+	if err == nil || !strings.Contains(err.Error(), "element 1 to be a list") {
+		t.Errorf("got err=%v, want an error for a non-list element", err)
+	}
+}
+
 func evaluateTestCases(t *testing.T, testCases map[string]struct {
 	condition string
 	list      any
@@ -270,3 +448,15 @@ func getDates() (time.Time, time.Time, time.Time) {
 	third := now.Add(-(time.Duration(24) * time.Hour * 1))
 	return first, second, third
 }
+
+// generateRevisionUl returns a list of objects labeled with the given
+// revisions, in order.
+func generateRevisionUl(revisions ...string) map[string]interface{} {
+	ul := &unstructured.UnstructuredList{}
+	for _, r := range revisions {
+		u := unstructured.Unstructured{Object: map[string]interface{}{}}
+		u.SetLabels(map[string]string{"app.kubernetes.io/revision": r})
+		ul.Items = append(ul.Items, u)
+	}
+	return ul.UnstructuredContent()
+}
@@ -0,0 +1,148 @@
+package custom_cel
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// Lookup returns a cel.EnvOption to configure a function for safely reading
+// nested fields.
+//
+// # Lookup
+//
+// Returns the value found at the dot separated path on the given object or
+// the default value when any segment of the path is missing. List elements
+// are referenced by their index between brackets. Keys containing dots
+// (e.g. most annotations) can't be referenced.
+//
+// lookup(<dyn>, <string>, <dyn>) ==> <dyn>
+//
+// Examples:
+//
+// lookup(pod, "status.phase", "Unknown") ==> "Running"
+//
+// lookup(pod, "status.containerStatuses[0].restartCount", 0) ==> 0 when the pod has no container statuses yet
+func Lookup() cel.EnvOption {
+	return cel.Lib(lookupLib{})
+}
+
+type lookupLib struct{}
+
+// lookupDecls declares the lookup function.
+var lookupDecls = libraryDecls{
+	functions: map[string][]cel.FunctionOpt{
+		"lookup": {
+			cel.Overload(
+				"lookup_dyn_string_dyn",
+				[]*cel.Type{cel.DynType, cel.StringType, cel.DynType},
+				cel.DynType,
+				cel.FunctionBinding(lookup),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u lookupLib) CompileOptions() []cel.EnvOption {
+	return lookupDecls.options()
+}
+
+// ProgramOptions implements the Library interface method defining the basic program options
+func (u lookupLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+func lookup(args ...ref.Val) ref.Val {
+	obj, pathVal, def := args[0], args[1], args[2]
+	path, ok := pathVal.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(pathVal)
+	}
+	segments, err := parseLookupPath(string(path))
+	if err != nil {
+		return types.NewErr("lookup: invalid path %q: %s", path, err)
+	}
+
+	curr := obj
+	for _, s := range segments {
+		var found bool
+		if s.isIndex {
+			curr, found = lookupIndex(curr, s.index)
+		} else {
+			curr, found = lookupKey(curr, s.key)
+		}
+		if !found {
+			return def
+		}
+	}
+	return curr
+}
+
+func lookupKey(v ref.Val, key string) (ref.Val, bool) {
+	m, ok := v.(traits.Mapper)
+	if !ok {
+		return nil, false
+	}
+	return m.Find(types.String(key))
+}
+
+func lookupIndex(v ref.Val, index int64) (ref.Val, bool) {
+	l, ok := v.(traits.Lister)
+	if !ok {
+		return nil, false
+	}
+	size, ok := l.Size().(types.Int)
+	if !ok || index >= int64(size) {
+		return nil, false
+	}
+	return l.Get(types.Int(index)), true
+}
+
+type lookupSegment struct {
+	key     string
+	index   int64
+	isIndex bool
+}
+
+// parseLookupPath splits a path such as "status.containerStatuses[0].ready"
+// into its map key and list index segments.
+func parseLookupPath(path string) ([]lookupSegment, error) {
+	var segments []lookupSegment
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" && rest == "" {
+			return nil, errEmptySegment
+		}
+		if key != "" {
+			segments = append(segments, lookupSegment{key: key})
+		}
+		if rest == "" {
+			continue
+		}
+		// rest holds one or more "N]" chunks, e.g. "0]" or "0][1]"
+		for _, idx := range strings.Split(rest, "[") {
+			n, ok := strings.CutSuffix(idx, "]")
+			if !ok {
+				return nil, errUnterminatedIndex
+			}
+			i, err := strconv.ParseInt(n, 10, 64)
+			if err != nil || i < 0 {
+				return nil, errInvalidIndex
+			}
+			segments = append(segments, lookupSegment{index: i, isIndex: true})
+		}
+	}
+	return segments, nil
+}
+
+var (
+	errEmptySegment      = errors.New("empty segment")
+	errUnterminatedIndex = errors.New("unterminated index")
+	errInvalidIndex      = errors.New("index must be a non-negative integer")
+)
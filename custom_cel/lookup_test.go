@@ -0,0 +1,110 @@
+package custom_cel
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+func Test_lookup(t *testing.T) {
+	pod := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "my-pod",
+		},
+		"status": map[string]interface{}{
+			"phase": "Running",
+			"containerStatuses": []interface{}{
+				map[string]interface{}{
+					"restartCount": 3,
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		condition string
+		want      ref.Val
+	}{
+		"present path": {
+			condition: `lookup(pod, "status.phase", "Unknown")`,
+			want:      types.String("Running"),
+		},
+		"present path with index": {
+			condition: `lookup(pod, "status.containerStatuses[0].restartCount", 0)`,
+			want:      types.Int(3),
+		},
+		"missing path": {
+			condition: `lookup(pod, "spec.nodeName", "none")`,
+			want:      types.String("none"),
+		},
+		"partially missing path": {
+			condition: `lookup(pod, "metadata.annotations.owner", "nobody")`,
+			want:      types.String("nobody"),
+		},
+		"index out of range": {
+			condition: `lookup(pod, "status.containerStatuses[1].restartCount", 0)`,
+			want:      types.Int(0),
+		},
+		"index on non list": {
+			condition: `lookup(pod, "status.phase[0]", "none")`,
+			want:      types.String("none"),
+		},
+		"key on non map": {
+			condition: `lookup(pod, "metadata.name.first", "none")`,
+			want:      types.String("none"),
+		},
+		"composes with comparisons": {
+			condition: `lookup(pod, "status.containerStatuses[0].restartCount", 0) > 2`,
+			want:      types.True,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			prg := setupLookupProgram(t, tc.condition)
+			got, _, err := prg.Eval(map[string]interface{}{"pod": pod})
+			if err != nil {
+				t.Fatalf("eval error: %s", err)
+			}
+			if got.Equal(tc.want) != types.True {
+				t.Errorf("\ngot=%v\nwant=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_lookupInvalidPath(t *testing.T) {
+	for _, path := range []string{"", "status..phase", "status.containerStatuses[0", "status.containerStatuses[a]", "status.containerStatuses[-1]"} {
+		t.Run(path, func(t *testing.T) {
+			prg := setupLookupProgram(t, `lookup(pod, "`+path+`", "none")`)
+			_, _, err := prg.Eval(map[string]interface{}{"pod": map[string]interface{}{}})
+			if err == nil {
+				t.Errorf("expected error for path %q", path)
+			}
+		})
+	}
+}
+
+func setupLookupProgram(t *testing.T, condition string) cel.Program {
+	t.Helper()
+	env, err := cel.NewEnv(
+		cel.Variable("pod", cel.DynType),
+		Lookup(),
+	)
+	if err != nil {
+		t.Fatalf("unable to create new env: %s", err)
+	}
+
+	ast, issues := env.Compile(condition)
+	if issues != nil && issues.Err() != nil {
+		t.Fatalf("compile error: %s", issues.Err())
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		t.Fatalf("program error: %s", err)
+	}
+	return prg
+}
@@ -0,0 +1,119 @@
+package custom_cel
+
+import (
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Pods returns a cel.EnvOption to configure helper functions for Pod objects.
+//
+// # QoSClass
+//
+// Returns the pod's QoS class, one of "Guaranteed", "Burstable" or "BestEffort".
+// The class reported on status.qosClass is used when present, otherwise it is
+// computed from the containers' cpu and memory requests and limits.
+//
+// qos_class(<dyn>) ==> <string>
+//
+// Examples:
+//
+// pods.items.filter(p, qos_class(p) == "BestEffort")
+func Pods() cel.EnvOption {
+	return cel.Lib(podsLib{})
+}
+
+type podsLib struct{}
+
+// podsDecls declares the qos_class function.
+var podsDecls = libraryDecls{
+	functions: map[string][]cel.FunctionOpt{
+		"qos_class": {
+			cel.Overload(
+				"qos_class_dyn",
+				[]*cel.Type{cel.DynType},
+				cel.StringType,
+				cel.UnaryBinding(qosClass),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u podsLib) CompileOptions() []cel.EnvOption {
+	return podsDecls.options()
+}
+
+// ProgramOptions implements the Library interface method defining the basic program options
+func (u podsLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+var unstructuredType = reflect.TypeOf(map[string]interface{}{})
+
+func qosClass(val ref.Val) ref.Val {
+	native, err := val.ConvertToNative(unstructuredType)
+	if err != nil {
+		return types.NewErr("qos_class: unable to convert %s to an object: %s", val.Type().TypeName(), err)
+	}
+	pod := &corev1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(native.(map[string]interface{}), pod); err != nil {
+		return types.NewErr("qos_class: unable to convert object to a Pod: %s", err)
+	}
+	if pod.Status.QOSClass != "" {
+		return types.String(pod.Status.QOSClass)
+	}
+	return types.String(computeQOSClass(pod))
+}
+
+// computeQOSClass mirrors the kubelet's QoS classification. Requests
+// default to limits when unset, as the API server does on creation.
+func computeQOSClass(pod *corev1.Pod) corev1.PodQOSClass {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	isGuaranteed := true
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			limit, hasLimit := c.Resources.Limits[name]
+			hasLimit = hasLimit && !limit.IsZero()
+			request, hasRequest := c.Resources.Requests[name]
+			hasRequest = hasRequest && !request.IsZero()
+			if !hasRequest && hasLimit {
+				request, hasRequest = limit, true
+			}
+			if hasRequest {
+				addQuantity(requests, name, request)
+			}
+			if hasLimit {
+				addQuantity(limits, name, limit)
+			} else {
+				isGuaranteed = false
+			}
+		}
+	}
+
+	if len(requests) == 0 && len(limits) == 0 {
+		return corev1.PodQOSBestEffort
+	}
+	if isGuaranteed && len(requests) == len(limits) {
+		for name, limit := range limits {
+			if request := requests[name]; request.Cmp(limit) != 0 {
+				return corev1.PodQOSBurstable
+			}
+		}
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+func addQuantity(l corev1.ResourceList, name corev1.ResourceName, q resource.Quantity) {
+	sum := l[name]
+	sum.Add(q)
+	l[name] = sum
+}
@@ -0,0 +1,103 @@
+package custom_cel
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+func Test_qosClass(t *testing.T) {
+	container := func(requests, limits map[string]interface{}) interface{} {
+		resources := map[string]interface{}{}
+		if requests != nil {
+			resources["requests"] = requests
+		}
+		if limits != nil {
+			resources["limits"] = limits
+		}
+		return map[string]interface{}{
+			"name":      "c",
+			"image":     "i",
+			"resources": resources,
+		}
+	}
+	pod := func(containers ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"spec": map[string]interface{}{
+				"containers": containers,
+			},
+		}
+	}
+	cpuMem := func(cpu, mem string) map[string]interface{} {
+		return map[string]interface{}{"cpu": cpu, "memory": mem}
+	}
+
+	reported := pod(container(nil, nil))
+	reported["status"] = map[string]interface{}{"qosClass": "Guaranteed"}
+
+	testCases := map[string]struct {
+		pod  map[string]interface{}
+		want string
+	}{
+		"best effort without resources": {
+			pod:  pod(container(nil, nil), container(nil, nil)),
+			want: "BestEffort",
+		},
+		"guaranteed with equal requests and limits": {
+			pod:  pod(container(cpuMem("1", "1Gi"), cpuMem("1", "1Gi")), container(cpuMem("500m", "512Mi"), cpuMem("0.5", "512Mi"))),
+			want: "Guaranteed",
+		},
+		"guaranteed with limits only": {
+			pod:  pod(container(nil, cpuMem("1", "1Gi"))),
+			want: "Guaranteed",
+		},
+		"burstable with requests below limits": {
+			pod:  pod(container(cpuMem("500m", "1Gi"), cpuMem("1", "1Gi"))),
+			want: "Burstable",
+		},
+		"burstable with requests only": {
+			pod:  pod(container(cpuMem("1", "1Gi"), nil)),
+			want: "Burstable",
+		},
+		"burstable when a container has no limits": {
+			pod:  pod(container(cpuMem("1", "1Gi"), cpuMem("1", "1Gi")), container(nil, nil)),
+			want: "Burstable",
+		},
+		"burstable with memory limit only": {
+			pod:  pod(container(nil, map[string]interface{}{"memory": "1Gi"})),
+			want: "Burstable",
+		},
+		"reported by status": {
+			pod:  reported,
+			want: "Guaranteed",
+		},
+	}
+
+	env, err := cel.NewEnv(cel.Variable("pod", cel.DynType), Pods())
+	if err != nil {
+		t.Fatalf("unable to create new env: %s", err)
+	}
+	ast, issues := env.Compile(`qos_class(pod)`)
+	if issues != nil && issues.Err() != nil {
+		t.Fatalf("compile error: %s", issues.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		t.Fatalf("program error: %s", err)
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			got, _, err := prg.Eval(map[string]interface{}{"pod": tc.pod})
+			if err != nil {
+				t.Fatalf("eval error: %s", err)
+			}
+			if got.Equal(types.String(tc.want)) != types.True {
+				t.Errorf("got=%v want=%s", got, tc.want)
+			}
+		})
+	}
+}
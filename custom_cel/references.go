@@ -0,0 +1,109 @@
+package custom_cel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+// ReferenceError is returned by CheckReferences when a condition
+// references a variable which isn't declared when evaluating conditions.
+type ReferenceError struct {
+	// Condition is the index of the condition on the spec's
+	// AllConditions.
+	Condition int
+	// ConditionName is the name of the condition.
+	ConditionName string
+	// Name is the referenced identifier.
+	Name string
+	// Excluded is set when Name is a target without includeWhenEvaluating.
+	Excluded bool
+}
+
+func (e *ReferenceError) Error() string {
+	if e.Excluded {
+		return fmt.Sprintf("condition %s references target %q which is not included when evaluating, set includeWhenEvaluating on it", e.ConditionName, e.Name)
+	}
+	return fmt.Sprintf("condition %s references unknown identifier %q", e.ConditionName, e.Name)
+}
+
+// CheckReferences type-checks the conditions of cTTL and returns a
+// *ReferenceError for each identifier the checker reports as undeclared,
+// i.e. which is neither a target included when evaluating, an extra
+// context value, the Helm release when included, one of the variables
+// declared for it nor a type. Variables introduced by macros, such as the
+// iteration variable of all, are only known within them. Conditions which
+// can't be parsed, and unknown functions, are left to be reported when
+// compiled.
+func CheckReferences(cTTL *cleanerv1alpha1.ConditionalTTL) []error {
+	env, err := cel.NewEnv(BuildCELOptions(cTTL)...)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for i, c := range cTTL.Spec.AllConditions() {
+		parsed, issues := env.Parse(c.Expression)
+		if issues != nil && issues.Err() != nil {
+			continue
+		}
+		// collected before checking, which rewrites identifiers into their
+		// qualified names
+		idents := map[int64]string{}
+		for _, e := range ast.MatchDescendants(ast.NavigateAST(parsed.NativeRep()), ast.KindMatcher(ast.IdentKind)) {
+			idents[e.ID()] = e.AsIdent()
+		}
+		_, issues = env.Check(parsed)
+		if issues == nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, issue := range issues.Errors() {
+			name, ok := idents[issue.ExprID]
+			if !ok || !strings.HasPrefix(issue.Message, "undeclared reference") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			excluded := slices.ContainsFunc(cTTL.Spec.Targets, func(t cleanerv1alpha1.Target) bool {
+				return t.Name == name
+			})
+			errs = append(errs, &ReferenceError{Condition: i, ConditionName: c.Name, Name: name, Excluded: excluded})
+		}
+	}
+	return errs
+}
+
+// PreviousReferences returns the names of the targets the conditions of
+// cTTL select from `previous`, so that only their state needs to be kept.
+// all is set when previous is used otherwise, e.g. indexed or iterated, or
+// when a condition can't be parsed.
+func PreviousReferences(cTTL *cleanerv1alpha1.ConditionalTTL) (names []string, all bool) {
+	env, err := cel.NewEnv(BuildCELOptions(cTTL)...)
+	if err != nil {
+		return nil, true
+	}
+
+	for _, c := range cTTL.Spec.AllConditions() {
+		parsed, issues := env.Parse(c.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, true
+		}
+		for _, e := range ast.MatchDescendants(ast.NavigateAST(parsed.NativeRep()), ast.KindMatcher(ast.IdentKind)) {
+			if e.AsIdent() != "previous" {
+				continue
+			}
+			parent, ok := e.Parent()
+			if !ok || parent.Kind() != ast.SelectKind {
+				return nil, true
+			}
+			if name := parent.AsSelect().FieldName(); !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, false
+}
@@ -0,0 +1,138 @@
+package custom_cel
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+func Test_CheckReferences(t *testing.T) {
+	cTTL := func(conditions ...string) *cleanerv1alpha1.ConditionalTTL {
+		return &cleanerv1alpha1.ConditionalTTL{
+			Spec: cleanerv1alpha1.ConditionalTTLSpec{
+				Targets: []cleanerv1alpha1.Target{
+					{Name: "deploy", IncludeWhenEvaluating: ptr.To(true)},
+					{Name: "pods", IncludeWhenEvaluating: ptr.To(false)},
+				},
+				ExtraContext:      []cleanerv1alpha1.ContextValue{{Name: "flag"}},
+				Conditions:        conditions,
+				HistoryLimit:      5,
+				KeepPreviousState: true,
+			},
+		}
+	}
+	testCases := map[string]struct {
+		conditions []string
+		want       []ReferenceError
+	}{
+		"declared names": {
+			conditions: []string{`deploy.status.replicas == 0 && flag == "true" && time > timestamp("2022-01-01T00:00:00Z") && size(history) > 0 && has(previous.deploy)`},
+		},
+		"excluded target": {
+			conditions: []string{`true`, `size(pods.items) == 0`},
+			want:       []ReferenceError{{Condition: 1, ConditionName: "1", Name: "pods", Excluded: true}},
+		},
+		"unknown identifier": {
+			conditions: []string{`deploy.spec.replicas == replicas`},
+			want:       []ReferenceError{{Condition: 0, ConditionName: "0", Name: "replicas"}},
+		},
+		"comprehension variables": {
+			conditions: []string{`deploy.spec.template.spec.containers.all(c, c.image.startsWith("app") && deploy.metadata.labels.exists(k, k == c.name))`},
+		},
+		"comprehension variables out of scope": {
+			conditions: []string{`deploy.spec.template.spec.containers.exists(c, c.name == "app") && c.image == ""`},
+			want:       []ReferenceError{{Condition: 0, ConditionName: "0", Name: "c"}},
+		},
+		"nested comprehension over an excluded target": {
+			conditions: []string{`deploy.spec.template.spec.containers.all(c, pods.items.exists(p, p.spec.containers[0].name == c.name))`},
+			want:       []ReferenceError{{Condition: 0, ConditionName: "0", Name: "pods", Excluded: true}},
+		},
+		"macros": {
+			conditions: []string{`cel.bind(replicas, deploy.spec.replicas, replicas > 0) && [deploy].sort_by(d, d.metadata.name)[0].metadata.name == "app" && [1, 2].map(x, x * 2).filter(y, y > 2).size() == 1`},
+		},
+		"namespaced functions and types": {
+			conditions: []string{`strings.quote(flag) != "" && type(deploy) == map && int(flag) > 0`},
+		},
+		"reported once per condition": {
+			conditions: []string{`pods.items.size() > 0 && pods.kind == "List"`, `pods == null`},
+			want: []ReferenceError{
+				{Condition: 0, ConditionName: "0", Name: "pods", Excluded: true},
+				{Condition: 1, ConditionName: "1", Name: "pods", Excluded: true},
+			},
+		},
+		"unknown functions are ignored": {
+			conditions: []string{`unknown(deploy) && deploy.unknown()`},
+		},
+		"parse errors are ignored": {
+			conditions: []string{`deploy.status ==`},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			errs := CheckReferences(cTTL(tc.conditions...))
+			if len(errs) != len(tc.want) {
+				t.Fatalf("got errors %v, want %v", errs, tc.want)
+			}
+			for i, err := range errs {
+				var refErr *ReferenceError
+				if !errors.As(err, &refErr) || *refErr != tc.want[i] {
+					t.Errorf("got error %v, want %+v", err, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_PreviousReferences(t *testing.T) {
+	testCases := map[string]struct {
+		conditions []string
+		want       []string
+		wantAll    bool
+	}{
+		"not referenced": {
+			conditions: []string{`deploy.status.replicas == 0`},
+		},
+		"selected": {
+			conditions: []string{`has(previous.deploy) && previous.deploy.status.replicas == deploy.status.replicas`, `previous.pods.items.size() > 0 && previous.deploy != null`},
+			want:       []string{"deploy", "pods"},
+		},
+		"indexed": {
+			conditions: []string{`previous["deploy"].status.replicas == 0`},
+			wantAll:    true,
+		},
+		"iterated": {
+			conditions: []string{`previous.all(name, name != "")`},
+			wantAll:    true,
+		},
+		"unparseable": {
+			conditions: []string{`previous.deploy ==`},
+			wantAll:    true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{
+				Spec: cleanerv1alpha1.ConditionalTTLSpec{
+					Targets: []cleanerv1alpha1.Target{
+						{Name: "deploy", IncludeWhenEvaluating: ptr.To(true)},
+						{Name: "pods", IncludeWhenEvaluating: ptr.To(true)},
+					},
+					Conditions:        tc.conditions,
+					KeepPreviousState: true,
+				},
+			}
+			got, all := PreviousReferences(cTTL)
+			if all != tc.wantAll {
+				t.Errorf("got all=%t, want %t", all, tc.wantAll)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
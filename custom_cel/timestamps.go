@@ -0,0 +1,95 @@
+package custom_cel
+
+import (
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Timestamps returns a cel.EnvOption to configure helper functions for
+// comparing timestamps.
+//
+// # WithinDuration
+//
+// Returns whether two timestamps are at most the given duration apart, in
+// either order. Timestamps may be given as strings, as read from objects,
+// in which case they must be in RFC 3339 format. Evaluation fails when the
+// duration is negative.
+//
+// withinDuration(<dyn>, <dyn>, <duration>) ==> <bool>
+//
+// Examples:
+//
+// withinDuration(job.status.completionTime, pod.status.startTime, duration("5m"))
+//
+// withinDuration(deploy.metadata.creationTimestamp, time, duration("1h"))
+func Timestamps() cel.EnvOption {
+	return cel.Lib(timestampsLib{})
+}
+
+type timestampsLib struct{}
+
+// timestampsDecls declares the withinDuration function.
+var timestampsDecls = libraryDecls{
+	functions: map[string][]cel.FunctionOpt{
+		"withinDuration": {
+			cel.Overload(
+				"within_duration_dyn_dyn_duration",
+				[]*cel.Type{cel.DynType, cel.DynType, cel.DurationType},
+				cel.BoolType,
+				cel.FunctionBinding(withinDuration),
+			),
+		},
+	},
+}
+
+// CompileOptions implements the Library interface method defining the basic compile configuration
+func (u timestampsLib) CompileOptions() []cel.EnvOption {
+	return timestampsDecls.options()
+}
+
+// ProgramOptions implements the Library interface method defining the basic program options
+func (u timestampsLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+func withinDuration(args ...ref.Val) ref.Val {
+	d, ok := args[2].(types.Duration)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(args[2])
+	}
+	if d.Duration < 0 {
+		return types.NewErr("withinDuration: duration %s is negative", d.Duration)
+	}
+	t1, err := asTime(args[0])
+	if err != nil {
+		return err
+	}
+	t2, err := asTime(args[1])
+	if err != nil {
+		return err
+	}
+	diff := t1.Sub(t2)
+	if diff < 0 {
+		diff = -diff
+	}
+	return types.Bool(diff <= d.Duration)
+}
+
+// asTime returns the time of a timestamp or of a string in RFC 3339 format.
+func asTime(v ref.Val) (time.Time, ref.Val) {
+	switch v := v.(type) {
+	case types.Timestamp:
+		return v.Time, nil
+	case types.String:
+		t, err := time.Parse(time.RFC3339, string(v))
+		if err != nil {
+			return time.Time{}, types.NewErr("withinDuration: unable to parse timestamp %q: %s", string(v), err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, types.NewErr("withinDuration: expected a timestamp or a string but got %s", v.Type().TypeName())
+	}
+}
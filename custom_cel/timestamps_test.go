@@ -0,0 +1,94 @@
+package custom_cel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+func Test_withinDuration(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	job := map[string]interface{}{
+		"status": map[string]interface{}{"completionTime": "2024-01-02T11:57:00Z"},
+	}
+
+	testCases := map[string]struct {
+		expr    string
+		want    bool
+		wantErr string
+	}{
+		"strings inside the tolerance": {
+			expr: `withinDuration(job.status.completionTime, "2024-01-02T12:00:00Z", duration("5m"))`,
+			want: true,
+		},
+		"strings outside the tolerance": {
+			expr: `withinDuration(job.status.completionTime, "2024-01-02T12:10:00Z", duration("5m"))`,
+			want: false,
+		},
+		"in either order": {
+			expr: `withinDuration("2024-01-02T12:00:00Z", job.status.completionTime, duration("5m"))`,
+			want: true,
+		},
+		"exactly the tolerance apart": {
+			expr: `withinDuration(job.status.completionTime, "2024-01-02T12:00:00Z", duration("3m"))`,
+			want: true,
+		},
+		"a string and a timestamp": {
+			expr: `withinDuration(job.status.completionTime, time, duration("1m"))`,
+			want: false,
+		},
+		"timestamps": {
+			expr: `withinDuration(time, timestamp("2024-01-02T12:00:30Z"), duration("1m"))`,
+			want: true,
+		},
+		"unparseable string": {
+			expr:    `withinDuration("yesterday", time, duration("1m"))`,
+			wantErr: "unable to parse timestamp",
+		},
+		"neither a timestamp nor a string": {
+			expr:    `withinDuration(1, time, duration("1m"))`,
+			wantErr: "expected a timestamp or a string",
+		},
+		"negative duration": {
+			expr:    `withinDuration(time, time, duration("-1m"))`,
+			wantErr: "is negative",
+		},
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("job", cel.DynType),
+		Timestamps(),
+	)
+	if err != nil {
+		t.Fatalf("unable to create new env: %s", err)
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			ast, issues := env.Compile(tc.expr)
+			if issues != nil && issues.Err() != nil {
+				t.Fatalf("compile error: %s", issues.Err())
+			}
+			prg, err := env.Program(ast)
+			if err != nil {
+				t.Fatalf("program error: %s", err)
+			}
+			got, _, err := prg.Eval(map[string]interface{}{"time": now, "job": job})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got err=%v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("eval error: %s", err)
+			}
+			if b, ok := got.Value().(bool); !ok || b != tc.want {
+				t.Errorf("got=%v want=%t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kinds checks that the kinds referenced by targets are served by
+// the API server.
+package kinds
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/utils/clock"
+)
+
+// maxSuggestions caps how many close matches are suggested for an unknown
+// kind or apiVersion.
+const maxSuggestions = 3
+
+// MinResetInterval is how long the Checker waits between resets of its
+// mapper, each of which rediscovers every group served by the API server.
+const MinResetInterval = 10 * time.Second
+
+// UnknownKindError is returned by Checker.Check when a kind isn't served.
+type UnknownKindError struct {
+	GVK schema.GroupVersionKind
+	// UnknownVersion is set when the whole apiVersion isn't served, in
+	// which case Suggestions holds apiVersions instead of kinds.
+	UnknownVersion bool
+	// Suggestions holds the closest matches, best first.
+	Suggestions []string
+	// RetryAfter is set when the mapper was reset too recently, for other
+	// kinds, to pick up kinds installed since, to how long until it may be
+	// reset again.
+	RetryAfter time.Duration
+}
+
+func (e *UnknownKindError) Error() string {
+	msg := fmt.Sprintf("kind %q is not served by %s", e.GVK.Kind, e.GVK.GroupVersion())
+	switch {
+	case e.GVK.GroupVersion().Empty():
+		msg = fmt.Sprintf("kind %q is not served", e.GVK.Kind)
+	case e.UnknownVersion:
+		msg = fmt.Sprintf("apiVersion %q is not served", e.GVK.GroupVersion())
+	}
+	if len(e.Suggestions) == 0 {
+		return msg
+	}
+	quoted := make([]string, len(e.Suggestions))
+	for i, s := range e.Suggestions {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return msg + ", did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+// AmbiguousKindError is returned when resolving a kind without an apiVersion
+// which is served by more than one group.
+type AmbiguousKindError struct {
+	Kind string
+	// GroupVersions holds the preferred version of each group serving Kind.
+	GroupVersions []schema.GroupVersion
+}
+
+func (e *AmbiguousKindError) Error() string {
+	gvs := make([]string, len(e.GroupVersions))
+	for i, gv := range e.GroupVersions {
+		gvs[i] = fmt.Sprintf("%q", gv.String())
+	}
+	return fmt.Sprintf("kind %q is served by more than one group, set apiVersion to one of %s", e.Kind, strings.Join(gvs, ", "))
+}
+
+// ResolveKind returns the preferred group and version serving kind, or
+// those of its items if it is a List kind, according to mapper. It fails
+// with an *AmbiguousKindError if more than one group serves it. Mappers
+// which only know about the groups they were asked for, such as the
+// controller-runtime's default, can only resolve core kinds.
+func ResolveKind(mapper meta.RESTMapper, kind string) (schema.GroupVersionKind, error) {
+	item := strings.TrimSuffix(kind, "List")
+	// kinds are also mapped from their lowercase singular resource
+	gvks, err := mapper.KindsFor(schema.GroupVersionResource{Resource: strings.ToLower(item)})
+	if err != nil && !meta.IsNoMatchError(err) {
+		return schema.GroupVersionKind{}, err
+	}
+	// ordered by preference
+	var gvs []schema.GroupVersion
+	for _, gvk := range gvks {
+		if gvk.Kind != item || slices.ContainsFunc(gvs, func(gv schema.GroupVersion) bool { return gv.Group == gvk.Group }) {
+			continue
+		}
+		gvs = append(gvs, gvk.GroupVersion())
+	}
+	switch len(gvs) {
+	case 0:
+		return schema.GroupVersionKind{}, &meta.NoKindMatchError{GroupKind: schema.GroupKind{Kind: kind}}
+	case 1:
+		return gvs[0].WithKind(kind), nil
+	}
+	sort.Slice(gvs, func(i, j int) bool { return gvs[i].String() < gvs[j].String() })
+	return schema.GroupVersionKind{}, &AmbiguousKindError{Kind: kind, GroupVersions: gvs}
+}
+
+// Checker checks kinds against a RESTMapper, suggesting close matches from
+// discovery for those which aren't served. Kinds found to be served are
+// cached until forgotten.
+type Checker struct {
+	mapper    meta.RESTMapper
+	discovery discovery.DiscoveryInterface
+
+	// Clock provides the current time when rate limiting the resets of
+	// the mapper. Defaults to the real clock.
+	Clock clock.PassiveClock
+
+	mu        sync.Mutex
+	known     map[schema.GroupVersionKind]bool
+	lastReset time.Time
+	// resetFor holds when the mapper was last reset for a miss of each
+	// kind, within MinResetInterval.
+	resetFor map[schema.GroupVersionKind]time.Time
+}
+
+// NewChecker returns a Checker using mapper to check kinds and discovery to
+// suggest close matches.
+func NewChecker(mapper meta.RESTMapper, discovery discovery.DiscoveryInterface) *Checker {
+	return &Checker{
+		mapper:    mapper,
+		discovery: discovery,
+		known:     map[schema.GroupVersionKind]bool{},
+		resetFor:  map[schema.GroupVersionKind]time.Time{},
+	}
+}
+
+// Check returns an *UnknownKindError if gvk, or the kind of its items if it
+// is a List kind, isn't served. Kinds without a group and version are
+// checked by resolving them. Other errors are returned when it couldn't be
+// checked.
+func (c *Checker) Check(gvk schema.GroupVersionKind) error {
+	if gvk.GroupVersion().Empty() {
+		_, err := c.Resolve(gvk.Kind)
+		return err
+	}
+	item := itemKind(gvk)
+	c.mu.Lock()
+	known := c.known[item]
+	c.mu.Unlock()
+	if known {
+		return nil
+	}
+
+	_, err := c.mapper.RESTMapping(item.GroupKind(), item.Version)
+	var retryAfter time.Duration
+	if meta.IsNoMatchError(err) {
+		// the mapper may predate the kind's CRD
+		var reset bool
+		if reset, retryAfter = c.reset(item); reset {
+			_, err = c.mapper.RESTMapping(item.GroupKind(), item.Version)
+		}
+	}
+	if meta.IsNoMatchError(err) {
+		e := c.unknownKind(gvk)
+		e.RetryAfter = retryAfter
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.known[item] = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Resolve is like ResolveKind using the Checker's mapper, returning an
+// *UnknownKindError if no group serves kind.
+func (c *Checker) Resolve(kind string) (schema.GroupVersionKind, error) {
+	gvk, err := ResolveKind(c.mapper, kind)
+	var retryAfter time.Duration
+	if meta.IsNoMatchError(err) {
+		// the mapper may predate the kind's CRD
+		var reset bool
+		if reset, retryAfter = c.reset(schema.GroupVersionKind{Kind: kind}); reset {
+			gvk, err = ResolveKind(c.mapper, kind)
+		}
+	}
+	if meta.IsNoMatchError(err) {
+		e := c.unknownKind(schema.GroupVersionKind{Kind: kind})
+		e.RetryAfter = retryAfter
+		return schema.GroupVersionKind{}, e
+	}
+	return gvk, err
+}
+
+// reset resets the mapper after a miss of gvk so that it is found if it
+// was installed since the mapper was last reset. Since misses, e.g. of
+// misspelled kinds, would otherwise keep rediscovering every group, the
+// mapper is reset at most once every MinResetInterval, and not again for
+// a kind it was already reset for within it. When it isn't reset for
+// another kind's sake, the returned duration is how long until it may be.
+func (c *Checker) reset(gvk schema.GroupVersionKind) (bool, time.Duration) {
+	r, ok := c.mapper.(meta.ResettableRESTMapper)
+	if !ok {
+		return false, 0
+	}
+	c.mu.Lock()
+	now := c.now()
+	if at, ok := c.resetFor[gvk]; ok && now.Sub(at) < MinResetInterval {
+		c.mu.Unlock()
+		return false, 0
+	}
+	if wait := c.lastReset.Add(MinResetInterval).Sub(now); !c.lastReset.IsZero() && wait > 0 {
+		c.mu.Unlock()
+		return false, wait
+	}
+	c.lastReset = now
+	for k, at := range c.resetFor {
+		if now.Sub(at) >= MinResetInterval {
+			delete(c.resetFor, k)
+		}
+	}
+	c.resetFor[gvk] = now
+	c.mu.Unlock()
+	r.Reset()
+	return true, 0
+}
+
+func (c *Checker) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+// RetryAfter returns the longest UnknownKindError.RetryAfter found in err,
+// which may join several errors, or zero if there is none.
+func RetryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case *UnknownKindError:
+		return e.RetryAfter
+	case interface{ Unwrap() []error }:
+		var d time.Duration
+		for _, err := range e.Unwrap() {
+			d = max(d, RetryAfter(err))
+		}
+		return d
+	case interface{ Unwrap() error }:
+		return RetryAfter(e.Unwrap())
+	}
+	return 0
+}
+
+// Forget drops gvk from the kinds known to be served, e.g. once resolving a
+// target of that kind found it no longer is.
+func (c *Checker) Forget(gvk schema.GroupVersionKind) {
+	c.mu.Lock()
+	delete(c.known, itemKind(gvk))
+	c.mu.Unlock()
+}
+
+func (c *Checker) unknownKind(gvk schema.GroupVersionKind) *UnknownKindError {
+	e := &UnknownKindError{GVK: gvk}
+	item := itemKind(gvk)
+	if gvk.GroupVersion().Empty() {
+		_, lists, err := c.discovery.ServerGroupsAndResources()
+		if err != nil && len(lists) == 0 {
+			return e
+		}
+		var kinds []string
+		for _, l := range lists {
+			for _, r := range l.APIResources {
+				if !strings.Contains(r.Name, "/") && !slices.Contains(kinds, r.Kind) {
+					kinds = append(kinds, r.Kind)
+				}
+			}
+		}
+		e.Suggestions = withListSuffix(closest(item.Kind, kinds), item.Kind != gvk.Kind)
+		return e
+	}
+	resources, err := c.discovery.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	switch {
+	case err == nil:
+		var kinds []string
+		for _, r := range resources.APIResources {
+			// subresources share their parent's kind
+			if !strings.Contains(r.Name, "/") {
+				kinds = append(kinds, r.Kind)
+			}
+		}
+		e.Suggestions = withListSuffix(closest(item.Kind, kinds), item.Kind != gvk.Kind)
+	case apierrors.IsNotFound(err):
+		e.UnknownVersion = true
+		groups, err := c.discovery.ServerGroups()
+		if err != nil {
+			return e
+		}
+		var versions []string
+		for _, g := range groups.Groups {
+			for _, v := range g.Versions {
+				versions = append(versions, v.GroupVersion)
+			}
+		}
+		e.Suggestions = closest(gvk.GroupVersion().String(), versions)
+	}
+	return e
+}
+
+// itemKind returns the kind of the items of a List kind, or gvk itself.
+func itemKind(gvk schema.GroupVersionKind) schema.GroupVersionKind {
+	return gvk.GroupVersion().WithKind(strings.TrimSuffix(gvk.Kind, "List"))
+}
+
+// withListSuffix appends List to each kind when list is set.
+func withListSuffix(kinds []string, list bool) []string {
+	if list {
+		for i := range kinds {
+			kinds[i] += "List"
+		}
+	}
+	return kinds
+}
+
+// closest returns up to maxSuggestions candidates within an edit distance
+// of a third of s's length, ignoring case, closest first.
+func closest(s string, candidates []string) []string {
+	maxDistance := max(1, len(s)/3)
+	distances := map[string]int{}
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(s), strings.ToLower(c)); d <= maxDistance {
+			distances[c] = d
+		}
+	}
+	var matches []string
+	for c := range distances {
+		matches = append(matches, c)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if distances[matches[i]] != distances[matches[j]] {
+			return distances[matches[i]] < distances[matches[j]]
+		}
+		return matches[i] < matches[j]
+	})
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+	return matches
+}
+
+// levenshtein returns the number of single byte insertions, deletions or
+// substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
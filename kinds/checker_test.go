@@ -0,0 +1,284 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kinds
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func newTestChecker() (*Checker, *fakediscovery.FakeDiscovery) {
+	disc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	disc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true},
+				{Name: "pods/status", Kind: "Pod", Namespaced: true},
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				{Name: "events", Kind: "Event", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true},
+				{Name: "daemonsets", Kind: "DaemonSet", Namespaced: true},
+			},
+		},
+		// the first version of a group is the preferred one
+		{
+			GroupVersion: "batch/v1",
+			APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob", Namespaced: true}},
+		},
+		{
+			GroupVersion: "batch/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob", Namespaced: true}},
+		},
+		{
+			GroupVersion: "events.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "events", Kind: "Event", Namespaced: true}},
+		},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disc))
+	return NewChecker(mapper, disc), disc
+}
+
+func TestChecker(t *testing.T) {
+	testCases := map[string]struct {
+		gvk     schema.GroupVersionKind
+		wantErr *UnknownKindError
+	}{
+		"served kind": {
+			gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		"served list kind": {
+			gvk: schema.GroupVersionKind{Version: "v1", Kind: "PodList"},
+		},
+		"misspelled kind": {
+			gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deploymnet"},
+			wantErr: &UnknownKindError{
+				GVK:         schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deploymnet"},
+				Suggestions: []string{"Deployment"},
+			},
+		},
+		"misspelled list kind": {
+			gvk: schema.GroupVersionKind{Version: "v1", Kind: "PodsList"},
+			wantErr: &UnknownKindError{
+				GVK:         schema.GroupVersionKind{Version: "v1", Kind: "PodsList"},
+				Suggestions: []string{"PodList"},
+			},
+		},
+		"kind without close matches": {
+			gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Widget"},
+			wantErr: &UnknownKindError{
+				GVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Widget"},
+			},
+		},
+		"misspelled apiVersion": {
+			gvk: schema.GroupVersionKind{Group: "app", Version: "v1", Kind: "Deployment"},
+			wantErr: &UnknownKindError{
+				GVK:            schema.GroupVersionKind{Group: "app", Version: "v1", Kind: "Deployment"},
+				UnknownVersion: true,
+				Suggestions:    []string{"apps/v1"},
+			},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			c, _ := newTestChecker()
+			err := c.Check(tc.gvk)
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			var unknown *UnknownKindError
+			if !errors.As(err, &unknown) {
+				t.Fatalf("got err=%v, want an UnknownKindError", err)
+			}
+			if !reflect.DeepEqual(unknown, tc.wantErr) {
+				t.Errorf("got %#v, want %#v", unknown, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckerResolve(t *testing.T) {
+	testCases := map[string]struct {
+		kind    string
+		want    schema.GroupVersionKind
+		wantErr error
+	}{
+		"core kind": {
+			kind: "Pod",
+			want: schema.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		},
+		"core list kind": {
+			kind: "PodList",
+			want: schema.GroupVersionKind{Version: "v1", Kind: "PodList"},
+		},
+		"group kind": {
+			kind: "Deployment",
+			want: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		"preferred version": {
+			kind: "CronJob",
+			want: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+		},
+		"served by more than one group": {
+			kind: "Event",
+			wantErr: &AmbiguousKindError{
+				Kind:          "Event",
+				GroupVersions: []schema.GroupVersion{{Group: "events.k8s.io", Version: "v1"}, {Version: "v1"}},
+			},
+		},
+		"misspelled kind": {
+			kind: "Deploymnet",
+			wantErr: &UnknownKindError{
+				GVK:         schema.GroupVersionKind{Kind: "Deploymnet"},
+				Suggestions: []string{"Deployment"},
+			},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			c, _ := newTestChecker()
+			got, err := c.Resolve(tc.kind)
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Fatalf("got err=%#v, want %#v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+			// resolved kinds are checked as such
+			if err := c.Check(schema.GroupVersionKind{Kind: tc.kind}); !reflect.DeepEqual(err, tc.wantErr) {
+				t.Errorf("got check err=%#v, want %#v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckerPicksUpNewKinds(t *testing.T) {
+	c, disc := newTestChecker()
+	clock := testingclock.NewFakeClock(time.Now())
+	c.Clock = clock
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	var unknown *UnknownKindError
+	if err := c.Check(gvk); !errors.As(err, &unknown) || unknown.RetryAfter != 0 {
+		t.Fatalf("got err=%v, want an UnknownKindError before the CRD is installed", err)
+	}
+
+	disc.Resources = append(disc.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+	})
+	// the mapper was just reset for the kind
+	if err := c.Check(gvk); !errors.As(err, &unknown) || unknown.RetryAfter != 0 {
+		t.Fatalf("got err=%v, want an UnknownKindError until the mapper may be reset again", err)
+	}
+	clock.Step(MinResetInterval)
+	if err := c.Check(gvk); err != nil {
+		t.Fatalf("unexpected error once the CRD is installed: %s", err)
+	}
+
+	// known kinds are cached until forgotten
+	disc.Resources = disc.Resources[:len(disc.Resources)-1]
+	if err := c.Check(gvk); err != nil {
+		t.Fatalf("unexpected error for a cached kind: %s", err)
+	}
+	c.Forget(gvk.GroupVersion().WithKind("WidgetList"))
+	if c.known[gvk] {
+		t.Errorf("got %s still known once forgotten", gvk)
+	}
+}
+
+func TestCheckerRateLimitsResets(t *testing.T) {
+	c, _ := newTestChecker()
+	clock := testingclock.NewFakeClock(time.Now())
+	c.Clock = clock
+	var unknown *UnknownKindError
+	if err := c.Check(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}); !errors.As(err, &unknown) || unknown.RetryAfter != 0 {
+		t.Fatalf("got err=%v, want an UnknownKindError checked against a reset mapper", err)
+	}
+
+	clock.Step(time.Second)
+	gadget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}
+	if err := c.Check(gadget); !errors.As(err, &unknown) || unknown.RetryAfter != MinResetInterval-time.Second {
+		t.Fatalf("got err=%v, want an UnknownKindError to be retried after %s", err, MinResetInterval-time.Second)
+	}
+	joined := errors.Join(errors.New("other"), fmt.Errorf("target %q: %w", "gadget", unknown))
+	if got := RetryAfter(joined); got != MinResetInterval-time.Second {
+		t.Errorf("got RetryAfter=%s on the joined errors, want %s", got, MinResetInterval-time.Second)
+	}
+
+	clock.Step(MinResetInterval)
+	if err := c.Check(gadget); !errors.As(err, &unknown) || unknown.RetryAfter != 0 {
+		t.Fatalf("got err=%v, want an UnknownKindError checked against a reset mapper", err)
+	}
+	if _, err := c.Resolve("Gizmo"); !errors.As(err, &unknown) || unknown.RetryAfter == 0 {
+		t.Errorf("got err=%v, want resolving to be rate limited as well", err)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	testCases := map[string]struct {
+		err  error
+		want string
+	}{
+		"unknown kind": {
+			err: &UnknownKindError{
+				GVK:         schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deploymnet"},
+				Suggestions: []string{"Deployment", "DaemonSet"},
+			},
+			want: `kind "Deploymnet" is not served by apps/v1, did you mean "Deployment" or "DaemonSet"?`,
+		},
+		"unknown kind without apiVersion": {
+			err:  &UnknownKindError{GVK: schema.GroupVersionKind{Kind: "Widget"}},
+			want: `kind "Widget" is not served`,
+		},
+		"ambiguous kind": {
+			err: &AmbiguousKindError{
+				Kind:          "Event",
+				GroupVersions: []schema.GroupVersion{{Group: "events.k8s.io", Version: "v1"}, {Version: "v1"}},
+			},
+			want: `kind "Event" is served by more than one group, set apiVersion to one of "events.k8s.io/v1", "v1"`,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			if tc.err.Error() != tc.want {
+				t.Errorf("got %q, want %q", tc.err.Error(), tc.want)
+			}
+		})
+	}
+}
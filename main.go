@@ -17,9 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
-	"sigs.k8s.io/controller-runtime/pkg/config"
+	"strings"
+	"time"
+	// deletion windows' time zones are resolved without the image
+	// providing a time zone database
+	_ "time/tzdata"
+
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -28,15 +34,25 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
 	"github.com/vtex/cleaner-controller/controllers"
+	"github.com/vtex/cleaner-controller/custom_cel"
+	"github.com/vtex/cleaner-controller/kinds"
+	"github.com/vtex/cleaner-controller/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -59,6 +75,20 @@ func main() {
 	var maxConcurrentReconciles int
 	var qps float64
 	var burst int
+	var maxRequeueAfter time.Duration
+	var requeueJitter float64
+	var deleteConcurrency int
+	var allowForceFinalizerRemoval bool
+	var allowCrossNamespaceHelm bool
+	var protectedLabel string
+	var maxTargetStateSize int
+	var errorBackoffBase time.Duration
+	var errorBackoffMax time.Duration
+	var defaultRetryPeriod time.Duration
+	var helmTimeout time.Duration
+	var cloudEventTimeout time.Duration
+	var helmDriver string
+	var otlpEndpoint string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -67,6 +97,34 @@ func main() {
 	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "Define how many concurrent reconciles are allowed.")
 	flag.Float64Var(&qps, "qps", 5, "The maximum QPS to the master from the client used by this controller.")
 	flag.IntVar(&burst, "burst", 10, "The maximum burst for throttle.")
+	flag.DurationVar(&maxRequeueAfter, "max-requeue-after", controllers.DefaultMaxRequeueAfter,
+		"The maximum time to wait before re-checking a ConditionalTTL which has not expired yet.")
+	flag.IntVar(&deleteConcurrency, "delete-concurrency", controllers.DefaultDeleteConcurrency,
+		"How many objects of a list target are deleted concurrently.")
+	flag.BoolVar(&allowForceFinalizerRemoval, "allow-force-finalizer-removal", false,
+		"Allow removing the finalizers of targets stuck in deletion when requested by a ConditionalTTL.")
+	flag.StringVar(&protectedLabel, "protected-label", controllers.DefaultProtectedLabel,
+		"The label which, set to \"true\", protects an object from ever being deleted, even when matched by a target.")
+	flag.BoolVar(&allowCrossNamespaceHelm, "allow-cross-namespace-helm", false,
+		"Allow uninstalling Helm releases in a namespace other than the ConditionalTTL's when requested by it.")
+	flag.Float64Var(&requeueJitter, "requeue-jitter", 0.1,
+		"The maximum fraction by which requeues are randomly shortened or extended to spread out the evaluation of ConditionalTTLs created at once. Zero disables jitter.")
+	flag.DurationVar(&errorBackoffBase, "error-backoff-base", controllers.DefaultErrorBackoffBase,
+		"How long to wait before retrying a failed reconcile, doubled on each consecutive failure.")
+	flag.DurationVar(&errorBackoffMax, "error-backoff-max", controllers.DefaultErrorBackoffMax,
+		"The maximum time to wait before retrying a failed reconcile.")
+	flag.DurationVar(&defaultRetryPeriod, "default-retry-period", controllers.DefaultRetryPeriod,
+		"How long to wait before evaluating the conditions of a ConditionalTTL again when it has no retry config.")
+	flag.DurationVar(&helmTimeout, "helm-timeout", controllers.DefaultHelmTimeout,
+		"How long uninstalling a Helm release may take before it is retried, unless set on the ConditionalTTL.")
+	flag.DurationVar(&cloudEventTimeout, "cloud-event-timeout", controllers.DefaultCloudEventTimeout,
+		"How long sending a cloud event, including its retries, may take before it is given up.")
+	flag.StringVar(&helmDriver, "helm-driver", controllers.DefaultHelmDriver,
+		"The storage driver of Helm releases, one of "+strings.Join(controllers.HelmDrivers, ", ")+".")
+	flag.IntVar(&maxTargetStateSize, "max-target-state-size", controllers.DefaultMaxTargetStateSize,
+		"The maximum size in bytes of the targets' state kept on the status and sent on cloud events before it is reduced to their metadata.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The OTLP gRPC endpoint URL spans around reconciles are exported to, e.g. http://localhost:4317. Tracing is disabled when empty.")
 
 	opts := zap.Options{
 		Development: true,
@@ -76,6 +134,17 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := controllers.ValidateHelmDriver(helmDriver); err != nil {
+		setupLog.Error(err, "invalid --helm-driver")
+		os.Exit(1)
+	}
+
+	tracerProvider, err := newTracerProvider(otlpEndpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to create tracer provider")
+		os.Exit(1)
+	}
+
 	cfg := ctrl.GetConfigOrDie()
 	cfg.QPS = float32(qps)
 	cfg.Burst = burst
@@ -98,9 +167,6 @@ func main() {
 		// LeaderElectionReleaseOnCancel: true,
 		LeaderElection:   enableLeaderElection,
 		LeaderElectionID: "813ae16b.vtex.io",
-		Controller: config.Controller{
-			MaxConcurrentReconciles: maxConcurrentReconciles,
-		},
 	})
 
 	if err != nil {
@@ -114,18 +180,78 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ConditionalTTLReconciler{
+	dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	// shared so that kinds found to be served by either are cached for both.
+	// Unlike the manager's, its RESTMapper knows every group so that kinds
+	// without an apiVersion can be resolved.
+	cdc := memory.NewMemCacheClient(dc)
+	kindChecker := kinds.NewChecker(restmapper.NewDeferredDiscoveryRESTMapper(cdc), cdc)
+
+	cTTLReconciler := &controllers.ConditionalTTLReconciler{
 		Client:            mgr.GetClient(),
 		Scheme:            mgr.GetScheme(),
 		Config:            mgr.GetConfig(),
+		APIReader:         mgr.GetAPIReader(),
 		Recorder:          mgr.GetEventRecorderFor("cleaner-controller"),
 		CloudEventsClient: cec,
-	}).SetupWithManager(mgr); err != nil {
+		MaxRequeueAfter:   maxRequeueAfter,
+		RequeueJitter:     requeueJitter,
+		DeleteConcurrency: deleteConcurrency,
+
+		DefaultRetryPeriod:         defaultRetryPeriod,
+		AllowForceFinalizerRemoval: allowForceFinalizerRemoval,
+		ProtectedLabel:             protectedLabel,
+		AllowCrossNamespaceHelm:    allowCrossNamespaceHelm,
+		MaxTargetStateSize:         maxTargetStateSize,
+		HelmTimeout:                helmTimeout,
+		CloudEventTimeout:          cloudEventTimeout,
+		HelmDriver:                 helmDriver,
+		KindChecker:                kindChecker,
+		// set from the downward API by the manager's deployment
+		ControllerNamespace: os.Getenv("POD_NAMESPACE"),
+	}
+	if tracerProvider != nil {
+		cTTLReconciler.TracerProvider = tracerProvider
+	}
+	controllerOptions := controllers.ControllerOptions{
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ErrorBackoffBase:        errorBackoffBase,
+		ErrorBackoffMax:         errorBackoffMax,
+	}
+	if err = cTTLReconciler.SetupWithManager(mgr, controllerOptions.Build()); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ConditionalTTL")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterConditionalTTLReconciler{
+		ConditionalTTLReconciler: cTTLReconciler,
+	}).SetupWithManager(mgr, controllerOptions.Build()); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterConditionalTTL")
+		os.Exit(1)
+	}
+	if err = (&controllers.ConditionalTTLTemplateReconciler{
+		ConditionalTTLReconciler: cTTLReconciler,
+	}).SetupWithManager(mgr, controllerOptions.Build()); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ConditionalTTLTemplate")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&webhooks.ConditionalTTLValidator{Kinds: kindChecker, AllowCrossNamespaceHelm: allowCrossNamespaceHelm}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ConditionalTTL")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	// lists the CEL functions and macros available to conditions
+	if err := mgr.AddMetricsServerExtraHandler("/cel/functions", custom_cel.FunctionsHandler()); err != nil {
+		setupLog.Error(err, "unable to set up CEL functions endpoint")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -134,10 +260,37 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// catches broken CEL libraries before any condition is evaluated
+	if err := mgr.AddReadyzCheck("cel-env", custom_cel.EnvCheck()); err != nil {
+		setupLog.Error(err, "unable to set up CEL environment ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if tracerProvider != nil {
+		// flushes the spans still batched
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			setupLog.Error(err, "unable to shut down tracer provider")
+		}
+	}
+}
+
+// newTracerProvider returns a provider exporting spans to the OTLP endpoint,
+// or nil when there is none.
+func newTracerProvider(endpoint string) (*sdktrace.TracerProvider, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(attribute.String("service.name", "cleaner-controller"))
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
 }
@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package targets
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+// InvalidReferenceError is returned when a target's reference can't be
+// used to look it up, e.g. due to an invalid label selector.
+type InvalidReferenceError struct {
+	Err error
+}
+
+func (e *InvalidReferenceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveError is returned by Resolver.DeleteGroup when the target group
+// itself can't be looked up, as opposed to failing to delete its objects.
+type ResolveError struct {
+	Err error
+}
+
+func (e *ResolveError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundYetError is returned by Resolver.ResolveAll for targets with
+// OptionalUntilFound whose object doesn't exist yet.
+type NotFoundYetError struct {
+	Err error
+}
+
+func (e *NotFoundYetError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NotFoundYetError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFoundYet reports whether err is a *NotFoundYetError.
+func IsNotFoundYet(err error) bool {
+	var notFoundYet *NotFoundYetError
+	return errors.As(err, &notFoundYet)
+}
+
+// ErrorReason returns the Ready reason for an error resolving targets and
+// whether it is permanent, i.e. retrying is pointless until the target's
+// reference or the controller's permissions change. Errors joined by
+// Resolver.ResolveAll are permanent if any of them is.
+func ErrorReason(err error) (reason string, permanent bool) {
+	var refErr *InvalidReferenceError
+	switch {
+	case errors.As(err, &refErr):
+		return cleanerv1alpha1.ConditionReasonInvalidTargetReference, true
+	case apierrors.IsForbidden(err):
+		return cleanerv1alpha1.ConditionReasonTargetForbidden, true
+	case apimeta.IsNoMatchError(err):
+		return cleanerv1alpha1.ConditionReasonUnknownKind, true
+	}
+	return cleanerv1alpha1.ConditionReasonTargetResolveError, false
+}
+
+// IsPermanentError reports whether retrying to resolve a target group is
+// pointless until its reference or the controller's permissions change.
+// Errors deleting the group's objects, e.g. denied by a webhook, are never
+// considered permanent.
+func IsPermanentError(err error) bool {
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) {
+		return false
+	}
+	_, permanent := ErrorReason(err)
+	return permanent
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package targets
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/lru"
+)
+
+// maxFilteredCounts caps how many targets FilteredCounts remembers, the
+// least recently resolved ones being forgotten first.
+const maxFilteredCounts = 4096
+
+// FilteredCounts remembers how many listed objects were last filtered out
+// of each target by its annotation selector, so that TargetsFiltered is
+// only recorded when that number changes. The zero value is ready to use.
+type FilteredCounts struct {
+	mu     sync.Mutex
+	counts *lru.Cache
+}
+
+type filteredKey struct {
+	owner     types.UID
+	namespace string
+	target    string
+}
+
+// changed records count for key and reports whether it differs from the
+// last one recorded. A nil FilteredCounts always reports a change.
+func (f *FilteredCounts) changed(key filteredKey, count int) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts = lru.New(maxFilteredCounts)
+	}
+	last, ok := f.counts.Get(key)
+	f.counts.Add(key, count)
+	return !ok || last.(int) != count
+}
@@ -0,0 +1,582 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package targets resolves the objects referenced by targets and deletes
+// them, on behalf of both ConditionalTTLs and ClusterConditionalTTLs.
+package targets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/kinds"
+)
+
+// DefaultDeleteConcurrency is how many objects of a list target are deleted
+// concurrently when Resolver.DeleteConcurrency is unset.
+const DefaultDeleteConcurrency = 8
+
+// DefaultForceRemoveFinalizersAfter is how long a target must be stuck in
+// deletion before its finalizers are removed when
+// Target.ForceRemoveFinalizersAfter is unset.
+const DefaultForceRemoveFinalizersAfter = 5 * time.Minute
+
+// DefaultProtectedLabel is the label protecting objects from deletion when
+// Resolver.ProtectedLabel is unset.
+const DefaultProtectedLabel = "cleaner.vtex.io/protected"
+
+// Resolver resolves the objects referenced by targets and deletes them.
+type Resolver struct {
+	client.Client
+
+	// APIReader reads the metadata of targets declared with MetadataOnly
+	// directly from the API server, so that no informers are started for
+	// them. Defaults to Client.
+	APIReader client.Reader
+
+	// KindChecker, when set, resolves the kind of targets omitting their
+	// apiVersion, since the manager's RESTMapper only resolves core kinds.
+	KindChecker *kinds.Checker
+
+	// Recorder records events on the owner of the targets.
+	Recorder record.EventRecorder
+
+	// Clock provides the current time when deciding whether to remove the
+	// finalizers of targets stuck in deletion. Defaults to the real clock.
+	Clock clock.PassiveClock
+
+	// DeleteConcurrency is how many objects of a list target are deleted
+	// concurrently. Defaults to DefaultDeleteConcurrency.
+	DeleteConcurrency int
+
+	// AllowForceFinalizerRemoval enables removing the finalizers of targets
+	// stuck in deletion when requested by Target.ForceRemoveFinalizers.
+	AllowForceFinalizerRemoval bool
+
+	// ProtectedLabel is the label which, set to "true", keeps an object
+	// from ever being deleted, even when matched by a target. Defaults to
+	// DefaultProtectedLabel.
+	ProtectedLabel string
+
+	// FilteredCounts, when set, keeps TargetsFiltered from being recorded
+	// again on every resolve while the number of filtered out objects
+	// stays the same.
+	FilteredCounts *FilteredCounts
+}
+
+// Owner describes the object declaring the targets being resolved.
+type Owner struct {
+	// Object is the owner itself, on which events are recorded. A
+	// ConditionalTTL owner is never included in its own targets.
+	Object client.Object
+
+	// Namespace is the namespace targets of namespaced kinds are looked up
+	// in.
+	Namespace string
+
+	// AllowConditionalTTLTargets allows targets to reference
+	// ConditionalTTLs.
+	AllowConditionalTTLTargets bool
+
+	// NamespacedOnly rejects targets of cluster-scoped kinds, e.g. when the
+	// same targets are resolved in several namespaces.
+	NamespacedOnly bool
+
+	// AllowedNamespaces are the namespaces targets of namespaced kinds may
+	// be looked up in. Only Namespace is allowed when empty.
+	AllowedNamespaces []string
+}
+
+// allowsNamespace reports whether targets may be looked up in namespace.
+func (o Owner) allowsNamespace(namespace string) bool {
+	if len(o.AllowedNamespaces) == 0 {
+		return namespace == o.Namespace
+	}
+	return slices.Contains(o.AllowedNamespaces, namespace)
+}
+
+// isSelf reports whether item, a ConditionalTTL, is the owner itself.
+func (o Owner) isSelf(item client.Object) bool {
+	return item.GetName() == o.Object.GetName() && item.GetNamespace() == o.Object.GetNamespace()
+}
+
+// Resolve resolves either a single target given its name or a List kind
+// given a labelSelector. ConditionalTTLs can only be targeted when the owner
+// allows it and the owner itself is never included.
+func (r *Resolver) Resolve(ctx context.Context, owner Owner, t *cleanerv1alpha1.Target) (runtime.Unstructured, error) {
+	log := log.FromContext(ctx)
+	gvk, err := r.GVK(t.Reference)
+	if err != nil {
+		var ambiguous *kinds.AmbiguousKindError
+		if errors.As(err, &ambiguous) {
+			return nil, &InvalidReferenceError{err}
+		}
+		return nil, err
+	}
+	targetsCTTLs := gvk.Group == cleanerv1alpha1.GroupVersion.Group && gvk.Kind == "ConditionalTTL"
+	if targetsCTTLs && !owner.AllowConditionalTTLTargets {
+		return nil, &InvalidReferenceError{fmt.Errorf("Target %q references ConditionalTTLs which requires allowConditionalTTLTargets", t.Name)}
+	}
+	if t.Reference.Name != nil {
+		namespace, err := r.namespace(owner, gvk)
+		if err != nil {
+			return nil, err
+		}
+		key := types.NamespacedName{Name: *t.Reference.Name, Namespace: namespace}
+		if targetsCTTLs && key.Name == owner.Object.GetName() && key.Namespace == owner.Object.GetNamespace() {
+			return nil, &InvalidReferenceError{fmt.Errorf("Target %q references the ConditionalTTL itself", t.Name)}
+		}
+		return r.get(ctx, gvk, key, t.MetadataOnly)
+	}
+	// TODO: remove when we add admission webhook
+	if t.Reference.LabelSelector == nil && t.Reference.AnnotationSelector == nil {
+		return nil, &InvalidReferenceError{fmt.Errorf("Target %q reference Name, LabelSelector and AnnotationSelector can't all be nil", t.Name)}
+	}
+	// objects are only filtered by their annotations after being listed
+	ls := labels.Everything()
+	if t.Reference.LabelSelector != nil {
+		if ls, err = metav1.LabelSelectorAsSelector(t.Reference.LabelSelector); err != nil {
+			return nil, &InvalidReferenceError{err}
+		}
+	}
+	itemGVK := gvk.GroupVersion().WithKind(strings.TrimSuffix(gvk.Kind, "List"))
+	namespace, err := r.namespace(owner, itemGVK)
+	if err != nil {
+		return nil, err
+	}
+	ul, err := r.list(ctx, gvk, &client.ListOptions{
+		LabelSelector: ls,
+		Namespace:     namespace,
+	}, t.MetadataOnly)
+	if err != nil {
+		return nil, err
+	}
+	// sanity check
+	if ul.GetContinue() != "" {
+		err = errors.New("r.List: unexpected continuation token")
+		log.Error(err, "", "gvk", gvk, "labelSelector", ls)
+		return nil, err
+	}
+	if as := t.Reference.AnnotationSelector; as != nil {
+		listed := len(ul.Items)
+		items := ul.Items[:0]
+		for _, item := range ul.Items {
+			if as.Matches(item.GetAnnotations()) {
+				items = append(items, item)
+			}
+		}
+		ul.Items = items
+		filtered := listed - len(items)
+		key := filteredKey{owner: owner.Object.GetUID(), namespace: namespace, target: t.Name}
+		if r.FilteredCounts.changed(key, filtered) && filtered > 0 {
+			r.Recorder.Eventf(owner.Object, corev1.EventTypeNormal, "TargetsFiltered", "Target %q: %d of %d listed objects filtered out by the annotation selector", t.Name, filtered, listed)
+		}
+	}
+	if targetsCTTLs {
+		items := ul.Items[:0]
+		for _, item := range ul.Items {
+			if owner.isSelf(&item) {
+				r.Recorder.Eventf(owner.Object, corev1.EventTypeWarning, "SelfTargetSkipped", "Target %q matches the ConditionalTTL itself, skipping it", t.Name)
+				continue
+			}
+			items = append(items, item)
+		}
+		ul.Items = items
+	}
+	return ul, nil
+}
+
+// GVK returns the GroupVersionKind of ref, resolving its kind to the
+// preferred version when the apiVersion is omitted. Kinds are resolved by
+// KindChecker when set, since the manager's RESTMapper only resolves core
+// kinds.
+func (r *Resolver) GVK(ref cleanerv1alpha1.TargetReference) (schema.GroupVersionKind, error) {
+	if ref.APIVersion != "" {
+		return schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind), nil
+	}
+	if r.KindChecker != nil {
+		return r.KindChecker.Resolve(ref.Kind)
+	}
+	return kinds.ResolveKind(r.RESTMapper(), ref.Kind)
+}
+
+// CheckKinds returns the *kinds.UnknownKindError and
+// *kinds.AmbiguousKindError of the targets' kinds, joined, or nil when
+// KindChecker isn't set. Kinds which can't be checked, e.g. as discovery
+// of an unrelated group fails, are left to be reported when resolving the
+// targets.
+func (r *Resolver) CheckKinds(targets []cleanerv1alpha1.Target) error {
+	if r.KindChecker == nil {
+		return nil
+	}
+	var errs []error
+	for _, t := range targets {
+		err := r.KindChecker.Check(t.Reference.GroupVersionKind())
+		var unknown *kinds.UnknownKindError
+		var ambiguous *kinds.AmbiguousKindError
+		if errors.As(err, &unknown) || errors.As(err, &ambiguous) {
+			errs = append(errs, fmt.Errorf("target %q: %w", t.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// namespace returns the namespace targets of the given kind are looked up
+// in: the owner's, as long as it is allowed, or none if the kind is
+// cluster-scoped.
+func (r *Resolver) namespace(owner Owner, gvk schema.GroupVersionKind) (string, error) {
+	namespaced, err := apiutil.IsGVKNamespaced(gvk, r.RESTMapper())
+	if err != nil {
+		// the kind may have been served when it was checked
+		if apimeta.IsNoMatchError(err) && r.KindChecker != nil {
+			r.KindChecker.Forget(gvk)
+		}
+		return "", err
+	}
+	if !namespaced {
+		if owner.NamespacedOnly {
+			return "", &InvalidReferenceError{fmt.Errorf("kind %s is cluster-scoped and can't be looked up by namespace", gvk.Kind)}
+		}
+		return "", nil
+	}
+	if !owner.allowsNamespace(owner.Namespace) {
+		return "", &InvalidReferenceError{fmt.Errorf("namespace %q is not in the allowed namespaces", owner.Namespace)}
+	}
+	return owner.Namespace, nil
+}
+
+// get gets a single object. When metadataOnly is set only its metadata is
+// read, without using the cache.
+func (r *Resolver) get(ctx context.Context, gvk schema.GroupVersionKind, key types.NamespacedName, metadataOnly bool) (*unstructured.Unstructured, error) {
+	if !metadataOnly {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		if err := r.Get(ctx, key, u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	m := &metav1.PartialObjectMetadata{}
+	m.SetGroupVersionKind(gvk)
+	if err := r.apiReader().Get(ctx, key, m); err != nil {
+		return nil, err
+	}
+	return metadataToUnstructured(gvk, m)
+}
+
+// list lists objects of the given kind. When metadataOnly is set only their
+// metadata is read, without using the cache.
+func (r *Resolver) list(ctx context.Context, gvk schema.GroupVersionKind, opts *client.ListOptions, metadataOnly bool) (*unstructured.UnstructuredList, error) {
+	ul := &unstructured.UnstructuredList{}
+	ul.SetGroupVersionKind(gvk)
+	if !metadataOnly {
+		if err := r.List(ctx, ul, opts); err != nil {
+			return nil, err
+		}
+		return ul, nil
+	}
+	ml := &metav1.PartialObjectMetadataList{}
+	ml.SetGroupVersionKind(gvk)
+	if err := r.apiReader().List(ctx, ml, opts); err != nil {
+		return nil, err
+	}
+	ul.SetResourceVersion(ml.GetResourceVersion())
+	ul.SetContinue(ml.GetContinue())
+	ul.Items = make([]unstructured.Unstructured, 0, len(ml.Items))
+	for i := range ml.Items {
+		u, err := metadataToUnstructured(gvk, &ml.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		ul.Items = append(ul.Items, *u)
+	}
+	return ul, nil
+}
+
+func (r *Resolver) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
+func (r *Resolver) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// metadataToUnstructured converts m into an object of the given kind holding
+// only its metadata, which is enough to evaluate conditions on and delete it.
+func metadataToUnstructured(gvk schema.GroupVersionKind, m *metav1.PartialObjectMetadata) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+// ResolveAll resolves a list of cleanerv1alpha1.TargetStatus given the
+// owner's targets. Every target is resolved even if some fail so that the
+// errors of all of them are returned, joined, along with the statuses of
+// the failing targets only, their Error set. Missing objects of targets
+// with OptionalUntilFound are reported as *NotFoundYetError.
+func (r *Resolver) ResolveAll(ctx context.Context, owner Owner, targets []cleanerv1alpha1.Target) ([]cleanerv1alpha1.TargetStatus, error) {
+	ts := make([]cleanerv1alpha1.TargetStatus, len(targets))
+	var failed []cleanerv1alpha1.TargetStatus
+	var errs []error
+	for i, t := range targets {
+		ui, err := r.Resolve(ctx, owner, &t)
+		if err != nil && t.OptionalUntilFound && t.Reference.Name != nil && apierrors.IsNotFound(err) {
+			err = &NotFoundYetError{err}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error resolving target %q: %w", t.Name, err))
+			failed = append(failed, cleanerv1alpha1.TargetStatus{
+				Name:                  t.Name,
+				Delete:                t.Delete,
+				IncludeWhenEvaluating: t.IncludedWhenEvaluating(),
+				Error:                 err.Error(),
+			})
+			continue
+		}
+		gvk := ui.GetObjectKind().GroupVersionKind()
+		content := ui.UnstructuredContent()
+		// empty lists still have items, so that conditions such as
+		// size(pods.items) == 0 don't fail with no such key
+		if _, ok := ui.(*unstructured.UnstructuredList); ok && content["items"] == nil {
+			content["items"] = []interface{}{}
+		}
+		ts[i] = cleanerv1alpha1.TargetStatus{
+			Name:                  t.Name,
+			APIVersion:            gvk.GroupVersion().String(),
+			Kind:                  gvk.Kind,
+			Delete:                t.Delete,
+			IncludeWhenEvaluating: t.IncludedWhenEvaluating(),
+			State:                 &unstructured.Unstructured{Object: content},
+		}
+	}
+	if len(errs) > 0 {
+		return failed, errors.Join(errs...)
+	}
+	return ts, nil
+}
+
+// DeleteGroup deletes the objects referenced by a target and returns how
+// many of them are still present afterwards, e.g. objects with finalizers
+// of their own. Groups deleted with DeletionModeFireAndForget aren't
+// checked and always report none. Errors resolving the group are returned
+// as *ResolveError.
+func (r *Resolver) DeleteGroup(ctx context.Context, owner Owner, t *cleanerv1alpha1.Target) (int, error) {
+	ui, err := r.Resolve(ctx, owner, t)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, &ResolveError{err}
+	}
+	switch u := ui.(type) {
+	case *unstructured.UnstructuredList:
+		err = r.deleteAll(ctx, owner, t, u.Items)
+	case *unstructured.Unstructured:
+		err = r.delete(ctx, owner, t, u)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if t.DeletionMode == cleanerv1alpha1.DeletionModeFireAndForget {
+		return 0, nil
+	}
+
+	// confirm the targets are gone
+	ui, err = r.Resolve(ctx, owner, t)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, &ResolveError{err}
+	}
+	remaining := []unstructured.Unstructured{}
+	switch u := ui.(type) {
+	case *unstructured.UnstructuredList:
+		remaining = u.Items
+	case *unstructured.Unstructured:
+		remaining = append(remaining, *u)
+	}
+	// protected objects are never waited for, as they are left in place
+	remaining = slices.DeleteFunc(remaining, func(item unstructured.Unstructured) bool {
+		return r.IsProtected(&item)
+	})
+	if t.ForceRemoveFinalizers {
+		if err := r.forceRemoveFinalizers(ctx, owner, t, remaining); err != nil {
+			return len(remaining), err
+		}
+	}
+	return len(remaining), nil
+}
+
+// IsProtected reports whether obj carries the protected label set to
+// "true" and must therefore never be deleted.
+func (r *Resolver) IsProtected(obj metav1.Object) bool {
+	label := r.ProtectedLabel
+	if label == "" {
+		label = DefaultProtectedLabel
+	}
+	return obj.GetLabels()[label] == "true"
+}
+
+// delete deletes a target using t's grace period, in the background when
+// fired and forgotten, and publishes events regarding what was done or any
+// errors encountered. Protected targets are skipped, as are targets already
+// being deleted, which would otherwise be deleted again on every retry
+// while their finalizers run, unless t's grace period is shorter than the
+// one they are being deleted with.
+func (r *Resolver) delete(ctx context.Context, owner Owner, t *cleanerv1alpha1.Target, target *unstructured.Unstructured) error {
+	if r.IsProtected(target) {
+		r.Recorder.Eventf(owner.Object, corev1.EventTypeNormal, "SkippedProtected", "Target %s/%s not deleted since it is protected", target.GetKind(), target.GetName())
+		return nil
+	}
+	if target.GetDeletionTimestamp() != nil && !shortensGracePeriod(t, target) {
+		return nil
+	}
+	opts := []client.DeleteOption{}
+	if t.GracePeriodSeconds != nil {
+		opts = append(opts, client.GracePeriodSeconds(*t.GracePeriodSeconds))
+	}
+	if t.DeletionMode == cleanerv1alpha1.DeletionModeFireAndForget {
+		opts = append(opts, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	}
+	err := r.Delete(ctx, target, opts...)
+	if err == nil {
+		if t.GracePeriodSeconds != nil {
+			r.Recorder.Eventf(owner.Object, corev1.EventTypeNormal, "TargetDeleted", "Target %s/%s deleted with grace period %ds", target.GetKind(), target.GetName(), *t.GracePeriodSeconds)
+		} else {
+			r.Recorder.Eventf(owner.Object, corev1.EventTypeNormal, "TargetDeleted", "Target %s/%s deleted", target.GetKind(), target.GetName())
+		}
+		return nil
+	}
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	r.Recorder.Eventf(owner.Object, corev1.EventTypeWarning, "DeleteTargetFailed", "Error deleting target %s/%s: %s", target.GetKind(), target.GetName(), err.Error())
+	return err
+}
+
+// shortensGracePeriod reports whether deleting target again with t's grace
+// period would cut its running graceful deletion short, e.g. to force it
+// with a grace period of 0.
+func shortensGracePeriod(t *cleanerv1alpha1.Target, target *unstructured.Unstructured) bool {
+	current := target.GetDeletionGracePeriodSeconds()
+	return t.GracePeriodSeconds != nil && current != nil && *t.GracePeriodSeconds < *current
+}
+
+// deleteAll deletes items using up to DeleteConcurrency concurrent
+// requests. Every item is attempted, stopping early only if ctx is
+// cancelled, and all encountered errors are returned.
+func (r *Resolver) deleteAll(ctx context.Context, owner Owner, t *cleanerv1alpha1.Target, items []unstructured.Unstructured) error {
+	concurrency := r.DeleteConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultDeleteConcurrency
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		wg.Add(1)
+		go func(item *unstructured.Unstructured) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := r.delete(ctx, owner, t, item); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s/%s: %w", item.GetKind(), item.GetName(), err))
+				mu.Unlock()
+			}
+		}(&items[i])
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// forceRemoveFinalizers removes the finalizers of the target's objects which
+// are still present ForceRemoveFinalizersAfter their deletion began. It does
+// nothing unless AllowForceFinalizerRemoval is set.
+func (r *Resolver) forceRemoveFinalizers(ctx context.Context, owner Owner, t *cleanerv1alpha1.Target, items []unstructured.Unstructured) error {
+	if !r.AllowForceFinalizerRemoval {
+		log.FromContext(ctx).Info("Ignoring forceRemoveFinalizers since it is not allowed", "target", t.Name)
+		return nil
+	}
+	after := DefaultForceRemoveFinalizersAfter
+	if t.ForceRemoveFinalizersAfter != nil {
+		after = t.ForceRemoveFinalizersAfter.Duration
+	}
+	var errs []error
+	for i := range items {
+		item := &items[i]
+		deletedAt := item.GetDeletionTimestamp()
+		if deletedAt == nil || len(item.GetFinalizers()) == 0 || r.now().Sub(deletedAt.Time) < after {
+			continue
+		}
+		base := item.DeepCopy()
+		finalizers := item.GetFinalizers()
+		item.SetFinalizers(nil)
+		if err := r.Patch(ctx, item, client.MergeFrom(base)); err != nil && !apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(owner.Object, corev1.EventTypeWarning, "ForceRemoveFinalizersFailed", "Error removing finalizers of target %s/%s: %s", item.GetKind(), item.GetName(), err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		r.Recorder.Eventf(owner.Object, corev1.EventTypeWarning, "FinalizersForceRemoved", "Removed finalizers %v of target %s/%s stuck in deletion since %s", finalizers, item.GetKind(), item.GetName(), deletedAt.UTC().Format(time.RFC3339))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,581 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package targets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/kinds"
+)
+
+// newTestResolver builds a resolver backed by a fake client pre-populated
+// with objs, routing client calls through funcs.
+func newTestResolver(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *Resolver {
+	t.Helper()
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(cleanerv1alpha1.AddToScheme(s))
+	c := fake.NewClientBuilder().
+		WithScheme(s).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(s)).
+		WithObjects(objs...).
+		WithInterceptorFuncs(funcs).
+		Build()
+	return &Resolver{
+		Client:   c,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+func newTestOwner(namespace string) Owner {
+	return Owner{
+		Object:    &cleanerv1alpha1.ConditionalTTL{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: namespace}},
+		Namespace: namespace,
+	}
+}
+
+func newTestPod(namespace, name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+}
+
+func TestResolveLooksUpTheOwnersNamespace(t *testing.T) {
+	r := newTestResolver(t, interceptor.Funcs{},
+		newTestPod("a", "pod", map[string]string{"app": "x"}),
+		newTestPod("b", "pod", map[string]string{"app": "x"}),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+	)
+	testCases := map[string]struct {
+		owner     Owner
+		target    cleanerv1alpha1.Target
+		wantItems int
+		wantErr   bool
+	}{
+		"list in the owner's namespace": {
+			owner: newTestOwner("a"),
+			target: cleanerv1alpha1.Target{Name: "pods", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+			}},
+			wantItems: 1,
+		},
+		"cluster-scoped kind": {
+			owner: newTestOwner("a"),
+			target: cleanerv1alpha1.Target{Name: "namespace", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+				Name:     ptr.To("a"),
+			}},
+		},
+		"cluster-scoped kind when only namespaced kinds are allowed": {
+			owner: func() Owner {
+				o := newTestOwner("a")
+				o.NamespacedOnly = true
+				return o
+			}(),
+			target: cleanerv1alpha1.Target{Name: "namespace", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+				Name:     ptr.To("a"),
+			}},
+			wantErr: true,
+		},
+		"allowed namespace": {
+			owner: func() Owner {
+				o := newTestOwner("a")
+				o.AllowedNamespaces = []string{"a", "b"}
+				return o
+			}(),
+			target: cleanerv1alpha1.Target{Name: "pods", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+			}},
+			wantItems: 1,
+		},
+		"namespace not allowed": {
+			owner: func() Owner {
+				o := newTestOwner("a")
+				o.AllowedNamespaces = []string{"b"}
+				return o
+			}(),
+			target: cleanerv1alpha1.Target{Name: "pods", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+			}},
+			wantErr: true,
+		},
+		"cluster-scoped kind outside the allowed namespaces": {
+			owner: func() Owner {
+				o := newTestOwner("a")
+				o.AllowedNamespaces = []string{"b"}
+				return o
+			}(),
+			target: cleanerv1alpha1.Target{Name: "namespace", Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+				Name:     ptr.To("a"),
+			}},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			ui, err := r.Resolve(context.TODO(), tc.owner, &tc.target)
+			if tc.wantErr {
+				var refErr *InvalidReferenceError
+				if !errors.As(err, &refErr) {
+					t.Fatalf("got err=%v, want an InvalidReferenceError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if ul, ok := ui.(*unstructured.UnstructuredList); ok && len(ul.Items) != tc.wantItems {
+				t.Errorf("got %d items, want %d", len(ul.Items), tc.wantItems)
+			}
+		})
+	}
+}
+
+func TestResolveAllEmptyList(t *testing.T) {
+	r := newTestResolver(t, interceptor.Funcs{}, newTestPod("a", "pod", map[string]string{"app": "x"}))
+	for _, metadataOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("metadataOnly=%v", metadataOnly), func(t *testing.T) {
+			target := cleanerv1alpha1.Target{Name: "pods", MetadataOnly: metadataOnly, Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}},
+			}}
+			ts, err := r.ResolveAll(context.TODO(), newTestOwner("a"), []cleanerv1alpha1.Target{target})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			// as stored on the status
+			b, err := json.Marshal(ts[0].State)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := &unstructured.Unstructured{}
+			if err := json.Unmarshal(b, &state.Object); err != nil {
+				t.Fatal(err)
+			}
+			items, ok := state.Object["items"].([]interface{})
+			if !ok || len(items) != 0 {
+				t.Errorf("got items %#v, want an empty list", state.Object["items"])
+			}
+		})
+	}
+}
+
+func TestResolveRecordsFilteredOnChange(t *testing.T) {
+	annotated := newTestPod("a", "annotated", map[string]string{"app": "x"})
+	annotated.Annotations = map[string]string{"cleanup": "true"}
+	r := newTestResolver(t, interceptor.Funcs{}, annotated, newTestPod("a", "other", map[string]string{"app": "x"}))
+	r.FilteredCounts = &FilteredCounts{}
+	target := &cleanerv1alpha1.Target{Name: "pods", Reference: cleanerv1alpha1.TargetReference{
+		TypeMeta:           metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+		AnnotationSelector: &cleanerv1alpha1.AnnotationSelector{MatchAnnotations: map[string]string{"cleanup": "true"}},
+	}}
+	filteredEvents := func() int {
+		n := 0
+		for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+			if e := <-r.Recorder.(*record.FakeRecorder).Events; strings.HasPrefix(e, "Normal TargetsFiltered ") {
+				n++
+			}
+		}
+		return n
+	}
+
+	for i, want := range []int{1, 0, 0} {
+		if _, err := r.Resolve(context.TODO(), newTestOwner("a"), target); err != nil {
+			t.Fatalf("resolve %d: unexpected error: %s", i, err)
+		}
+		if got := filteredEvents(); got != want {
+			t.Errorf("resolve %d: got %d TargetsFiltered events, want %d", i, got, want)
+		}
+	}
+
+	if err := r.Create(context.TODO(), newTestPod("a", "another", map[string]string{"app": "x"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Resolve(context.TODO(), newTestOwner("a"), target); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := filteredEvents(); got != 1 {
+		t.Errorf("got %d TargetsFiltered events once more objects are filtered out, want 1", got)
+	}
+}
+
+// failingMapper fails every mapping as when discovery is unavailable.
+type failingMapper struct {
+	apimeta.RESTMapper
+}
+
+func (failingMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*apimeta.RESTMapping, error) {
+	return nil, errors.New("discovery failed")
+}
+
+func TestCheckKindsLeavesUncheckedKinds(t *testing.T) {
+	r := newTestResolver(t, interceptor.Funcs{})
+	r.KindChecker = kinds.NewChecker(failingMapper{}, nil)
+	target := cleanerv1alpha1.Target{Name: "pod", Reference: cleanerv1alpha1.TargetReference{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+	}}
+	if err := r.CheckKinds([]cleanerv1alpha1.Target{target}); err != nil {
+		t.Errorf("got err=%v, want kinds which can't be checked to be left to resolving", err)
+	}
+}
+
+func TestDeleteGroupOutsideAllowedNamespaces(t *testing.T) {
+	r := newTestResolver(t, interceptor.Funcs{}, newTestPod("a", "pod", map[string]string{"app": "x"}))
+	owner := newTestOwner("a")
+	owner.AllowedNamespaces = []string{"b"}
+	target := &cleanerv1alpha1.Target{Name: "pods", Delete: true, Reference: cleanerv1alpha1.TargetReference{
+		TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+	}}
+
+	if _, err := r.DeleteGroup(context.TODO(), owner, target); !IsPermanentError(err) {
+		t.Errorf("got err=%v, want a permanent error", err)
+	}
+	if err := r.Get(context.TODO(), client.ObjectKey{Namespace: "a", Name: "pod"}, &corev1.Pod{}); err != nil {
+		t.Errorf("expected the pod outside the allowed namespaces to be kept, got %v", err)
+	}
+}
+
+func TestDeleteAllStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	var deletes atomic.Int32
+	r := newTestResolver(t, interceptor.Funcs{
+		Delete: func(_ context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deletes.Add(1)
+			cancel()
+			return nil
+		},
+	})
+	r.DeleteConcurrency = 1
+	items := make([]unstructured.Unstructured, 10)
+	for i := range items {
+		items[i].SetName(fmt.Sprintf("item-%d", i))
+	}
+
+	err := r.deleteAll(ctx, newTestOwner("default"), &cleanerv1alpha1.Target{}, items)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	// the in-flight delete may be followed by at most one more before
+	// the cancellation is observed
+	if got := deletes.Load(); got > 2 {
+		t.Errorf("got %d deletes after cancellation, want at most 2", got)
+	}
+}
+
+func TestDeleteGroupSkipsProtected(t *testing.T) {
+	testCases := map[string]struct {
+		protectedLabel string
+		podLabels      map[string]string
+		wantDeleted    bool
+	}{
+		"unprotected": {
+			podLabels:   map[string]string{"app": "x"},
+			wantDeleted: true,
+		},
+		"protected": {
+			podLabels: map[string]string{"app": "x", DefaultProtectedLabel: "true"},
+		},
+		"protected by a custom label": {
+			protectedLabel: "example.com/keep",
+			podLabels:      map[string]string{"app": "x", "example.com/keep": "true"},
+		},
+		"default label ignored when customized": {
+			protectedLabel: "example.com/keep",
+			podLabels:      map[string]string{"app": "x", DefaultProtectedLabel: "true"},
+			wantDeleted:    true,
+		},
+		"label not set to true": {
+			podLabels:   map[string]string{"app": "x", DefaultProtectedLabel: "false"},
+			wantDeleted: true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			r := newTestResolver(t, interceptor.Funcs{},
+				newTestPod("default", "pod", tc.podLabels),
+				newTestPod("default", "other", map[string]string{"app": "x"}),
+			)
+			r.ProtectedLabel = tc.protectedLabel
+			target := &cleanerv1alpha1.Target{Name: "pods", Delete: true, Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+			}}
+
+			remaining, err := r.DeleteGroup(context.TODO(), newTestOwner("default"), target)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			// protected objects are left in place without being waited for
+			if remaining != 0 {
+				t.Errorf("got %d remaining objects, want 0", remaining)
+			}
+			err = r.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "pod"}, &corev1.Pod{})
+			if deleted := apierrors.IsNotFound(err); deleted != tc.wantDeleted {
+				t.Errorf("got deleted=%v, want %v (err=%v)", deleted, tc.wantDeleted, err)
+			}
+			err = r.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "other"}, &corev1.Pod{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("expected the unprotected pod to be deleted, got err=%v", err)
+			}
+			skipped := 0
+			for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+				if e := <-r.Recorder.(*record.FakeRecorder).Events; strings.HasPrefix(e, "Normal SkippedProtected ") {
+					skipped++
+				}
+			}
+			if want := map[bool]int{true: 0, false: 1}[tc.wantDeleted]; skipped != want {
+				t.Errorf("got %d SkippedProtected events, want %d", skipped, want)
+			}
+		})
+	}
+}
+
+func TestDeleteGroupDeletionMode(t *testing.T) {
+	testCases := map[string]struct {
+		mode          cleanerv1alpha1.DeletionMode
+		wantRemaining int
+		wantPolicy    *metav1.DeletionPropagation
+	}{
+		"verify": {
+			mode:          cleanerv1alpha1.DeletionModeVerify,
+			wantRemaining: 2,
+		},
+		"default": {
+			wantRemaining: 2,
+		},
+		"fire and forget": {
+			mode:       cleanerv1alpha1.DeletionModeFireAndForget,
+			wantPolicy: ptr.To(metav1.DeletePropagationBackground),
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			var policies []*metav1.DeletionPropagation
+			var lists atomic.Int32
+			funcs := interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					o := &client.DeleteOptions{}
+					o.ApplyOptions(opts)
+					policies = append(policies, o.PropagationPolicy)
+					return c.Delete(ctx, obj, opts...)
+				},
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					lists.Add(1)
+					return c.List(ctx, list, opts...)
+				},
+			}
+			// the finalizers keep the pods around once deleted
+			pod := func(name string) *corev1.Pod {
+				p := newTestPod("default", name, map[string]string{"app": "x"})
+				p.Finalizers = []string{"example.com/wait"}
+				return p
+			}
+			r := newTestResolver(t, funcs, pod("a"), pod("b"))
+			r.DeleteConcurrency = 1
+			target := &cleanerv1alpha1.Target{Name: "pods", Delete: true, DeletionMode: tc.mode, Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+			}}
+
+			remaining, err := r.DeleteGroup(context.TODO(), newTestOwner("default"), target)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if remaining != tc.wantRemaining {
+				t.Errorf("got %d remaining objects, want %d", remaining, tc.wantRemaining)
+			}
+			// the group is only listed again to verify its deletion
+			wantLists := int32(2)
+			if tc.mode == cleanerv1alpha1.DeletionModeFireAndForget {
+				wantLists = 1
+			}
+			if got := lists.Load(); got != wantLists {
+				t.Errorf("got %d lists, want %d", got, wantLists)
+			}
+			if len(policies) != 2 {
+				t.Fatalf("got %d deletes, want 2", len(policies))
+			}
+			for _, p := range policies {
+				if (p == nil) != (tc.wantPolicy == nil) || p != nil && *p != *tc.wantPolicy {
+					t.Errorf("got propagation policy %v, want %v", p, tc.wantPolicy)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteGroupSkipsObjectsBeingDeleted(t *testing.T) {
+	var deletes atomic.Int32
+	funcs := interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deletes.Add(1)
+			return c.Delete(ctx, obj, opts...)
+		},
+	}
+	// the finalizers keep the pods around once deleted
+	pod := func(name string) *corev1.Pod {
+		p := newTestPod("default", name, map[string]string{"app": "x"})
+		p.Finalizers = []string{"example.com/wait"}
+		return p
+	}
+	r := newTestResolver(t, funcs, pod("a"), pod("b"))
+	target := &cleanerv1alpha1.Target{Name: "pods", Delete: true, Reference: cleanerv1alpha1.TargetReference{
+		TypeMeta:      metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "x"}},
+	}}
+
+	for i, wantDeletes := range []int32{2, 2} {
+		remaining, err := r.DeleteGroup(context.TODO(), newTestOwner("default"), target)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// pods being deleted are still waited for
+		if remaining != 2 {
+			t.Errorf("attempt %d: got %d remaining objects, want 2", i, remaining)
+		}
+		if got := deletes.Load(); got != wantDeletes {
+			t.Errorf("attempt %d: got %d deletes, want %d", i, got, wantDeletes)
+		}
+	}
+	deleted := 0
+	for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+		if e := <-r.Recorder.(*record.FakeRecorder).Events; strings.HasPrefix(e, "Normal TargetDeleted ") {
+			deleted++
+		}
+	}
+	if deleted != 2 {
+		t.Errorf("got %d TargetDeleted events, want 2", deleted)
+	}
+}
+
+func TestDeleteGroupShortensGracePeriod(t *testing.T) {
+	testCases := map[string]struct {
+		gracePeriod *int64
+		wantDeletes int32
+	}{
+		"no grace period":     {},
+		"same grace period":   {gracePeriod: ptr.To[int64](30)},
+		"longer grace period": {gracePeriod: ptr.To[int64](60)},
+		"forced":              {gracePeriod: ptr.To[int64](0), wantDeletes: 1},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			var deletes atomic.Int32
+			funcs := interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					deletes.Add(1)
+					return c.Delete(ctx, obj, opts...)
+				},
+			}
+			// already being deleted with a 30s grace period
+			pod := newTestPod("default", "a", nil)
+			pod.Finalizers = []string{"example.com/wait"}
+			pod.DeletionTimestamp = ptr.To(metav1.Now())
+			pod.DeletionGracePeriodSeconds = ptr.To[int64](30)
+			r := newTestResolver(t, funcs, pod)
+			target := &cleanerv1alpha1.Target{Name: "pod", Delete: true, GracePeriodSeconds: tc.gracePeriod, Reference: cleanerv1alpha1.TargetReference{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				Name:     ptr.To("a"),
+			}}
+
+			if _, err := r.DeleteGroup(context.TODO(), newTestOwner("default"), target); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := deletes.Load(); got != tc.wantDeletes {
+				t.Errorf("got %d deletes, want %d", got, tc.wantDeletes)
+			}
+		})
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	notFound := apierrors.NewNotFound(corev1.Resource("pods"), "pod")
+	testCases := map[string]struct {
+		err           error
+		wantReason    string
+		wantPermanent bool
+	}{
+		"not found": {
+			err:        notFound,
+			wantReason: cleanerv1alpha1.ConditionReasonTargetResolveError,
+		},
+		"unavailable": {
+			err:        apierrors.NewServiceUnavailable("unavailable"),
+			wantReason: cleanerv1alpha1.ConditionReasonTargetResolveError,
+		},
+		"forbidden": {
+			err:           fmt.Errorf("Error resolving target %q: %w", "pod", apierrors.NewForbidden(corev1.Resource("pods"), "pod", errors.New("denied"))),
+			wantReason:    cleanerv1alpha1.ConditionReasonTargetForbidden,
+			wantPermanent: true,
+		},
+		"unknown kind": {
+			err:           &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "example.com", Kind: "Widget"}},
+			wantReason:    cleanerv1alpha1.ConditionReasonUnknownKind,
+			wantPermanent: true,
+		},
+		"invalid reference": {
+			err:           &InvalidReferenceError{errors.New("invalid selector")},
+			wantReason:    cleanerv1alpha1.ConditionReasonInvalidTargetReference,
+			wantPermanent: true,
+		},
+		"joined with a permanent error": {
+			err:           errors.Join(notFound, &InvalidReferenceError{errors.New("invalid selector")}),
+			wantReason:    cleanerv1alpha1.ConditionReasonInvalidTargetReference,
+			wantPermanent: true,
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			reason, permanent := ErrorReason(tc.err)
+			if reason != tc.wantReason || permanent != tc.wantPermanent {
+				t.Errorf("got %s, permanent=%v, want %s, permanent=%v", reason, permanent, tc.wantReason, tc.wantPermanent)
+			}
+		})
+	}
+}
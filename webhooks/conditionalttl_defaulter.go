@@ -0,0 +1,57 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/custom_cel"
+)
+
+//+kubebuilder:webhook:path=/mutate-cleaner-vtex-io-v1alpha1-conditionalttl,mutating=true,failurePolicy=fail,sideEffects=None,groups=cleaner.vtex.io,resources=conditionalttls,verbs=create;update,versions=v1alpha1,name=mconditionalttl.kb.io,admissionReviewVersions=v1
+
+// ConditionalTTLDefaulter defaults the includeWhenEvaluating of targets
+// referenced by the conditions to true, unless it was set explicitly.
+type ConditionalTTLDefaulter struct{}
+
+// Default implements webhook.CustomDefaulter.
+func (d *ConditionalTTLDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	cTTL, ok := obj.(*cleanerv1alpha1.ConditionalTTL)
+	if !ok {
+		return fmt.Errorf("expected a ConditionalTTL but got a %T", obj)
+	}
+	referenced := map[string]bool{}
+	for _, err := range custom_cel.CheckReferences(cTTL) {
+		var refErr *custom_cel.ReferenceError
+		if errors.As(err, &refErr) && refErr.Excluded {
+			referenced[refErr.Name] = true
+		}
+	}
+	for i := range cTTL.Spec.Targets {
+		t := &cTTL.Spec.Targets[i]
+		if referenced[t.Name] && t.IncludeWhenEvaluating == nil {
+			t.IncludeWhenEvaluating = ptr.To(true)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+)
+
+func Test_defaultIncludeWhenEvaluating(t *testing.T) {
+	testCases := map[string]struct {
+		targets     string
+		conditions  string
+		wantInclude map[string]bool
+	}{
+		"referenced target": {
+			targets:     `[{"name": "pod", "delete": true}, {"name": "svc", "delete": true}]`,
+			conditions:  `["pod.status.phase == 'Succeeded'"]`,
+			wantInclude: map[string]bool{"pod": true, "svc": false},
+		},
+		"referenced within a macro": {
+			targets:     `[{"name": "pods", "delete": true}]`,
+			conditions:  `["pods.items.all(p, p.status.phase == 'Succeeded')"]`,
+			wantInclude: map[string]bool{"pods": true},
+		},
+		"explicitly excluded": {
+			targets:     `[{"name": "pod", "delete": true, "includeWhenEvaluating": false}]`,
+			conditions:  `["pod.status.phase == 'Succeeded'"]`,
+			wantInclude: map[string]bool{"pod": false},
+		},
+		"explicitly included": {
+			targets:     `[{"name": "pod", "delete": true, "includeWhenEvaluating": true}]`,
+			conditions:  `["true"]`,
+			wantInclude: map[string]bool{"pod": true},
+		},
+		"shadowed by a macro variable": {
+			targets:     `[{"name": "pod", "delete": true}, {"name": "pods", "delete": true}]`,
+			conditions:  `["pods.items.all(pod, pod.status.phase == 'Succeeded')"]`,
+			wantInclude: map[string]bool{"pod": false, "pods": true},
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			raw := []byte(`{"apiVersion": "cleaner.vtex.io/v1alpha1", "kind": "ConditionalTTL", "metadata": {"name": "test", "namespace": "default"}, "spec": {"targets": ` + tc.targets + `, "conditions": ` + tc.conditions + `}}`)
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			if err := json.Unmarshal(raw, cTTL); err != nil {
+				t.Fatal(err)
+			}
+			if err := (&ConditionalTTLDefaulter{}).Default(context.Background(), cTTL); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := included(cTTL); !reflect.DeepEqual(got, tc.wantInclude) {
+				t.Errorf("got includeWhenEvaluating %v, want %v", got, tc.wantInclude)
+			}
+		})
+	}
+}
+
+func included(cTTL *cleanerv1alpha1.ConditionalTTL) map[string]bool {
+	got := map[string]bool{}
+	for _, target := range cTTL.Spec.Targets {
+		got[target.Name] = target.IncludedWhenEvaluating()
+	}
+	return got
+}
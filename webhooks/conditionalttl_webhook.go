@@ -0,0 +1,387 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/custom_cel"
+	"github.com/vtex/cleaner-controller/kinds"
+)
+
+//+kubebuilder:webhook:path=/validate-cleaner-vtex-io-v1alpha1-conditionalttl,mutating=false,failurePolicy=fail,sideEffects=None,groups=cleaner.vtex.io,resources=conditionalttls,verbs=create;update,versions=v1alpha1,name=vconditionalttl.kb.io,admissionReviewVersions=v1
+
+// ConditionalTTLValidator validates ConditionalTTL objects on creation and update.
+type ConditionalTTLValidator struct {
+	// Kinds, when set, rejects targets whose kind isn't served by the API
+	// server.
+	Kinds *kinds.Checker
+
+	// AllowCrossNamespaceHelm mirrors the controller's flag, warning about
+	// Helm releases in other namespaces which wouldn't be uninstalled.
+	AllowCrossNamespaceHelm bool
+}
+
+// SetupWebhookWithManager registers the validating webhook, along with the
+// defaulting one, with the Manager.
+func (v *ConditionalTTLValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&cleanerv1alpha1.ConditionalTTL{}).
+		WithDefaulter(&ConditionalTTLDefaulter{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *ConditionalTTLValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *ConditionalTTLValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *ConditionalTTLValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ConditionalTTLValidator) validate(obj runtime.Object) (admission.Warnings, error) {
+	cTTL, ok := obj.(*cleanerv1alpha1.ConditionalTTL)
+	if !ok {
+		return nil, fmt.Errorf("expected a ConditionalTTL but got a %T", obj)
+	}
+	reserved := custom_cel.ReservedNames(cTTL)
+	errs := validateTargets(cTTL.Spec.Targets, reserved, field.NewPath("spec", "targets"))
+	if v.Kinds != nil {
+		errs = append(errs, validateTargetKinds(v.Kinds, cTTL.Spec.Targets, field.NewPath("spec", "targets"))...)
+	}
+	errs = append(errs, validateExtraContext(cTTL.Spec.ExtraContext, cTTL.Spec.Targets, reserved, field.NewPath("spec", "extraContext"))...)
+	errs = append(errs, validateConditionReferences(cTTL, field.NewPath("spec"))...)
+	helmErrs, warnings := v.validateHelmReleases(cTTL, field.NewPath("spec"))
+	errs = append(errs, helmErrs...)
+	errs = append(errs, validateAllowedNamespaces(cTTL, field.NewPath("spec", "allowedNamespaces"))...)
+	errs = append(errs, validateCloudEvent(cTTL.Spec.CloudEvent, field.NewPath("spec", "cloudEvent"))...)
+	errs = append(errs, validateCloudEventSinkRef(cTTL, field.NewPath("spec", "cloudEventSinkRef"))...)
+	if w := cTTL.Spec.DeletionWindow; w != nil {
+		if err := w.Validate(); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "deletionWindow"), w, err.Error()))
+		}
+	}
+	if len(errs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(
+		cleanerv1alpha1.GroupVersion.WithKind("ConditionalTTL").GroupKind(),
+		cTTL.GetName(),
+		errs,
+	)
+}
+
+// validateAllowedNamespaces checks the names of the allowed namespaces,
+// which must include the ConditionalTTL's own as its targets are looked up
+// in it.
+func validateAllowedNamespaces(cTTL *cleanerv1alpha1.ConditionalTTL, path *field.Path) field.ErrorList {
+	allowed := cTTL.Spec.AllowedNamespaces
+	if len(allowed) == 0 {
+		return nil
+	}
+	var errs field.ErrorList
+	for i, ns := range allowed {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(path.Index(i), ns, msg))
+		}
+	}
+	if len(cTTL.Spec.Targets) > 0 && !slices.Contains(allowed, cTTL.GetNamespace()) {
+		errs = append(errs, field.Invalid(path, allowed, fmt.Sprintf("must include the ConditionalTTL's namespace %q, which its targets are looked up in", cTTL.GetNamespace())))
+	}
+	return errs
+}
+
+// cloudEventExtensionName matches the names CloudEvents allows for
+// attributes, keeping to the recommended length.
+var cloudEventExtensionName = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// cloudEventAttributes are the attributes of the CloudEvents spec, which
+// extensions can't replace.
+var cloudEventAttributes = []string{"specversion", "id", "source", "type", "subject", "time", "datacontenttype", "dataschema", "data"}
+
+// httpHeaderName matches the names HTTP allows for headers.
+var httpHeaderName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// maxCloudEventBackoff bounds the retry backoff of a CloudEvent, whose
+// retries block the reconcile.
+const maxCloudEventBackoff = 30 * time.Second
+
+// validateCloudEvent rejects extension attributes whose names break the
+// CloudEvents naming rules or clash with the event's other attributes.
+func validateCloudEvent(c *cleanerv1alpha1.CloudEventConfig, path *field.Path) field.ErrorList {
+	if c == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	names := make([]string, 0, len(c.Extensions))
+	for name := range c.Extensions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		p := path.Child("extensions").Key(name)
+		switch {
+		case !cloudEventExtensionName.MatchString(name):
+			errs = append(errs, field.Invalid(p, name, "must consist of at most 20 lower-case letters and digits"))
+		case slices.Contains(cloudEventAttributes, name):
+			errs = append(errs, field.Invalid(p, name, "name is reserved"))
+		}
+	}
+	if h := c.HeadersFrom; h != nil {
+		names := make([]string, 0, len(h.Headers))
+		for name := range h.Headers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			p := path.Child("headersFrom", "headers").Key(name)
+			lower := strings.ToLower(name)
+			switch {
+			case !httpHeaderName.MatchString(name):
+				errs = append(errs, field.Invalid(p, name, "must be a valid HTTP header name"))
+			case strings.HasPrefix(lower, "ce-") || lower == "content-type":
+				errs = append(errs, field.Invalid(p, name, "header is set by the cloud event"))
+			}
+		}
+	}
+	if t := c.TLS; t != nil && t.CA != nil {
+		p := path.Child("tls", "ca")
+		if (t.CA.ConfigMap == "") == (t.CA.Secret == "") {
+			errs = append(errs, field.Invalid(p, t.CA, "exactly one of configMap and secret must be set"))
+		}
+		if t.InsecureSkipVerify {
+			errs = append(errs, field.Invalid(p, t.CA, "the CA bundle isn't used when insecureSkipVerify is set"))
+		}
+	}
+	if r := c.Retry; r != nil && r.Backoff != nil && r.Backoff.Duration > maxCloudEventBackoff {
+		errs = append(errs, field.Invalid(path.Child("retry", "backoff"), r.Backoff.Duration.String(), "must be at most "+maxCloudEventBackoff.String()))
+	}
+	return errs
+}
+
+// validateCloudEventSinkRef checks that the sink reference isn't set along
+// with a sink address and that its namespace is allowed.
+func validateCloudEventSinkRef(cTTL *cleanerv1alpha1.ConditionalTTL, path *field.Path) field.ErrorList {
+	ref := cTTL.Spec.CloudEventSinkRef
+	if ref == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if cTTL.Spec.CloudEventSink != nil {
+		errs = append(errs, field.Invalid(path, ref.Name, "cloudEventSink and cloudEventSinkRef are mutually exclusive"))
+	}
+	if ns := ref.Namespace; ns != "" && ns != cTTL.GetNamespace() && !slices.Contains(cTTL.Spec.AllowedNamespaces, ns) {
+		errs = append(errs, field.Invalid(path.Child("namespace"), ns, "must be the ConditionalTTL's namespace or one of allowedNamespaces"))
+	}
+	if gvk, err := ref.GroupVersionKind(); err != nil {
+		errs = append(errs, field.Invalid(path.Child("apiVersion"), ref.APIVersion, err.Error()))
+	} else if !slices.Contains(cleanerv1alpha1.SinkKinds, gvk.GroupKind()) {
+		supported := make([]string, 0, len(cleanerv1alpha1.SinkKinds))
+		for _, gk := range cleanerv1alpha1.SinkKinds {
+			supported = append(supported, gk.String())
+		}
+		errs = append(errs, field.NotSupported(path.Child("kind"), gvk.GroupKind().String(), supported))
+	}
+	return errs
+}
+
+func validateTargets(targets []cleanerv1alpha1.Target, reserved []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, t := range targets {
+		if slices.Contains(reserved, t.Name) {
+			errs = append(errs, field.Invalid(path.Index(i).Child("name"), t.Name, "name is reserved"))
+		}
+		if t.DeletionMode == cleanerv1alpha1.DeletionModeFireAndForget && t.ForceRemoveFinalizers {
+			errs = append(errs, field.Invalid(path.Index(i).Child("forceRemoveFinalizers"), true, "objects deleted with deletionMode FireAndForget aren't waited for"))
+		}
+	}
+	return errs
+}
+
+// validateTargetKinds rejects targets whose kind isn't served, or is served
+// by more than one group when their apiVersion is omitted. Kinds which
+// can't be checked, e.g. as discovery is unavailable, or which may have
+// been installed since the checker last refreshed its mapping, are left to
+// be reported when reconciling.
+func validateTargetKinds(checker *kinds.Checker, targets []cleanerv1alpha1.Target, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, t := range targets {
+		err := checker.Check(t.Reference.GroupVersionKind())
+		p := path.Index(i).Child("reference")
+		var ambiguous *kinds.AmbiguousKindError
+		if errors.As(err, &ambiguous) {
+			errs = append(errs, field.Invalid(p.Child("kind"), t.Reference.Kind, ambiguous.Error()))
+			continue
+		}
+		var unknown *kinds.UnknownKindError
+		if !errors.As(err, &unknown) || unknown.RetryAfter > 0 {
+			continue
+		}
+		if unknown.UnknownVersion {
+			errs = append(errs, field.Invalid(p.Child("apiVersion"), t.Reference.APIVersion, unknown.Error()))
+			continue
+		}
+		errs = append(errs, field.Invalid(p.Child("kind"), t.Reference.Kind, unknown.Error()))
+	}
+	return errs
+}
+
+func validateExtraContext(values []cleanerv1alpha1.ContextValue, targets []cleanerv1alpha1.Target, reserved []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for _, t := range targets {
+		names[t.Name] = true
+	}
+	for i, v := range values {
+		p := path.Index(i)
+		switch {
+		case slices.Contains(reserved, v.Name):
+			errs = append(errs, field.Invalid(p.Child("name"), v.Name, "name is reserved"))
+		case names[v.Name]:
+			errs = append(errs, field.Duplicate(p.Child("name"), v.Name))
+		}
+		names[v.Name] = true
+		if (v.ConfigMapKeyRef == nil) == (v.SecretKeyRef == nil) {
+			errs = append(errs, field.Invalid(p, v.Name, "exactly one of configMapKeyRef or secretKeyRef must be set"))
+		}
+	}
+	return errs
+}
+
+// validateHelmReleases checks the release names, namespaces, targets and
+// dry runs of spec.helm and spec.helmReleases. Releases in other namespaces
+// are rejected unless listed in allowedNamespaces, when set, and warned
+// about unless the controller allows them.
+func (v *ConditionalTTLValidator) validateHelmReleases(cTTL *cleanerv1alpha1.ConditionalTTL, path *field.Path) (field.ErrorList, admission.Warnings) {
+	var errs field.ErrorList
+	var warnings admission.Warnings
+	check := func(helm cleanerv1alpha1.HelmConfig, p *field.Path) {
+		if helm.DryRun && !helm.Delete {
+			errs = append(errs, field.Invalid(p.Child("dryRun"), true, "dryRun requires delete"))
+		}
+		switch {
+		case helm.FromTarget != "":
+			if helm.Release != "" {
+				errs = append(errs, field.Invalid(p.Child("fromTarget"), helm.FromTarget, "release and fromTarget are mutually exclusive"))
+			}
+			if !slices.ContainsFunc(cTTL.Spec.Targets, func(t cleanerv1alpha1.Target) bool { return t.Name == helm.FromTarget }) {
+				errs = append(errs, field.NotFound(p.Child("fromTarget"), helm.FromTarget))
+			}
+			return
+		case helm.Release == "":
+			errs = append(errs, field.Required(p.Child("release"), "one of release or fromTarget is required"))
+		}
+		if helm.Namespace == "" || helm.Namespace == cTTL.GetNamespace() {
+			return
+		}
+		for _, msg := range validation.IsDNS1123Label(helm.Namespace) {
+			errs = append(errs, field.Invalid(p.Child("namespace"), helm.Namespace, msg))
+		}
+		if allowed := cTTL.Spec.AllowedNamespaces; len(allowed) > 0 && !slices.Contains(allowed, helm.Namespace) {
+			errs = append(errs, field.Invalid(p.Child("namespace"), helm.Namespace, "must be the ConditionalTTL's namespace or one of allowedNamespaces"))
+			return
+		}
+		if helm.Delete && !v.AllowCrossNamespaceHelm {
+			warnings = append(warnings, fmt.Sprintf("%s: release %q in namespace %q won't be uninstalled unless the controller runs with --allow-cross-namespace-helm", p.Child("namespace"), helm.Release, helm.Namespace))
+		}
+	}
+	if helm := cTTL.Spec.Helm; helm != nil {
+		check(*helm, path.Child("helm"))
+		if helm.IncludeWhenEvaluating {
+			errs = append(errs, validateHelmReleaseVariable(cTTL, path)...)
+		}
+		if helm.CompleteWhenNotFound {
+			p := path.Child("helm", "completeWhenNotFound")
+			switch {
+			case !helm.IncludeWhenEvaluating:
+				errs = append(errs, field.Invalid(p, true, "requires includeWhenEvaluating"))
+			case helm.OptionalWhenEvaluating:
+				errs = append(errs, field.Invalid(p, true, "can't be combined with optionalWhenEvaluating"))
+			}
+		}
+	}
+	for i, helm := range cTTL.Spec.HelmReleases {
+		check(helm, path.Child("helmReleases").Index(i))
+		if helm.IncludeWhenEvaluating {
+			errs = append(errs, field.Invalid(path.Child("helmReleases").Index(i).Child("includeWhenEvaluating"), true, "only spec.helm may be included when evaluating"))
+		}
+		if helm.CompleteWhenNotFound {
+			errs = append(errs, field.Invalid(path.Child("helmReleases").Index(i).Child("completeWhenNotFound"), true, "only spec.helm may be included when evaluating"))
+		}
+	}
+	return errs, warnings
+}
+
+// validateHelmReleaseVariable checks that spec.helm can be exposed to the
+// conditions as the helmRelease variable.
+func validateHelmReleaseVariable(cTTL *cleanerv1alpha1.ConditionalTTL, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if cTTL.Spec.Helm.FromTarget != "" {
+		errs = append(errs, field.Invalid(path.Child("helm", "includeWhenEvaluating"), true, "release must be set to include it when evaluating"))
+	}
+	for i, t := range cTTL.Spec.Targets {
+		if t.Name == custom_cel.HelmReleaseVariable {
+			errs = append(errs, field.Invalid(path.Child("targets").Index(i).Child("name"), t.Name, "name is reserved when the Helm release is included when evaluating"))
+		}
+	}
+	for i, v := range cTTL.Spec.ExtraContext {
+		if v.Name == custom_cel.HelmReleaseVariable {
+			errs = append(errs, field.Invalid(path.Child("extraContext").Index(i).Child("name"), v.Name, "name is reserved when the Helm release is included when evaluating"))
+		}
+	}
+	return errs
+}
+
+func validateConditionReferences(cTTL *cleanerv1alpha1.ConditionalTTL, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, err := range custom_cel.CheckReferences(cTTL) {
+		var refErr *custom_cel.ReferenceError
+		if !errors.As(err, &refErr) {
+			errs = append(errs, field.InternalError(path, err))
+			continue
+		}
+		if i := refErr.Condition; i < len(cTTL.Spec.Conditions) {
+			errs = append(errs, field.Invalid(path.Child("conditions").Index(i), cTTL.Spec.Conditions[i], refErr.Error()))
+		} else {
+			i -= len(cTTL.Spec.Conditions)
+			errs = append(errs, field.Invalid(path.Child("namedConditions").Index(i).Child("expression"), cTTL.Spec.NamedConditions[i].Expression, refErr.Error()))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,588 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+
+	cleanerv1alpha1 "github.com/vtex/cleaner-controller/api/v1alpha1"
+	"github.com/vtex/cleaner-controller/kinds"
+)
+
+func Test_validateReservedTargetNames(t *testing.T) {
+	testCases := map[string]struct {
+		names        []string
+		historyLimit int32
+		keepPrevious bool
+		wantErr      bool
+	}{
+		"no targets":                    {names: nil},
+		"regular names":                 {names: []string{"pods", "deployment"}},
+		"names starting with time":      {names: []string{"times", "timeout"}},
+		"reserved name":                 {names: []string{"pods", "time"}, wantErr: true},
+		"history and previous not kept": {names: []string{"history", "previous"}},
+		"history kept":                  {names: []string{"history"}, historyLimit: 5, wantErr: true},
+		"previous kept":                 {names: []string{"previous"}, keepPrevious: true, wantErr: true},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.HistoryLimit = tc.historyLimit
+			cTTL.Spec.KeepPreviousState = tc.keepPrevious
+			for _, n := range tc.names {
+				cTTL.Spec.Targets = append(cTTL.Spec.Targets, cleanerv1alpha1.Target{Name: n})
+			}
+			_, createErr := v.ValidateCreate(context.Background(), cTTL)
+			_, updateErr := v.ValidateUpdate(context.Background(), cTTL.DeepCopy(), cTTL)
+			for _, err := range []error{createErr, updateErr} {
+				if tc.wantErr != (err != nil) {
+					t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+				}
+				if err != nil && !apierrors.IsInvalid(err) {
+					t.Errorf("expected an Invalid error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_validateAllowedNamespaces(t *testing.T) {
+	testCases := map[string]struct {
+		allowed     []string
+		wantMessage string
+	}{
+		"default": {},
+		"own and other namespace": {
+			allowed: []string{"default", "previews"},
+		},
+		"invalid name": {
+			allowed:     []string{"default", "Previews"},
+			wantMessage: "spec.allowedNamespaces[1]: Invalid value",
+		},
+		"without own namespace": {
+			allowed:     []string{"previews"},
+			wantMessage: `must include the ConditionalTTL's namespace "default"`,
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.SetNamespace("default")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{{Name: "pods"}}
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_validateCloudEvent(t *testing.T) {
+	testCases := map[string]struct {
+		extensions  map[string]string
+		headers     map[string]string
+		tls         *cleanerv1alpha1.CloudEventTLS
+		retry       *cleanerv1alpha1.CloudEventRetry
+		wantMessage string
+	}{
+		"valid extensions": {
+			extensions: map[string]string{"team": "payments", "partitionkey": "store1"},
+		},
+		"upper-case name": {
+			extensions:  map[string]string{"teamName": "payments"},
+			wantMessage: "spec.cloudEvent.extensions[teamName]: Invalid value",
+		},
+		"name too long": {
+			extensions:  map[string]string{"averyveryverylongname": "x"},
+			wantMessage: "spec.cloudEvent.extensions[averyveryverylongname]: Invalid value",
+		},
+		"reserved name": {
+			extensions:  map[string]string{"subject": "x"},
+			wantMessage: "name is reserved",
+		},
+		"valid headers": {
+			headers: map[string]string{"Authorization": "token", "X-Api-Key": "key"},
+		},
+		"invalid header name": {
+			headers:     map[string]string{"Api Key": "key"},
+			wantMessage: "spec.cloudEvent.headersFrom.headers[Api Key]: Invalid value",
+		},
+		"cloud event header": {
+			headers:     map[string]string{"Ce-Type": "type"},
+			wantMessage: "header is set by the cloud event",
+		},
+		"CA bundle from a ConfigMap": {
+			tls: &cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca"}, ClientCertificateSecret: "sink-client"},
+		},
+		"CA bundle from both a ConfigMap and a Secret": {
+			tls:         &cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{ConfigMap: "sink-ca", Secret: "sink-ca"}},
+			wantMessage: "spec.cloudEvent.tls.ca: Invalid value",
+		},
+		"CA bundle from neither": {
+			tls:         &cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{Key: "ca.pem"}},
+			wantMessage: "exactly one of configMap and secret must be set",
+		},
+		"CA bundle skipping verification": {
+			tls:         &cleanerv1alpha1.CloudEventTLS{CA: &cleanerv1alpha1.CABundle{Secret: "sink-ca"}, InsecureSkipVerify: true},
+			wantMessage: "isn't used when insecureSkipVerify is set",
+		},
+		"retry backoff": {
+			retry: &cleanerv1alpha1.CloudEventRetry{Attempts: 3, Backoff: &metav1.Duration{Duration: 30 * time.Second}},
+		},
+		"retry backoff too long": {
+			retry:       &cleanerv1alpha1.CloudEventRetry{Attempts: 3, Backoff: &metav1.Duration{Duration: time.Minute}},
+			wantMessage: "spec.cloudEvent.retry.backoff: Invalid value",
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.CloudEvent = &cleanerv1alpha1.CloudEventConfig{Type: "team.deleted", Extensions: tc.extensions}
+			if tc.headers != nil {
+				cTTL.Spec.CloudEvent.HeadersFrom = &cleanerv1alpha1.SecretHeaders{Name: "sink-auth", Headers: tc.headers}
+			}
+			cTTL.Spec.CloudEvent.TLS = tc.tls
+			cTTL.Spec.CloudEvent.Retry = tc.retry
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_validateCloudEventSinkRef(t *testing.T) {
+	testCases := map[string]struct {
+		sink        *string
+		ref         *cleanerv1alpha1.SinkReference
+		allowed     []string
+		wantMessage string
+	}{
+		"service": {
+			ref: &cleanerv1alpha1.SinkReference{Name: "events"},
+		},
+		"sink and reference": {
+			sink:        ptr.To("http://events.example.com"),
+			ref:         &cleanerv1alpha1.SinkReference{Name: "events"},
+			wantMessage: "cloudEventSink and cloudEventSinkRef are mutually exclusive",
+		},
+		"other namespace": {
+			ref:         &cleanerv1alpha1.SinkReference{Name: "events", Namespace: "eventing"},
+			wantMessage: `spec.cloudEventSinkRef.namespace: Invalid value: "eventing"`,
+		},
+		"allowed namespace": {
+			ref:     &cleanerv1alpha1.SinkReference{Name: "events", Namespace: "eventing"},
+			allowed: []string{"default", "eventing"},
+		},
+		"knative broker": {
+			ref: &cleanerv1alpha1.SinkReference{APIVersion: "eventing.knative.dev/v1", Kind: "Broker", Name: "default"},
+		},
+		"unsupported kind": {
+			ref:         &cleanerv1alpha1.SinkReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "events"},
+			wantMessage: `spec.cloudEventSinkRef.kind: Unsupported value: "Deployment.apps"`,
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.SetNamespace("default")
+			cTTL.Spec.CloudEventSink = tc.sink
+			cTTL.Spec.CloudEventSinkRef = tc.ref
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_validateDeletionMode(t *testing.T) {
+	testCases := map[string]struct {
+		target      cleanerv1alpha1.Target
+		wantMessage string
+	}{
+		"verify with forced finalizer removal": {
+			target: cleanerv1alpha1.Target{Name: "pods", DeletionMode: cleanerv1alpha1.DeletionModeVerify, ForceRemoveFinalizers: true},
+		},
+		"fire and forget": {
+			target: cleanerv1alpha1.Target{Name: "pods", DeletionMode: cleanerv1alpha1.DeletionModeFireAndForget},
+		},
+		"fire and forget with forced finalizer removal": {
+			target:      cleanerv1alpha1.Target{Name: "pods", DeletionMode: cleanerv1alpha1.DeletionModeFireAndForget, ForceRemoveFinalizers: true},
+			wantMessage: "spec.targets[0].forceRemoveFinalizers: Invalid value: true",
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{tc.target}
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_validateExtraContext(t *testing.T) {
+	ref := &cleanerv1alpha1.KeySelector{Name: "flags", Key: "cleanup"}
+	testCases := map[string]struct {
+		values  []cleanerv1alpha1.ContextValue
+		wantErr bool
+	}{
+		"configmap": {values: []cleanerv1alpha1.ContextValue{{Name: "cleanup", ConfigMapKeyRef: ref}}},
+		"secret":    {values: []cleanerv1alpha1.ContextValue{{Name: "token", SecretKeyRef: ref}}},
+		"reserved name": {
+			values:  []cleanerv1alpha1.ContextValue{{Name: "time", ConfigMapKeyRef: ref}},
+			wantErr: true,
+		},
+		"clashes with a target": {
+			values:  []cleanerv1alpha1.ContextValue{{Name: "pods", ConfigMapKeyRef: ref}},
+			wantErr: true,
+		},
+		"duplicated": {
+			values:  []cleanerv1alpha1.ContextValue{{Name: "a", ConfigMapKeyRef: ref}, {Name: "a", SecretKeyRef: ref}},
+			wantErr: true,
+		},
+		"no reference": {
+			values:  []cleanerv1alpha1.ContextValue{{Name: "a"}},
+			wantErr: true,
+		},
+		"both references": {
+			values:  []cleanerv1alpha1.ContextValue{{Name: "a", ConfigMapKeyRef: ref, SecretKeyRef: ref}},
+			wantErr: true,
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{{Name: "pods"}}
+			cTTL.Spec.ExtraContext = tc.values
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateDeletionWindow(t *testing.T) {
+	testCases := map[string]struct {
+		window  *cleanerv1alpha1.DeletionWindow
+		wantErr bool
+	}{
+		"no window": {},
+		"valid": {
+			window: &cleanerv1alpha1.DeletionWindow{Days: []cleanerv1alpha1.Weekday{"Saturday"}, Start: "22:00", End: "06:00", TimeZone: "America/Sao_Paulo"},
+		},
+		"invalid time zone": {
+			window:  &cleanerv1alpha1.DeletionWindow{Start: "22:00", End: "06:00", TimeZone: "Mars/Olympus_Mons"},
+			wantErr: true,
+		},
+		"invalid time": {
+			window:  &cleanerv1alpha1.DeletionWindow{Start: "10pm", End: "06:00"},
+			wantErr: true,
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.DeletionWindow = tc.window
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("got err=%v, wantErr=%v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateConditionReferences(t *testing.T) {
+	testCases := map[string]struct {
+		conditions      []string
+		namedConditions []cleanerv1alpha1.NamedCondition
+		wantMessage     string
+	}{
+		"included target": {conditions: []string{`deploy.status.replicas == 0`}},
+		"excluded target": {
+			conditions:  []string{`true`, `size(pods.items) == 0`},
+			wantMessage: `spec.conditions[1]: Invalid value: "size(pods.items) == 0": condition 1 references target "pods" which is not included when evaluating`,
+		},
+		"unknown identifier": {
+			conditions:  []string{`deploy.spec.replicas == replicas`},
+			wantMessage: `condition 0 references unknown identifier "replicas"`,
+		},
+		"named condition": {
+			conditions:      []string{`true`},
+			namedConditions: []cleanerv1alpha1.NamedCondition{{Name: "noPods", Expression: `size(pods.items) == 0`}},
+			wantMessage:     `spec.namedConditions[0].expression: Invalid value: "size(pods.items) == 0": condition noPods references target "pods" which is not included when evaluating`,
+		},
+	}
+
+	v := &ConditionalTTLValidator{}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{
+				{Name: "deploy", IncludeWhenEvaluating: ptr.To(true)},
+				{Name: "pods"},
+			}
+			cTTL.Spec.Conditions = tc.conditions
+			cTTL.Spec.NamedConditions = tc.namedConditions
+			_, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_validateHelmReleases(t *testing.T) {
+	testCases := map[string]struct {
+		helm        *cleanerv1alpha1.HelmConfig
+		releases    []cleanerv1alpha1.HelmConfig
+		allow       bool
+		allowed     []string
+		wantMessage string
+		wantWarning string
+	}{
+		"same namespace": {helm: &cleanerv1alpha1.HelmConfig{Release: "app", Delete: true}},
+		"same namespace with allowed namespaces": {
+			helm:    &cleanerv1alpha1.HelmConfig{Release: "app", Delete: true},
+			allowed: []string{"tooling", "staging"},
+		},
+		"other namespace allowed": {
+			helm:  &cleanerv1alpha1.HelmConfig{Release: "app", Namespace: "workload", Delete: true},
+			allow: true,
+		},
+		"other namespace not allowed": {
+			helm:        &cleanerv1alpha1.HelmConfig{Release: "app", Namespace: "workload", Delete: true},
+			wantWarning: `spec.helm.namespace: release "app" in namespace "workload" won't be uninstalled unless the controller runs with --allow-cross-namespace-helm`,
+		},
+		"other namespace listed": {
+			helm:    &cleanerv1alpha1.HelmConfig{Release: "app", Namespace: "workload", Delete: true},
+			allow:   true,
+			allowed: []string{"tooling", "workload"},
+		},
+		"other namespace not listed": {
+			releases:    []cleanerv1alpha1.HelmConfig{{Release: "db"}, {Release: "app", Namespace: "workload", Delete: true}},
+			allow:       true,
+			allowed:     []string{"tooling", "staging"},
+			wantMessage: `spec.helmReleases[1].namespace: Invalid value: "workload": must be the ConditionalTTL's namespace or one of allowedNamespaces`,
+		},
+		"other namespace kept": {
+			helm: &cleanerv1alpha1.HelmConfig{Release: "app", Namespace: "workload"},
+		},
+		"invalid namespace": {
+			releases:    []cleanerv1alpha1.HelmConfig{{Release: "db"}, {Release: "app", Namespace: "Workload"}},
+			allow:       true,
+			wantMessage: `spec.helmReleases[1].namespace: Invalid value: "Workload"`,
+		},
+		"missing release": {
+			releases:    []cleanerv1alpha1.HelmConfig{{Delete: true}},
+			wantMessage: "spec.helmReleases[0].release: Required value",
+		},
+		"from target": {
+			helm: &cleanerv1alpha1.HelmConfig{FromTarget: "app", Delete: true},
+		},
+		"from unknown target": {
+			helm:        &cleanerv1alpha1.HelmConfig{FromTarget: "db", Delete: true},
+			wantMessage: `spec.helm.fromTarget: Not found: "db"`,
+		},
+		"release and from target": {
+			helm:        &cleanerv1alpha1.HelmConfig{Release: "app", FromTarget: "app", Delete: true},
+			wantMessage: "release and fromTarget are mutually exclusive",
+		},
+		"dry run": {
+			helm: &cleanerv1alpha1.HelmConfig{Release: "app", Delete: true, DryRun: true},
+		},
+		"dry run without delete": {
+			helm:        &cleanerv1alpha1.HelmConfig{Release: "app", DryRun: true},
+			wantMessage: "spec.helm.dryRun: Invalid value: true: dryRun requires delete",
+		},
+		"included when evaluating": {
+			helm: &cleanerv1alpha1.HelmConfig{Release: "app", IncludeWhenEvaluating: true},
+		},
+		"from target included when evaluating": {
+			helm:        &cleanerv1alpha1.HelmConfig{FromTarget: "app", IncludeWhenEvaluating: true},
+			wantMessage: "spec.helm.includeWhenEvaluating: Invalid value: true: release must be set",
+		},
+		"release list included when evaluating": {
+			releases:    []cleanerv1alpha1.HelmConfig{{Release: "app", IncludeWhenEvaluating: true}},
+			wantMessage: "spec.helmReleases[0].includeWhenEvaluating: Invalid value: true: only spec.helm",
+		},
+		"completed when not found": {
+			helm: &cleanerv1alpha1.HelmConfig{Release: "app", IncludeWhenEvaluating: true, CompleteWhenNotFound: true},
+		},
+		"completed when not found without including": {
+			helm:        &cleanerv1alpha1.HelmConfig{Release: "app", CompleteWhenNotFound: true},
+			wantMessage: "spec.helm.completeWhenNotFound: Invalid value: true: requires includeWhenEvaluating",
+		},
+		"completed when not found and optional": {
+			helm:        &cleanerv1alpha1.HelmConfig{Release: "app", IncludeWhenEvaluating: true, OptionalWhenEvaluating: true, CompleteWhenNotFound: true},
+			wantMessage: "spec.helm.completeWhenNotFound: Invalid value: true: can't be combined with optionalWhenEvaluating",
+		},
+		"release list completed when not found": {
+			releases:    []cleanerv1alpha1.HelmConfig{{Release: "app", CompleteWhenNotFound: true}},
+			wantMessage: "spec.helmReleases[0].completeWhenNotFound: Invalid value: true: only spec.helm",
+		},
+	}
+
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			cTTL := &cleanerv1alpha1.ConditionalTTL{}
+			cTTL.SetName("test")
+			cTTL.SetNamespace("tooling")
+			cTTL.Spec.Targets = []cleanerv1alpha1.Target{{Name: "app"}}
+			cTTL.Spec.Helm = tc.helm
+			cTTL.Spec.HelmReleases = tc.releases
+			cTTL.Spec.AllowedNamespaces = tc.allowed
+			v := &ConditionalTTLValidator{AllowCrossNamespaceHelm: tc.allow}
+			warnings, err := v.ValidateCreate(context.Background(), cTTL)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+			if tc.wantWarning == "" && len(warnings) > 0 || tc.wantWarning != "" && (len(warnings) != 1 || warnings[0] != tc.wantWarning) {
+				t.Errorf("got warnings %q, want %q", warnings, tc.wantWarning)
+			}
+		})
+	}
+}
+
+func Test_validateTargetKinds(t *testing.T) {
+	testCases := map[string]struct {
+		apiVersion  string
+		kind        string
+		wantMessage string
+	}{
+		"served kind":      {apiVersion: "apps/v1", kind: "Deployment"},
+		"served list kind": {apiVersion: "v1", kind: "PodList"},
+		"kind only":        {kind: "Deployment"},
+		"misspelled kind only": {
+			kind:        "Deploymnet",
+			wantMessage: `spec.targets[0].reference.kind: Invalid value: "Deploymnet": kind "Deploymnet" is not served, did you mean "Deployment"?`,
+		},
+		"misspelled kind": {
+			apiVersion:  "apps/v1",
+			kind:        "Deploymnet",
+			wantMessage: `spec.targets[0].reference.kind: Invalid value: "Deploymnet": kind "Deploymnet" is not served by apps/v1, did you mean "Deployment"?`,
+		},
+		"misspelled apiVersion": {
+			apiVersion:  "app/v1",
+			kind:        "Deployment",
+			wantMessage: `spec.targets[0].reference.apiVersion: Invalid value: "app/v1": apiVersion "app/v1" is not served, did you mean "apps/v1"?`,
+		},
+	}
+
+	disc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	disc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disc))
+	checker := kinds.NewChecker(mapper, disc)
+	clock := testingclock.NewFakeClock(time.Now())
+	checker.Clock = clock
+	v := &ConditionalTTLValidator{Kinds: checker}
+	validate := func(apiVersion, kind string) error {
+		cTTL := &cleanerv1alpha1.ConditionalTTL{}
+		cTTL.SetName("test")
+		target := cleanerv1alpha1.Target{Name: "target"}
+		target.Reference.APIVersion = apiVersion
+		target.Reference.Kind = kind
+		cTTL.Spec.Targets = []cleanerv1alpha1.Target{target}
+		_, err := v.ValidateCreate(context.Background(), cTTL)
+		return err
+	}
+	for description, tc := range testCases {
+		t.Run(description, func(t *testing.T) {
+			// lets the checker refresh its mapping for each case
+			clock.Step(kinds.MinResetInterval)
+			err := validate(tc.apiVersion, tc.kind)
+			if (tc.wantMessage != "") != (err != nil) {
+				t.Fatalf("got err=%v, want %q", err, tc.wantMessage)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantMessage) {
+				t.Errorf("got err=%v, want it to contain %q", err, tc.wantMessage)
+			}
+		})
+	}
+
+	t.Run("mapping refreshed too recently", func(t *testing.T) {
+		clock.Step(kinds.MinResetInterval)
+		if err := validate("apps/v1", "Deploymnet"); err == nil {
+			t.Fatal("expected the misspelled kind to be rejected")
+		}
+		// the kind may have been installed since, left to the controller
+		if err := validate("apps/v1", "StatefulSet"); err != nil {
+			t.Errorf("got err=%v, want the kind to be left unchecked", err)
+		}
+	})
+}